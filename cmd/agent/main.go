@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/agent"
+	"github.com/fntkg/container-orchestrator/pkg/client"
+	"github.com/fntkg/container-orchestrator/pkg/labels"
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+)
+
+func main() {
+	// Node IDs are lowercase host names.
+	hostname, _ := os.Hostname()
+	hostname = strings.ToLower(hostname)
+	server := flag.String("server", "http://localhost:8080", "URL of the API server")
+	nodeID := flag.String("node-id", hostname, "ID this node registers under")
+	cpu := flag.String("cpu", "1", "CPU capacity advertised by the node")
+	memory := flag.String("memory", "1Gi", "memory capacity advertised by the node")
+	heartbeatInterval := flag.Duration("heartbeat-interval", agent.DefaultHeartbeatInterval, "how often the node lease is renewed")
+	dataDir := flag.String("data-dir", "agent-data", "directory holding the logs of the tasks run by the agent")
+	cgroupRoot := flag.String("cgroup-root", "", "cgroup v2 directory under which each task gets a cgroup enforcing its CPU and memory limits, such as /sys/fs/cgroup/orchestrator; limits are not enforced when empty")
+	listen := flag.String("listen", ":10250", "address the agent serves task logs on")
+	advertiseAddress := flag.String("advertise-address", "", "URL the API server reaches this agent at; defaults to http://<node-id> on the -listen port")
+	logMaxSize := flag.Int64("log-max-size", logs.DefaultMaxSize, "size in bytes at which a task's log file is rotated")
+	logMaxFiles := flag.Int("log-max-files", logs.DefaultMaxFiles, "number of rotated log files kept per task")
+	taintList := flag.String("taints", "", "comma-separated taints the node registers with, such as dedicated=team-a:NoSchedule")
+	labelList := flag.String("labels", "", "comma-separated labels the node registers with, such as zone=a,disk=ssd; without any, the node keeps those it has")
+	flag.Parse()
+	if *nodeID == "" {
+		log.Fatalf("-node-id is required")
+	}
+
+	capacity := resource.List{}
+	for name, raw := range map[resource.Name]string{resource.CPU: *cpu, resource.Memory: *memory} {
+		q, err := resource.ParseQuantity(raw)
+		if err != nil {
+			log.Fatalf("Invalid %s capacity: %v", name, err)
+		}
+		capacity[name] = q
+	}
+
+	var taints []models.Taint
+	for _, raw := range strings.Split(*taintList, ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		taint, err := models.ParseTaint(raw)
+		if err != nil {
+			log.Fatalf("Invalid -taints: %v", err)
+		}
+		taints = append(taints, taint)
+	}
+
+	var nodeLabels map[string]string
+	for _, raw := range strings.Split(*labelList, ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(raw, "=")
+		if !ok {
+			log.Fatalf("Invalid -labels: %q is not key=value", raw)
+		}
+		if err := labels.ValidateKey(key); err != nil {
+			log.Fatalf("Invalid -labels: %v", err)
+		}
+		if err := labels.ValidateValue(value); err != nil {
+			log.Fatalf("Invalid -labels: %v", err)
+		}
+		if nodeLabels == nil {
+			nodeLabels = make(map[string]string)
+		}
+		nodeLabels[key] = value
+	}
+
+	address := *advertiseAddress
+	if address == "" {
+		_, port, err := net.SplitHostPort(*listen)
+		if err != nil {
+			log.Fatalf("Invalid -listen address %q: %v", *listen, err)
+		}
+		address = "http://" + net.JoinHostPort(*nodeID, port)
+	}
+
+	opts := []runtime.ProcessOption{runtime.WithLogRotation(*logMaxSize, *logMaxFiles)}
+	if *cgroupRoot != "" {
+		opts = append(opts, runtime.WithCgroupRoot(*cgroupRoot))
+	}
+	rt, err := runtime.NewProcessRuntime(*dataDir, opts...)
+	if err != nil {
+		log.Fatalf("Failed to set up the process runtime in %s: %v", *dataDir, err)
+	}
+	a := agent.New(client.New(*server), models.Node{ID: *nodeID, Address: address, Capacity: capacity, Labels: nodeLabels, Taints: taints}, rt)
+	a.HeartbeatInterval = *heartbeatInterval
+
+	srv := &http.Server{Addr: *listen, Handler: a.Handler()}
+	go func() {
+		log.Printf("Serving task logs on %s, advertised as %s", *listen, address)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Log server failed: %v", err)
+		}
+	}()
+
+	stopCh := make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Println("Shutting down gracefully...")
+		close(stopCh)
+	}()
+
+	if err := a.Run(stopCh); err != nil {
+		log.Fatalf("Agent failed: %v", err)
+	}
+	// Run stopped every task, which ends the log streams that followed them.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Log server shutdown: %v", err)
+	}
+}
@@ -1,58 +1,149 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/fntkg/container-orchestrator/pkg/api"
 	"github.com/fntkg/container-orchestrator/pkg/controller"
 	"github.com/fntkg/container-orchestrator/pkg/datastore"
 	"github.com/fntkg/container-orchestrator/pkg/models"
 	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
 	"github.com/fntkg/container-orchestrator/pkg/scheduler"
 	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
 )
 
 func main() {
-	// Initialize the in-memory datastore.
-	ds := datastore.NewInMemoryDatastore()
+	strategyName := flag.String("scheduler-strategy", scheduler.LeastAllocated.String(), "node scoring strategy: least-allocated or most-allocated")
+	datastoreKind := flag.String("datastore", "memory", "datastore implementation: memory or file")
+	dataDir := flag.String("data-dir", "data", "directory holding the write-ahead log and snapshots of the file datastore")
+	snapshotEvery := flag.Int("snapshot-every", datastore.DefaultSnapshotEvery, "number of logged writes after which the file datastore compacts into a snapshot")
+	nodeGracePeriod := flag.Duration("node-grace-period", controller.DefaultNodeGracePeriod, "time without a heartbeat after which a node is marked NotReady")
+	nodeUnknownPeriod := flag.Duration("node-unknown-period", controller.DefaultNodeUnknownPeriod, "time without a heartbeat after which a node is marked Unknown")
+	evictionTimeout := flag.Duration("eviction-timeout", controller.DefaultEvictionTimeout, "time a node may stay unhealthy before its tasks are evicted and rescheduled")
+	evictionRate := flag.Float64("eviction-rate", controller.DefaultEvictionRate, "tasks evicted per second while most nodes are healthy")
+	flag.Parse()
+	if *nodeUnknownPeriod <= *nodeGracePeriod {
+		log.Fatalf("-node-unknown-period must be longer than -node-grace-period")
+	}
+	strategy, err := scheduler.ParseScoringStrategy(*strategyName)
+	if err != nil {
+		log.Fatalf("Invalid -scheduler-strategy: %v", err)
+	}
+
+	// Initialize the datastore.
+	var ds datastore.Datastore
+	switch *datastoreKind {
+	case "memory":
+		ds = datastore.NewInMemoryDatastore()
+	case "file":
+		fds, err := datastore.NewFileDatastore(*dataDir, *snapshotEvery)
+		if err != nil {
+			log.Fatalf("Failed to open datastore in %s: %v", *dataDir, err)
+		}
+		defer func() {
+			if err := fds.Close(); err != nil {
+				log.Printf("Failed to close datastore: %v", err)
+			}
+		}()
+		ds = fds
+	default:
+		log.Fatalf("Invalid -datastore %q: must be memory or file", *datastoreKind)
+	}
+	existingNodes, err := ds.GetNodes()
+	if err != nil {
+		log.Fatalf("Failed to read datastore: %v", err)
+	}
+	// Only seed the demo nodes and tasks into an empty datastore, so that a
+	// restart with a file datastore keeps the state it recovered.
+	seed := len(existingNodes) == 0
 
 	// Create the Node DefaultNodeManager using the datastore.
 	nm := node.NewManager(ds)
-	if err := nm.Register(models.Node{ID: "node-1", Healthy: true}); err != nil {
-		log.Fatalf("Failed to register node-1: %v", err)
+	nodeCapacity := resource.List{
+		resource.CPU:    resource.MustParse("4"),
+		resource.Memory: resource.MustParse("8Gi"),
 	}
-	if err := nm.Register(models.Node{ID: "node-2", Healthy: true}); err != nil {
-		log.Fatalf("Failed to register node-2: %v", err)
+	if seed {
+		if err := nm.Register(models.Node{ID: "node-1", Healthy: true, Capacity: nodeCapacity}); err != nil {
+			log.Fatalf("Failed to register node-1: %v", err)
+		}
+		if err := nm.Register(models.Node{ID: "node-2", Healthy: true, Capacity: nodeCapacity}); err != nil {
+			log.Fatalf("Failed to register node-2: %v", err)
+		}
 	}
 
 	// Create the Task DefaultNodeManager using the datastore.
 	tm := taskmanager.NewTaskManager(ds)
 	// Optionally, create some initial tasks.
-	if err := tm.CreateTask(models.Task{ID: "task-1", Status: "pending"}); err != nil {
-		log.Fatalf("Failed to create task-1: %v", err)
-	}
-	if err := tm.CreateTask(models.Task{ID: "task-2", Status: "pending"}); err != nil {
-		log.Fatalf("Failed to create task-2: %v", err)
+	if seed {
+		if err := tm.CreateTask(models.Task{ID: "task-1", Status: models.TaskPending}); err != nil {
+			log.Fatalf("Failed to create task-1: %v", err)
+		}
+		if err := tm.CreateTask(models.Task{ID: "task-2", Status: models.TaskPending}); err != nil {
+			log.Fatalf("Failed to create task-2: %v", err)
+		}
 	}
 
-	// Initialize the scheduler.
-	sched := scheduler.NewDefaultScheduler()
+	// Initialize the scheduler, which accounts for tasks already bound in the datastore.
+	sched := scheduler.NewResourceFitScheduler(ds, strategy)
 
 	// Create the Controller DefaultNodeManager with the scheduler, Task DefaultNodeManager, and Node DefaultNodeManager.
 	ctrlManager := controller.NewControllerManager(sched, tm, nm)
 	stopCh := make(chan struct{})
 	go ctrlManager.Run(stopCh)
 
-	// Create the API router with the Node DefaultNodeManager and datastore.
-	apiInstance := api.NewAPI(nm, tm)
+	// Keep the tasks of every replica set at its replica count.
+	rsm := workload.NewReplicaSetManager(ds)
+	replicaSetController := controller.NewReplicaSetController(rsm, tm)
+	go replicaSetController.Run(stopCh)
+
+	// Roll out deployments through their replica sets.
+	dm := workload.NewDeploymentManager(ds)
+	deploymentController := controller.NewDeploymentController(dm, rsm)
+	go deploymentController.Run(stopCh)
+
+	// Run the task of every daemon set on each of its nodes.
+	dsm := workload.NewDaemonSetManager(ds)
+	daemonSetController := controller.NewDaemonSetController(dsm, tm, nm)
+	go daemonSetController.Run(stopCh)
+
+	// Run jobs to completion.
+	jm := workload.NewJobManager(ds)
+	jobController := controller.NewJobController(jm, tm)
+	go jobController.Run(stopCh)
+
+	// Start the jobs of cron jobs on schedule.
+	cjm := workload.NewCronJobManager(ds)
+	cronJobController := controller.NewCronJobController(cjm, jm)
+	go cronJobController.Run(stopCh)
+
+	// Mark nodes that stop sending heartbeats as NotReady, then Unknown, and
+	// evict the tasks of nodes that stay unhealthy.
+	nodeLifecycle := controller.NewNodeLifecycleController(nm, tm, *nodeGracePeriod, *nodeUnknownPeriod)
+	nodeLifecycle.EvictionTimeout = *evictionTimeout
+	nodeLifecycle.EvictionRate = *evictionRate
+	go nodeLifecycle.Run(stopCh)
+
+	// Create the API router with the Node DefaultNodeManager and Task DefaultNodeManager.
+	apiInstance := api.NewAPI(nm, tm, api.WithReplicaSetManager(rsm), api.WithDeploymentManager(dm), api.WithDaemonSetManager(dsm), api.WithJobManager(jm), api.WithCronJobManager(cjm))
 	apiPort := ":8080"
+	srv := &http.Server{Addr: apiPort, Handler: apiInstance.Router()}
+	// Shutdown waits for in-flight requests, so open watch streams must be
+	// told to finish.
+	srv.RegisterOnShutdown(apiInstance.Close)
 	go func() {
 		log.Printf("Starting API server on port %s", apiPort)
-		if err := http.ListenAndServe(apiPort, apiInstance.Router()); err != nil {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("API server failed: %v", err)
 		}
 	}()
@@ -62,5 +153,10 @@ func main() {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
 	log.Println("Shutting down gracefully...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("API server shutdown: %v", err)
+	}
 	close(stopCh)
 }
@@ -0,0 +1,514 @@
+// Package agent implements the node agent: the process that runs on each
+// node, registers it with the API server, keeps its lease alive and runs the
+// tasks bound to it.
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/backoff"
+	"github.com/fntkg/container-orchestrator/pkg/client"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+)
+
+const (
+	// DefaultHeartbeatInterval is how often the agent renews its node lease.
+	// It must be well below the API server's node grace period.
+	DefaultHeartbeatInterval = 10 * time.Second
+	// DefaultResyncPeriod is how often the agent lists its tasks again, as a
+	// safety net for changes missed while the watch was being reopened.
+	DefaultResyncPeriod = 30 * time.Second
+	// DefaultStopTimeout is how long a task that is being stopped may take
+	// to exit before it is killed.
+	DefaultStopTimeout = 10 * time.Second
+	// retryDelay is how long the agent waits before reopening a failed watch.
+	retryDelay = time.Second
+	// backoffResetAfter is how long a task must run before exiting for its
+	// restart delay to start over from RestartBackoff.Initial.
+	backoffResetAfter = 10 * time.Minute
+	// maxReportAttempts bounds how often a status report is retried when the
+	// task changes under it.
+	maxReportAttempts = 5
+)
+
+// ReasonLost is the Reason of a task that was running on a node whose agent
+// restarted and no longer knows about it.
+const ReasonLost = "Lost"
+
+// ReasonError is the Reason of a task whose process exited unsuccessfully
+// or could not be started.
+const ReasonError = "Error"
+
+// ReasonCompleted is the LastTerminationReason of a task whose process
+// exited successfully.
+const ReasonCompleted = "Completed"
+
+// ReasonOOMKilled is the Reason of a task killed for exceeding its memory
+// limit.
+const ReasonOOMKilled = "OOMKilled"
+
+// ReasonLivenessProbeFailed is the Reason of a task stopped because its
+// liveness probe failed.
+const ReasonLivenessProbeFailed = "LivenessProbeFailed"
+
+// Reasons of a task's Ready condition when it is false.
+const (
+	// ReasonStarting: the task has not passed its readiness probe yet.
+	ReasonStarting = "Starting"
+	// ReasonReadinessProbeFailed: the readiness probe stopped passing.
+	ReasonReadinessProbeFailed = "ReadinessProbeFailed"
+	// ReasonTerminated: the task's process has ended.
+	ReasonTerminated = "Terminated"
+)
+
+// Agent runs the tasks bound to one node.
+type Agent struct {
+	client  *client.Client
+	node    models.Node
+	runtime runtime.Runtime
+
+	// HeartbeatInterval, ResyncPeriod, StopTimeout and RestartBackoff
+	// override their defaults when set before Run.
+	HeartbeatInterval time.Duration
+	ResyncPeriod      time.Duration
+	StopTimeout       time.Duration
+	RestartBackoff    backoff.Backoff
+
+	mu sync.Mutex
+	// running holds a cancel function for every task being run.
+	running map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New creates an Agent that registers n with the API server behind c and
+// runs its tasks with rt.
+func New(c *client.Client, n models.Node, rt runtime.Runtime) *Agent {
+	return &Agent{
+		client:            c,
+		node:              n,
+		runtime:           rt,
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		ResyncPeriod:      DefaultResyncPeriod,
+		StopTimeout:       DefaultStopTimeout,
+		RestartBackoff:    backoff.DefaultRestart,
+		running:           make(map[string]context.CancelFunc),
+	}
+}
+
+// Run registers the node and then runs its tasks until stopCh is closed.
+// Tasks still running at that point are stopped.
+func (a *Agent) Run(stopCh <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+	log.Printf("Agent: registered node %s", a.node.ID)
+
+	var loops sync.WaitGroup
+	loops.Add(2)
+	go func() {
+		defer loops.Done()
+		a.heartbeatLoop(ctx)
+	}()
+	go func() {
+		defer loops.Done()
+		a.watchLoop(ctx)
+	}()
+	loops.Wait()
+
+	a.mu.Lock()
+	for _, stop := range a.running {
+		stop()
+	}
+	a.mu.Unlock()
+	a.wg.Wait()
+	log.Printf("Agent: node %s stopped", a.node.ID)
+	return nil
+}
+
+// register registers the node and sends a first heartbeat so that it is
+// subject to lease expiry from the start.
+func (a *Agent) register(ctx context.Context) error {
+	n := a.node
+	n.Healthy = true
+	n.Condition = models.NodeReady
+	if _, err := a.client.RegisterNode(ctx, n); err != nil {
+		return fmt.Errorf("registering node %s: %w", n.ID, err)
+	}
+	if _, err := a.client.Heartbeat(ctx, n.ID); err != nil {
+		return fmt.Errorf("sending first heartbeat for node %s: %w", n.ID, err)
+	}
+	return nil
+}
+
+// heartbeatLoop renews the node lease until ctx is done. A node that has
+// disappeared from the API server is registered again.
+func (a *Agent) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(a.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, err := a.client.Heartbeat(ctx, a.node.ID)
+			if errors.Is(err, client.ErrNotFound) {
+				err = a.register(ctx)
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Agent: heartbeat for node %s failed: %v", a.node.ID, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchLoop keeps the local tasks in sync with the API server: it lists the
+// node's tasks, then follows changes to them, and lists again whenever the
+// watch breaks or the resync period elapses. A watch whose start has
+// already left the server's history is listed again right away.
+func (a *Agent) watchLoop(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := a.listAndWatch(ctx)
+		if errors.Is(err, client.ErrGone) {
+			continue
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Agent: watching tasks of node %s: %v", a.node.ID, err)
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// listAndWatch syncs every task bound to the node and then every change to
+// them until the resync period elapses or the watch fails.
+func (a *Agent) listAndWatch(ctx context.Context) error {
+	tasks, from, err := a.client.ListTasks(ctx, a.node.ID)
+	if err != nil {
+		return err
+	}
+	// The list reflects every change up to from, so watching from there
+	// misses nothing that it did not already show.
+	for _, t := range tasks {
+		a.sync(ctx, t)
+	}
+
+	watchCtx, cancel := context.WithTimeout(ctx, a.ResyncPeriod)
+	defer cancel()
+	w, err := a.client.WatchTasks(watchCtx, a.node.ID, from)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for {
+		ev, err := w.Next()
+		if err != nil {
+			if watchCtx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var t models.Task
+		if err := ev.Decode(&t); err != nil {
+			return fmt.Errorf("decoding task %s: %w", ev.ID, err)
+		}
+		a.sync(ctx, t)
+	}
+}
+
+// sync reacts to the latest known state of a task bound to this node.
+func (a *Agent) sync(ctx context.Context, t models.Task) {
+	a.mu.Lock()
+	stop, running := a.running[t.ID]
+	a.mu.Unlock()
+
+	switch {
+	case running && (t.Status.IsTerminal() || t.NodeID != a.node.ID):
+		// The task was cancelled, evicted or moved elsewhere.
+		log.Printf("Agent: stopping task %s, now %s", t.ID, t.Status)
+		stop()
+	case !running && t.NodeID == a.node.ID && t.Status == models.TaskScheduled:
+		a.start(ctx, t)
+	case !running && t.NodeID == a.node.ID && t.Status == models.TaskRunning:
+		// Nothing here is running it, most likely because the agent
+		// restarted. Its process is gone.
+		t.Status = models.TaskFailed
+		t.Reason = ReasonLost
+		t.Message = "The node agent restarted while the task was running"
+		if _, err := a.client.UpdateTaskStatus(ctx, t); err != nil && !errors.Is(err, client.ErrConflict) {
+			log.Printf("Agent: marking task %s lost: %v", t.ID, err)
+		}
+	}
+}
+
+// start marks a scheduled task running and runs it in the background. The
+// update is conditional on the version seen, so a task that changed in the
+// meantime is left for the event carrying that change.
+func (a *Agent) start(ctx context.Context, t models.Task) {
+	t.Status = models.TaskRunning
+	t.SetCondition(models.TaskCondition{Type: models.TaskReady, Status: models.ConditionFalse, Reason: ReasonStarting}, time.Now())
+	updated, err := a.client.UpdateTaskStatus(ctx, t)
+	if err != nil {
+		if !errors.Is(err, client.ErrConflict) {
+			log.Printf("Agent: starting task %s: %v", t.ID, err)
+		}
+		return
+	}
+
+	taskCtx, cancel := context.WithCancel(ctx)
+	a.mu.Lock()
+	a.running[t.ID] = cancel
+	a.mu.Unlock()
+	log.Printf("Agent: running task %s", t.ID)
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer func() {
+			a.mu.Lock()
+			delete(a.running, t.ID)
+			a.mu.Unlock()
+			cancel()
+		}()
+
+		a.run(ctx, taskCtx, updated)
+	}()
+}
+
+// run runs a task until it ends for good, restarting it in between as its
+// restart policy asks. Restarts are delayed by RestartBackoff, which grows
+// with every restart unless the task ran for a while before exiting. Once
+// the final status is reported, or the task is stopped, its container is
+// removed; its logs stay readable.
+func (a *Agent) run(ctx, taskCtx context.Context, t *models.Task) {
+	id := t.ID
+	defer a.remove(id)
+	attempt := 0
+	for {
+		started := time.Now()
+		result := a.execute(taskCtx, *t)
+		if taskCtx.Err() != nil {
+			// Stopped on purpose; whoever stopped it owns the status.
+			return
+		}
+		if t = a.report(ctx, t.ID, result); t == nil {
+			return
+		}
+
+		if time.Since(started) >= backoffResetAfter {
+			attempt = 0
+		}
+		delay := a.RestartBackoff.Delay(attempt)
+		attempt++
+		log.Printf("Agent: restarting task %s in %v after %s (restart %d)", t.ID, delay.Round(time.Millisecond), t.LastTerminationReason, t.RestartCount)
+		select {
+		case <-time.After(delay):
+		case <-taskCtx.Done():
+			return
+		}
+	}
+}
+
+// remove deletes the container of a task that has ended.
+func (a *Agent) remove(id string) {
+	if err := a.runtime.Remove(id); err != nil && !errors.Is(err, runtime.ErrNotFound) {
+		log.Printf("Agent: removing container of task %s: %v", id, err)
+	}
+}
+
+// runResult is how one run of a task ended.
+type runResult struct {
+	status runtime.Status
+	// err is set when the container could not be run at all.
+	err error
+	// unhealthy holds the last error of the liveness probe when it failed
+	// and the container was stopped because of it.
+	unhealthy string
+}
+
+// execute runs a container for t until it exits or ctx is done, in which
+// case it is stopped. While the container runs, its probes are checked. A
+// container left over from an earlier run of the same task is replaced.
+func (a *Agent) execute(ctx context.Context, t models.Task) runResult {
+	spec := runtime.SpecFromTask(t)
+	err := a.runtime.Create(spec)
+	if errors.Is(err, runtime.ErrAlreadyExists) {
+		if err = a.runtime.Remove(spec.ID); err == nil {
+			err = a.runtime.Create(spec)
+		}
+	}
+	if err != nil {
+		return runResult{err: err}
+	}
+	if err := a.runtime.Start(spec.ID); err != nil {
+		return runResult{err: err}
+	}
+
+	var result runResult
+	probeCtx, stopProbes := context.WithCancel(ctx)
+	probes := a.startProbes(probeCtx, t, spec, func(message string) {
+		// Only the probe goroutine writes this, and only before the
+		// container has exited and the probes have been waited for.
+		result.unhealthy = message
+		log.Printf("Agent: liveness probe of task %s failed, stopping it: %s", t.ID, message)
+		if err := a.runtime.Stop(spec.ID, a.StopTimeout); err != nil {
+			log.Printf("Agent: stopping task %s: %v", t.ID, err)
+		}
+	})
+	result.status, result.err = a.runtime.Wait(ctx, spec.ID)
+	stopProbes()
+	probes.Wait()
+
+	if ctx.Err() != nil {
+		if err := a.runtime.Stop(spec.ID, a.StopTimeout); err != nil {
+			log.Printf("Agent: stopping task %s: %v", spec.ID, err)
+		}
+		result.status, result.err = a.runtime.Status(spec.ID)
+	}
+	return result
+}
+
+// startProbes runs the probes of a task whose container has just started,
+// until ctx is done. Readiness is written to the task's Ready condition, and
+// onLivenessFailure is called when the liveness probe fails. The returned
+// WaitGroup is done once every probe has stopped.
+func (a *Agent) startProbes(ctx context.Context, t models.Task, spec runtime.Spec, onLivenessFailure func(message string)) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	if t.ReadinessProbe == nil {
+		a.setReady(ctx, t.ID, models.ConditionTrue, "", "")
+	} else {
+		a.setReady(ctx, t.ID, models.ConditionFalse, ReasonStarting, "")
+		w := newProbeWorker(*t.ReadinessProbe, spec, false, func(passing bool, message string) {
+			if passing {
+				a.setReady(ctx, t.ID, models.ConditionTrue, "", "")
+			} else {
+				a.setReady(ctx, t.ID, models.ConditionFalse, ReasonReadinessProbeFailed, message)
+			}
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	if t.LivenessProbe != nil {
+		w := newProbeWorker(*t.LivenessProbe, spec, true, func(passing bool, message string) {
+			if !passing {
+				onLivenessFailure(message)
+			}
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	return &wg
+}
+
+// setReady sets the Ready condition of a task that is running here.
+func (a *Agent) setReady(ctx context.Context, taskID string, status models.ConditionStatus, reason, message string) {
+	for attempt := 0; attempt < maxReportAttempts; attempt++ {
+		t, err := a.client.GetTask(ctx, taskID)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Agent: updating readiness of task %s: %v", taskID, err)
+			}
+			return
+		}
+		if t.Status != models.TaskRunning || t.NodeID != a.node.ID {
+			return
+		}
+		if c := t.Condition(models.TaskReady); c != nil && c.Status == status && c.Reason == reason && c.Message == message {
+			return
+		}
+		t.SetCondition(models.TaskCondition{Type: models.TaskReady, Status: status, Reason: reason, Message: message}, time.Now())
+		_, err = a.client.UpdateTaskStatus(ctx, *t)
+		if err == nil || !errors.Is(err, client.ErrConflict) {
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Agent: updating readiness of task %s: %v", taskID, err)
+			}
+			return
+		}
+	}
+}
+
+// report records how a run of a task ended. When the restart policy asks for
+// the task to run again it stays running, with its restart counted, and is
+// returned; otherwise it moves to its final phase and report returns nil.
+// Updates are retried when the task is modified concurrently, and given up
+// once the task is no longer running here.
+func (a *Agent) report(ctx context.Context, taskID string, result runResult) *models.Task {
+	reason, message, failed := termination(result)
+	for attempt := 0; attempt < maxReportAttempts; attempt++ {
+		t, err := a.client.GetTask(ctx, taskID)
+		if err != nil {
+			log.Printf("Agent: reporting task %s: %v", taskID, err)
+			return nil
+		}
+		if t.Status != models.TaskRunning || t.NodeID != a.node.ID {
+			return nil
+		}
+
+		restart := t.RestartAllowed(failed)
+		t.LastTerminationReason = reason
+		t.Message = message
+		t.SetCondition(models.TaskCondition{Type: models.TaskReady, Status: models.ConditionFalse, Reason: ReasonTerminated}, time.Now())
+		switch {
+		case restart:
+			t.RestartCount++
+			t.ExitCode = nil
+		case failed:
+			t.Status = models.TaskFailed
+			t.Reason = reason
+		default:
+			t.Status = models.TaskSucceeded
+		}
+		if !restart && result.err == nil {
+			t.ExitCode = &result.status.ExitCode
+		}
+		updated, err := a.client.UpdateTaskStatus(ctx, *t)
+		if err == nil {
+			if !restart {
+				log.Printf("Agent: task %s %s", taskID, t.Status)
+				return nil
+			}
+			return updated
+		}
+		if !errors.Is(err, client.ErrConflict) {
+			log.Printf("Agent: reporting task %s: %v", taskID, err)
+			return nil
+		}
+	}
+	return nil
+}
+
+// termination describes how a run ended: its reason, a message for people,
+// and whether it counts as a failure.
+func termination(result runResult) (reason, message string, failed bool) {
+	switch {
+	case result.err != nil:
+		return ReasonError, result.err.Error(), true
+	case result.unhealthy != "":
+		return ReasonLivenessProbeFailed, "Liveness probe failed: " + result.unhealthy, true
+	case result.status.OOMKilled:
+		return ReasonOOMKilled, "Killed for exceeding its memory limit", true
+	case result.status.ExitCode != 0:
+		return ReasonError, fmt.Sprintf("Exited with code %d", result.status.ExitCode), true
+	}
+	return ReasonCompleted, "", false
+}
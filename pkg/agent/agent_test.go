@@ -0,0 +1,375 @@
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/agent"
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/backoff"
+	"github.com/fntkg/container-orchestrator/pkg/client"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+)
+
+// startAgent runs an agent for node-1 against a fresh API server.
+func startAgent(t *testing.T, rt runtime.Runtime) (node.NodeManager, taskmanager.TaskManager) {
+	t.Helper()
+	ds := datastore.NewInMemoryDatastore()
+	nm := node.NewManager(ds)
+	tm := taskmanager.NewTaskManager(ds)
+	apiInstance := api.NewAPI(nm, tm)
+	srv := httptest.NewServer(apiInstance.Router())
+
+	a := agent.New(client.New(srv.URL), models.Node{ID: "node-1"}, rt)
+	a.HeartbeatInterval = 20 * time.Millisecond
+	a.ResyncPeriod = 100 * time.Millisecond
+	a.RestartBackoff = backoff.Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond, Factor: 2}
+	stopCh := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- a.Run(stopCh) }()
+	t.Cleanup(func() {
+		close(stopCh)
+		apiInstance.Close()
+		if err := <-done; err != nil {
+			t.Errorf("agent failed: %v", err)
+		}
+		srv.Close()
+	})
+
+	waitFor(t, "node registration", func() bool {
+		n, err := nm.GetNode("node-1")
+		return err == nil && !n.LastHeartbeatTime.IsZero()
+	})
+	return nm, tm
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// bind creates a task and binds it to node-1, as the controller would.
+func bind(t *testing.T, tm taskmanager.TaskManager, id string) {
+	t.Helper()
+	bindTask(t, tm, models.Task{ID: id, Command: "run-" + id})
+}
+
+func bindTask(t *testing.T, tm taskmanager.TaskManager, task models.Task) {
+	t.Helper()
+	if err := tm.CreateTask(task); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	created, _ := tm.GetTask(task.ID)
+	created.Status = models.TaskScheduled
+	created.NodeID = "node-1"
+	if err := tm.UpdateTask(*created); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+}
+
+func phaseOf(tm taskmanager.TaskManager, id string) models.TaskPhase {
+	task, err := tm.GetTask(id)
+	if err != nil {
+		return ""
+	}
+	return task.Status
+}
+
+// isRemoved reports whether the agent has removed the container of a task.
+func isRemoved(rt runtime.Runtime, id string) bool {
+	_, err := rt.Status(id)
+	return errors.Is(err, runtime.ErrNotFound)
+}
+
+// isRunning reports whether the agent has started the container of a task.
+// The task turns running before that happens.
+func isRunning(rt runtime.Runtime, id string) bool {
+	status, err := rt.Status(id)
+	return err == nil && status.State == runtime.StateRunning
+}
+
+func TestAgent_RunsBoundTasks(t *testing.T) {
+	rt := runtime.NewFake()
+	rt.FailStart("broken", errors.New("executable not found"))
+	nm, tm := startAgent(t, rt)
+	// Tasks for other nodes are none of this agent's business.
+	if err := tm.CreateTask(models.Task{ID: "elsewhere"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	if err := tm.UpdateTask(models.Task{ID: "elsewhere", Status: models.TaskScheduled, NodeID: "node-2"}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+	for _, id := range []string{"ok", "bad", "broken"} {
+		bind(t, tm, id)
+	}
+
+	waitFor(t, "tasks to start", func() bool {
+		return isRunning(rt, "ok") && isRunning(rt, "bad")
+	})
+	if spec, err := rt.Spec("ok"); err != nil || spec.Command != "run-ok" {
+		t.Errorf("expected the task's command in the spec, got %+v (%v)", spec, err)
+	}
+	if err := rt.Exit("ok", 0, "done\n"); err != nil {
+		t.Fatalf("Exit: %v", err)
+	}
+	if err := rt.Exit("bad", 3, ""); err != nil {
+		t.Fatalf("Exit: %v", err)
+	}
+
+	waitFor(t, "tasks to finish", func() bool {
+		return phaseOf(tm, "ok").IsTerminal() && phaseOf(tm, "bad").IsTerminal() && phaseOf(tm, "broken").IsTerminal()
+	})
+	ok, _ := tm.GetTask("ok")
+	if ok.Status != models.TaskSucceeded || ok.ExitCode == nil || *ok.ExitCode != 0 {
+		t.Errorf("expected ok to succeed with exit code 0, got %+v", ok)
+	}
+	// Every task went through running on its way.
+	if got := ok.Transitions[len(ok.Transitions)-2].To; got != models.TaskRunning {
+		t.Errorf("expected ok to pass through running, got %s", got)
+	}
+	bad, _ := tm.GetTask("bad")
+	if bad.Status != models.TaskFailed || bad.ExitCode == nil || *bad.ExitCode != 3 || bad.Reason != agent.ReasonError {
+		t.Errorf("expected bad to fail with exit code 3, got %+v", bad)
+	}
+	broken, _ := tm.GetTask("broken")
+	if broken.Status != models.TaskFailed || broken.ExitCode != nil || broken.Message != "executable not found" {
+		t.Errorf("expected broken to fail without exit code, got %+v", broken)
+	}
+	// Finished containers are removed, but their logs are kept.
+	waitFor(t, "containers to be removed", func() bool {
+		return isRemoved(rt, "ok") && isRemoved(rt, "bad")
+	})
+	var out strings.Builder
+	if err := rt.Logs(context.Background(), "ok", logs.Options{}, &out); err != nil || out.String() != "done\n" {
+		t.Errorf("expected the logs of ok to be kept, got %q (%v)", out.String(), err)
+	}
+	if phaseOf(tm, "elsewhere") != models.TaskScheduled {
+		t.Errorf("expected task of another node to be left alone, got %s", phaseOf(tm, "elsewhere"))
+	}
+	if n, _ := nm.GetNode("node-1"); n.Condition != models.NodeReady {
+		t.Errorf("expected node to be Ready, got %s", n.Condition)
+	}
+}
+
+func TestAgent_ReportsOOMKill(t *testing.T) {
+	rt := runtime.NewFake()
+	_, tm := startAgent(t, rt)
+	bind(t, tm, "hog")
+	waitFor(t, "task to start", func() bool { return isRunning(rt, "hog") })
+	if err := rt.OOMKill("hog"); err != nil {
+		t.Fatalf("OOMKill: %v", err)
+	}
+	waitFor(t, "task to fail", func() bool { return phaseOf(tm, "hog") == models.TaskFailed })
+	if task, _ := tm.GetTask("hog"); task.Reason != agent.ReasonOOMKilled || task.ExitCode == nil || *task.ExitCode != 137 {
+		t.Errorf("expected OOMKilled with exit code 137, got %+v", task)
+	}
+}
+
+func TestAgent_FailsTasksWithoutCommand(t *testing.T) {
+	_, tm := startAgent(t, runtime.NewFake())
+	if err := tm.CreateTask(models.Task{ID: "empty"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	if err := tm.UpdateTask(models.Task{ID: "empty", Status: models.TaskScheduled, NodeID: "node-1"}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+	waitFor(t, "task to fail", func() bool { return phaseOf(tm, "empty") == models.TaskFailed })
+}
+
+func TestAgent_StopsCancelledTasks(t *testing.T) {
+	rt := runtime.NewFake()
+	_, tm := startAgent(t, rt)
+	bind(t, tm, "long")
+	waitFor(t, "task to start", func() bool { return isRunning(rt, "long") })
+
+	task, _ := tm.GetTask("long")
+	task.Status = models.TaskCancelled
+	if err := tm.UpdateTask(*task); err != nil {
+		t.Fatalf("failed to cancel task: %v", err)
+	}
+	waitFor(t, "task to be stopped and removed", func() bool { return isRemoved(rt, "long") })
+	if got := phaseOf(tm, "long"); got != models.TaskCancelled {
+		t.Errorf("expected task to stay cancelled, got %s", got)
+	}
+}
+
+func TestAgent_MarksUnknownRunningTasksLost(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	bind(t, tm, "orphan")
+	if err := tm.UpdateTask(models.Task{ID: "orphan", Status: models.TaskRunning, NodeID: "node-1"}); err != nil {
+		t.Fatalf("failed to start task: %v", err)
+	}
+	apiInstance := api.NewAPI(node.NewManager(ds), tm)
+	srv := httptest.NewServer(apiInstance.Router())
+	defer srv.Close()
+	defer apiInstance.Close()
+
+	a := agent.New(client.New(srv.URL), models.Node{ID: "node-1"}, runtime.NewFake())
+	stopCh := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- a.Run(stopCh) }()
+	defer func() {
+		close(stopCh)
+		<-done
+	}()
+
+	waitFor(t, "orphan to be marked lost", func() bool { return phaseOf(tm, "orphan") == models.TaskFailed })
+	if task, _ := tm.GetTask("orphan"); task.Reason != agent.ReasonLost {
+		t.Errorf("expected reason %s, got %+v", agent.ReasonLost, task)
+	}
+}
+
+// startCount returns how many times a container has been started.
+func startCount(rt *runtime.Fake, id string) int {
+	n := 0
+	for _, started := range rt.Started() {
+		if started == id {
+			n++
+		}
+	}
+	return n
+}
+
+func TestAgent_RestartsOnFailureUntilRetriesRunOut(t *testing.T) {
+	rt := runtime.NewFake()
+	_, tm := startAgent(t, rt)
+	retries := 2
+	bindTask(t, tm, models.Task{ID: "flaky", Command: "run", RestartPolicy: models.RestartOnFailure, MaxRetries: &retries})
+
+	for run := 1; run <= 3; run++ {
+		waitFor(t, "task to start", func() bool { return startCount(rt, "flaky") == run && isRunning(rt, "flaky") })
+		if run > 1 {
+			task, _ := tm.GetTask("flaky")
+			if task.Status != models.TaskRunning || task.RestartCount != run-1 || task.LastTerminationReason != agent.ReasonError {
+				t.Fatalf("run %d: expected a running task restarted %d times after an error, got %+v", run, run-1, task)
+			}
+		}
+		if err := rt.Exit("flaky", 1, "boom\n"); err != nil {
+			t.Fatalf("Exit: %v", err)
+		}
+	}
+
+	waitFor(t, "task to fail", func() bool { return phaseOf(tm, "flaky") == models.TaskFailed })
+	task, _ := tm.GetTask("flaky")
+	if task.RestartCount != 2 || task.Reason != agent.ReasonError || task.ExitCode == nil || *task.ExitCode != 1 {
+		t.Errorf("expected failure after 2 restarts, got %+v", task)
+	}
+	if startCount(rt, "flaky") != 3 {
+		t.Errorf("expected 3 runs, got %d", startCount(rt, "flaky"))
+	}
+}
+
+func TestAgent_RestartPolicies(t *testing.T) {
+	rt := runtime.NewFake()
+	_, tm := startAgent(t, rt)
+	bindTask(t, tm, models.Task{ID: "always", Command: "run", RestartPolicy: models.RestartAlways})
+	bindTask(t, tm, models.Task{ID: "on-failure", Command: "run", RestartPolicy: models.RestartOnFailure})
+	waitFor(t, "tasks to start", func() bool { return isRunning(rt, "always") && isRunning(rt, "on-failure") })
+
+	// A successful exit only restarts a task whose policy is Always.
+	rt.Exit("always", 0, "")
+	rt.Exit("on-failure", 0, "")
+	waitFor(t, "always to restart", func() bool { return startCount(rt, "always") == 2 && isRunning(rt, "always") })
+	waitFor(t, "on-failure to succeed", func() bool { return phaseOf(tm, "on-failure") == models.TaskSucceeded })
+	if task, _ := tm.GetTask("always"); task.RestartCount != 1 || task.LastTerminationReason != agent.ReasonCompleted {
+		t.Errorf("expected always to have restarted after completing, got %+v", task)
+	}
+
+	// Cancelling a task stops its restarts.
+	task, _ := tm.GetTask("always")
+	task.Status = models.TaskCancelled
+	if err := tm.UpdateTask(*task); err != nil {
+		t.Fatalf("failed to cancel task: %v", err)
+	}
+	waitFor(t, "always to stop", func() bool { return !isRunning(rt, "always") })
+	time.Sleep(50 * time.Millisecond)
+	if n := startCount(rt, "always"); n != 2 {
+		t.Errorf("expected no restart after cancellation, got %d runs", n)
+	}
+}
+
+// probePort returns the port of a test server.
+func probePort(srv *httptest.Server) int {
+	return srv.Listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestAgent_ReadinessProbe(t *testing.T) {
+	var unready atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	rt := runtime.NewFake()
+	_, tm := startAgent(t, rt)
+	probe := &models.Probe{HTTPGet: &models.HTTPGetAction{Path: "/ready", Port: probePort(srv)}, PeriodSeconds: 1, FailureThreshold: 1}
+	bindTask(t, tm, models.Task{ID: "web", Command: "serve", ReadinessProbe: probe})
+	bind(t, tm, "plain")
+
+	ready := func(id string) bool {
+		task, err := tm.GetTask(id)
+		return err == nil && task.IsReady()
+	}
+	waitFor(t, "tasks to become ready", func() bool { return ready("web") && ready("plain") })
+
+	unready.Store(true)
+	waitFor(t, "web to become unready", func() bool { return !ready("web") })
+	task, _ := tm.GetTask("web")
+	if c := task.Condition(models.TaskReady); c.Reason != agent.ReasonReadinessProbeFailed || !strings.Contains(c.Message, "503") {
+		t.Errorf("expected the probe failure in the condition, got %+v", c)
+	}
+	if task.Status != models.TaskRunning {
+		t.Errorf("expected an unready task to keep running, got %s", task.Status)
+	}
+
+	rt.Exit("plain", 0, "")
+	waitFor(t, "plain to finish", func() bool { return phaseOf(tm, "plain") == models.TaskSucceeded })
+	if c := mustGet(t, tm, "plain").Condition(models.TaskReady); c.Status != models.ConditionFalse || c.Reason != agent.ReasonTerminated {
+		t.Errorf("expected a finished task to be unready, got %+v", c)
+	}
+}
+
+func TestAgent_LivenessProbeStopsTask(t *testing.T) {
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	rt := runtime.NewFake()
+	_, tm := startAgent(t, rt)
+	probe := &models.Probe{TCPSocket: &models.TCPSocketAction{Port: port}, FailureThreshold: 1}
+	bindTask(t, tm, models.Task{ID: "stuck", Command: "serve", LivenessProbe: probe})
+
+	waitFor(t, "task to fail", func() bool { return phaseOf(tm, "stuck") == models.TaskFailed })
+	task := mustGet(t, tm, "stuck")
+	if task.Reason != agent.ReasonLivenessProbeFailed || task.ExitCode == nil || *task.ExitCode != 143 {
+		t.Errorf("expected the task to be stopped by its liveness probe, got %+v", task)
+	}
+}
+
+func mustGet(t *testing.T, tm taskmanager.TaskManager, id string) *models.Task {
+	t.Helper()
+	task, err := tm.GetTask(id)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	return task
+}
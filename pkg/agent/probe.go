@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+)
+
+// maxProbeOutput bounds how much of an exec probe's output ends up in the
+// message of a failed check.
+const maxProbeOutput = 256
+
+// probeClient is shared by HTTP probes. Connections are not kept alive
+// between checks, since a check is meant to find out whether new ones are
+// accepted.
+var probeClient = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+// probeWorker runs the checks of one probe of a running container and
+// reports when the probe starts or stops passing.
+type probeWorker struct {
+	check        func(ctx context.Context) error
+	initialDelay time.Duration
+	period       time.Duration
+	timeout      time.Duration
+	successes    int
+	failures     int
+	// passing is the current result. It changes once enough checks in a
+	// row disagree with it.
+	passing  bool
+	onChange func(passing bool, message string)
+}
+
+// newProbeWorker creates a worker for probe p of the container run from
+// spec, starting out as passing or not.
+func newProbeWorker(p models.Probe, spec runtime.Spec, passing bool, onChange func(passing bool, message string)) *probeWorker {
+	return &probeWorker{
+		check:        checkFor(p, spec),
+		initialDelay: p.InitialDelay(),
+		period:       p.Period(),
+		timeout:      p.Timeout(),
+		successes:    p.Successes(),
+		failures:     p.Failures(),
+		passing:      passing,
+		onChange:     onChange,
+	}
+}
+
+// run checks the probe every period until ctx is done.
+func (w *probeWorker) run(ctx context.Context) {
+	timer := time.NewTimer(w.initialDelay)
+	defer timer.Stop()
+	streak := 0
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, w.timeout)
+		err := w.check(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if (err == nil) == w.passing {
+			streak = 0
+		} else {
+			streak++
+			threshold := w.successes
+			if w.passing {
+				threshold = w.failures
+			}
+			if streak >= threshold {
+				w.passing = !w.passing
+				streak = 0
+				message := ""
+				if err != nil {
+					message = err.Error()
+				}
+				w.onChange(w.passing, message)
+			}
+		}
+		timer.Reset(w.period)
+	}
+}
+
+// checkFor returns the check described by p.
+func checkFor(p models.Probe, spec runtime.Spec) func(ctx context.Context) error {
+	switch {
+	case p.Exec != nil:
+		command := p.Exec.Command
+		return func(ctx context.Context) error { return execCheck(ctx, command, spec) }
+	case p.HTTPGet != nil:
+		url := "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(p.HTTPGet.Port)) + p.HTTPGet.Path
+		return func(ctx context.Context) error { return httpCheck(ctx, url) }
+	case p.TCPSocket != nil:
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(p.TCPSocket.Port))
+		return func(ctx context.Context) error { return tcpCheck(ctx, addr) }
+	}
+	return func(ctx context.Context) error { return errors.New("probe has no check") }
+}
+
+// execCheck runs command with the environment and working directory of the
+// container.
+func execCheck(ctx context.Context, command []string, spec runtime.Spec) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = runtime.Environ(spec)
+	cmd.Dir = spec.WorkingDir
+	// Do not wait for background processes of the command that hold on to
+	// its output.
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("command timed out")
+	}
+	output := strings.TrimSpace(string(out))
+	if len(output) > maxProbeOutput {
+		output = output[:maxProbeOutput] + "..."
+	}
+	if output != "" {
+		return fmt.Errorf("command failed: %v: %s", err, output)
+	}
+	return fmt.Errorf("command failed: %v", err)
+}
+
+// httpCheck requests url and accepts any status code from 200 to 399.
+func httpCheck(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP probe failed with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// tcpCheck opens a connection to addr.
+func tcpCheck(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+)
+
+func TestProbeWorker_Thresholds(t *testing.T) {
+	results := []error{nil, errors.New("down"), errors.New("down"), nil, errors.New("down"), errors.New("down"), errors.New("down"), nil, nil}
+	var mu sync.Mutex
+	var changes []bool
+	checks := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &probeWorker{
+		check: func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if checks == len(results) {
+				cancel()
+				return nil
+			}
+			checks++
+			return results[checks-1]
+		},
+		period:    time.Millisecond,
+		timeout:   time.Second,
+		successes: 2,
+		failures:  3,
+		passing:   true,
+		onChange: func(passing bool, message string) {
+			changes = append(changes, passing)
+			if !passing && message != "down" {
+				t.Errorf("expected the failure in the message, got %q", message)
+			}
+		},
+	}
+	w.run(ctx)
+
+	// Two failures are not enough; three in a row are, and then it takes
+	// two successes to pass again.
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Errorf("expected a failure followed by a recovery, got %v", changes)
+	}
+}
+
+func TestProbeWorker_Timeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &probeWorker{
+		check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		period:    time.Millisecond,
+		timeout:   5 * time.Millisecond,
+		successes: 1,
+		failures:  1,
+		passing:   true,
+		onChange:  func(bool, string) { cancel() },
+	}
+	done := make(chan struct{})
+	go func() {
+		w.run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a hanging check was not timed out")
+	}
+}
+
+func TestChecks(t *testing.T) {
+	var unhealthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unhealthy.Load() || r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	spec := runtime.Spec{ID: "task-1", Env: []models.EnvVar{{Name: "WANT", Value: "yes"}}, WorkingDir: t.TempDir()}
+	probe := func(p models.Probe) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return checkFor(p, spec)(ctx)
+	}
+
+	if err := probe(models.Probe{Exec: &models.ExecAction{Command: []string{"sh", "-c", `test "$WANT" = yes && test "$PWD" = "$(pwd -P)"`}}}); err != nil {
+		t.Errorf("exec probe with the task's environment failed: %v", err)
+	}
+	if err := probe(models.Probe{Exec: &models.ExecAction{Command: []string{"sh", "-c", "echo nope; exit 1"}}}); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("expected the failing command's output in the error, got %v", err)
+	}
+	if err := probe(models.Probe{HTTPGet: &models.HTTPGetAction{Path: "/healthz", Port: port}}); err != nil {
+		t.Errorf("HTTP probe failed: %v", err)
+	}
+	unhealthy.Store(true)
+	if err := probe(models.Probe{HTTPGet: &models.HTTPGetAction{Path: "/healthz", Port: port}}); err == nil {
+		t.Error("expected a 503 to fail the HTTP probe")
+	}
+	if err := probe(models.Probe{TCPSocket: &models.TCPSocketAction{Port: port}}); err != nil {
+		t.Errorf("TCP probe failed: %v", err)
+	}
+	if err := probe(models.Probe{TCPSocket: &models.TCPSocketAction{Port: closedPort}}); err == nil {
+		t.Error("expected a closed port to fail the TCP probe")
+	}
+}
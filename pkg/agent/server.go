@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+	"github.com/gorilla/mux"
+)
+
+// Handler returns the HTTP handler the agent serves on its node address.
+// The API server proxies requests for task logs and usage to it.
+func (a *Agent) Handler() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/tasks/{id}/logs", a.logsHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/stats", a.statsHandler).Methods("GET")
+	return r
+}
+
+// statsHandler returns the current resource usage of a task's container as
+// a models.TaskUsage.
+func (a *Agent) statsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	stats, err := a.runtime.Stats(id)
+	if err != nil {
+		http.Error(w, err.Error(), runtimeErrorStatus(err))
+		return
+	}
+	usage := models.TaskUsage{
+		TaskID:          id,
+		CPUSeconds:      stats.CPUUsage.Seconds(),
+		MemoryBytes:     stats.MemoryUsage,
+		MemoryPeakBytes: stats.MemoryPeak,
+		Timestamp:       time.Now(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		log.Printf("Agent: writing usage of task %s: %v", id, err)
+	}
+}
+
+// logsHandler writes the output of a task as plain text, one line per entry.
+// With follow=true it keeps streaming until the task exits or the client
+// goes away.
+func (a *Agent) logsHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := logs.ParseOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	// Check that the task exists before the headers go out, so that a
+	// missing one can still be answered with 404.
+	if _, err := a.runtime.Status(id); err != nil {
+		http.Error(w, err.Error(), runtimeErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	out := io.Writer(w)
+	if opts.Follow {
+		// Send the headers now: the task may not print anything for a while.
+		rc := http.NewResponseController(w)
+		w.WriteHeader(http.StatusOK)
+		rc.Flush()
+		out = &flushWriter{w: w, rc: rc}
+	}
+	if err := a.runtime.Logs(r.Context(), id, opts, out); err != nil && r.Context().Err() == nil {
+		log.Printf("Agent: streaming logs of task %s: %v", id, err)
+	}
+}
+
+// runtimeErrorStatus maps runtime errors to HTTP status codes.
+func runtimeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, runtime.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, runtime.ErrStatsUnavailable):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// flushWriter sends every write to the client straight away.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.rc.Flush()
+}
@@ -0,0 +1,113 @@
+package agent_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/agent"
+	"github.com/fntkg/container-orchestrator/pkg/client"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+)
+
+func newLogsServer(t *testing.T) (*httptest.Server, *runtime.Fake) {
+	t.Helper()
+	rt := runtime.NewFake()
+	a := agent.New(client.New("http://unused"), models.Node{ID: "node-1"}, rt)
+	srv := httptest.NewServer(a.Handler())
+	t.Cleanup(srv.Close)
+	return srv, rt
+}
+
+func runFake(t *testing.T, rt *runtime.Fake, id string) {
+	t.Helper()
+	if err := rt.Create(runtime.Spec{ID: id, Command: "run"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rt.Start(id); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestHandler_Logs(t *testing.T) {
+	srv, rt := newLogsServer(t)
+	runFake(t, rt, "task-1")
+	rt.Exit("task-1", 0, "one\ntwo\nthree\n")
+
+	if code, body := get(t, srv.URL+"/tasks/task-1/logs"); code != http.StatusOK || body != "one\ntwo\nthree\n" {
+		t.Errorf("unexpected response %d %q", code, body)
+	}
+	if code, body := get(t, srv.URL+"/tasks/task-1/logs?tail=1"); code != http.StatusOK || body != "three\n" {
+		t.Errorf("unexpected tail response %d %q", code, body)
+	}
+	if code, _ := get(t, srv.URL+"/tasks/task-1/logs?tail=x"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid tail, got %d", code)
+	}
+	if code, _ := get(t, srv.URL+"/tasks/missing/logs"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown task, got %d", code)
+	}
+}
+
+func TestHandler_FollowLogs(t *testing.T) {
+	srv, rt := newLogsServer(t)
+	runFake(t, rt, "task-1")
+
+	resp, err := http.Get(srv.URL + "/tasks/task-1/logs?follow=true")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	// The headers are flushed before the task has printed anything; the
+	// stream then ends once it exits.
+	rt.Exit("task-1", 0, "done\n")
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "done\n" {
+		t.Fatalf("unexpected followed line %q (%v)", line, err)
+	}
+}
+
+func TestHandler_Stats(t *testing.T) {
+	srv, rt := newLogsServer(t)
+	runFake(t, rt, "task-1")
+	runFake(t, rt, "task-2")
+	if err := rt.SetStats("task-1", runtime.Stats{CPUUsage: 1500 * time.Millisecond, MemoryUsage: 4096, MemoryPeak: 8192}); err != nil {
+		t.Fatalf("SetStats: %v", err)
+	}
+
+	code, body := get(t, srv.URL+"/tasks/task-1/stats")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected response %d %q", code, body)
+	}
+	var usage models.TaskUsage
+	if err := json.Unmarshal([]byte(body), &usage); err != nil {
+		t.Fatalf("decoding usage: %v", err)
+	}
+	if usage.TaskID != "task-1" || usage.CPUSeconds != 1.5 || usage.MemoryBytes != 4096 || usage.MemoryPeakBytes != 8192 || usage.Timestamp.IsZero() {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+	if code, _ := get(t, srv.URL+"/tasks/task-2/stats"); code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without usage tracking, got %d", code)
+	}
+	if code, _ := get(t, srv.URL+"/tasks/missing/stats"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown task, got %d", code)
+	}
+}
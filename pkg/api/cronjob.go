@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+	"github.com/gorilla/mux"
+)
+
+// WithCronJobManager serves the cron job endpoints from m. Without it they
+// are not registered.
+func WithCronJobManager(m workload.CronJobManager) Option {
+	return func(a *API) { a.cronJobs = m }
+}
+
+// registerCronJobRoutes adds the cron job endpoints to the router.
+func (a *API) registerCronJobRoutes() {
+	a.router.HandleFunc("/cronjobs", a.getCronJobsHandler).Methods("GET")
+	a.router.HandleFunc("/cronjobs", a.createCronJobHandler).Methods("POST")
+	a.router.HandleFunc("/cronjobs/{id}", a.getCronJobHandler).Methods("GET")
+	a.router.HandleFunc("/cronjobs/{id}", a.updateCronJobHandler).Methods("PUT")
+	a.router.HandleFunc("/cronjobs/{id}", a.deleteCronJobHandler).Methods("DELETE")
+}
+
+// getCronJobsHandler returns every cron job, or streams changes to them when
+// called with watch=true.
+func (a *API) getCronJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if isWatch(r) {
+		a.serveWatch(w, r, a.cronJobs.Watch, nil)
+		return
+	}
+	cronJobs, err := a.cronJobs.GetCronJobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, cronJobs)
+}
+
+// createCronJobHandler creates a cron job. Its jobs are started by the cron
+// job controller.
+func (a *API) createCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	var cj models.CronJob
+	if err := json.NewDecoder(r.Body).Decode(&cj); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateCronJob(cj); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.cronJobs.CreateCronJob(cj); err != nil {
+		http.Error(w, err.Error(), cronJobErrorStatus(err))
+		return
+	}
+	a.writeCronJob(w, cj.ID, http.StatusCreated)
+}
+
+// getCronJobHandler returns a single cron job, with its resource version as
+// ETag.
+func (a *API) getCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeCronJob(w, mux.Vars(r)["id"], http.StatusOK)
+}
+
+// updateCronJobHandler replaces a cron job, for instance to change its
+// schedule or suspend it. The update is conditional on the version given in
+// an If-Match header, or else on the resourceVersion in the body when it is
+// set.
+func (a *API) updateCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	var cj models.CronJob
+	if err := json.NewDecoder(r.Body).Decode(&cj); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	cj.ID = mux.Vars(r)["id"]
+	if err := models.ValidateCronJob(cj); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	version, conditional, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if conditional {
+		cj.ResourceVersion = version
+	}
+	if err := a.cronJobs.UpdateCronJob(cj); err != nil {
+		http.Error(w, err.Error(), conflictStatus(err, conditional, cronJobErrorStatus))
+		return
+	}
+	a.writeCronJob(w, cj.ID, http.StatusOK)
+}
+
+// deleteCronJobHandler deletes a cron job. Its jobs are deleted by the cron
+// job controller.
+func (a *API) deleteCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.cronJobs.DeleteCronJob(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), cronJobErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCronJob responds with the stored cron job and its ETag.
+func (a *API) writeCronJob(w http.ResponseWriter, id string, status int) {
+	cj, err := a.cronJobs.GetCronJob(id)
+	if err != nil {
+		http.Error(w, err.Error(), cronJobErrorStatus(err))
+		return
+	}
+	setETag(w, cj.ResourceVersion)
+	writeJSON(w, status, cj)
+}
+
+// cronJobErrorStatus maps cron job manager errors to HTTP status codes.
+func cronJobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, workload.ErrCronJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, workload.ErrAlreadyExists), errors.Is(err, datastore.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
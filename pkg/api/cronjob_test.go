@@ -0,0 +1,97 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// Test the cron job endpoints from creation to deletion.
+func TestCronJobEndpoints(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	cjm := workload.NewCronJobManager(ds)
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(ds), api.WithCronJobManager(cjm))
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	backup := `{"id":"backup","schedule":"30 2 * * mon-fri","timeZone":"UTC","concurrencyPolicy":"Forbid","startingDeadlineSeconds":300,"jobTemplate":{"template":{"command":"backup"}}}`
+	w := do("POST", "/cronjobs", backup)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.CronJob
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	etag := w.Header().Get("ETag")
+	if created.ConcurrencyPolicy != models.ForbidConcurrent || created.CreationTime.IsZero() || etag == "" {
+		t.Errorf("expected the cron job as created with an ETag, got %+v", created)
+	}
+	if w := do("POST", "/cronjobs", backup); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 creating backup twice, got %d", w.Code)
+	}
+
+	for name, body := range map[string]string{
+		"no id":              `{"schedule":"@daily","jobTemplate":{"template":{"command":"true"}}}`,
+		"no schedule":        `{"id":"a","jobTemplate":{"template":{"command":"true"}}}`,
+		"invalid schedule":   `{"id":"a","schedule":"61 * * * *","jobTemplate":{"template":{"command":"true"}}}`,
+		"unknown time zone":  `{"id":"a","schedule":"@daily","timeZone":"Mars/Olympus","jobTemplate":{"template":{"command":"true"}}}`,
+		"unknown policy":     `{"id":"a","schedule":"@daily","concurrencyPolicy":"Sometimes","jobTemplate":{"template":{"command":"true"}}}`,
+		"negative deadline":  `{"id":"a","schedule":"@daily","startingDeadlineSeconds":-1,"jobTemplate":{"template":{"command":"true"}}}`,
+		"negative history":   `{"id":"a","schedule":"@daily","failedJobsHistoryLimit":-1,"jobTemplate":{"template":{"command":"true"}}}`,
+		"invalid template":   `{"id":"a","schedule":"@daily","jobTemplate":{"completions":0,"template":{"command":"true"}}}`,
+		"Always restart":     `{"id":"a","schedule":"@daily","jobTemplate":{"template":{"command":"true","restartPolicy":"Always"}}}`,
+		"malformed document": `{"id":`,
+	} {
+		if w := do("POST", "/cronjobs", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, w.Code)
+		}
+	}
+
+	suspended := `{"schedule":"@hourly","suspend":true,"jobTemplate":{"template":{"command":"backup"}}}`
+	if w := do("PUT", "/cronjobs/backup", suspended, "If-Match", etag); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 updating, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/cronjobs/backup", suspended, "If-Match", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412 updating a stale version, got %d", w.Code)
+	}
+	if w := do("PUT", "/cronjobs/missing", suspended); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 updating a missing cron job, got %d", w.Code)
+	}
+	w = do("GET", "/cronjobs/backup", "")
+	var got models.CronJob
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", w.Code, err)
+	}
+	if got.Schedule != "@hourly" || !got.Suspend || !got.CreationTime.Equal(created.CreationTime) {
+		t.Errorf("expected the updated, suspended cron job, got %+v", got)
+	}
+
+	w = do("GET", "/cronjobs", "")
+	var all []models.CronJob
+	if err := json.NewDecoder(w.Body).Decode(&all); err != nil || len(all) != 1 {
+		t.Errorf("expected 1 cron job, got %v (%v)", all, err)
+	}
+
+	if w := do("DELETE", "/cronjobs/backup", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 deleting, got %d", w.Code)
+	}
+	if w := do("GET", "/cronjobs/backup", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after deletion, got %d", w.Code)
+	}
+}
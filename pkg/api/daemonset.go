@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+	"github.com/gorilla/mux"
+)
+
+// WithDaemonSetManager serves the daemon set endpoints from m. Without it
+// they are not registered.
+func WithDaemonSetManager(m workload.DaemonSetManager) Option {
+	return func(a *API) { a.daemonSets = m }
+}
+
+// registerDaemonSetRoutes adds the daemon set endpoints to the router.
+func (a *API) registerDaemonSetRoutes() {
+	a.router.HandleFunc("/daemonsets", a.getDaemonSetsHandler).Methods("GET")
+	a.router.HandleFunc("/daemonsets", a.createDaemonSetHandler).Methods("POST")
+	a.router.HandleFunc("/daemonsets/{id}", a.getDaemonSetHandler).Methods("GET")
+	a.router.HandleFunc("/daemonsets/{id}", a.updateDaemonSetHandler).Methods("PUT")
+	a.router.HandleFunc("/daemonsets/{id}", a.deleteDaemonSetHandler).Methods("DELETE")
+}
+
+// getDaemonSetsHandler returns every daemon set, or streams changes to them
+// when called with watch=true.
+func (a *API) getDaemonSetsHandler(w http.ResponseWriter, r *http.Request) {
+	if isWatch(r) {
+		a.serveWatch(w, r, a.daemonSets.Watch, nil)
+		return
+	}
+	daemonSets, err := a.daemonSets.GetDaemonSets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, daemonSets)
+}
+
+// createDaemonSetHandler creates a daemon set. Its tasks are created by the
+// daemon set controller.
+func (a *API) createDaemonSetHandler(w http.ResponseWriter, r *http.Request) {
+	var cj models.DaemonSet
+	if err := json.NewDecoder(r.Body).Decode(&cj); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateDaemonSet(cj); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.daemonSets.CreateDaemonSet(cj); err != nil {
+		http.Error(w, err.Error(), daemonSetErrorStatus(err))
+		return
+	}
+	a.writeDaemonSet(w, cj.ID, http.StatusCreated)
+}
+
+// getDaemonSetHandler returns a single daemon set, with its resource version
+// as ETag.
+func (a *API) getDaemonSetHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeDaemonSet(w, mux.Vars(r)["id"], http.StatusOK)
+}
+
+// updateDaemonSetHandler replaces a daemon set; a new template is rolled out
+// as its update strategy says. The update is conditional on the version
+// given in an If-Match header, or else on the resourceVersion in the body
+// when it is set.
+func (a *API) updateDaemonSetHandler(w http.ResponseWriter, r *http.Request) {
+	var cj models.DaemonSet
+	if err := json.NewDecoder(r.Body).Decode(&cj); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	cj.ID = mux.Vars(r)["id"]
+	if err := models.ValidateDaemonSet(cj); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	version, conditional, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if conditional {
+		cj.ResourceVersion = version
+	}
+	if err := a.daemonSets.UpdateDaemonSet(cj); err != nil {
+		http.Error(w, err.Error(), conflictStatus(err, conditional, daemonSetErrorStatus))
+		return
+	}
+	a.writeDaemonSet(w, cj.ID, http.StatusOK)
+}
+
+// deleteDaemonSetHandler deletes a daemon set. Its tasks are removed by the
+// daemon set controller.
+func (a *API) deleteDaemonSetHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.daemonSets.DeleteDaemonSet(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), daemonSetErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeDaemonSet responds with the stored daemon set and its ETag.
+func (a *API) writeDaemonSet(w http.ResponseWriter, id string, status int) {
+	cj, err := a.daemonSets.GetDaemonSet(id)
+	if err != nil {
+		http.Error(w, err.Error(), daemonSetErrorStatus(err))
+		return
+	}
+	setETag(w, cj.ResourceVersion)
+	writeJSON(w, status, cj)
+}
+
+// daemonSetErrorStatus maps daemon set manager errors to HTTP status codes.
+func daemonSetErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, workload.ErrDaemonSetNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, workload.ErrAlreadyExists), errors.Is(err, datastore.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// Test the daemon set endpoints from creation to deletion.
+func TestDaemonSetEndpoints(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	dsm := workload.NewDaemonSetManager(ds)
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(ds), api.WithDaemonSetManager(dsm))
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	logs := `{"id":"logs","nodeSelector":{"matchLabels":{"zone":"a"}},"template":{"command":"ship-logs"},"updateStrategy":{"rollingUpdate":{"maxUnavailable":"20%"}}}`
+	w := do("POST", "/daemonsets", logs)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.DaemonSet
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	etag := w.Header().Get("ETag")
+	if created.UpdateStrategy.Type != models.DaemonSetRollingUpdate || created.MaxUnavailable(10) != 2 || created.NodeSelector.MatchLabels["zone"] != "a" || etag == "" {
+		t.Errorf("expected the daemon set as created with an ETag, got %+v", created)
+	}
+	if w := do("POST", "/daemonsets", logs); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 creating logs twice, got %d", w.Code)
+	}
+
+	for name, body := range map[string]string{
+		"no id":                  `{"template":{"command":"true"}}`,
+		"Never restart policy":   `{"id":"a","template":{"command":"true","restartPolicy":"Never"}}`,
+		"reserved label":         `{"id":"a","template":{"command":"true","labels":{"template-hash":"x"}}}`,
+		"template bound to node": `{"id":"a","template":{"command":"true","nodeId":"node-1"}}`,
+		"unknown strategy":       `{"id":"a","template":{"command":"true"},"updateStrategy":{"type":"Sometimes"}}`,
+		"zero maxUnavailable":    `{"id":"a","template":{"command":"true"},"updateStrategy":{"rollingUpdate":{"maxUnavailable":0}}}`,
+		"rolling with OnDelete":  `{"id":"a","template":{"command":"true"},"updateStrategy":{"type":"OnDelete","rollingUpdate":{}}}`,
+		"invalid template":       `{"id":"a","template":{"args":["x"]}}`,
+		"invalid node selector":  `{"id":"a","nodeSelector":{"matchExpressions":[{"key":"zone","operator":"Exists","values":["a"]}]},"template":{"command":"true"}}`,
+	} {
+		if w := do("POST", "/daemonsets", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, w.Code)
+		}
+	}
+
+	updated := `{"template":{"command":"ship-logs-v2"},"updateStrategy":{"type":"OnDelete"}}`
+	if w := do("PUT", "/daemonsets/logs", updated, "If-Match", etag); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 updating, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/daemonsets/logs", updated, "If-Match", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412 updating a stale version, got %d", w.Code)
+	}
+	w = do("GET", "/daemonsets/logs", "")
+	var got models.DaemonSet
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", w.Code, err)
+	}
+	if got.Template.Command != "ship-logs-v2" || got.UpdateStrategy.Type != models.DaemonSetOnDelete || !got.NodeSelector.IsEmpty() {
+		t.Errorf("expected the updated daemon set, got %+v", got)
+	}
+
+	if w := do("DELETE", "/daemonsets/logs", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 deleting, got %d", w.Code)
+	}
+	if w := do("GET", "/daemonsets/logs", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after deletion, got %d", w.Code)
+	}
+}
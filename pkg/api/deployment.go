@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+	"github.com/gorilla/mux"
+)
+
+// WithDeploymentManager serves the deployment endpoints from m. Without it
+// they are not registered.
+func WithDeploymentManager(m workload.DeploymentManager) Option {
+	return func(a *API) { a.deployments = m }
+}
+
+// registerDeploymentRoutes adds the deployment endpoints to the router.
+func (a *API) registerDeploymentRoutes() {
+	a.router.HandleFunc("/deployments", a.getDeploymentsHandler).Methods("GET")
+	a.router.HandleFunc("/deployments", a.createDeploymentHandler).Methods("POST")
+	a.router.HandleFunc("/deployments/{id}", a.getDeploymentHandler).Methods("GET")
+	a.router.HandleFunc("/deployments/{id}", a.updateDeploymentHandler).Methods("PUT")
+	a.router.HandleFunc("/deployments/{id}", a.deleteDeploymentHandler).Methods("DELETE")
+	a.router.HandleFunc("/deployments/{id}/scale", a.scaleDeploymentHandler).Methods("PUT")
+	a.router.HandleFunc("/deployments/{id}/pause", a.pauseDeploymentHandler(true)).Methods("POST")
+	a.router.HandleFunc("/deployments/{id}/resume", a.pauseDeploymentHandler(false)).Methods("POST")
+	a.router.HandleFunc("/deployments/{id}/revisions", a.deploymentRevisionsHandler).Methods("GET")
+	a.router.HandleFunc("/deployments/{id}/rollback", a.rollbackDeploymentHandler).Methods("POST")
+}
+
+// getDeploymentsHandler returns every deployment, or streams changes to them
+// when called with watch=true.
+func (a *API) getDeploymentsHandler(w http.ResponseWriter, r *http.Request) {
+	if isWatch(r) {
+		a.serveWatch(w, r, a.deployments.Watch, nil)
+		return
+	}
+	deployments, err := a.deployments.GetDeployments()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, deployments)
+}
+
+// createDeploymentHandler creates a deployment. Its replica sets are
+// created by the deployment controller.
+func (a *API) createDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	var d models.Deployment
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateDeployment(d); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.deployments.CreateDeployment(d); err != nil {
+		http.Error(w, err.Error(), deploymentErrorStatus(err))
+		return
+	}
+	a.writeDeployment(w, d.ID, http.StatusCreated)
+}
+
+// getDeploymentHandler returns a single deployment, with its resource
+// version as ETag.
+func (a *API) getDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeDeployment(w, mux.Vars(r)["id"], http.StatusOK)
+}
+
+// updateDeploymentHandler replaces a deployment; a new template is rolled
+// out. The update is conditional on the version given in an If-Match
+// header, or else on the resourceVersion in the body when it is set.
+func (a *API) updateDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	var d models.Deployment
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	d.ID = mux.Vars(r)["id"]
+	if err := models.ValidateDeployment(d); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	version, conditional, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if conditional {
+		d.ResourceVersion = version
+	}
+	if err := a.deployments.UpdateDeployment(d); err != nil {
+		http.Error(w, err.Error(), conflictStatus(err, conditional, deploymentErrorStatus))
+		return
+	}
+	a.writeDeployment(w, d.ID, http.StatusOK)
+}
+
+// scaleDeploymentHandler sets the replica count of a deployment from a
+// payload such as {"replicas": 3}.
+func (a *API) scaleDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	replicas, ok := decodeScale(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if err := a.deployments.Scale(id, replicas); err != nil {
+		http.Error(w, err.Error(), deploymentErrorStatus(err))
+		return
+	}
+	a.writeDeployment(w, id, http.StatusOK)
+}
+
+// deleteDeploymentHandler deletes a deployment. Its replica sets are deleted
+// by the deployment controller.
+func (a *API) deleteDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.deployments.DeleteDeployment(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), deploymentErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pauseDeploymentHandler returns a handler that pauses or resumes the
+// rollouts of a deployment.
+func (a *API) pauseDeploymentHandler(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if err := a.deployments.SetPaused(id, paused); err != nil {
+			http.Error(w, err.Error(), deploymentErrorStatus(err))
+			return
+		}
+		a.writeDeployment(w, id, http.StatusOK)
+	}
+}
+
+// deploymentRevisionsHandler returns the replica sets of a deployment,
+// oldest revision first.
+func (a *API) deploymentRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	history, err := a.deployments.Revisions(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), deploymentErrorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// rollbackDeploymentHandler rolls a deployment back to the revision given as
+// {"revision": N}, or to the previous one without a body.
+func (a *API) rollbackDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Revision int `json:"revision"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if payload.Revision < 0 {
+		http.Error(w, "revision must not be negative", http.StatusBadRequest)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if err := a.deployments.Rollback(id, payload.Revision); err != nil {
+		http.Error(w, err.Error(), deploymentErrorStatus(err))
+		return
+	}
+	a.writeDeployment(w, id, http.StatusOK)
+}
+
+// writeDeployment responds with the stored deployment and its ETag.
+func (a *API) writeDeployment(w http.ResponseWriter, id string, status int) {
+	d, err := a.deployments.GetDeployment(id)
+	if err != nil {
+		http.Error(w, err.Error(), deploymentErrorStatus(err))
+		return
+	}
+	setETag(w, d.ResourceVersion)
+	writeJSON(w, status, d)
+}
+
+// deploymentErrorStatus maps deployment manager errors to HTTP status codes.
+func deploymentErrorStatus(err error) int {
+	var validationErr models.ValidationError
+	switch {
+	case errors.Is(err, workload.ErrDeploymentNotFound), errors.Is(err, workload.ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, workload.ErrAlreadyExists), errors.Is(err, datastore.ErrConflict):
+		return http.StatusConflict
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,97 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// Test the deployment endpoints from creation to deletion.
+func TestDeploymentEndpoints(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(ds), api.WithDeploymentManager(workload.NewDeploymentManager(ds)))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) models.Deployment {
+		t.Helper()
+		var d models.Deployment
+		if err := json.NewDecoder(w.Body).Decode(&d); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		return d
+	}
+
+	web := `{"id":"web","replicas":4,"selector":{"matchLabels":{"app":"web"}},"template":{"labels":{"app":"web"},"command":"nginx"},` +
+		`"strategy":{"type":"RollingUpdate","rollingUpdate":{"maxSurge":"50%","maxUnavailable":0}}}`
+	w := do("POST", "/deployments", web)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	created := decode(w)
+	if surge, unavailable := created.SurgeAndUnavailable(); surge != 2 || unavailable != 0 {
+		t.Errorf("expected a surge of 2 and no unavailable replicas, got %d and %d", surge, unavailable)
+	}
+	if w := do("POST", "/deployments", web); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 creating web twice, got %d", w.Code)
+	}
+
+	for name, body := range map[string]string{
+		"unknown strategy":            `{"id":"a","replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a"}},"strategy":{"type":"Canary"}}`,
+		"no surge and no unavailable": `{"id":"a","replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a"}},"strategy":{"rollingUpdate":{"maxSurge":0,"maxUnavailable":"0%"}}}`,
+		"reserved template label":     `{"id":"a","replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a","template-hash":"x"}}}`,
+		"negative history limit":      `{"id":"a","replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a"}},"revisionHistoryLimit":-1}`,
+		"malformed percentage":        `{"id":"a","replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a"}},"strategy":{"rollingUpdate":{"maxSurge":"lots"}}}`,
+	} {
+		if w := do("POST", "/deployments", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, w.Code)
+		}
+	}
+
+	if w := do("PUT", "/deployments/web/scale", `{"replicas":6}`); w.Code != http.StatusOK || decode(w).Replicas != 6 {
+		t.Errorf("expected status 200 and 6 replicas scaling, got %d", w.Code)
+	}
+	if w := do("POST", "/deployments/web/pause", ""); w.Code != http.StatusOK || !decode(w).Paused {
+		t.Errorf("expected status 200 and a paused deployment, got %d", w.Code)
+	}
+	if w := do("POST", "/deployments/web/resume", ""); w.Code != http.StatusOK || decode(w).Paused {
+		t.Errorf("expected status 200 and a resumed deployment, got %d", w.Code)
+	}
+
+	w = do("GET", "/deployments/web/revisions", "")
+	var history []models.ReplicaSet
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil || w.Code != http.StatusOK || len(history) != 0 {
+		t.Errorf("expected status 200 and no revisions yet, got %d: %v", w.Code, err)
+	}
+	if w := do("POST", "/deployments/web/rollback", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 rolling back without history, got %d", w.Code)
+	}
+	if w := do("POST", "/deployments/web/rollback", `{"revision":-1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 rolling back to a negative revision, got %d", w.Code)
+	}
+	if w := do("POST", "/deployments/missing/rollback", `{"revision":1}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 rolling back a missing deployment, got %d", w.Code)
+	}
+
+	moved := `{"replicas":2,"selector":{"matchLabels":{"app":"api"}},"template":{"labels":{"app":"api"}}}`
+	if w := do("PUT", "/deployments/web", moved); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 changing the selector, got %d", w.Code)
+	}
+	if w := do("DELETE", "/deployments/web", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 deleting, got %d", w.Code)
+	}
+	if w := do("GET", "/deployments/web", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after deletion, got %d", w.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+	"github.com/gorilla/mux"
+)
+
+// WithJobManager serves the job endpoints from m. Without it they are not
+// registered.
+func WithJobManager(m workload.JobManager) Option {
+	return func(a *API) { a.jobs = m }
+}
+
+// registerJobRoutes adds the job endpoints to the router.
+func (a *API) registerJobRoutes() {
+	a.router.HandleFunc("/jobs", a.getJobsHandler).Methods("GET")
+	a.router.HandleFunc("/jobs", a.createJobHandler).Methods("POST")
+	a.router.HandleFunc("/jobs/{id}", a.getJobHandler).Methods("GET")
+	a.router.HandleFunc("/jobs/{id}", a.deleteJobHandler).Methods("DELETE")
+}
+
+// getJobsHandler returns every job, or streams changes to them when called
+// with watch=true.
+func (a *API) getJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if isWatch(r) {
+		a.serveWatch(w, r, a.jobs.Watch, nil)
+		return
+	}
+	jobs, err := a.jobs.GetJobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// createJobHandler creates a job. Its tasks are created by the job
+// controller.
+func (a *API) createJobHandler(w http.ResponseWriter, r *http.Request) {
+	var j models.Job
+	if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateJob(j); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.jobs.CreateJob(j); err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+	a.writeJob(w, j.ID, http.StatusCreated)
+}
+
+// getJobHandler returns a single job, with its resource version as ETag.
+// The Complete and Failed conditions of its status tell whether it has
+// finished.
+func (a *API) getJobHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeJob(w, mux.Vars(r)["id"], http.StatusOK)
+}
+
+// deleteJobHandler deletes a job. Its tasks are stopped and deleted by the
+// job controller.
+func (a *API) deleteJobHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.jobs.DeleteJob(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJob responds with the stored job and its ETag.
+func (a *API) writeJob(w http.ResponseWriter, id string, status int) {
+	j, err := a.jobs.GetJob(id)
+	if err != nil {
+		http.Error(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+	setETag(w, j.ResourceVersion)
+	writeJSON(w, status, j)
+}
+
+// jobErrorStatus maps job manager errors to HTTP status codes.
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, workload.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, workload.ErrAlreadyExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
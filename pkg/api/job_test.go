@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// Test the job endpoints from creation to deletion.
+func TestJobEndpoints(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	jm := workload.NewJobManager(ds)
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(ds), api.WithJobManager(jm))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	batch := `{"id":"batch","completions":5,"parallelism":2,"backoffLimit":0,"activeDeadlineSeconds":60,"template":{"command":"true","restartPolicy":"OnFailure"}}`
+	w := do("POST", "/jobs", batch)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.Job
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if created.WantedCompletions() != 5 || created.MaxParallel() != 2 || created.FailureLimit() != 0 || w.Header().Get("ETag") == "" {
+		t.Errorf("expected the job as created with an ETag, got %+v", created)
+	}
+	if w := do("POST", "/jobs", batch); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 creating batch twice, got %d", w.Code)
+	}
+
+	for name, body := range map[string]string{
+		"no id":                  `{"template":{"command":"true"}}`,
+		"zero completions":       `{"id":"a","completions":0,"template":{"command":"true"}}`,
+		"zero parallelism":       `{"id":"a","parallelism":0,"template":{"command":"true"}}`,
+		"negative backoff limit": `{"id":"a","backoffLimit":-1,"template":{"command":"true"}}`,
+		"zero deadline":          `{"id":"a","activeDeadlineSeconds":0,"template":{"command":"true"}}`,
+		"Always restart policy":  `{"id":"a","template":{"command":"true","restartPolicy":"Always"}}`,
+		"invalid template":       `{"id":"a","template":{"args":["x"]}}`,
+	} {
+		if w := do("POST", "/jobs", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, w.Code)
+		}
+	}
+
+	// The job controller reports the final state through the conditions.
+	j, _ := jm.GetJob("batch")
+	j.Status.Succeeded = 5
+	j.Status.Conditions = []models.JobCondition{{Type: models.JobComplete, Status: models.ConditionTrue}}
+	if err := jm.UpdateJobStatus(*j); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	w = do("GET", "/jobs/batch", "")
+	var got models.Job
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", w.Code, err)
+	}
+	if !got.IsFinished() || got.Condition(models.JobComplete) == nil {
+		t.Errorf("expected a complete job, got %+v", got.Status)
+	}
+
+	if w := do("DELETE", "/jobs/batch", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 deleting, got %d", w.Code)
+	}
+	if w := do("GET", "/jobs/batch", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after deletion, got %d", w.Code)
+	}
+}
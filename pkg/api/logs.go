@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+	"github.com/gorilla/mux"
+)
+
+// taskLogsHandler streams the output of a task. Logs stay on the node that
+// ran the task, so the request is proxied to that node's agent; the tail,
+// since, timestamps and follow parameters are passed on unchanged.
+func (a *API) taskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := logs.ParseOptions(r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.proxyToAgent(w, r, "logs")
+}
+
+// taskStatsHandler returns the current resource usage of a task, which only
+// the agent of the node running it can read.
+func (a *API) taskStatsHandler(w http.ResponseWriter, r *http.Request) {
+	a.proxyToAgent(w, r, "stats")
+}
+
+// proxyToAgent passes a request about the task named in the path on to
+// /tasks/{id}/<what> on the agent of the node the task is bound to.
+func (a *API) proxyToAgent(w http.ResponseWriter, r *http.Request, what string) {
+	task, err := a.taskManager.GetTask(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), taskErrorStatus(err))
+		return
+	}
+	if task.NodeID == "" {
+		http.Error(w, "task "+task.ID+" has not been scheduled to a node", http.StatusBadRequest)
+		return
+	}
+	n, err := a.nodeManager.GetNode(task.NodeID)
+	if err != nil {
+		http.Error(w, "node "+task.NodeID+": "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if n.Address == "" {
+		http.Error(w, "node "+n.ID+" does not serve "+what, http.StatusBadGateway)
+		return
+	}
+	target, err := url.Parse(n.Address)
+	if err != nil {
+		http.Error(w, "node "+n.ID+" has an invalid address", http.StatusBadGateway)
+		return
+	}
+
+	// A followed stream lasts as long as the task, so it must end when the
+	// API is closed for shutdown.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-a.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = target.Scheme
+			pr.Out.URL.Host = target.Host
+			pr.Out.URL.Path = strings.TrimSuffix(target.Path, "/") + "/tasks/" + url.PathEscape(task.ID) + "/" + what
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = ""
+		},
+		// Flush every write so that followed lines arrive as they are logged.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if r.Context().Err() != nil {
+				return
+			}
+			log.Printf("API: proxying %s of task %s to node %s: %v", what, task.ID, n.ID, err)
+			http.Error(w, "node "+n.ID+" is unreachable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -0,0 +1,169 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+)
+
+// newLogsServer serves an API whose task-1 ran on a node with the given
+// address.
+func newLogsServer(t *testing.T, address string) (*httptest.Server, *api.API) {
+	t.Helper()
+	ds := datastore.NewInMemoryDatastore()
+	for _, n := range []models.Node{{ID: "node-1", Address: address}, {ID: "node-2"}} {
+		if err := ds.SaveNode(n); err != nil {
+			t.Fatalf("error saving node: %v", err)
+		}
+	}
+	for _, task := range []models.Task{
+		{ID: "task-1", Status: models.TaskRunning, NodeID: "node-1"},
+		{ID: "task-2", Status: models.TaskRunning, NodeID: "node-2"},
+		{ID: "task-3", Status: models.TaskPending},
+	} {
+		if err := ds.SaveTask(task); err != nil {
+			t.Fatalf("error saving task: %v", err)
+		}
+	}
+	apiInstance := api.NewAPI(node.NewManager(ds), taskmanager.NewTaskManager(ds))
+	srv := httptest.NewServer(apiInstance.Router())
+	t.Cleanup(func() {
+		apiInstance.Close()
+		srv.Close()
+	})
+	return srv, apiInstance
+}
+
+func TestTaskLogsEndpoint_ProxiesToNode(t *testing.T) {
+	var gotPath, gotQuery string
+	agentSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "three\n")
+	}))
+	defer agentSrv.Close()
+	srv, _ := newLogsServer(t, agentSrv.URL)
+
+	resp, err := http.Get(srv.URL + "/tasks/task-1/logs?tail=1&timestamps=true")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "three\n" {
+		t.Errorf("unexpected response %d %q", resp.StatusCode, body)
+	}
+	if gotPath != "/tasks/task-1/logs" || gotQuery != "tail=1&timestamps=true" {
+		t.Errorf("unexpected proxied request %s?%s", gotPath, gotQuery)
+	}
+}
+
+func TestTaskStatsEndpoint_ProxiesToNode(t *testing.T) {
+	var gotPath string
+	agentSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"taskId":"task-1","cpuSeconds":1.5,"memoryBytes":4096}`)
+	}))
+	defer agentSrv.Close()
+	srv, _ := newLogsServer(t, agentSrv.URL)
+
+	resp, err := http.Get(srv.URL + "/tasks/task-1/stats")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	var usage models.TaskUsage
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected response %d (%v)", resp.StatusCode, err)
+	}
+	if gotPath != "/tasks/task-1/stats" || usage.CPUSeconds != 1.5 || usage.MemoryBytes != 4096 {
+		t.Errorf("unexpected proxied request %s or usage %+v", gotPath, usage)
+	}
+
+	for path, want := range map[string]int{
+		"/tasks/missing/stats": http.StatusNotFound,
+		"/tasks/task-3/stats":  http.StatusBadRequest,
+		"/tasks/task-2/stats":  http.StatusBadGateway,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: expected %d, got %d", path, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestTaskLogsEndpoint_Errors(t *testing.T) {
+	// A node address nothing listens on.
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	srv, _ := newLogsServer(t, dead.URL)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/tasks/missing/logs", http.StatusNotFound},
+		{"/tasks/task-3/logs", http.StatusBadRequest},
+		{"/tasks/task-1/logs?since=yesterday", http.StatusBadRequest},
+		{"/tasks/task-2/logs", http.StatusBadGateway},
+		{"/tasks/task-1/logs", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestTaskLogsEndpoint_FollowEndsOnClose(t *testing.T) {
+	agentSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first\n")
+		http.NewResponseController(w).Flush()
+		<-r.Context().Done()
+	}))
+	defer agentSrv.Close()
+	srv, apiInstance := newLogsServer(t, agentSrv.URL)
+
+	resp, err := http.Get(srv.URL + "/tasks/task-1/logs?follow=true")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	// The first line arrives while the agent is still streaming.
+	if line, err := r.ReadString('\n'); err != nil || line != "first\n" {
+		t.Fatalf("unexpected line %q (%v)", line, err)
+	}
+
+	apiInstance.Close()
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, r)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("followed stream did not end when the API was closed")
+	}
+}
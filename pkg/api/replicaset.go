@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+	"github.com/gorilla/mux"
+)
+
+// WithReplicaSetManager serves the replica set endpoints from m. Without it
+// they are not registered.
+func WithReplicaSetManager(m workload.ReplicaSetManager) Option {
+	return func(a *API) { a.replicaSets = m }
+}
+
+// registerReplicaSetRoutes adds the replica set endpoints to the router.
+func (a *API) registerReplicaSetRoutes() {
+	a.router.HandleFunc("/replicasets", a.getReplicaSetsHandler).Methods("GET")
+	a.router.HandleFunc("/replicasets", a.createReplicaSetHandler).Methods("POST")
+	a.router.HandleFunc("/replicasets/{id}", a.getReplicaSetHandler).Methods("GET")
+	a.router.HandleFunc("/replicasets/{id}", a.updateReplicaSetHandler).Methods("PUT")
+	a.router.HandleFunc("/replicasets/{id}", a.deleteReplicaSetHandler).Methods("DELETE")
+	a.router.HandleFunc("/replicasets/{id}/scale", a.scaleReplicaSetHandler).Methods("PUT")
+}
+
+// getReplicaSetsHandler returns every replica set, or streams changes to
+// them when called with watch=true.
+func (a *API) getReplicaSetsHandler(w http.ResponseWriter, r *http.Request) {
+	if isWatch(r) {
+		a.serveWatch(w, r, a.replicaSets.Watch, nil)
+		return
+	}
+	sets, err := a.replicaSets.GetReplicaSets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, sets)
+}
+
+// createReplicaSetHandler creates a replica set. Its tasks are created by
+// the replica set controller.
+func (a *API) createReplicaSetHandler(w http.ResponseWriter, r *http.Request) {
+	var rs models.ReplicaSet
+	if err := json.NewDecoder(r.Body).Decode(&rs); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateReplicaSet(rs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.replicaSets.CreateReplicaSet(rs); err != nil {
+		http.Error(w, err.Error(), replicaSetErrorStatus(err))
+		return
+	}
+	a.writeReplicaSet(w, rs.ID, http.StatusCreated)
+}
+
+// getReplicaSetHandler returns a single replica set, with its resource
+// version as ETag.
+func (a *API) getReplicaSetHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeReplicaSet(w, mux.Vars(r)["id"], http.StatusOK)
+}
+
+// updateReplicaSetHandler replaces the replica count and template of a
+// replica set. The update is conditional on the version given in an If-Match
+// header, or else on the resourceVersion in the body when it is set.
+func (a *API) updateReplicaSetHandler(w http.ResponseWriter, r *http.Request) {
+	var rs models.ReplicaSet
+	if err := json.NewDecoder(r.Body).Decode(&rs); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	rs.ID = mux.Vars(r)["id"]
+	if err := models.ValidateReplicaSet(rs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	version, conditional, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if conditional {
+		rs.ResourceVersion = version
+	}
+	if err := a.replicaSets.UpdateReplicaSet(rs); err != nil {
+		http.Error(w, err.Error(), conflictStatus(err, conditional, replicaSetErrorStatus))
+		return
+	}
+	a.writeReplicaSet(w, rs.ID, http.StatusOK)
+}
+
+// scaleReplicaSetHandler sets the replica count of a replica set from a
+// payload such as {"replicas": 3}.
+func (a *API) scaleReplicaSetHandler(w http.ResponseWriter, r *http.Request) {
+	replicas, ok := decodeScale(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if err := a.replicaSets.Scale(id, replicas); err != nil {
+		http.Error(w, err.Error(), replicaSetErrorStatus(err))
+		return
+	}
+	a.writeReplicaSet(w, id, http.StatusOK)
+}
+
+// deleteReplicaSetHandler deletes a replica set. Its tasks are stopped and
+// deleted by the replica set controller.
+func (a *API) deleteReplicaSetHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.replicaSets.DeleteReplicaSet(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), replicaSetErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeReplicaSet responds with the stored replica set and its ETag.
+func (a *API) writeReplicaSet(w http.ResponseWriter, id string, status int) {
+	rs, err := a.replicaSets.GetReplicaSet(id)
+	if err != nil {
+		http.Error(w, err.Error(), replicaSetErrorStatus(err))
+		return
+	}
+	setETag(w, rs.ResourceVersion)
+	writeJSON(w, status, rs)
+}
+
+// replicaSetErrorStatus maps replica set manager errors to HTTP status codes.
+func replicaSetErrorStatus(err error) int {
+	var validationErr models.ValidationError
+	switch {
+	case errors.Is(err, workload.ErrReplicaSetNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, workload.ErrAlreadyExists), errors.Is(err, datastore.ErrConflict):
+		return http.StatusConflict
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// decodeScale reads the replica count of a scale request, answering 400 Bad
+// Request when there is none.
+func decodeScale(w http.ResponseWriter, r *http.Request) (int, bool) {
+	var payload struct {
+		Replicas *int `json:"replicas"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Replicas == nil {
+		http.Error(w, "Invalid request payload: expected {\"replicas\": <count>}", http.StatusBadRequest)
+		return 0, false
+	}
+	return *payload.Replicas, true
+}
+
+// writeJSON encodes v as the response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,97 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// Test the replica set endpoints from creation to deletion.
+func TestReplicaSetEndpoints(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(ds), api.WithReplicaSetManager(workload.NewReplicaSetManager(ds)))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	web := `{"id":"web","replicas":2,"selector":{"matchLabels":{"app":"web"}},"template":{"labels":{"app":"web"},"command":"nginx"}}`
+	w := do("POST", "/replicasets", web)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.ReplicaSet
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	etag := w.Header().Get("ETag")
+	if created.Replicas != 2 || created.Template.RestartPolicy != models.RestartAlways || etag == "" {
+		t.Errorf("expected a replica set of 2 restarting tasks with an ETag, got %+v", created)
+	}
+	if w := do("POST", "/replicasets", web); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 creating web twice, got %d", w.Code)
+	}
+
+	for name, body := range map[string]string{
+		"selector not matching the template": `{"id":"a","replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"b"}}}`,
+		"empty selector":                     `{"id":"a","replicas":1,"template":{"labels":{"app":"a"}}}`,
+		"negative replicas":                  `{"id":"a","replicas":-1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a"}}}`,
+		"restart policy other than Always":   `{"id":"a","replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a"},"restartPolicy":"Never"}}`,
+		"invalid template":                   `{"id":"a","replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a"},"args":["x"]}}`,
+		"unknown selector operator":          `{"id":"a","replicas":1,"selector":{"matchExpressions":[{"key":"app","operator":"Like","values":["a"]}]},"template":{"labels":{"app":"a"}}}`,
+		"In without values":                  `{"id":"a","replicas":1,"selector":{"matchExpressions":[{"key":"app","operator":"In"}]},"template":{"labels":{"app":"a"}}}`,
+		"invalid label":                      `{"id":"a","labels":{"a b":"c"},"replicas":1,"selector":{"matchLabels":{"app":"a"}},"template":{"labels":{"app":"a"}}}`,
+	} {
+		if w := do("POST", "/replicasets", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, w.Code)
+		}
+	}
+
+	w = do("PUT", "/replicasets/web/scale", `{"replicas":5}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 scaling, got %d: %s", w.Code, w.Body.String())
+	}
+	var scaled models.ReplicaSet
+	if err := json.NewDecoder(w.Body).Decode(&scaled); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if scaled.Replicas != 5 {
+		t.Errorf("expected 5 replicas, got %d", scaled.Replicas)
+	}
+	if w := do("PUT", "/replicasets/web/scale", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 scaling without a count, got %d", w.Code)
+	}
+	if w := do("PUT", "/replicasets/missing/scale", `{"replicas":1}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 scaling a missing replica set, got %d", w.Code)
+	}
+
+	// The selector of a replica set is fixed.
+	moved := `{"replicas":2,"selector":{"matchLabels":{"app":"api"}},"template":{"labels":{"app":"api"}}}`
+	if w := do("PUT", "/replicasets/web", moved); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 changing the selector, got %d", w.Code)
+	}
+	req := httptest.NewRequest("PUT", "/replicasets/web", bytes.NewReader([]byte(web)))
+	req.Header.Set("If-Match", etag)
+	w = httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412 updating a stale version, got %d", w.Code)
+	}
+
+	if w := do("DELETE", "/replicasets/web", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 deleting, got %d", w.Code)
+	}
+	if w := do("GET", "/replicasets/web", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after deletion, got %d", w.Code)
+	}
+}
@@ -2,11 +2,20 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/labels"
 	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fntkg/container-orchestrator/pkg/models"
 	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
 	"github.com/gorilla/mux"
 )
 
@@ -15,15 +24,45 @@ type API struct {
 	router      *mux.Router
 	nodeManager node.NodeManager // NodeManager interface
 	taskManager taskmanager.TaskManager
+	replicaSets workload.ReplicaSetManager
+	deployments workload.DeploymentManager
+	daemonSets  workload.DaemonSetManager
+	jobs        workload.JobManager
+	cronJobs    workload.CronJobManager
+
+	heartbeatInterval time.Duration
+	writeTimeout      time.Duration
+	// done is closed by Close to end every open watch stream.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Option configures optional behaviour of the API.
+type Option func(*API)
+
+// WithHeartbeatInterval sets how often idle watch streams send a heartbeat.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(a *API) { a.heartbeatInterval = d }
+}
+
+// WithWriteTimeout sets how long a single write to a watch stream may block.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(a *API) { a.writeTimeout = d }
 }
 
-// NewAPI creates a new API instance with the provided NodeManager and Datastore.
-func NewAPI(nm node.NodeManager, tm taskmanager.TaskManager) *API {
+// NewAPI creates a new API instance with the provided NodeManager and TaskManager.
+func NewAPI(nm node.NodeManager, tm taskmanager.TaskManager, opts ...Option) *API {
 	r := mux.NewRouter().StrictSlash(true)
 	api := &API{
-		router:      r,
-		nodeManager: nm,
-		taskManager: tm,
+		router:            r,
+		nodeManager:       nm,
+		taskManager:       tm,
+		heartbeatInterval: DefaultHeartbeatInterval,
+		writeTimeout:      DefaultWriteTimeout,
+		done:              make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(api)
 	}
 
 	// Health endpoint
@@ -32,11 +71,37 @@ func NewAPI(nm node.NodeManager, tm taskmanager.TaskManager) *API {
 	// Node endpoints
 	r.HandleFunc("/nodes", api.getNodesHandler).Methods("GET")
 	r.HandleFunc("/nodes", api.registerNodeHandler).Methods("POST")
+	r.HandleFunc("/nodes/{id}", api.getNodeHandler).Methods("GET")
 	r.HandleFunc("/nodes/{id}", api.updateNodeHandler).Methods("PUT")
+	r.HandleFunc("/nodes/{id}/heartbeat", api.heartbeatHandler).Methods("POST")
+	r.HandleFunc("/nodes/{id}/taints", api.updateNodeTaintsHandler).Methods("PUT")
+	r.HandleFunc("/nodes/{id}/labels", api.updateNodeLabelsHandler).Methods("PUT")
 
 	// Task endpoints
 	r.HandleFunc("/tasks", api.getTasksHandler).Methods("GET")
 	r.HandleFunc("/tasks", api.registerTaskHandler).Methods("POST")
+	r.HandleFunc("/tasks/{id}", api.getTaskHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}", api.updateTaskHandler).Methods("PUT")
+	r.HandleFunc("/tasks/{id}/status", api.updateTaskStatusHandler).Methods("PUT")
+	r.HandleFunc("/tasks/{id}/logs", api.taskLogsHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/stats", api.taskStatsHandler).Methods("GET")
+
+	// Workload endpoints
+	if api.replicaSets != nil {
+		api.registerReplicaSetRoutes()
+	}
+	if api.deployments != nil {
+		api.registerDeploymentRoutes()
+	}
+	if api.daemonSets != nil {
+		api.registerDaemonSetRoutes()
+	}
+	if api.jobs != nil {
+		api.registerJobRoutes()
+	}
+	if api.cronJobs != nil {
+		api.registerCronJobRoutes()
+	}
 
 	return api
 }
@@ -46,6 +111,13 @@ func (a *API) Router() *mux.Router {
 	return a.router
 }
 
+// Close ends all open watch streams. It is meant to be registered with
+// http.Server.RegisterOnShutdown, since Shutdown alone waits for streaming
+// handlers that would otherwise never return.
+func (a *API) Close() {
+	a.closeOnce.Do(func() { close(a.done) })
+}
+
 // healthHandler returns a simple "OK" to indicate the service is up.
 func (a *API) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -56,11 +128,34 @@ func (a *API) healthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getNodesHandler returns the list of registered nodes.
+// getNodesHandler returns the list of registered nodes, or streams changes to
+// them when called with watch=true. The labelSelector parameter restricts
+// both to the nodes whose labels match it.
 func (a *API) getNodesHandler(w http.ResponseWriter, r *http.Request) {
-	nodes := a.nodeManager.GetNodes()
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		http.Error(w, "Invalid labelSelector: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if isWatch(r) {
+		var match func(datastore.Event) bool
+		if len(selector) > 0 {
+			match = func(ev datastore.Event) bool {
+				var n models.Node
+				return ev.Decode(&n) == nil && selector.Matches(n.Labels)
+			}
+		}
+		a.serveWatch(w, r, a.nodeManager.Watch, match)
+		return
+	}
+	nodes := []models.Node{}
+	for _, n := range a.nodeManager.GetNodes() {
+		if selector.Matches(n.Labels) {
+			nodes = append(nodes, n)
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(nodes)
+	err = json.NewEncoder(w).Encode(nodes)
 	if err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
@@ -74,10 +169,18 @@ func (a *API) registerNodeHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	if err := models.ValidateNode(n); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err := a.nodeManager.Register(n); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if stored, err := a.nodeManager.GetNode(n.ID); err == nil {
+		n = *stored
+		setETag(w, n.ResourceVersion)
+	}
 	w.WriteHeader(http.StatusCreated)
 	err := json.NewEncoder(w).Encode(n)
 	if err != nil {
@@ -86,7 +189,24 @@ func (a *API) registerNodeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// updateNodeHandler updates the health status of an existing node.
+// getNodeHandler returns a single node, with its resource version as ETag.
+func (a *API) getNodeHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := a.nodeManager.GetNode(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), nodeErrorStatus(err))
+		return
+	}
+	setETag(w, n.ResourceVersion)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(n)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// updateNodeHandler updates the health status of an existing node. With an
+// If-Match header the update only applies to that version of the node.
 func (a *API) updateNodeHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -99,26 +219,156 @@ func (a *API) updateNodeHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	version, conditional, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if err := a.nodeManager.UpdateHealth(id, payload.Healthy); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if conditional {
+		var n *models.Node
+		n, err = a.nodeManager.GetNode(id)
+		if err == nil {
+			n.Healthy = payload.Healthy
+			n.ResourceVersion = version
+			err = a.nodeManager.UpdateNode(*n)
+		}
+	} else {
+		err = a.nodeManager.UpdateHealth(id, payload.Healthy)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), conflictStatus(err, conditional, nodeErrorStatus))
 		return
 	}
+	if updated, err := a.nodeManager.GetNode(id); err == nil {
+		setETag(w, updated.ResourceVersion)
+	}
 	w.WriteHeader(http.StatusOK)
-	err := json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+	err = json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// heartbeatHandler renews the lease of a node and returns the updated node.
+func (a *API) heartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := a.nodeManager.Heartbeat(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), nodeErrorStatus(err))
+		return
+	}
+	setETag(w, n.ResourceVersion)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(n)
 	if err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-// getTasksHandler returns the list of registered tasks.
+// updateNodeTaintsHandler replaces the taints of a node and returns the
+// updated node. The body is {"taints": [...]}; an empty list removes them.
+func (a *API) updateNodeTaintsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var payload struct {
+		Taints *[]models.Taint `json:"taints"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Taints == nil {
+		http.Error(w, "Invalid request payload: expected {\"taints\": [...]}", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateNode(models.Node{ID: id, Taints: *payload.Taints}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := a.nodeManager.UpdateTaints(id, *payload.Taints)
+	if err != nil {
+		http.Error(w, err.Error(), nodeErrorStatus(err))
+		return
+	}
+	setETag(w, n.ResourceVersion)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(n)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// updateNodeLabelsHandler replaces the labels and annotations of a node and
+// returns the updated node. The body is {"labels": {...}, "annotations":
+// {...}}; either may be left out to keep the current ones, and an empty
+// object removes them.
+func (a *API) updateNodeLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var payload struct {
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Labels == nil && payload.Annotations == nil {
+		http.Error(w, "Invalid request payload: expected {\"labels\": {...}, \"annotations\": {...}}", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateNode(models.Node{ID: id, Labels: payload.Labels, Annotations: payload.Annotations}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := a.nodeManager.UpdateLabels(id, payload.Labels, payload.Annotations)
+	if err != nil {
+		http.Error(w, err.Error(), nodeErrorStatus(err))
+		return
+	}
+	setETag(w, n.ResourceVersion)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(n)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getTasksHandler returns the list of registered tasks, or streams changes to
+// them when called with watch=true. The nodeId parameter restricts both to
+// the tasks bound to one node, and labelSelector to the tasks whose labels
+// match it.
 func (a *API) getTasksHandler(w http.ResponseWriter, r *http.Request) {
-	tasks, err := a.taskManager.GetTasks()
+	nodeID := r.URL.Query().Get("nodeId")
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		http.Error(w, "Invalid labelSelector: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	wanted := func(t models.Task) bool {
+		return (nodeID == "" || t.NodeID == nodeID) && selector.Matches(t.Labels)
+	}
+	if isWatch(r) {
+		var match func(datastore.Event) bool
+		if nodeID != "" || len(selector) > 0 {
+			match = func(ev datastore.Event) bool {
+				var t models.Task
+				return ev.Decode(&t) == nil && wanted(t)
+			}
+		}
+		a.serveWatch(w, r, a.taskManager.Watch, match)
+		return
+	}
+	tasks, revision, err := a.taskManager.ListTasks()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// Watching from this revision picks up right after the list.
+	w.Header().Set("X-Resource-Version", strconv.FormatUint(revision, 10))
+	if nodeID != "" || len(selector) > 0 {
+		matching := []models.Task{}
+		for _, t := range tasks {
+			if wanted(t) {
+				matching = append(matching, t)
+			}
+		}
+		tasks = matching
+	}
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(tasks)
 	if err != nil {
@@ -127,17 +377,31 @@ func (a *API) getTasksHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// registerTaskHandler registers a new task.
+// registerTaskHandler registers a new task. The node a task runs on is
+// chosen by the scheduler and its owner is set by the controllers, so
+// neither may be given.
 func (a *API) registerTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var t models.Task
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	if t.NodeID != "" || t.Owner != nil {
+		http.Error(w, "nodeId and owner cannot be set when creating a task", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateTask(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err := a.taskManager.CreateTask(t); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), taskErrorStatus(err))
 		return
 	}
+	if stored, err := a.taskManager.GetTask(t.ID); err == nil {
+		t = *stored
+		setETag(w, t.ResourceVersion)
+	}
 	w.WriteHeader(http.StatusCreated)
 	err := json.NewEncoder(w).Encode(t)
 	if err != nil {
@@ -145,3 +409,209 @@ func (a *API) registerTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// getTaskHandler returns a single task, with its resource version as ETag.
+func (a *API) getTaskHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := a.taskManager.GetTask(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), taskErrorStatus(err))
+		return
+	}
+	setETag(w, task.ResourceVersion)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(task)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// updateTaskHandler replaces the spec of an existing task, and cancels it
+// when its status is set to cancelled. The rest of the task belongs to the
+// controllers and to its node agent: a nodeId or owner other than the
+// stored one, and any other status change, are answered with 400 Bad
+// Request, and what the agent reported is kept. Illegal transitions are
+// answered with 409 Conflict. The update is conditional on the version
+// given in an If-Match header, or else on the resourceVersion in the body
+// when it is set.
+func (a *API) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var t models.Task
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	t.ID = mux.Vars(r)["id"]
+	if err := models.ValidateTask(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.applyTaskUpdate(w, r, t, func(t, stored models.Task) (models.Task, error) {
+		if t.NodeID != "" && t.NodeID != stored.NodeID || t.Owner != nil && (stored.Owner == nil || *t.Owner != *stored.Owner) {
+			return t, fmt.Errorf("%w: nodeId and owner are set by the controllers", errReadOnlyField)
+		}
+		if t.Status == "" {
+			t.Status = stored.Status
+		}
+		cancelling := t.Status != stored.Status
+		if cancelling && t.Status != models.TaskCancelled {
+			return t, fmt.Errorf("%w: the status can only be set to %q; node agents report it through /tasks/{id}/status", errReadOnlyField, models.TaskCancelled)
+		}
+		t.NodeID, t.Owner = stored.NodeID, stored.Owner
+		t.ExitCode, t.Conditions = stored.ExitCode, stored.Conditions
+		t.RestartCount, t.LastTerminationReason = stored.RestartCount, stored.LastTerminationReason
+		if !cancelling {
+			t.Reason, t.Message = stored.Reason, stored.Message
+		}
+		return t, nil
+	})
+}
+
+// updateTaskStatusHandler records what the node agent of a task observed:
+// its status, reason, message, exit code, conditions, restart count and
+// last termination reason. The rest of the task is kept. Only the
+// controllers bind tasks to nodes, so the status may not be moved to
+// pending or scheduled.
+func (a *API) updateTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
+	var t models.Task
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	t.ID = mux.Vars(r)["id"]
+	a.applyTaskUpdate(w, r, t, func(t, stored models.Task) (models.Task, error) {
+		if t.Status != stored.Status && (t.Status == models.TaskPending || t.Status == models.TaskScheduled) {
+			return t, fmt.Errorf("%w: tasks are only moved to %q by the controllers", errReadOnlyField, t.Status)
+		}
+		updated := stored
+		updated.Status, updated.Reason, updated.Message = t.Status, t.Reason, t.Message
+		updated.ExitCode, updated.Conditions = t.ExitCode, t.Conditions
+		updated.RestartCount, updated.LastTerminationReason = t.RestartCount, t.LastTerminationReason
+		updated.ResourceVersion = t.ResourceVersion
+		return updated, nil
+	})
+}
+
+// errReadOnlyField is returned for updates that change a part of a task
+// the endpoint used does not own.
+var errReadOnlyField = errors.New("field cannot be changed here")
+
+// maxUpdateAttempts bounds how often an update merged with the stored task
+// is retried after losing a race with another writer.
+const maxUpdateAttempts = 5
+
+// applyTaskUpdate merges an update into the stored task and saves the
+// result, which is written back. The write is conditional on the version
+// the update was merged with, so that nothing written in between is lost;
+// unless the client asked for a given version, through If-Match or the
+// resourceVersion in the body, a lost race is retried with the latest task.
+func (a *API) applyTaskUpdate(w http.ResponseWriter, r *http.Request, update models.Task, merge func(update, stored models.Task) (models.Task, error)) {
+	version, ifMatch, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ifMatch {
+		update.ResourceVersion = version
+	}
+	conditional := update.ResourceVersion != 0
+	for attempt := 0; ; attempt++ {
+		stored, err := a.taskManager.GetTask(update.ID)
+		if err != nil {
+			http.Error(w, err.Error(), taskErrorStatus(err))
+			return
+		}
+		merged, err := merge(update, *stored)
+		if err != nil {
+			http.Error(w, err.Error(), taskErrorStatus(err))
+			return
+		}
+		merged.ResourceVersion = update.ResourceVersion
+		if !conditional {
+			merged.ResourceVersion = stored.ResourceVersion
+		}
+		err = a.taskManager.UpdateTask(merged)
+		if err == nil {
+			break
+		}
+		if conditional || !errors.Is(err, datastore.ErrConflict) || attempt+1 >= maxUpdateAttempts {
+			http.Error(w, err.Error(), conflictStatus(err, ifMatch, taskErrorStatus))
+			return
+		}
+	}
+
+	updated, err := a.taskManager.GetTask(update.ID)
+	if err != nil {
+		http.Error(w, err.Error(), taskErrorStatus(err))
+		return
+	}
+	setETag(w, updated.ResourceVersion)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(updated)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// taskErrorStatus maps task manager errors to HTTP status codes.
+func taskErrorStatus(err error) int {
+	var transitionErr *taskmanager.TransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		return http.StatusConflict
+	case errors.Is(err, taskmanager.ErrTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, taskmanager.ErrTaskExists):
+		return http.StatusConflict
+	case errors.Is(err, taskmanager.ErrInvalidPhase):
+		return http.StatusBadRequest
+	case errors.Is(err, errReadOnlyField):
+		return http.StatusBadRequest
+	case errors.Is(err, datastore.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// nodeErrorStatus maps node manager errors to HTTP status codes.
+func nodeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, node.ErrNodeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, datastore.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// conflictStatus reports a version conflict on a request carrying If-Match
+// as 412 Precondition Failed, and defers to fallback for everything else.
+func conflictStatus(err error, ifMatch bool, fallback func(error) int) int {
+	if ifMatch && errors.Is(err, datastore.ErrConflict) {
+		return http.StatusPreconditionFailed
+	}
+	return fallback(err)
+}
+
+// setETag exposes a resource version as a strong entity tag.
+func setETag(w http.ResponseWriter, version uint64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatUint(version, 10)))
+}
+
+// ifMatchVersion returns the resource version named by the If-Match header.
+// The second result is false when the header is absent or "*".
+func ifMatchVersion(r *http.Request) (uint64, bool, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+	tag, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid If-Match header %q", header)
+	}
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if err != nil || version == 0 {
+		return 0, false, fmt.Errorf("invalid If-Match header %q: expected a resource version", header)
+	}
+	return version, true, nil
+}
@@ -8,11 +8,16 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/fntkg/container-orchestrator/pkg/api"
 	"github.com/fntkg/container-orchestrator/pkg/datastore"
 	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
 )
 
 // FakeNodeManager implements the node.NodeManager interface for testing purposes.
@@ -30,6 +35,64 @@ func (fnm *FakeNodeManager) GetNodes() []models.Node {
 	return fnm.nodes
 }
 
+func (fnm *FakeNodeManager) GetNode(id string) (*models.Node, error) {
+	for _, n := range fnm.nodes {
+		if n.ID == id {
+			return &n, nil
+		}
+	}
+	return nil, fmt.Errorf("node not found")
+}
+
+func (fnm *FakeNodeManager) UpdateNode(n models.Node) error {
+	for i := range fnm.nodes {
+		if fnm.nodes[i].ID == n.ID {
+			fnm.nodes[i] = n
+			return nil
+		}
+	}
+	return fmt.Errorf("node not found")
+}
+
+func (fnm *FakeNodeManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return nil, fmt.Errorf("watch not supported")
+}
+
+func (fnm *FakeNodeManager) UpdateTaints(id string, taints []models.Taint) (*models.Node, error) {
+	for i := range fnm.nodes {
+		if fnm.nodes[i].ID == id {
+			fnm.nodes[i].Taints = taints
+			return &fnm.nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("node not found")
+}
+
+func (fnm *FakeNodeManager) UpdateLabels(id string, labels, annotations map[string]string) (*models.Node, error) {
+	for i := range fnm.nodes {
+		if fnm.nodes[i].ID == id {
+			if labels != nil {
+				fnm.nodes[i].Labels = labels
+			}
+			if annotations != nil {
+				fnm.nodes[i].Annotations = annotations
+			}
+			return &fnm.nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("node not found")
+}
+
+func (fnm *FakeNodeManager) Heartbeat(id string) (*models.Node, error) {
+	for i := range fnm.nodes {
+		if fnm.nodes[i].ID == id {
+			fnm.nodes[i].Healthy = true
+			return &fnm.nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("node not found")
+}
+
 func (fnm *FakeNodeManager) UpdateHealth(id string, healthy bool) error {
 	for i, n := range fnm.nodes {
 		if n.ID == id {
@@ -44,7 +107,7 @@ func (fnm *FakeNodeManager) UpdateHealth(id string, healthy bool) error {
 func TestHealthEndpoint(t *testing.T) {
 	fnm := &FakeNodeManager{}
 	ds := datastore.NewInMemoryDatastore()
-	apiInstance := api.NewAPI(fnm, ds)
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(ds))
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -77,7 +140,7 @@ func TestGetNodesEndpoint(t *testing.T) {
 		},
 	}
 	ds := datastore.NewInMemoryDatastore()
-	apiInstance := api.NewAPI(fnm, ds)
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(ds))
 
 	req := httptest.NewRequest("GET", "/nodes", nil)
 	w := httptest.NewRecorder()
@@ -108,7 +171,7 @@ func TestGetNodesEndpoint(t *testing.T) {
 func TestRegisterNodeEndpoint(t *testing.T) {
 	fnm := &FakeNodeManager{}
 	ds := datastore.NewInMemoryDatastore()
-	apiInstance := api.NewAPI(fnm, ds)
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(ds))
 
 	newNode := models.Node{ID: "node-3", Healthy: true}
 	bodyBytes, _ := json.Marshal(newNode)
@@ -146,7 +209,7 @@ func TestUpdateNodeEndpoint(t *testing.T) {
 		},
 	}
 	ds := datastore.NewInMemoryDatastore()
-	apiInstance := api.NewAPI(fnm, ds)
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(ds))
 
 	payload := map[string]bool{"healthy": false}
 	payloadBytes, _ := json.Marshal(payload)
@@ -197,7 +260,7 @@ func TestGetTasksEndpoint(t *testing.T) {
 	}
 
 	fnm := &FakeNodeManager{}
-	apiInstance := api.NewAPI(fnm, ds)
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(ds))
 
 	req := httptest.NewRequest("GET", "/tasks", nil)
 	w := httptest.NewRecorder()
@@ -228,7 +291,7 @@ func TestGetTasksEndpoint(t *testing.T) {
 func TestRegisterTaskEndpoint(t *testing.T) {
 	ds := datastore.NewInMemoryDatastore()
 	fnm := &FakeNodeManager{}
-	apiInstance := api.NewAPI(fnm, ds)
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(ds))
 
 	newTask := models.Task{ID: "task-3"}
 	bodyBytes, _ := json.Marshal(newTask)
@@ -257,3 +320,591 @@ func TestRegisterTaskEndpoint(t *testing.T) {
 		t.Errorf("expected task ID 'task-3', got '%s'", taskResp.ID)
 	}
 }
+
+func TestRegisterTaskEndpoint_ExistingID(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(ds))
+
+	for i, want := range []int{http.StatusCreated, http.StatusConflict} {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(`{"id":"task-3"}`))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("request %d: expected status %d, got %d", i+1, want, w.Code)
+		}
+	}
+}
+
+// Test that POST /tasks leaves the node and owner of a task to the scheduler
+// and the controllers.
+func TestRegisterTaskEndpoint_RejectsNodeAndOwner(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(ds))
+
+	for _, payload := range []string{
+		`{"id":"task-3","command":"true","nodeId":"node-1"}`,
+		`{"id":"task-3","command":"true","owner":{"kind":"DaemonSet","id":"agents"}}`,
+	} {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(payload))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", payload, w.Code)
+		}
+	}
+	if tasks, _ := ds.GetTasks(); len(tasks) != 0 {
+		t.Errorf("expected no task to be created")
+	}
+}
+
+// Test that IDs, which name log files and cgroups on nodes, are restricted to
+// DNS labels, or host names for nodes.
+func TestRegisterEndpoints_InvalidIDs(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	apiInstance := api.NewAPI(node.NewManager(ds), taskmanager.NewTaskManager(ds))
+	post := func(path, body string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	long := strings.Repeat("a", models.MaxIDLength+1)
+	for _, id := range []string{"a/x", "..", ".", "Task-1", "task_1", "-task", "task-", "a.b", long} {
+		if code := post("/tasks", `{"id":"`+id+`","command":"true"}`); code != http.StatusBadRequest {
+			t.Errorf("task %q: expected status 400, got %d", id, code)
+		}
+		if id == "a.b" || id == long {
+			continue
+		}
+		if code := post("/nodes", `{"id":"`+id+`","healthy":true}`); code != http.StatusBadRequest {
+			t.Errorf("node %q: expected status 400, got %d", id, code)
+		}
+	}
+	if code := post("/nodes", `{"id":"node-1.example.com","healthy":true}`); code != http.StatusCreated {
+		t.Errorf("expected a host name to be accepted as a node ID, got %d", code)
+	}
+}
+
+// Test that POST /tasks rejects requests larger than limits and bad quantities.
+func TestRegisterTaskEndpoint_InvalidResources(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	fnm := &FakeNodeManager{}
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(ds))
+
+	payloads := []string{
+		`{"id":"task-4","resources":{"requests":{"cpu":"2"},"limits":{"cpu":"1"}}}`,
+		`{"id":"task-5","resources":{"requests":{"memory":"lots"}}}`,
+		`{"id":"task-6","resources":{"requests":{"gpu":"1"}}}`,
+		`{"resources":{"requests":{"cpu":"1"}}}`,
+		`{"id":"task-7","args":["-c","true"]}`,
+		`{"id":"task-8","command":"env","env":[{"name":"A=B","value":"c"}]}`,
+		`{"id":"task-9","command":"true","restartPolicy":"Sometimes"}`,
+		`{"id":"task-10","command":"true","maxRetries":3}`,
+		`{"id":"task-11","command":"true","restartPolicy":"OnFailure","maxRetries":-1}`,
+		`{"id":"task-12","command":"true","livenessProbe":{"periodSeconds":5}}`,
+		`{"id":"task-13","command":"true","readinessProbe":{"tcpSocket":{"port":80},"httpGet":{"port":80}}}`,
+		`{"id":"task-14","command":"true","readinessProbe":{"httpGet":{"port":70000}}}`,
+		`{"id":"task-15","command":"true","livenessProbe":{"exec":{"command":["true"]},"successThreshold":2}}`,
+		`{"id":"task-16","command":"true","readinessProbe":{"exec":{"command":[]}}}`,
+		`{"id":"task-17","command":"true","readinessProbe":{"tcpSocket":{"port":80},"timeoutSeconds":-1}}`,
+		`{"id":"task-18","command":"true","labels":{"-app":"web"}}`,
+		`{"id":"task-19","command":"true","labels":{"app":"web server"}}`,
+		`{"id":"task-20","command":"true","annotations":{"Example.com/owner":"ops"}}`,
+		`{"id":"task-21","command":"true","nodeSelector":{"disk":"ssd!"}}`,
+		`{"id":"task-22","command":"true","affinity":{"nodeAffinity":{"required":[{}]}}}`,
+		`{"id":"task-23","command":"true","affinity":{"nodeAffinity":{"preferred":[{"weight":0,"preference":{"matchLabels":{"disk":"ssd"}}}]}}}`,
+		`{"id":"task-24","command":"true","affinity":{"nodeAffinity":{"required":[{"matchExpressions":[{"key":"disk","operator":"Gt","values":["1"]}]}]}}}`,
+		`{"id":"task-25","command":"true","tolerations":[{"operator":"Equal","value":"a"}]}`,
+		`{"id":"task-26","command":"true","tolerations":[{"key":"a","operator":"Exists","value":"b"}]}`,
+		`{"id":"task-27","command":"true","tolerations":[{"key":"a","effect":"NoSchedule","tolerationSeconds":30}]}`,
+		`{"id":"task-28","command":"true","tolerations":[{"key":"a","effect":"Sometimes"}]}`,
+		`{"id":"task-29","command":"true","affinity":{"taskAffinity":{"required":[{"labelSelector":{},"topologyKey":"zone"}]}}}`,
+		`{"id":"task-30","command":"true","affinity":{"taskAntiAffinity":{"required":[{"labelSelector":{"matchLabels":{"app":"web"}}}]}}}`,
+		`{"id":"task-31","command":"true","affinity":{"taskAffinity":{"preferred":[{"weight":101,"term":{"labelSelector":{"matchLabels":{"app":"web"}},"topologyKey":"node"}}]}}}`,
+		`{"id":"task-32","command":"true","topologySpreadConstraints":[{"maxSkew":0,"topologyKey":"zone","labelSelector":{"matchLabels":{"app":"web"}}}]}`,
+		`{"id":"task-33","command":"true","topologySpreadConstraints":[{"maxSkew":1,"topologyKey":"zone","whenUnsatisfiable":"Sometimes","labelSelector":{"matchLabels":{"app":"web"}}}]}`,
+		`{"id":"task-34","command":"true","topologySpreadConstraints":[{"maxSkew":1,"topologyKey":"bad key!","labelSelector":{"matchLabels":{"app":"web"}}}]}`,
+	}
+	for _, payload := range payloads {
+		req := httptest.NewRequest("POST", "/tasks", bytes.NewReader([]byte(payload)))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("payload %s: expected status 400, got %d", payload, w.Code)
+		}
+	}
+
+	tasks, _ := ds.GetTasks()
+	if len(tasks) != 0 {
+		t.Errorf("expected no tasks to be stored, got %d", len(tasks))
+	}
+}
+
+// Test that POST /nodes stores capacity and rejects allocatable above capacity.
+func TestRegisterNodeEndpoint_Resources(t *testing.T) {
+	fnm := &FakeNodeManager{}
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(datastore.NewInMemoryDatastore()))
+
+	valid := `{"id":"node-4","healthy":true,"capacity":{"cpu":"4","memory":"8Gi"},"allocatable":{"cpu":"3500m","memory":"7Gi"}}`
+	req := httptest.NewRequest("POST", "/nodes", bytes.NewReader([]byte(valid)))
+	w := httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(fnm.nodes) != 1 || fnm.nodes[0].Allocatable[resource.CPU].MilliValue() != 3500 {
+		t.Errorf("expected node with 3500m allocatable cpu, got %+v", fnm.nodes)
+	}
+
+	invalid := `{"id":"node-5","capacity":{"cpu":"2"},"allocatable":{"cpu":"4"}}`
+	req = httptest.NewRequest("POST", "/nodes", bytes.NewReader([]byte(invalid)))
+	w = httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+// Test that a node address must be a URL the API server can proxy to.
+func TestRegisterNodeEndpoint_Address(t *testing.T) {
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(datastore.NewInMemoryDatastore()))
+	for address, want := range map[string]int{
+		"http://10.0.0.4:10250": http.StatusCreated,
+		"10.0.0.4:10250":        http.StatusBadRequest,
+		"ftp://10.0.0.4":        http.StatusBadRequest,
+		"http://":               http.StatusBadRequest,
+	} {
+		body := `{"id":"node-1","address":"` + address + `"}`
+		req := httptest.NewRequest("POST", "/nodes", bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", address, want, w.Code)
+		}
+	}
+}
+
+// Test that PUT /tasks/{id}/status enforces the task lifecycle.
+func TestUpdateTaskEndpoint_Transitions(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	if err := tm.CreateTask(models.Task{ID: "task-1"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	if err := tm.UpdateTask(models.Task{ID: "task-1", Status: models.TaskScheduled, NodeID: "node-1"}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+	apiInstance := api.NewAPI(&FakeNodeManager{}, tm)
+
+	put := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/tasks/"+id+"/status", bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	if w := put("task-1", `{"status":"succeeded"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for scheduled -> succeeded, got %d", w.Code)
+	}
+	if w := put("task-1", `{"status":"bogus"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown phase, got %d", w.Code)
+	}
+
+	w := put("task-1", `{"status":"running"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var updated models.Task
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if updated.Status != models.TaskRunning || len(updated.Transitions) != 3 {
+		t.Errorf("expected running task with 3 transitions, got %+v", updated)
+	}
+	if w := put("missing", `{"status":"running"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown task, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/tasks/task-1", nil)
+	rec := httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(rec, req)
+	var fetched models.Task
+	if err := json.NewDecoder(rec.Body).Decode(&fetched); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if fetched.Status != models.TaskRunning {
+		t.Errorf("expected task to remain running, got %s", fetched.Status)
+	}
+}
+
+// Test that the node and owner of a task, and what its agent reports, can
+// only be changed by those they belong to.
+func TestUpdateTaskEndpoints_KeepFieldsTheyDoNotOwn(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	owner := &models.OwnerReference{Kind: "ReplicaSet", ID: "web"}
+	for _, task := range []models.Task{{ID: "task-1", Owner: owner}, {ID: "pending"}} {
+		if err := tm.CreateTask(task); err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+	if err := tm.UpdateTask(models.Task{ID: "task-1", Status: models.TaskScheduled, NodeID: "node-1", Owner: owner}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+	apiInstance := api.NewAPI(&FakeNodeManager{}, tm)
+	put := func(path, body string) int {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, tc := range []struct{ path, body string }{
+		{"/tasks/task-1", `{"status":"scheduled","nodeId":"node-2"}`},
+		{"/tasks/pending", `{"status":"scheduled","nodeId":"node-2"}`},
+		{"/tasks/task-1", `{"owner":{"kind":"ReplicaSet","id":"other"}}`},
+		{"/tasks/task-1", `{"status":"running"}`},
+		{"/tasks/task-1/status", `{"status":"pending"}`},
+		{"/tasks/pending/status", `{"status":"scheduled"}`},
+	} {
+		if code := put(tc.path, tc.body); code != http.StatusBadRequest {
+			t.Errorf("PUT %s %s: expected status 400, got %d", tc.path, tc.body, code)
+		}
+	}
+
+	if code := put("/tasks/task-1", `{"labels":{"app":"web"},"restartCount":5,"exitCode":1,"reason":"Evicted"}`); code != http.StatusOK {
+		t.Fatalf("expected the spec update to succeed, got %d", code)
+	}
+	task, _ := tm.GetTask("task-1")
+	if task.Labels["app"] != "web" || task.NodeID != "node-1" || task.Owner == nil || *task.Owner != *owner ||
+		task.Status != models.TaskScheduled || task.RestartCount != 0 || task.ExitCode != nil || task.Reason != "" {
+		t.Errorf("expected only the labels to change, got %+v", task)
+	}
+
+	if code := put("/tasks/task-1/status", `{"status":"running","nodeId":"node-2","command":"evil"}`); code != http.StatusOK {
+		t.Fatalf("expected the status update to succeed, got %d", code)
+	}
+	task, _ = tm.GetTask("task-1")
+	if task.Status != models.TaskRunning || task.NodeID != "node-1" || task.Command != "" || task.Labels["app"] != "web" {
+		t.Errorf("expected only the status to change, got %+v", task)
+	}
+
+	if code := put("/tasks/task-1", `{"status":"cancelled","reason":"NoLongerNeeded"}`); code != http.StatusOK {
+		t.Fatalf("expected the task to be cancelled, got %d", code)
+	}
+	task, _ = tm.GetTask("task-1")
+	if task.Status != models.TaskCancelled || task.Reason != "NoLongerNeeded" {
+		t.Errorf("expected the task to be cancelled, got %+v", task)
+	}
+}
+
+// Test that ETag and If-Match give clients safe read-modify-write updates.
+func TestTaskEndpoints_ETagAndIfMatch(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	apiInstance := api.NewAPI(node.NewManager(ds), tm)
+
+	do := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	created := do("POST", "/tasks", `{"id":"task-1"}`, "")
+	etag := created.Header().Get("ETag")
+	if created.Code != http.StatusCreated || etag == "" {
+		t.Fatalf("expected 201 with ETag, got %d %q", created.Code, etag)
+	}
+	if got := do("GET", "/tasks/task-1", "", "").Header().Get("ETag"); got != etag {
+		t.Errorf("expected GET to return ETag %s, got %s", etag, got)
+	}
+
+	updated := do("PUT", "/tasks/task-1", `{"labels":{"app":"web"}}`, etag)
+	if updated.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", updated.Code, updated.Body.String())
+	}
+	newETag := updated.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("expected a new ETag after update, got %q", newETag)
+	}
+
+	// Reusing the old ETag loses the race.
+	if w := do("PUT", "/tasks/task-1", `{"status":"cancelled"}`, etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for stale If-Match, got %d", w.Code)
+	}
+	// A stale resourceVersion in the body is a conflict.
+	var stale models.Task
+	_ = json.NewDecoder(created.Body).Decode(&stale)
+	stale.Status = models.TaskCancelled
+	staleBody, _ := json.Marshal(stale)
+	if w := do("PUT", "/tasks/task-1", string(staleBody), ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for stale resourceVersion, got %d", w.Code)
+	}
+	if w := do("PUT", "/tasks/task-1", `{"status":"cancelled"}`, "not-a-tag"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for malformed If-Match, got %d", w.Code)
+	}
+}
+
+// Test conditional node health updates.
+func TestNodeEndpoints_ETagAndIfMatch(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	apiInstance := api.NewAPI(node.NewManager(ds), taskmanager.NewTaskManager(ds))
+
+	req := httptest.NewRequest("POST", "/nodes", bytes.NewReader([]byte(`{"id":"node-1","healthy":true}`)))
+	w := httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusCreated || etag == "" {
+		t.Fatalf("expected 201 with ETag, got %d %q", w.Code, etag)
+	}
+
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/nodes/node-1", bytes.NewReader([]byte(`{"healthy":false}`)))
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+	first := put(etag)
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", first.Code, first.Body.String())
+	}
+	if second := put(etag); second.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for stale If-Match, got %d", second.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/nodes/node-1", nil)
+	w = httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(w, req)
+	if w.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Errorf("expected GET ETag %s, got %s", first.Header().Get("ETag"), w.Header().Get("ETag"))
+	}
+	req = httptest.NewRequest("GET", "/nodes/missing", nil)
+	w = httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown node, got %d", w.Code)
+	}
+}
+
+// Test the /nodes/{id}/heartbeat POST endpoint.
+func TestHeartbeatEndpoint(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	nm := node.NewManager(ds)
+	if err := nm.Register(models.Node{ID: "node-1", Healthy: false}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+	apiInstance := api.NewAPI(nm, taskmanager.NewTaskManager(ds))
+
+	req := httptest.NewRequest("POST", "/nodes/node-1/heartbeat", nil)
+	w := httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var n models.Node
+	if err := json.NewDecoder(w.Body).Decode(&n); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if !n.Healthy || n.Condition != models.NodeReady || n.LastHeartbeatTime.IsZero() {
+		t.Errorf("expected heartbeat to mark node Ready with a timestamp, got %+v", n)
+	}
+
+	req = httptest.NewRequest("POST", "/nodes/missing/heartbeat", nil)
+	w = httptest.NewRecorder()
+	apiInstance.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown node, got %d", w.Code)
+	}
+}
+
+// Test that GET /tasks?nodeId= only returns the tasks bound to that node.
+func TestGetTasksEndpoint_NodeFilter(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	for _, task := range []models.Task{
+		{ID: "task-1", Status: models.TaskScheduled, NodeID: "node-1"},
+		{ID: "task-2", Status: models.TaskScheduled, NodeID: "node-2"},
+		{ID: "task-3", Status: models.TaskPending},
+	} {
+		if err := ds.SaveTask(task); err != nil {
+			t.Fatalf("error saving task: %v", err)
+		}
+	}
+	apiInstance := api.NewAPI(&FakeNodeManager{}, taskmanager.NewTaskManager(ds))
+
+	for nodeID, want := range map[string]int{"node-1": 1, "node-3": 0} {
+		req := httptest.NewRequest("GET", "/tasks?nodeId="+nodeID, nil)
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+
+		var tasksResp []models.Task
+		if err := json.NewDecoder(w.Body).Decode(&tasksResp); err != nil {
+			t.Fatalf("error decoding tasks: %v", err)
+		}
+		if len(tasksResp) != want {
+			t.Errorf("%s: expected %d tasks, got %+v", nodeID, want, tasksResp)
+		}
+		for _, task := range tasksResp {
+			if task.NodeID != nodeID {
+				t.Errorf("%s: got task %s bound to %q", nodeID, task.ID, task.NodeID)
+			}
+		}
+	}
+}
+
+// Test that GET /nodes?labelSelector= and GET /tasks?labelSelector= only
+// return the objects whose labels match, and reject malformed selectors.
+func TestListEndpoints_LabelSelector(t *testing.T) {
+	fnm := &FakeNodeManager{
+		nodes: []models.Node{
+			{ID: "node-1", Labels: map[string]string{"zone": "a", "disk": "ssd"}},
+			{ID: "node-2", Labels: map[string]string{"zone": "b"}},
+			{ID: "node-3"},
+		},
+	}
+	ds := datastore.NewInMemoryDatastore()
+	for _, task := range []models.Task{
+		{ID: "task-1", Status: models.TaskScheduled, NodeID: "node-1", Labels: map[string]string{"app": "web", "env": "prod"}},
+		{ID: "task-2", Status: models.TaskScheduled, NodeID: "node-2", Labels: map[string]string{"app": "web", "env": "dev"}},
+		{ID: "task-3", Status: models.TaskPending, Labels: map[string]string{"app": "db"}},
+	} {
+		if err := ds.SaveTask(task); err != nil {
+			t.Fatalf("error saving task: %v", err)
+		}
+	}
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(ds))
+
+	ids := func(path string) []string {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", path, w.Code, w.Body.String())
+		}
+		var objs []struct{ ID string }
+		if err := json.NewDecoder(w.Body).Decode(&objs); err != nil {
+			t.Fatalf("%s: error decoding response: %v", path, err)
+		}
+		var out []string
+		for _, o := range objs {
+			out = append(out, o.ID)
+		}
+		sort.Strings(out)
+		return out
+	}
+	for path, want := range map[string]string{
+		"/nodes?labelSelector=zone%3Da":                 "node-1",
+		"/nodes?labelSelector=zone":                     "node-1,node-2",
+		"/nodes?labelSelector=%21zone":                  "node-3",
+		"/nodes?labelSelector=zone+notin+%28a%29":       "node-2,node-3",
+		"/tasks?labelSelector=app%3Dweb%2Cenv%21%3Ddev": "task-1",
+		"/tasks?labelSelector=app+in+%28web%2Cdb%29":    "task-1,task-2,task-3",
+		"/tasks?labelSelector=app%3Dweb&nodeId=node-2":  "task-2",
+		"/tasks?labelSelector=app%3Ddb&nodeId=node-2":   "",
+	} {
+		if got := strings.Join(ids(path), ","); got != want {
+			t.Errorf("%s: expected [%s], got [%s]", path, want, got)
+		}
+	}
+
+	for _, path := range []string{"/nodes?labelSelector=zone+in+%28a", "/tasks?labelSelector=%3Dweb"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, w.Code)
+		}
+	}
+}
+
+// Test that PUT /nodes/{id}/taints replaces the taints of a node.
+func TestUpdateNodeTaintsEndpoint(t *testing.T) {
+	fnm := &FakeNodeManager{nodes: []models.Node{{ID: "node-1", Healthy: true}}}
+	apiInstance := api.NewAPI(fnm, taskmanager.NewTaskManager(datastore.NewInMemoryDatastore()))
+	put := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/nodes/"+id+"/taints", bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := put("node-1", `{"taints":[{"key":"dedicated","value":"team-a","effect":"NoSchedule"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var n models.Node
+	if err := json.NewDecoder(w.Body).Decode(&n); err != nil {
+		t.Fatalf("error decoding node: %v", err)
+	}
+	if len(n.Taints) != 1 || n.Taints[0].String() != "dedicated=team-a:NoSchedule" {
+		t.Errorf("expected the taint to be set, got %+v", n.Taints)
+	}
+
+	for body, want := range map[string]int{
+		`{}`: http.StatusBadRequest,
+		`{"taints":[{"key":"a","effect":"Never"}]}`:                                        http.StatusBadRequest,
+		`{"taints":[{"key":"a","effect":"NoSchedule"},{"key":"a","effect":"NoSchedule"}]}`: http.StatusBadRequest,
+		`{"taints":[]}`: http.StatusOK,
+	} {
+		if w := put("node-1", body); w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", body, want, w.Code)
+		}
+	}
+	if len(fnm.nodes[0].Taints) != 0 {
+		t.Errorf("expected an empty list to remove the taints, got %+v", fnm.nodes[0].Taints)
+	}
+}
+
+// Test that PUT /nodes/{id}/labels replaces or removes the labels and
+// annotations of a node, and keeps those left out.
+func TestUpdateNodeLabelsEndpoint(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	nm := node.NewManager(ds)
+	if err := nm.Register(models.Node{ID: "node-1", Healthy: true, Labels: map[string]string{"zone": "a"}}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+	apiInstance := api.NewAPI(nm, taskmanager.NewTaskManager(ds))
+	put := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/nodes/"+id+"/labels", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		apiInstance.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := put("node-1", `{"labels":{"zone":"b","disk":"ssd"},"annotations":{"example.com/owner":"ops"}}`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Fatalf("expected status 200 with an ETag, got %d: %s", w.Code, w.Body.String())
+	}
+	var n models.Node
+	if err := json.NewDecoder(w.Body).Decode(&n); err != nil {
+		t.Fatalf("error decoding node: %v", err)
+	}
+	if len(n.Labels) != 2 || n.Labels["zone"] != "b" || n.Annotations["example.com/owner"] != "ops" {
+		t.Errorf("expected the labels and annotations to be set, got %v and %v", n.Labels, n.Annotations)
+	}
+
+	for _, tc := range []struct {
+		id, body string
+		want     int
+	}{
+		{"node-1", `{}`, http.StatusBadRequest},
+		{"node-1", `{"labels":{"-zone":"a"}}`, http.StatusBadRequest},
+		{"node-1", `{"annotations":{"Example.com/owner":"ops"}}`, http.StatusBadRequest},
+		{"missing", `{"labels":{}}`, http.StatusNotFound},
+		{"node-1", `{"labels":{}}`, http.StatusOK},
+	} {
+		if w := put(tc.id, tc.body); w.Code != tc.want {
+			t.Errorf("%s %s: expected status %d, got %d", tc.id, tc.body, tc.want, w.Code)
+		}
+	}
+	stored, _ := nm.GetNode("node-1")
+	if len(stored.Labels) != 0 || stored.Annotations["example.com/owner"] != "ops" {
+		t.Errorf("expected the labels to be removed and the annotations kept, got %v and %v", stored.Labels, stored.Annotations)
+	}
+}
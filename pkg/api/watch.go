@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+)
+
+const (
+	// DefaultHeartbeatInterval is how often an idle watch stream carries a
+	// heartbeat so that clients and proxies can tell it is still alive.
+	DefaultHeartbeatInterval = 30 * time.Second
+	// DefaultWriteTimeout bounds how long a single write to a watch stream may
+	// take before the client is considered stuck and disconnected.
+	DefaultWriteTimeout = 10 * time.Second
+
+	// eventHeartbeat and eventError are stream-only event types that never
+	// come from the datastore.
+	eventHeartbeat datastore.EventType = "HEARTBEAT"
+	eventError     datastore.EventType = "ERROR"
+)
+
+// streamEvent is what a watch stream carries: a datastore event, a heartbeat
+// or a final error.
+type streamEvent struct {
+	datastore.Event
+	Message string `json:"message,omitempty"`
+}
+
+// eventWriter encodes events in one of the supported wire formats.
+type eventWriter interface {
+	contentType() string
+	write(w http.ResponseWriter, ev streamEvent) error
+}
+
+// ndjsonWriter writes one JSON document per line.
+type ndjsonWriter struct{}
+
+func (ndjsonWriter) contentType() string { return "application/x-ndjson" }
+
+func (ndjsonWriter) write(w http.ResponseWriter, ev streamEvent) error {
+	return json.NewEncoder(w).Encode(ev)
+}
+
+// sseWriter writes Server-Sent Events. The event ID is the resource version,
+// so browsers reconnect with a Last-Event-ID that resumes the stream.
+type sseWriter struct{}
+
+func (sseWriter) contentType() string { return "text/event-stream" }
+
+func (sseWriter) write(w http.ResponseWriter, ev streamEvent) error {
+	if ev.Type == eventHeartbeat {
+		_, err := fmt.Fprint(w, ": heartbeat\n\n")
+		return err
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if ev.ResourceVersion != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", ev.ResourceVersion); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	return err
+}
+
+// isWatch reports whether a list request asks for a watch stream.
+func isWatch(r *http.Request) bool {
+	v := r.URL.Query().Get("watch")
+	return v == "true" || v == "1"
+}
+
+// watchStartVersion returns the version a watch should resume after, taken
+// from the resourceVersion parameter or, for SSE reconnects, Last-Event-ID.
+func watchStartVersion(r *http.Request) (uint64, error) {
+	raw := r.URL.Query().Get("resourceVersion")
+	if raw == "" {
+		raw = r.Header.Get("Last-Event-ID")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid resourceVersion %q", raw)
+	}
+	return v, nil
+}
+
+// serveWatch streams events from a watch opened with open until the client
+// goes away, the watcher ends or the API is closed. When match is not nil,
+// only the events it accepts are sent.
+func (a *API) serveWatch(w http.ResponseWriter, r *http.Request, open func(fromVersion uint64) (datastore.Watcher, error), match func(datastore.Event) bool) {
+	from, err := watchStartVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	watcher, err := open(from)
+	if errors.Is(err, datastore.ErrResourceVersionTooOld) {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer watcher.Stop()
+
+	var enc eventWriter = ndjsonWriter{}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") || r.URL.Query().Get("format") == "sse" {
+		enc = sseWriter{}
+	}
+	rc := http.NewResponseController(w)
+	send := func(ev streamEvent) error {
+		// A deadline per write keeps a stalled client from pinning this
+		// handler; the datastore side never blocks on it either way.
+		_ = rc.SetWriteDeadline(time.Now().Add(a.writeTimeout))
+		if err := enc.write(w, ev); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", enc.contentType())
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(a.heartbeatInterval)
+	defer heartbeat.Stop()
+	lastVersion := from
+	for {
+		select {
+		case ev, ok := <-watcher.ResultChan():
+			if !ok {
+				// The watcher was dropped, most likely because this client
+				// fell behind. Tell it where to resume from.
+				msg := "watch closed"
+				if err := watcher.Err(); err != nil {
+					msg = err.Error()
+				}
+				_ = send(streamEvent{Event: datastore.Event{Type: eventError, ResourceVersion: lastVersion}, Message: msg})
+				return
+			}
+			lastVersion = ev.ResourceVersion
+			if match != nil && !match(ev) {
+				continue
+			}
+			if err := send(streamEvent{Event: ev}); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := send(streamEvent{Event: datastore.Event{Type: eventHeartbeat, ResourceVersion: lastVersion}}); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-a.done:
+			return
+		}
+	}
+}
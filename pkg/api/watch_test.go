@@ -0,0 +1,279 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+)
+
+type watchLine struct {
+	Type            string          `json:"type"`
+	Kind            string          `json:"kind"`
+	ID              string          `json:"id"`
+	ResourceVersion uint64          `json:"resourceVersion"`
+	Object          json.RawMessage `json:"object"`
+	Message         string          `json:"message"`
+}
+
+func newWatchServer(t *testing.T, opts ...api.Option) (*httptest.Server, *api.API, *datastore.InMemoryDatastore) {
+	t.Helper()
+	ds := datastore.NewInMemoryDatastore()
+	apiInstance := api.NewAPI(node.NewManager(ds), taskmanager.NewTaskManager(ds), opts...)
+	srv := httptest.NewServer(apiInstance.Router())
+	t.Cleanup(func() {
+		apiInstance.Close()
+		srv.Close()
+	})
+	return srv, apiInstance, ds
+}
+
+func openStream(t *testing.T, url string, header http.Header) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func readLine(t *testing.T, r *bufio.Reader) watchLine {
+	t.Helper()
+	lineCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			errCh <- err
+			return
+		}
+		lineCh <- line
+	}()
+	select {
+	case line := <-lineCh:
+		var ev watchLine
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("failed to decode %q: %v", line, err)
+		}
+		return ev
+	case err := <-errCh:
+		t.Fatalf("stream ended: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return watchLine{}
+}
+
+func TestWatchTasks_NDJSON(t *testing.T) {
+	srv, _, ds := newWatchServer(t)
+	tm := taskmanager.NewTaskManager(ds)
+
+	resp, r := openStream(t, srv.URL+"/tasks?watch=true", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("expected 200 ndjson stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if err := tm.CreateTask(models.Task{ID: "task-1"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	if err := ds.SaveNode(models.Node{ID: "node-1"}); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+	if err := tm.UpdateTask(models.Task{ID: "task-1", Status: models.TaskCancelled}); err != nil {
+		t.Fatalf("failed to update task: %v", err)
+	}
+
+	added := readLine(t, r)
+	if added.Type != "ADDED" || added.ID != "task-1" {
+		t.Errorf("expected ADDED task-1, got %+v", added)
+	}
+	modified := readLine(t, r)
+	if modified.Type != "MODIFIED" || modified.ID != "task-1" {
+		t.Errorf("expected MODIFIED task-1 (and no node events), got %+v", modified)
+	}
+	var task models.Task
+	if err := json.Unmarshal(modified.Object, &task); err != nil || task.Status != models.TaskCancelled {
+		t.Errorf("expected cancelled task in event, got %+v (%v)", task, err)
+	}
+}
+
+func TestWatchTasks_NodeFilter(t *testing.T) {
+	srv, _, ds := newWatchServer(t)
+	tm := taskmanager.NewTaskManager(ds)
+
+	_, r := openStream(t, srv.URL+"/tasks?watch=true&nodeId=node-1", nil)
+	for _, id := range []string{"other", "mine"} {
+		if err := tm.CreateTask(models.Task{ID: id}); err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+	if err := tm.UpdateTask(models.Task{ID: "other", Status: models.TaskScheduled, NodeID: "node-2"}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+	if err := tm.UpdateTask(models.Task{ID: "mine", Status: models.TaskScheduled, NodeID: "node-1"}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+
+	ev := readLine(t, r)
+	if ev.Type != "MODIFIED" || ev.ID != "mine" {
+		t.Errorf("expected only the binding of mine, got %+v", ev)
+	}
+}
+
+func TestWatchNodes_LabelSelector(t *testing.T) {
+	srv, _, ds := newWatchServer(t)
+
+	_, r := openStream(t, srv.URL+"/nodes?watch=true&labelSelector=zone%3Da", nil)
+	for _, n := range []models.Node{
+		{ID: "node-b", Labels: map[string]string{"zone": "b"}},
+		{ID: "node-a", Labels: map[string]string{"zone": "a"}},
+	} {
+		if err := ds.SaveNode(n); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+
+	ev := readLine(t, r)
+	if ev.Type != "ADDED" || ev.ID != "node-a" {
+		t.Errorf("expected only node-a, got %+v", ev)
+	}
+}
+
+func TestWatchNodes_ResumeFromResourceVersion(t *testing.T) {
+	srv, _, ds := newWatchServer(t)
+	for _, id := range []string{"node-1", "node-2"} {
+		if err := ds.SaveNode(models.Node{ID: id}); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+	nodes, _ := ds.GetNodes()
+	first := nodes[0].ResourceVersion
+	if nodes[1].ResourceVersion < first {
+		first = nodes[1].ResourceVersion
+	}
+
+	_, r := openStream(t, srv.URL+"/nodes?watch=true&resourceVersion="+strconv.FormatUint(first, 10), nil)
+	ev := readLine(t, r)
+	if ev.Type != "ADDED" || ev.ResourceVersion != first+1 {
+		t.Errorf("expected replay of the second node, got %+v", ev)
+	}
+
+	ds.SetWatchHistoryLimit(0)
+	resp, _ := openStream(t, srv.URL+"/nodes?watch=true&resourceVersion=1", nil)
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("expected 410 for compacted resourceVersion, got %d", resp.StatusCode)
+	}
+	resp, _ = openStream(t, srv.URL+"/nodes?watch=true&resourceVersion=abc", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for malformed resourceVersion, got %d", resp.StatusCode)
+	}
+}
+
+func TestWatchTasks_SSEAndHeartbeat(t *testing.T) {
+	srv, _, ds := newWatchServer(t, api.WithHeartbeatInterval(20*time.Millisecond))
+	resp, r := openStream(t, srv.URL+"/tasks?watch=true", http.Header{"Accept": {"text/event-stream"}})
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected SSE stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	if err := ds.SaveTask(models.Task{ID: "task-1"}); err != nil {
+		t.Fatalf("failed to save task: %v", err)
+	}
+
+	var sawHeartbeat, sawEvent bool
+	deadline := time.Now().Add(2 * time.Second)
+	for !(sawHeartbeat && sawEvent) && time.Now().Before(deadline) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended: %v", err)
+		}
+		switch {
+		case strings.HasPrefix(line, ": heartbeat"):
+			sawHeartbeat = true
+		case strings.HasPrefix(line, "event: ADDED"):
+			sawEvent = true
+		case strings.HasPrefix(line, "id: "):
+			if _, err := strconv.ParseUint(strings.TrimSpace(line[4:]), 10, 64); err != nil {
+				t.Errorf("expected numeric event id, got %q", line)
+			}
+		}
+	}
+	if !sawHeartbeat || !sawEvent {
+		t.Errorf("expected heartbeat and ADDED event, got heartbeat=%v event=%v", sawHeartbeat, sawEvent)
+	}
+}
+
+func TestWatch_CloseEndsStreams(t *testing.T) {
+	srv, apiInstance, _ := newWatchServer(t)
+	_, r := openStream(t, srv.URL+"/tasks?watch=true", nil)
+
+	apiInstance.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(r)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected clean end of stream, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream was not closed on shutdown")
+	}
+}
+
+func TestWatch_SlowClientIsDisconnected(t *testing.T) {
+	srv, _, ds := newWatchServer(t)
+	resp, r := openStream(t, srv.URL+"/tasks?watch=true", nil)
+	// Never hang the test if the stream unexpectedly stays open.
+	timer := time.AfterFunc(10*time.Second, func() { resp.Body.Close() })
+	defer timer.Stop()
+
+	// Writers must never wait for this client, which is not reading.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Large objects fill the socket buffers quickly.
+		padding := strings.Repeat("x", 4096)
+		for i := 0; i < 5000; i++ {
+			_ = ds.SaveTask(models.Task{ID: "task-" + strconv.Itoa(i%10) + padding, Status: models.TaskPending})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("datastore writes blocked on a slow watch client")
+	}
+
+	// The stream ends with an error telling the client where to resume.
+	var last watchLine
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		if err := json.Unmarshal([]byte(line), &last); err != nil {
+			t.Fatalf("failed to decode %q: %v", line, err)
+		}
+	}
+	if last.Type != "ERROR" || last.ResourceVersion == 0 {
+		t.Errorf("expected a final ERROR event with a resume version, got %+v", last)
+	}
+}
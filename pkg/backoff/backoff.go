@@ -0,0 +1,46 @@
+// Package backoff computes the delays between retries of an operation that
+// keeps failing: they grow exponentially up to a cap, with random jitter so
+// that many retries started together spread out.
+package backoff
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff describes a sequence of retry delays.
+type Backoff struct {
+	// Initial is the delay before the first retry.
+	Initial time.Duration
+	// Max caps every delay.
+	Max time.Duration
+	// Factor multiplies the delay after each retry. Values below 1 are
+	// treated as 1.
+	Factor float64
+	// Jitter is the fraction of each delay, between 0 and 1, that is
+	// randomly taken off it.
+	Jitter float64
+}
+
+// DefaultRestart spaces out the restarts of a task that keeps failing. The
+// node agent uses it for tasks restarted in place, and the controller
+// manager for tasks that are rescheduled.
+var DefaultRestart = Backoff{Initial: 10 * time.Second, Max: 5 * time.Minute, Factor: 2, Jitter: 0.1}
+
+// Delay returns the delay before retry number attempt, counting from zero.
+func (b Backoff) Delay(attempt int) time.Duration {
+	return b.delay(attempt, rand.Float64())
+}
+
+// delay computes the delay for a random number r in [0, 1).
+func (b Backoff) delay(attempt int, r float64) time.Duration {
+	factor := max(b.Factor, 1)
+	d := float64(b.Initial) * math.Pow(factor, float64(max(attempt, 0)))
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	d = min(d, float64(math.MaxInt64))
+	jitter := min(max(b.Jitter, 0), 1)
+	return time.Duration(d - d*jitter*r)
+}
@@ -0,0 +1,47 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second, Factor: 2}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for attempt, w := range want {
+		if got := b.Delay(attempt); got != w {
+			t.Errorf("attempt %d: got %v, want %v", attempt, got, w)
+		}
+	}
+	// Large attempts must not overflow past the cap.
+	if got := b.Delay(10000); got != 10*time.Second {
+		t.Errorf("attempt 10000: got %v", got)
+	}
+}
+
+func TestBackoff_Jitter(t *testing.T) {
+	b := Backoff{Initial: 4 * time.Second, Max: time.Minute, Factor: 2, Jitter: 0.5}
+	if got := b.delay(1, 0); got != 8*time.Second {
+		t.Errorf("no jitter drawn: got %v", got)
+	}
+	if got := b.delay(1, 0.5); got != 6*time.Second {
+		t.Errorf("half jitter drawn: got %v", got)
+	}
+	for range 100 {
+		if got := b.Delay(1); got <= 4*time.Second || got > 8*time.Second {
+			t.Fatalf("delay %v outside (4s, 8s]", got)
+		}
+	}
+}
+
+func TestBackoff_Degenerate(t *testing.T) {
+	// A factor below 1 keeps the delay constant, and no cap means none.
+	b := Backoff{Initial: time.Second, Factor: 0.5}
+	if got := b.Delay(3); got != time.Second {
+		t.Errorf("got %v, want 1s", got)
+	}
+	b = Backoff{Initial: time.Second, Factor: 3}
+	if got := b.Delay(3); got != 27*time.Second {
+		t.Errorf("got %v, want 27s", got)
+	}
+}
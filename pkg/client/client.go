@@ -0,0 +1,252 @@
+// Package client is a small HTTP client for the orchestrator API, used by
+// node agents and other programs that talk to the API server.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+var (
+	// ErrNotFound is matched by a StatusError for a missing object.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is matched by a StatusError for a write rejected because
+	// the object changed since it was read, or because of an illegal
+	// status transition.
+	ErrConflict = errors.New("conflict")
+	// ErrGone is matched by a StatusError for a watch whose resource version
+	// is too old to resume from; the caller must list again.
+	ErrGone = errors.New("resource version too old")
+)
+
+// StatusError is returned for any response with an unexpected status code.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
+}
+
+// Is maps status codes to ErrNotFound, ErrConflict and ErrGone.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == http.StatusNotFound
+	case ErrConflict:
+		return e.Code == http.StatusConflict || e.Code == http.StatusPreconditionFailed
+	case ErrGone:
+		return e.Code == http.StatusGone
+	}
+	return false
+}
+
+// Client talks to an API server.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a Client for the API server at baseURL, such as
+// "http://localhost:8080".
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{},
+	}
+}
+
+// RegisterNode registers n, replacing any node with the same ID.
+func (c *Client) RegisterNode(ctx context.Context, n models.Node) (*models.Node, error) {
+	var out models.Node
+	if err := c.do(ctx, http.MethodPost, "/nodes", n, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Heartbeat renews the lease of a node.
+func (c *Client) Heartbeat(ctx context.Context, nodeID string) (*models.Node, error) {
+	var out models.Node
+	if err := c.do(ctx, http.MethodPost, "/nodes/"+url.PathEscape(nodeID)+"/heartbeat", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTask returns a single task.
+func (c *Client) GetTask(ctx context.Context, id string) (*models.Task, error) {
+	var out models.Task
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTasks returns all tasks, or only those bound to nodeID when it is not
+// empty, together with the revision to watch for later changes from.
+func (c *Client) ListTasks(ctx context.Context, nodeID string) ([]models.Task, uint64, error) {
+	var out []models.Task
+	header, err := c.send(ctx, http.MethodGet, "/tasks"+nodeQuery(nodeID, nil), nil, &out)
+	if err != nil {
+		return nil, 0, err
+	}
+	revision, err := strconv.ParseUint(header.Get("X-Resource-Version"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading list revision: %w", err)
+	}
+	return out, revision, nil
+}
+
+// UpdateTaskStatus reports the status of a task as its node agent sees it;
+// the rest of t is ignored. A non-zero ResourceVersion makes the update
+// conditional on it.
+func (c *Client) UpdateTaskStatus(ctx context.Context, t models.Task) (*models.Task, error) {
+	var out models.Task
+	if err := c.do(ctx, http.MethodPut, "/tasks/"+url.PathEscape(t.ID)+"/status", t, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WatchTasks streams changes to tasks after fromVersion, restricted to the
+// tasks bound to nodeID when it is not empty. The stream ends when ctx is
+// cancelled or Close is called.
+func (c *Client) WatchTasks(ctx context.Context, nodeID string, fromVersion uint64) (*Watch, error) {
+	params := url.Values{"watch": {"true"}}
+	if fromVersion != 0 {
+		params.Set("resourceVersion", strconv.FormatUint(fromVersion, 10))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/tasks"+nodeQuery(nodeID, params), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(resp)
+	}
+	return &Watch{body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+// Watch is an open watch stream.
+type Watch struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+// WatchError is returned by Next when the server ends a stream with an
+// error event, typically because the client fell behind. Watching again
+// from ResourceVersion resumes the stream.
+type WatchError struct {
+	ResourceVersion uint64
+	Message         string
+}
+
+func (e *WatchError) Error() string {
+	return fmt.Sprintf("watch ended at resource version %d: %s", e.ResourceVersion, e.Message)
+}
+
+// Next blocks until the next change and returns it. Heartbeats are skipped.
+func (w *Watch) Next() (datastore.Event, error) {
+	for {
+		line, err := w.reader.ReadBytes('\n')
+		if err != nil {
+			return datastore.Event{}, err
+		}
+		var ev struct {
+			datastore.Event
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(line, &ev); err != nil {
+			return datastore.Event{}, fmt.Errorf("decoding watch event: %w", err)
+		}
+		switch ev.Type {
+		case "HEARTBEAT":
+			continue
+		case "ERROR":
+			return datastore.Event{}, &WatchError{ResourceVersion: ev.ResourceVersion, Message: ev.Message}
+		}
+		return ev.Event, nil
+	}
+}
+
+// Close ends the stream.
+func (w *Watch) Close() error {
+	return w.body.Close()
+}
+
+// do sends in as the JSON body of a request and decodes the response into
+// out.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	_, err := c.send(ctx, method, path, in, out)
+	return err
+}
+
+// send is do, also returning the response headers.
+func (c *Client) send(ctx context.Context, method, path string, in, out any) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, statusError(resp)
+	}
+	if out == nil {
+		return resp.Header, nil
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// statusError reads the error message from a failed response.
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+}
+
+// nodeQuery adds a nodeId filter to params and encodes them as a query
+// string.
+func nodeQuery(nodeID string, params url.Values) string {
+	if nodeID != "" {
+		if params == nil {
+			params = url.Values{}
+		}
+		params.Set("nodeId", nodeID)
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + params.Encode()
+}
@@ -0,0 +1,156 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/api"
+	"github.com/fntkg/container-orchestrator/pkg/client"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+)
+
+func newTestClient(t *testing.T) (*client.Client, taskmanager.TaskManager) {
+	t.Helper()
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	apiInstance := api.NewAPI(node.NewManager(ds), tm)
+	srv := httptest.NewServer(apiInstance.Router())
+	t.Cleanup(func() {
+		apiInstance.Close()
+		srv.Close()
+	})
+	return client.New(srv.URL + "/"), tm
+}
+
+func TestClient_NodesAndTasks(t *testing.T) {
+	c, tm := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.RegisterNode(ctx, models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("RegisterNode: %v", err)
+	}
+	n, err := c.Heartbeat(ctx, "node-1")
+	if err != nil || n.LastHeartbeatTime.IsZero() {
+		t.Fatalf("Heartbeat: %+v, %v", n, err)
+	}
+	if _, err := c.Heartbeat(ctx, "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown node, got %v", err)
+	}
+
+	for _, task := range []models.Task{{ID: "task-1"}, {ID: "task-2"}} {
+		if err := tm.CreateTask(task); err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+	if err := tm.UpdateTask(models.Task{ID: "task-1", Status: models.TaskScheduled, NodeID: "node-1"}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+	task, err := c.GetTask(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	task.Status = models.TaskRunning
+	updated, err := c.UpdateTaskStatus(ctx, *task)
+	if err != nil || updated.ResourceVersion <= task.ResourceVersion || updated.Status != models.TaskRunning || updated.NodeID != "node-1" {
+		t.Fatalf("UpdateTaskStatus: %+v, %v", updated, err)
+	}
+	// The version sent along makes a second write with it conflict.
+	if _, err := c.UpdateTaskStatus(ctx, *task); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected ErrConflict for a stale version, got %v", err)
+	}
+
+	all, _, err := c.ListTasks(ctx, "")
+	if err != nil || len(all) != 2 {
+		t.Errorf("ListTasks: expected 2 tasks, got %+v, %v", all, err)
+	}
+	bound, revision, err := c.ListTasks(ctx, "node-1")
+	if err != nil || len(bound) != 1 || bound[0].ID != "task-1" {
+		t.Errorf("ListTasks(node-1): expected task-1, got %+v, %v", bound, err)
+	}
+	// The revision is that of the last write to the store.
+	if revision != updated.ResourceVersion {
+		t.Errorf("ListTasks: expected revision %d, got %d", updated.ResourceVersion, revision)
+	}
+}
+
+func TestClient_WatchFromListRevision(t *testing.T) {
+	c, tm := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.RegisterNode(ctx, models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("RegisterNode: %v", err)
+	}
+	if err := tm.CreateTask(models.Task{ID: "task-1", Status: models.TaskPending}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	// Changes to other objects move the revision past the node's own tasks.
+	for range 3 {
+		if _, err := c.Heartbeat(ctx, "node-1"); err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+	}
+	tasks, revision, err := c.ListTasks(ctx, "node-1")
+	if err != nil || len(tasks) != 0 {
+		t.Fatalf("ListTasks: expected no tasks, got %+v, %v", tasks, err)
+	}
+	created, _ := tm.GetTask("task-1")
+	if revision <= created.ResourceVersion {
+		t.Errorf("expected the revision %d to be newer than task-1 (%d)", revision, created.ResourceVersion)
+	}
+
+	w, err := c.WatchTasks(ctx, "node-1", revision)
+	if err != nil {
+		t.Fatalf("WatchTasks: %v", err)
+	}
+	defer w.Close()
+	if err := tm.UpdateTask(models.Task{ID: "task-1", Status: models.TaskScheduled, NodeID: "node-1"}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+	ev, err := w.Next()
+	if err != nil || ev.ID != "task-1" || ev.ResourceVersion <= revision {
+		t.Errorf("expected the binding of task-1, got %+v, %v", ev, err)
+	}
+}
+
+func TestClient_WatchTasks(t *testing.T) {
+	c, tm := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := tm.CreateTask(models.Task{ID: "task-1"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	created, _ := tm.GetTask("task-1")
+
+	// Resuming from the creation skips it and sees the binding.
+	w, err := c.WatchTasks(ctx, "node-1", created.ResourceVersion)
+	if err != nil {
+		t.Fatalf("WatchTasks: %v", err)
+	}
+	defer w.Close()
+	if err := tm.CreateTask(models.Task{ID: "task-2"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	if err := tm.UpdateTask(models.Task{ID: "task-1", Status: models.TaskScheduled, NodeID: "node-1"}); err != nil {
+		t.Fatalf("failed to bind task: %v", err)
+	}
+
+	ev, err := w.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	var task models.Task
+	if err := ev.Decode(&task); err != nil || ev.Type != datastore.Modified || task.ID != "task-1" || task.NodeID != "node-1" {
+		t.Errorf("expected task-1 bound to node-1, got %+v (%v)", ev, err)
+	}
+
+	cancel()
+	if _, err := w.Next(); err == nil {
+		t.Error("expected Next to fail once the context is cancelled")
+	}
+}
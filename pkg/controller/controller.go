@@ -2,47 +2,138 @@
 package controller
 
 import (
+	"errors"
 	"log"
 	"time"
 
+	"github.com/fntkg/container-orchestrator/pkg/backoff"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
 	"github.com/fntkg/container-orchestrator/pkg/models"
 	"github.com/fntkg/container-orchestrator/pkg/node"
 	"github.com/fntkg/container-orchestrator/pkg/scheduler"
 	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
 )
 
+const (
+	// DefaultResyncPeriod is how often the controller reconciles even when no
+	// change has been observed, as a safety net for missed events.
+	DefaultResyncPeriod = 30 * time.Second
+	// watchRetryDelay is the pause before reopening a watch that failed.
+	watchRetryDelay = time.Second
+)
+
 // ControllerManager monitors and reconciles the cluster's state.
 type ControllerManager struct {
 	scheduler   scheduler.Scheduler
 	taskManager taskmanager.TaskManager
 	nodeManager node.NodeManager
+
+	// ResyncPeriod overrides DefaultResyncPeriod when set before Run.
+	ResyncPeriod time.Duration
+	// RestartBackoff spaces out the restarts of failed tasks that are
+	// rescheduled under their restart policy.
+	RestartBackoff backoff.Backoff
+
+	now func() time.Time
+	// trigger holds at most one pending request to reconcile, so a burst of
+	// events collapses into a single pass.
+	trigger chan struct{}
+	// restartTimer wakes the controller when the next deferred restart is
+	// due.
+	restartTimer *time.Timer
 }
 
 // NewControllerManager creates a new ControllerManager instance.
 func NewControllerManager(sched scheduler.Scheduler, tm taskmanager.TaskManager, nm node.NodeManager) *ControllerManager {
 	return &ControllerManager{
-		scheduler:   sched,
-		taskManager: tm,
-		nodeManager: nm,
+		scheduler:      sched,
+		taskManager:    tm,
+		nodeManager:    nm,
+		ResyncPeriod:   DefaultResyncPeriod,
+		RestartBackoff: backoff.DefaultRestart,
+		now:            time.Now,
+		trigger:        make(chan struct{}, 1),
 	}
 }
 
-// Run starts the reconciliation loop.
+// Run starts the reconciliation loop. The controller watches tasks and nodes
+// and reconciles as soon as either changes, and in any case once per resync
+// period.
 func (cm *ControllerManager) Run(stopCh <-chan struct{}) {
-	ticker := time.NewTicker(5 * time.Second)
+	go watchLoop("tasks", cm.taskManager.Watch, cm.trigger, stopCh)
+	go watchLoop("nodes", cm.nodeManager.Watch, cm.trigger, stopCh)
+
+	ticker := time.NewTicker(cm.ResyncPeriod)
 	defer ticker.Stop()
 
+	cm.reconcile()
 	for {
 		select {
+		case <-cm.trigger:
+			cm.reconcile()
 		case <-ticker.C:
 			cm.reconcile()
 		case <-stopCh:
+			if cm.restartTimer != nil {
+				cm.restartTimer.Stop()
+			}
 			log.Println("Controller DefaultNodeManager stopped")
 			return
 		}
 	}
 }
 
+// watchLoop keeps a watch open until stopCh is closed, signalling trigger on
+// every event. A watch that ends is resumed from the last version seen; if
+// that version has been compacted away, the loop triggers a full relist and
+// starts watching from the present.
+func watchLoop(name string, open func(fromVersion uint64) (datastore.Watcher, error), trigger chan<- struct{}, stopCh <-chan struct{}) {
+	notify := func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+
+	var lastVersion uint64
+	for {
+		w, err := open(lastVersion)
+		if errors.Is(err, datastore.ErrResourceVersionTooOld) {
+			log.Printf("Watch on %s fell behind, relisting", name)
+			lastVersion = 0
+			notify()
+			continue
+		}
+		if err != nil {
+			log.Printf("Error watching %s: %v", name, err)
+			select {
+			case <-time.After(watchRetryDelay):
+				continue
+			case <-stopCh:
+				return
+			}
+		}
+
+	events:
+		for {
+			select {
+			case ev, ok := <-w.ResultChan():
+				if !ok {
+					if err := w.Err(); err != nil {
+						log.Printf("Watch on %s closed: %v", name, err)
+					}
+					break events
+				}
+				lastVersion = ev.ResourceVersion
+				notify()
+			case <-stopCh:
+				w.Stop()
+				return
+			}
+		}
+	}
+}
+
 // reconcile performs a single reconciliation iteration.
 func (cm *ControllerManager) reconcile() {
 	log.Println("Controller DefaultNodeManager: Reconciling state...")
@@ -53,24 +144,121 @@ func (cm *ControllerManager) reconcile() {
 		log.Printf("Error retrieving tasks: %v", err)
 		return
 	}
+	if cm.requeueFailed(tasks) {
+		if tasks, err = cm.taskManager.GetTasks(); err != nil {
+			log.Printf("Error retrieving tasks: %v", err)
+			return
+		}
+	}
 
 	// Retrieve nodes from Node DefaultNodeManager.
 	nodes := cm.nodeManager.GetNodes()
 	// Filter only healthy nodes.
 	healthyNodes := make([]models.Node, 0)
+	healthy := make(map[string]models.Node)
 	for _, n := range nodes {
 		if n.Healthy {
 			healthyNodes = append(healthyNodes, n)
+			healthy[n.ID] = n
 		}
 	}
 
 	for _, task := range tasks {
-		assignedNode, err := cm.scheduler.Schedule(task, healthyNodes)
+		// Only pending tasks need a node.
+		if task.Status != models.TaskPending {
+			continue
+		}
+		candidates := healthyNodes
+		if task.NodeID != "" {
+			// Tasks created for a given node, such as those of daemon sets,
+			// wait for that node to be healthy. They still go through the
+			// scheduler, so that they only land there if they fit.
+			n, ok := healthy[task.NodeID]
+			if !ok {
+				continue
+			}
+			candidates = []models.Node{n}
+		}
+		assignedNode, err := cm.scheduler.Schedule(task, candidates)
 		if err != nil {
 			log.Printf("Error scheduling task %s: %v", task.ID, err)
+			continue
+		}
+
+		// Persist the binding so the task is not scheduled again and so that
+		// the scheduler accounts for it when placing the next task.
+		task.NodeID = assignedNode.ID
+		task.Status = models.TaskScheduled
+		if err := cm.taskManager.UpdateTask(task); err != nil {
+			log.Printf("Error binding task %s to Node %s: %v", task.ID, assignedNode.ID, err)
+			continue
+		}
+		log.Printf("Task %s assigned to Node %s", task.ID, assignedNode.ID)
+	}
+}
+
+// requeueFailed returns failed tasks to the pending phase so that they are
+// placed again: evicted tasks straight away, and tasks whose restart policy
+// asks for a restart once RestartBackoff has passed since they failed. Those
+// are the tasks the node agent could not restart in place, for instance
+// because it lost them. It reports whether any task changed.
+func (cm *ControllerManager) requeueFailed(tasks []models.Task) bool {
+	requeued := false
+	var nextDue time.Duration
+	for _, task := range tasks {
+		// The tasks of daemon sets belong to their node, and are replaced by
+		// the daemon set controller instead.
+		if task.Status != models.TaskFailed || task.Owner != nil && task.Owner.Kind == models.KindDaemonSet {
+			continue
+		}
+		evicted := task.Reason == models.ReasonEvicted
+		if !evicted && !task.RestartAllowed(true) {
+			continue
+		}
+		if !evicted {
+			if wait := cm.restartDelay(task); wait > 0 {
+				if nextDue == 0 || wait < nextDue {
+					nextDue = wait
+				}
+				continue
+			}
+		}
+		if err := cm.taskManager.Reschedule(task.ID); err != nil {
+			log.Printf("Error rescheduling failed task %s: %v", task.ID, err)
+			continue
+		}
+		if evicted {
+			log.Printf("Task %s evicted from Node %s is pending again", task.ID, task.NodeID)
 		} else {
-			log.Printf("Task %s assigned to Node %s", task.ID, assignedNode.ID)
-			// Optionally, update task status via Task DefaultNodeManager.
+			log.Printf("Task %s failed on Node %s (%s) is pending again for restart %d", task.ID, task.NodeID, task.Reason, task.RestartCount+1)
 		}
+		requeued = true
+	}
+	if nextDue > 0 {
+		cm.wakeAfter(nextDue)
+	}
+	return requeued
+}
+
+// restartDelay returns how much longer a failed task must wait before it is
+// restarted.
+func (cm *ControllerManager) restartDelay(task models.Task) time.Duration {
+	var failedAt time.Time
+	if n := len(task.Transitions); n > 0 {
+		failedAt = task.Transitions[n-1].Time
 	}
+	return failedAt.Add(cm.RestartBackoff.Delay(task.RestartCount)).Sub(cm.now())
+}
+
+// wakeAfter makes the controller reconcile once d has passed.
+func (cm *ControllerManager) wakeAfter(d time.Duration) {
+	if cm.restartTimer != nil {
+		cm.restartTimer.Stop()
+	}
+	cm.restartTimer = time.AfterFunc(d, func() {
+		select {
+		case cm.trigger <- struct{}{}:
+		default:
+		}
+	})
 }
@@ -3,20 +3,29 @@ package controller
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/fntkg/container-orchestrator/pkg/backoff"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
 	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/scheduler"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
 )
 
 // FakeScheduler implements the scheduler.Scheduler interface for testing.
 type FakeScheduler struct {
 	scheduledTasks []models.Task
-	nodeToReturn   models.Node
-	errToReturn    error
+	// candidates holds the nodes offered for each scheduled task.
+	candidates   [][]models.Node
+	nodeToReturn models.Node
+	errToReturn  error
 }
 
 // Schedule records the task and returns a predefined node (or error).
 func (fs *FakeScheduler) Schedule(task models.Task, nodes []models.Node) (*models.Node, error) {
 	fs.scheduledTasks = append(fs.scheduledTasks, task)
+	fs.candidates = append(fs.candidates, nodes)
 	if fs.errToReturn != nil {
 		return nil, fs.errToReturn
 	}
@@ -39,6 +48,70 @@ func (fnm *FakeNodeManager) GetNodes() []models.Node {
 	return fnm.nodes
 }
 
+// GetNode returns the node with the given ID.
+func (fnm *FakeNodeManager) GetNode(id string) (*models.Node, error) {
+	for _, n := range fnm.nodes {
+		if n.ID == id {
+			return &n, nil
+		}
+	}
+	return nil, fmt.Errorf("node not found")
+}
+
+// UpdateNode replaces a stored node.
+func (fnm *FakeNodeManager) UpdateNode(n models.Node) error {
+	for i := range fnm.nodes {
+		if fnm.nodes[i].ID == n.ID {
+			fnm.nodes[i] = n
+			return nil
+		}
+	}
+	return fmt.Errorf("node not found")
+}
+
+// Watch is not supported by the fake.
+func (fnm *FakeNodeManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return nil, fmt.Errorf("watch not supported")
+}
+
+// UpdateTaints replaces the taints of a node.
+func (fnm *FakeNodeManager) UpdateTaints(id string, taints []models.Taint) (*models.Node, error) {
+	for i := range fnm.nodes {
+		if fnm.nodes[i].ID == id {
+			fnm.nodes[i].Taints = taints
+			return &fnm.nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("node not found")
+}
+
+// UpdateLabels replaces the labels and annotations of a node that are given.
+func (fnm *FakeNodeManager) UpdateLabels(id string, labels, annotations map[string]string) (*models.Node, error) {
+	for i := range fnm.nodes {
+		if fnm.nodes[i].ID == id {
+			if labels != nil {
+				fnm.nodes[i].Labels = labels
+			}
+			if annotations != nil {
+				fnm.nodes[i].Annotations = annotations
+			}
+			return &fnm.nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("node not found")
+}
+
+// Heartbeat marks a node healthy.
+func (fnm *FakeNodeManager) Heartbeat(id string) (*models.Node, error) {
+	for i := range fnm.nodes {
+		if fnm.nodes[i].ID == id {
+			fnm.nodes[i].Healthy = true
+			return &fnm.nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("node not found")
+}
+
 // UpdateHealth updates the health status of a node.
 func (fnm *FakeNodeManager) UpdateHealth(id string, healthy bool) error {
 	for i, n := range fnm.nodes {
@@ -50,12 +123,86 @@ func (fnm *FakeNodeManager) UpdateHealth(id string, healthy bool) error {
 	return fmt.Errorf("node not found")
 }
 
+// FakeTaskManager implements the taskmanager.TaskManager interface for testing.
+type FakeTaskManager struct {
+	tasks   []models.Task
+	updates []models.Task
+}
+
+// CreateTask appends a task to the fake manager.
+func (ftm *FakeTaskManager) CreateTask(task models.Task) error {
+	ftm.tasks = append(ftm.tasks, task)
+	return nil
+}
+
+// GetTask returns the task with the given ID.
+func (ftm *FakeTaskManager) GetTask(taskID string) (*models.Task, error) {
+	for i := range ftm.tasks {
+		if ftm.tasks[i].ID == taskID {
+			t := ftm.tasks[i]
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("task not found")
+}
+
+// GetTasks returns a copy of all tasks.
+func (ftm *FakeTaskManager) GetTasks() ([]models.Task, error) {
+	return append([]models.Task(nil), ftm.tasks...), nil
+}
+
+// ListTasks returns a copy of all tasks, at revision zero.
+func (ftm *FakeTaskManager) ListTasks() ([]models.Task, uint64, error) {
+	tasks, err := ftm.GetTasks()
+	return tasks, 0, err
+}
+
+// Watch is not supported by the fake.
+func (ftm *FakeTaskManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return nil, fmt.Errorf("watch not supported")
+}
+
+// Reschedule moves a failed task back to pending.
+func (ftm *FakeTaskManager) Reschedule(taskID string) error {
+	for i := range ftm.tasks {
+		if ftm.tasks[i].ID == taskID {
+			ftm.tasks[i].Status = models.TaskPending
+			ftm.tasks[i].NodeID = ""
+			return nil
+		}
+	}
+	return fmt.Errorf("task not found")
+}
+
+// DeleteTask removes a task.
+func (ftm *FakeTaskManager) DeleteTask(taskID string) error {
+	for i := range ftm.tasks {
+		if ftm.tasks[i].ID == taskID {
+			ftm.tasks = append(ftm.tasks[:i], ftm.tasks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("task not found")
+}
+
+// UpdateTask records the update and replaces the stored task.
+func (ftm *FakeTaskManager) UpdateTask(task models.Task) error {
+	ftm.updates = append(ftm.updates, task)
+	for i := range ftm.tasks {
+		if ftm.tasks[i].ID == task.ID {
+			ftm.tasks[i] = task
+			return nil
+		}
+	}
+	return fmt.Errorf("task not found")
+}
+
 // TestControllerManager_Reconcile verifies that the reconcile method schedules each task.
 func TestControllerManager_Reconcile(t *testing.T) {
 	// Create sample tasks.
 	tasks := []models.Task{
-		{ID: "task-1"},
-		{ID: "task-2"},
+		{ID: "task-1", Status: "pending"},
+		{ID: "task-2", Status: "pending"},
 	}
 
 	// Create sample nodes.
@@ -75,7 +222,8 @@ func TestControllerManager_Reconcile(t *testing.T) {
 	}
 
 	// Create the Controller Manager using the fake dependencies.
-	cm := NewControllerManager(fakeScheduler, tasks, fakeNodeManager)
+	fakeTaskManager := &FakeTaskManager{tasks: tasks}
+	cm := NewControllerManager(fakeScheduler, fakeTaskManager, fakeNodeManager)
 
 	// Invoke the reconcile logic.
 	cm.reconcile()
@@ -91,4 +239,218 @@ func TestControllerManager_Reconcile(t *testing.T) {
 			t.Errorf("Expected task ID %s, got %s", task.ID, fakeScheduler.scheduledTasks[i].ID)
 		}
 	}
+
+	// Verify that each binding was persisted through the task manager.
+	if len(fakeTaskManager.updates) != len(tasks) {
+		t.Fatalf("Expected %d task updates, got %d", len(tasks), len(fakeTaskManager.updates))
+	}
+	for _, updated := range fakeTaskManager.updates {
+		if updated.NodeID != "node-1" || updated.Status != "scheduled" {
+			t.Errorf("Expected task %s bound to node-1 and scheduled, got node %q status %q", updated.ID, updated.NodeID, updated.Status)
+		}
+	}
+}
+
+// TestControllerManager_ReconcileSkipsBoundTasks verifies that only unbound pending tasks are scheduled.
+func TestControllerManager_ReconcileSkipsBoundTasks(t *testing.T) {
+	fakeTaskManager := &FakeTaskManager{tasks: []models.Task{
+		{ID: "bound", Status: "scheduled", NodeID: "node-1"},
+		{ID: "running", Status: "running", NodeID: "node-2"},
+		{ID: "done", Status: "succeeded"},
+		{ID: "pending", Status: "pending"},
+	}}
+	fakeNodeManager := &FakeNodeManager{nodes: []models.Node{
+		{ID: "node-1", Healthy: false},
+		{ID: "node-2", Healthy: true},
+	}}
+	fakeScheduler := &FakeScheduler{nodeToReturn: models.Node{ID: "node-2", Healthy: true}}
+	cm := NewControllerManager(fakeScheduler, fakeTaskManager, fakeNodeManager)
+
+	cm.reconcile()
+	if len(fakeScheduler.scheduledTasks) != 1 || fakeScheduler.scheduledTasks[0].ID != "pending" {
+		t.Fatalf("Expected only the pending task to be scheduled, got %+v", fakeScheduler.scheduledTasks)
+	}
+
+	// A second pass has nothing left to do.
+	cm.reconcile()
+	if len(fakeScheduler.scheduledTasks) != 1 {
+		t.Errorf("Expected bound task not to be rescheduled, got %d scheduling calls", len(fakeScheduler.scheduledTasks))
+	}
+
+	// A failed scheduling attempt must leave the task untouched.
+	fakeTaskManager.tasks = append(fakeTaskManager.tasks, models.Task{ID: "unschedulable", Status: "pending"})
+	fakeScheduler.errToReturn = fmt.Errorf("no room")
+	cm.reconcile()
+	task, _ := fakeTaskManager.GetTask("unschedulable")
+	if task.NodeID != "" || task.Status != "pending" {
+		t.Errorf("Expected unschedulable task to stay pending, got node %q status %q", task.NodeID, task.Status)
+	}
+}
+
+// TestControllerManager_ReconcileBindsTasksCreatedForANode checks that tasks
+// created for a node skip the scheduler and wait for it to be healthy, and
+// that failed tasks of daemon sets are not placed elsewhere.
+func TestControllerManager_ReconcileBindsTasksCreatedForANode(t *testing.T) {
+	daemon := &models.OwnerReference{Kind: models.KindDaemonSet, ID: "logs"}
+	fakeTaskManager := &FakeTaskManager{tasks: []models.Task{
+		{ID: "for-node-2", Status: models.TaskPending, NodeID: "node-2"},
+		{ID: "for-node-1", Status: models.TaskPending, NodeID: "node-1"},
+		{ID: "evicted-daemon", Status: models.TaskFailed, Reason: models.ReasonEvicted, NodeID: "node-1", Owner: daemon},
+	}}
+	fakeNodeManager := &FakeNodeManager{nodes: []models.Node{
+		{ID: "node-1", Healthy: false},
+		{ID: "node-2", Healthy: true},
+	}}
+	fakeScheduler := &FakeScheduler{nodeToReturn: models.Node{ID: "node-2", Healthy: true}}
+	cm := NewControllerManager(fakeScheduler, fakeTaskManager, fakeNodeManager)
+
+	cm.reconcile()
+	// The task is checked against its own node only.
+	if len(fakeScheduler.scheduledTasks) != 1 || fakeScheduler.scheduledTasks[0].ID != "for-node-2" ||
+		len(fakeScheduler.candidates[0]) != 1 || fakeScheduler.candidates[0][0].ID != "node-2" {
+		t.Errorf("Expected for-node-2 to be checked against node-2 alone, got %+v offered %+v", fakeScheduler.scheduledTasks, fakeScheduler.candidates)
+	}
+	if task, _ := fakeTaskManager.GetTask("for-node-2"); task.Status != models.TaskScheduled || task.NodeID != "node-2" {
+		t.Errorf("Expected the task to be bound to node-2, got status %q node %q", task.Status, task.NodeID)
+	}
+	if task, _ := fakeTaskManager.GetTask("for-node-1"); task.Status != models.TaskPending {
+		t.Errorf("Expected the task for the unhealthy node to wait, got %q", task.Status)
+	}
+	if task, _ := fakeTaskManager.GetTask("evicted-daemon"); task.Status != models.TaskFailed {
+		t.Errorf("Expected the evicted daemon set task to stay failed, got %q", task.Status)
+	}
+}
+
+// TestControllerManager_ReconcileChecksTasksCreatedForANode verifies that a
+// task pinned to a node is not bound there when it does not fit.
+func TestControllerManager_ReconcileChecksTasksCreatedForANode(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	nm := node.NewManager(ds)
+	if err := nm.Register(models.Node{ID: "node-1", Healthy: true, Taints: []models.Taint{{Key: "dedicated", Effect: models.TaintNoSchedule}}}); err != nil {
+		t.Fatalf("Failed to register node: %v", err)
+	}
+	for _, task := range []models.Task{
+		{ID: "intolerant", Status: models.TaskPending, NodeID: "node-1"},
+		{ID: "tolerant", Status: models.TaskPending, NodeID: "node-1", Tolerations: []models.Toleration{{Key: "dedicated", Operator: models.TolerationOpExists}}},
+	} {
+		if err := ds.SaveTask(task); err != nil {
+			t.Fatalf("Failed to save task: %v", err)
+		}
+	}
+	cm := NewControllerManager(scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated), tm, nm)
+
+	cm.reconcile()
+	if task, _ := tm.GetTask("intolerant"); task.Status != models.TaskPending {
+		t.Errorf("Expected the task that does not tolerate the taint to wait, got %q", task.Status)
+	}
+	if task, _ := tm.GetTask("tolerant"); task.Status != models.TaskScheduled || task.NodeID != "node-1" {
+		t.Errorf("Expected the tolerant task to be bound to node-1, got status %q node %q", task.Status, task.NodeID)
+	}
+}
+
+// TestControllerManager_RunReactsToEvents verifies that new tasks and nodes are
+// handled without waiting for the resync period.
+func TestControllerManager_RunReactsToEvents(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	nm := node.NewManager(ds)
+	cm := NewControllerManager(scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated), tm, nm)
+	cm.ResyncPeriod = time.Hour
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		cm.Run(stopCh)
+		close(done)
+	}()
+	defer func() {
+		close(stopCh)
+		<-done
+	}()
+
+	// The task cannot be placed until a node shows up.
+	if err := tm.CreateTask(models.Task{ID: "task-1"}); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := nm.Register(models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("Failed to register node: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		task, err := tm.GetTask("task-1")
+		if err != nil {
+			t.Fatalf("Failed to get task: %v", err)
+		}
+		if task.Status == models.TaskScheduled && task.NodeID == "node-1" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected task to be bound to node-1, got status %q node %q", task.Status, task.NodeID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestControllerManager_ReconcileRequeuesEvictedTasks checks that evicted
+// tasks are returned to pending and placed again in the same pass, while
+// tasks that failed for other reasons are left alone.
+func TestControllerManager_ReconcileRequeuesEvictedTasks(t *testing.T) {
+	fakeTaskManager := &FakeTaskManager{tasks: []models.Task{
+		{ID: "evicted", Status: models.TaskFailed, Reason: models.ReasonEvicted, NodeID: "node-1"},
+		{ID: "crashed", Status: models.TaskFailed, NodeID: "node-1"},
+	}}
+	fakeNodeManager := &FakeNodeManager{nodes: []models.Node{
+		{ID: "node-1", Healthy: false},
+		{ID: "node-2", Healthy: true},
+	}}
+	fakeScheduler := &FakeScheduler{nodeToReturn: models.Node{ID: "node-2", Healthy: true}}
+	cm := NewControllerManager(fakeScheduler, fakeTaskManager, fakeNodeManager)
+
+	cm.reconcile()
+	if len(fakeScheduler.scheduledTasks) != 1 || fakeScheduler.scheduledTasks[0].ID != "evicted" {
+		t.Fatalf("Expected only the evicted task to be scheduled, got %+v", fakeScheduler.scheduledTasks)
+	}
+	if len(fakeTaskManager.updates) != 1 || fakeTaskManager.updates[0].NodeID != "node-2" {
+		t.Errorf("Expected evicted task to be bound to node-2, got %+v", fakeTaskManager.updates)
+	}
+}
+
+// TestControllerManager_ReconcileRestartsFailedTasks checks that failed tasks
+// whose restart policy allows it are placed again once their backoff has
+// passed, and that other failed tasks stay failed.
+func TestControllerManager_ReconcileRestartsFailedTasks(t *testing.T) {
+	failedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	failed := []models.PhaseTransition{{From: models.TaskRunning, To: models.TaskFailed, Time: failedAt}}
+	retries := 3
+	fakeTaskManager := &FakeTaskManager{tasks: []models.Task{
+		{ID: "lost", Status: models.TaskFailed, Reason: "Lost", NodeID: "node-1", RestartPolicy: models.RestartOnFailure, RestartCount: 2, MaxRetries: &retries, Transitions: failed},
+		{ID: "exhausted", Status: models.TaskFailed, Reason: "Lost", NodeID: "node-1", RestartPolicy: models.RestartAlways, RestartCount: 3, MaxRetries: &retries, Transitions: failed},
+		{ID: "never", Status: models.TaskFailed, Reason: "Lost", NodeID: "node-1", Transitions: failed},
+	}}
+	fakeNodeManager := &FakeNodeManager{nodes: []models.Node{{ID: "node-2", Healthy: true}}}
+	fakeScheduler := &FakeScheduler{nodeToReturn: models.Node{ID: "node-2", Healthy: true}}
+	cm := NewControllerManager(fakeScheduler, fakeTaskManager, fakeNodeManager)
+	cm.RestartBackoff = backoff.Backoff{Initial: time.Second, Max: time.Minute, Factor: 2}
+	now := failedAt.Add(3 * time.Second)
+	cm.now = func() time.Time { return now }
+
+	// The third restart waits 4s.
+	cm.reconcile()
+	if len(fakeScheduler.scheduledTasks) != 0 {
+		t.Fatalf("Expected no restart before the backoff passed, got %+v", fakeScheduler.scheduledTasks)
+	}
+	if cm.restartTimer == nil {
+		t.Error("Expected a wake-up for the deferred restart")
+	} else {
+		cm.restartTimer.Stop()
+	}
+
+	now = failedAt.Add(4 * time.Second)
+	cm.reconcile()
+	if len(fakeScheduler.scheduledTasks) != 1 || fakeScheduler.scheduledTasks[0].ID != "lost" {
+		t.Fatalf("Expected only the lost task to be restarted, got %+v", fakeScheduler.scheduledTasks)
+	}
 }
@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/cron"
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// CronJobController starts the jobs of every cron job when its schedule is
+// due.
+//
+// A cron job owns the jobs it created, as recorded in their Owner. Each run
+// is named after the cron job and its scheduled time, in minutes since the
+// Unix epoch, so that it is started at most once. When several runs were
+// missed, as while the controller was down, only the latest one is started,
+// and only if it is within the starting deadline. Finished jobs beyond the
+// history limits are deleted, oldest first, and so are the jobs of deleted
+// cron jobs.
+type CronJobController struct {
+	cronJobs workload.CronJobManager
+	jobs     workload.JobManager
+
+	// ResyncPeriod overrides DefaultResyncPeriod when set before Run.
+	ResyncPeriod time.Duration
+
+	trigger       chan struct{}
+	scheduleTimer *time.Timer
+	now           func() time.Time
+}
+
+// NewCronJobController creates a CronJobController.
+func NewCronJobController(cjm workload.CronJobManager, jm workload.JobManager) *CronJobController {
+	return &CronJobController{
+		cronJobs:     cjm,
+		jobs:         jm,
+		ResyncPeriod: DefaultResyncPeriod,
+		trigger:      make(chan struct{}, 1),
+		now:          time.Now,
+	}
+}
+
+// Run watches cron jobs and jobs and reconciles whenever either changes,
+// when the next run of a cron job is due, and in any case once per resync
+// period, until stopCh is closed.
+func (c *CronJobController) Run(stopCh <-chan struct{}) {
+	go watchLoop("cron jobs", c.cronJobs.Watch, c.trigger, stopCh)
+	go watchLoop("jobs", c.jobs.Watch, c.trigger, stopCh)
+
+	ticker := time.NewTicker(c.ResyncPeriod)
+	defer ticker.Stop()
+
+	c.reconcile()
+	for {
+		select {
+		case <-c.trigger:
+			c.reconcile()
+		case <-ticker.C:
+			c.reconcile()
+		case <-stopCh:
+			if c.scheduleTimer != nil {
+				c.scheduleTimer.Stop()
+			}
+			log.Println("Cron job controller stopped")
+			return
+		}
+	}
+}
+
+// reconcile syncs every cron job and deletes the jobs of those that are
+// gone.
+func (c *CronJobController) reconcile() {
+	cronJobs, err := c.cronJobs.GetCronJobs()
+	if err != nil {
+		log.Printf("Error retrieving cron jobs: %v", err)
+		return
+	}
+	jobs, err := c.jobs.GetJobs()
+	if err != nil {
+		log.Printf("Error retrieving jobs: %v", err)
+		return
+	}
+	owned := make(map[string][]models.Job)
+	for _, j := range jobs {
+		if j.Owner != nil && j.Owner.Kind == models.KindCronJob {
+			owned[j.Owner.ID] = append(owned[j.Owner.ID], j)
+		}
+	}
+
+	var nextRun time.Duration
+	for _, cj := range cronJobs {
+		if wait := c.sync(cj, owned[cj.ID]); wait > 0 && (nextRun == 0 || wait < nextRun) {
+			nextRun = wait
+		}
+		delete(owned, cj.ID)
+	}
+	for id, orphans := range owned {
+		for _, j := range orphans {
+			c.deleteJob(j.ID, "of deleted cron job "+id)
+		}
+	}
+	if nextRun > 0 {
+		c.wakeAfter(nextRun)
+	}
+}
+
+// sync starts the latest run of a cron job that is due, prunes its
+// finished jobs and records its status. It returns how long until the next
+// run, or zero if there is none.
+func (c *CronJobController) sync(cj models.CronJob, owned []models.Job) time.Duration {
+	now := c.now()
+	schedule, err := cron.Parse(cj.Schedule)
+	if err != nil {
+		log.Printf("Cron job %s has an invalid schedule: %v", cj.ID, err)
+		return 0
+	}
+	loc, err := cj.Location()
+	if err != nil {
+		log.Printf("Cron job %s has an invalid time zone: %v", cj.ID, err)
+		return 0
+	}
+
+	status := cj.Status
+	var active, succeeded, failed []models.Job
+	for _, j := range owned {
+		switch {
+		case isJobDone(j, models.JobComplete):
+			succeeded = append(succeeded, j)
+			if j.Status.CompletionTime.After(status.LastSuccessfulTime) {
+				status.LastSuccessfulTime = j.Status.CompletionTime
+			}
+		case isJobDone(j, models.JobFailed):
+			failed = append(failed, j)
+		default:
+			active = append(active, j)
+		}
+	}
+	c.prune(succeeded, cj.SuccessfulHistoryLimit())
+	c.prune(failed, cj.FailedHistoryLimit())
+
+	var wait time.Duration
+	if !cj.Suspend {
+		if due, missed := lastDue(cj, schedule, loc, now); !due.IsZero() {
+			if missed > 1 {
+				log.Printf("Cron job %s missed %d runs, starting the latest one", cj.ID, missed-1)
+			}
+			if j, ok := c.start(cj, due, active); ok {
+				status.LastScheduleTime = due
+				active = j
+			}
+		}
+		if next := schedule.Next(now.In(loc)); !next.IsZero() {
+			wait = next.Sub(now)
+		}
+	}
+
+	status.Active = nil
+	for _, j := range active {
+		status.Active = append(status.Active, j.ID)
+	}
+	sort.Strings(status.Active)
+	if reflect.DeepEqual(status, cj.Status) {
+		return wait
+	}
+	cj.Status = status
+	// A conflict means the cron job changed, which triggers another pass.
+	if err := c.cronJobs.UpdateCronJobStatus(cj); err != nil && !errors.Is(err, datastore.ErrConflict) {
+		log.Printf("Error updating status of cron job %s: %v", cj.ID, err)
+	}
+	return wait
+}
+
+// start creates the job of the run scheduled at due, as the concurrency
+// policy allows, and returns the active jobs with it. It reports whether the
+// run was started.
+func (c *CronJobController) start(cj models.CronJob, due time.Time, active []models.Job) ([]models.Job, bool) {
+	id := fmt.Sprintf("%s-%d", cj.ID, due.Unix()/60)
+	for _, j := range active {
+		if j.ID == id {
+			// Started by an earlier pass whose status update was lost.
+			return active, true
+		}
+	}
+	if len(active) > 0 {
+		switch cj.ConcurrencyPolicy {
+		case models.ForbidConcurrent:
+			// The run starts once the active jobs have finished, if that is
+			// still within its starting deadline.
+			return active, false
+		case models.ReplaceConcurrent:
+			for _, j := range active {
+				c.deleteJob(j.ID, "replaced by a new run of cron job "+cj.ID)
+			}
+			active = nil
+		}
+	}
+
+	j := cj.JobTemplate
+	j.ID = id
+	j.Owner = &models.OwnerReference{Kind: models.KindCronJob, ID: cj.ID}
+	j.ResourceVersion = 0
+	j.Status = models.JobStatus{}
+	if err := c.jobs.CreateJob(j); errors.Is(err, workload.ErrAlreadyExists) {
+		// Started, and finished, before a status update that was lost.
+		return active, true
+	} else if err != nil {
+		log.Printf("Error creating job %s for cron job %s: %v", id, cj.ID, err)
+		return active, false
+	}
+	log.Printf("Cron job %s started job %s for %s", cj.ID, id, due.Format(time.RFC3339))
+	return append(active, j), true
+}
+
+// prune deletes the oldest of the finished jobs beyond limit.
+func (c *CronJobController) prune(finished []models.Job, limit int) {
+	if len(finished) <= limit {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finishedAt(finished[i]).Before(finishedAt(finished[j])) })
+	for _, j := range finished[:len(finished)-limit] {
+		c.deleteJob(j.ID, "beyond the history limit")
+	}
+}
+
+// deleteJob deletes a job, logging why.
+func (c *CronJobController) deleteJob(id, why string) {
+	if err := c.jobs.DeleteJob(id); err != nil && !errors.Is(err, workload.ErrJobNotFound) {
+		log.Printf("Error deleting job %s %s: %v", id, why, err)
+		return
+	}
+	log.Printf("Deleted job %s %s", id, why)
+}
+
+// wakeAfter makes the controller reconcile once d has passed.
+func (c *CronJobController) wakeAfter(d time.Duration) {
+	if c.scheduleTimer != nil {
+		c.scheduleTimer.Stop()
+	}
+	c.scheduleTimer = time.AfterFunc(d, func() {
+		select {
+		case c.trigger <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// lastDue returns the latest scheduled time of a cron job that is due but
+// has not been started, or the zero time if there is none, and how many
+// such times there are. Times before the cron job was created, up to the
+// last one started, and beyond the starting deadline are not due.
+func lastDue(cj models.CronJob, schedule cron.Schedule, loc *time.Location, now time.Time) (time.Time, int) {
+	earliest := cj.CreationTime
+	if cj.Status.LastScheduleTime.After(earliest) {
+		earliest = cj.Status.LastScheduleTime
+	}
+	if d := cj.StartingDeadlineSeconds; d != nil {
+		if start := now.Add(-time.Duration(*d) * time.Second); start.After(earliest) {
+			earliest = start
+		}
+	}
+	if earliest.IsZero() {
+		earliest = now
+	}
+	var due time.Time
+	missed := 0
+	for t := schedule.Next(earliest.In(loc)); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		due = t
+		missed++
+	}
+	return due, missed
+}
+
+// isJobDone reports whether a job has the given final condition.
+func isJobDone(j models.Job, typ models.JobConditionType) bool {
+	cond := j.Condition(typ)
+	return cond != nil && cond.Status == models.ConditionTrue
+}
+
+// finishedAt returns when a finished job completed or failed.
+func finishedAt(j models.Job) time.Time {
+	if !j.Status.CompletionTime.IsZero() {
+		return j.Status.CompletionTime
+	}
+	if cond := j.Condition(models.JobFailed); cond != nil {
+		return cond.LastTransitionTime
+	}
+	return j.Status.StartTime
+}
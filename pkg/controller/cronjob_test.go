@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// cronJobFixture drives a CronJobController with a fake clock.
+type cronJobFixture struct {
+	t   *testing.T
+	c   *CronJobController
+	cjm workload.CronJobManager
+	jm  workload.JobManager
+	now time.Time
+}
+
+// cronJobEpoch is the hour the tests schedule from. Cron jobs are created
+// five minutes before it.
+var cronJobEpoch = time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+func newCronJobFixture(t *testing.T, cj models.CronJob) *cronJobFixture {
+	t.Helper()
+	ds := datastore.NewInMemoryDatastore()
+	f := &cronJobFixture{t: t, cjm: workload.NewCronJobManager(ds), jm: workload.NewJobManager(ds)}
+	if cj.JobTemplate.Template.Command == "" {
+		cj.JobTemplate.Template.Command = "backup"
+	}
+	if err := f.cjm.CreateCronJob(cj); err != nil {
+		t.Fatalf("Failed to create cron job: %v", err)
+	}
+	created := f.cronJob(cj.ID)
+	created.CreationTime = cronJobEpoch.Add(-5 * time.Minute)
+	if err := ds.SaveCronJob(*created); err != nil {
+		t.Fatalf("Failed to backdate cron job: %v", err)
+	}
+	f.c = NewCronJobController(f.cjm, f.jm)
+	f.c.now = func() time.Time { return f.now }
+	return f
+}
+
+// at moves the clock to d after the given minute of the first hour, and
+// reconciles.
+func (f *cronJobFixture) at(minute int, d time.Duration) {
+	f.t.Helper()
+	f.now = cronJobEpoch.Add(time.Duration(minute)*time.Minute + d)
+	f.c.reconcile()
+}
+
+func (f *cronJobFixture) cronJob(id string) *models.CronJob {
+	f.t.Helper()
+	cj, err := f.cjm.GetCronJob(id)
+	if err != nil {
+		f.t.Fatalf("Failed to get cron job: %v", err)
+	}
+	return cj
+}
+
+// jobIDs returns the IDs of the jobs of a cron job, sorted.
+func (f *cronJobFixture) jobIDs(cronJobID string) []string {
+	f.t.Helper()
+	jobs, err := f.jm.GetJobs()
+	if err != nil {
+		f.t.Fatalf("Failed to get jobs: %v", err)
+	}
+	var ids []string
+	for _, j := range jobs {
+		if j.Owner != nil && j.Owner.Kind == models.KindCronJob && j.Owner.ID == cronJobID {
+			ids = append(ids, j.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// finish marks a job complete or failed at the current time.
+func (f *cronJobFixture) finish(id string, typ models.JobConditionType) {
+	f.t.Helper()
+	j, err := f.jm.GetJob(id)
+	if err != nil {
+		f.t.Fatalf("Failed to get job: %v", err)
+	}
+	j.Status.Conditions = []models.JobCondition{{Type: typ, Status: models.ConditionTrue, LastTransitionTime: f.now}}
+	if typ == models.JobComplete {
+		j.Status.CompletionTime = f.now
+	}
+	if err := f.jm.UpdateJobStatus(*j); err != nil {
+		f.t.Fatalf("Failed to finish job: %v", err)
+	}
+}
+
+// runID returns the ID of the run of a cron job scheduled at the given
+// minute of the first hour, plus the given number of hours.
+func runID(cronJobID string, minute, hours int) string {
+	due := cronJobEpoch.Add(time.Duration(minute)*time.Minute + time.Duration(hours)*time.Hour)
+	return fmt.Sprintf("%s-%d", cronJobID, due.Unix()/60)
+}
+
+func TestCronJobController_Schedules(t *testing.T) {
+	f := newCronJobFixture(t, models.CronJob{ID: "backup", Schedule: "15 * * * *", TimeZone: "UTC"})
+
+	f.at(15, -time.Second)
+	if ids := f.jobIDs("backup"); len(ids) != 0 {
+		t.Fatalf("Expected no job before the schedule is due, got %v", ids)
+	}
+	f.at(15, 30*time.Second)
+	first := runID("backup", 15, 0)
+	if ids := f.jobIDs("backup"); len(ids) != 1 || ids[0] != first {
+		t.Fatalf("Expected job %s, got %v", first, ids)
+	}
+	cj := f.cronJob("backup")
+	if cj.Status.LastScheduleTime.Minute() != 15 || len(cj.Status.Active) != 1 || cj.Status.Active[0] != first {
+		t.Errorf("Expected the run to be recorded, got %+v", cj.Status)
+	}
+	j, _ := f.jm.GetJob(first)
+	if j.Template.Command != "backup" || j.Template.RestartPolicy != models.RestartNever {
+		t.Errorf("Expected a job made from the template, got %+v", j)
+	}
+
+	// A second pass in the same minute does not start it again.
+	f.c.reconcile()
+	if ids := f.jobIDs("backup"); len(ids) != 1 {
+		t.Fatalf("Expected the run to start once, got %v", ids)
+	}
+
+	// Allow runs the next job alongside the first, then records success.
+	f.at(15, time.Hour)
+	if ids := f.jobIDs("backup"); len(ids) != 2 {
+		t.Fatalf("Expected 2 concurrent jobs, got %v", ids)
+	}
+	f.finish(first, models.JobComplete)
+	f.c.reconcile()
+	cj = f.cronJob("backup")
+	if !cj.Status.LastSuccessfulTime.Equal(f.now) || len(cj.Status.Active) != 1 {
+		t.Errorf("Expected one active job and a successful time, got %+v", cj.Status)
+	}
+
+	// Suspended cron jobs start nothing.
+	cj.Suspend = true
+	cj.ResourceVersion = 0
+	if err := f.cjm.UpdateCronJob(*cj); err != nil {
+		t.Fatalf("Failed to suspend: %v", err)
+	}
+	f.at(15, 2*time.Hour)
+	if ids := f.jobIDs("backup"); len(ids) != 2 {
+		t.Errorf("Expected a suspended cron job to start nothing, got %v", ids)
+	}
+
+	if err := f.cjm.DeleteCronJob("backup"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	f.c.reconcile()
+	if ids := f.jobIDs("backup"); len(ids) != 0 {
+		t.Errorf("Expected the jobs of the deleted cron job to be deleted, got %v", ids)
+	}
+}
+
+func TestCronJobController_ConcurrencyPolicy(t *testing.T) {
+	forbid := newCronJobFixture(t, models.CronJob{ID: "report", Schedule: "*/30 * * * *", TimeZone: "UTC", ConcurrencyPolicy: models.ForbidConcurrent})
+	forbid.at(0, time.Minute)
+	forbid.at(30, time.Minute)
+	ids := forbid.jobIDs("report")
+	if len(ids) != 1 {
+		t.Fatalf("Forbid: expected the second run to wait, got %v", ids)
+	}
+	// The waiting run starts once the first job has finished.
+	forbid.finish(ids[0], models.JobFailed)
+	forbid.c.reconcile()
+	if ids := forbid.jobIDs("report"); len(ids) != 2 || ids[1] != runID("report", 30, 0) {
+		t.Errorf("Forbid: expected the second run to start late, got %v", ids)
+	}
+
+	replace := newCronJobFixture(t, models.CronJob{ID: "report", Schedule: "*/30 * * * *", TimeZone: "UTC", ConcurrencyPolicy: models.ReplaceConcurrent})
+	replace.at(0, time.Minute)
+	replace.at(30, time.Minute)
+	if ids := replace.jobIDs("report"); len(ids) != 1 || ids[0] != runID("report", 30, 0) {
+		t.Errorf("Replace: expected only the second run, got %v", ids)
+	}
+}
+
+func TestCronJobController_StartingDeadline(t *testing.T) {
+	deadline := int64(60)
+	f := newCronJobFixture(t, models.CronJob{ID: "sync", Schedule: "0 * * * *", TimeZone: "UTC", StartingDeadlineSeconds: &deadline})
+
+	// Runs missed by more than the deadline are skipped.
+	f.at(0, 2*time.Minute)
+	if ids := f.jobIDs("sync"); len(ids) != 0 {
+		t.Fatalf("Expected the late run to be skipped, got %v", ids)
+	}
+	// Of several missed runs, only the latest one within the deadline starts.
+	f.at(0, 3*time.Hour+30*time.Second)
+	if ids := f.jobIDs("sync"); len(ids) != 1 || ids[0] != runID("sync", 0, 3) {
+		t.Errorf("Expected only the latest run, got %v", ids)
+	}
+}
+
+func TestCronJobController_HistoryLimits(t *testing.T) {
+	successful, failed := 1, 0
+	f := newCronJobFixture(t, models.CronJob{
+		ID:                         "clean",
+		Schedule:                   "0 * * * *",
+		TimeZone:                   "UTC",
+		SuccessfulJobsHistoryLimit: &successful,
+		FailedJobsHistoryLimit:     &failed,
+	})
+	for hour, result := range []models.JobConditionType{models.JobComplete, models.JobComplete, models.JobFailed} {
+		f.at(0, time.Duration(hour)*time.Hour)
+		f.now = f.now.Add(time.Minute)
+		f.finish(runID("clean", 0, hour), result)
+	}
+	f.c.reconcile()
+	if ids := f.jobIDs("clean"); len(ids) != 1 || ids[0] != runID("clean", 0, 1) {
+		t.Errorf("Expected only the latest successful job to be kept, got %v", ids)
+	}
+}
+
+func TestCronJobController_TimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	// Kolkata is 5:30 ahead of UTC, so minute 0 there is minute 30 in UTC.
+	f := newCronJobFixture(t, models.CronJob{ID: "tz", Schedule: "0 * * * *", TimeZone: "Asia/Kolkata"})
+	f.at(0, time.Minute)
+	if ids := f.jobIDs("tz"); len(ids) != 0 {
+		t.Fatalf("Expected no run at minute 0 UTC, got %v", ids)
+	}
+	f.at(30, time.Minute)
+	cj := f.cronJob("tz")
+	if len(cj.Status.Active) != 1 || cj.Status.LastScheduleTime.In(loc).Minute() != 0 {
+		t.Errorf("Expected a run at minute 0 in Kolkata, got %+v", cj.Status)
+	}
+}
@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// ReasonNodeIneligible is the Reason of a task cancelled because its node
+// no longer runs its daemon set, and ReasonTemplateUpdated of one cancelled
+// to be replaced by a task of the daemon set's new template.
+const (
+	ReasonNodeIneligible  = "NodeIneligible"
+	ReasonTemplateUpdated = "TemplateUpdated"
+)
+
+// DaemonSetController runs one task of every daemon set on each healthy
+// node that the daemon set's node selector matches, and that satisfies the
+// nodeSelector, required node affinity and tolerations of its template.
+// Tasks stay on nodes that gain a NoSchedule taint, or a NoExecute taint
+// that the template tolerates for a limited time, but are not replaced
+// there once they finish.
+//
+// A daemon set owns the tasks it created, as recorded in their Owner. Each
+// task is created bound to its node, so it skips the scheduler, and carries
+// a hash of the template it was made from. Tasks on nodes that became
+// unhealthy or stopped matching are cancelled, and so are the tasks of
+// deleted daemon sets. Tasks of an old template are replaced as the update
+// strategy says: with RollingUpdate, only as long as no more than
+// MaxUnavailable nodes are left without a ready task. Finished tasks are
+// deleted and replaced by new ones.
+type DaemonSetController struct {
+	daemonSets  workload.DaemonSetManager
+	taskManager taskmanager.TaskManager
+	nodeManager node.NodeManager
+
+	// ResyncPeriod overrides DefaultResyncPeriod when set before Run.
+	ResyncPeriod time.Duration
+
+	trigger chan struct{}
+}
+
+// NewDaemonSetController creates a DaemonSetController.
+func NewDaemonSetController(dsm workload.DaemonSetManager, tm taskmanager.TaskManager, nm node.NodeManager) *DaemonSetController {
+	return &DaemonSetController{
+		daemonSets:   dsm,
+		taskManager:  tm,
+		nodeManager:  nm,
+		ResyncPeriod: DefaultResyncPeriod,
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// Run watches daemon sets, tasks and nodes and reconciles whenever any of
+// them changes, and in any case once per resync period, until stopCh is
+// closed.
+func (c *DaemonSetController) Run(stopCh <-chan struct{}) {
+	go watchLoop("daemon sets", c.daemonSets.Watch, c.trigger, stopCh)
+	go watchLoop("tasks", c.taskManager.Watch, c.trigger, stopCh)
+	go watchLoop("nodes", c.nodeManager.Watch, c.trigger, stopCh)
+
+	ticker := time.NewTicker(c.ResyncPeriod)
+	defer ticker.Stop()
+
+	c.reconcile()
+	for {
+		select {
+		case <-c.trigger:
+			c.reconcile()
+		case <-ticker.C:
+			c.reconcile()
+		case <-stopCh:
+			log.Println("Daemon set controller stopped")
+			return
+		}
+	}
+}
+
+// reconcile syncs every daemon set and removes the tasks of those that are
+// gone.
+func (c *DaemonSetController) reconcile() {
+	sets, err := c.daemonSets.GetDaemonSets()
+	if err != nil {
+		log.Printf("Error retrieving daemon sets: %v", err)
+		return
+	}
+	tasks, err := c.taskManager.GetTasks()
+	if err != nil {
+		log.Printf("Error retrieving tasks: %v", err)
+		return
+	}
+	nodes := c.nodeManager.GetNodes()
+	taken := make(map[string]bool, len(tasks))
+	owned := make(map[string][]models.Task)
+	for _, t := range tasks {
+		taken[t.ID] = true
+		if t.Owner != nil && t.Owner.Kind == models.KindDaemonSet {
+			owned[t.Owner.ID] = append(owned[t.Owner.ID], t)
+		}
+	}
+
+	for _, ds := range sets {
+		c.sync(ds, owned[ds.ID], nodes, taken)
+		delete(owned, ds.ID)
+	}
+	for id, orphans := range owned {
+		for _, t := range orphans {
+			removeTask(c.taskManager, t, ReasonOwnerDeleted, "Daemon set "+id+" was deleted")
+		}
+	}
+}
+
+// sync creates, cancels and replaces the tasks of a daemon set so that each
+// eligible node runs one of its current template, and records its status.
+// taken holds the IDs in use, to which the IDs of new tasks are added.
+func (c *DaemonSetController) sync(ds models.DaemonSet, owned []models.Task, nodes []models.Node, taken map[string]bool) {
+	var eligible []string
+	keep := make(map[string]bool)
+	for _, n := range nodes {
+		if shouldContinue(ds, n) {
+			keep[n.ID] = true
+			if shouldRun(ds, n) {
+				eligible = append(eligible, n.ID)
+			}
+		}
+	}
+	sort.Strings(eligible)
+
+	byNode := make(map[string][]models.Task)
+	for _, t := range owned {
+		switch {
+		case t.Status.IsTerminal():
+			removeTask(c.taskManager, t, "", "")
+		case !keep[t.NodeID]:
+			if removeTask(c.taskManager, t, ReasonNodeIneligible, "Node "+t.NodeID+" no longer runs daemon set "+ds.ID) {
+				log.Printf("Daemon set %s removed task %s from node %s", ds.ID, t.ID, t.NodeID)
+			}
+		default:
+			byNode[t.NodeID] = append(byNode[t.NodeID], t)
+		}
+	}
+
+	hash := templateHash(ds.Template)
+	status := models.DaemonSetStatus{DesiredNumberScheduled: len(eligible)}
+	var outdated []models.Task
+	for _, nodeID := range eligible {
+		tasks := byNode[nodeID]
+		if len(tasks) == 0 {
+			if c.createTask(ds, hash, nodeID, taken) {
+				status.CurrentNumberScheduled++
+				status.UpdatedNumberScheduled++
+			}
+			continue
+		}
+		sort.SliceStable(tasks, func(i, j int) bool { return keepFirst(tasks[i], tasks[j], hash) })
+		for _, t := range tasks[1:] {
+			removeTask(c.taskManager, t, ReasonScaledDown, "Node "+nodeID+" already runs daemon set "+ds.ID)
+		}
+		kept := tasks[0]
+		status.CurrentNumberScheduled++
+		if kept.IsReady() {
+			status.NumberReady++
+		}
+		if kept.Labels[models.TemplateHashLabel] == hash {
+			status.UpdatedNumberScheduled++
+		} else {
+			outdated = append(outdated, kept)
+		}
+	}
+	status.NumberUnavailable = status.DesiredNumberScheduled - status.NumberReady
+
+	if ds.UpdateStrategy.Type != models.DaemonSetOnDelete {
+		// Replacing an unready task costs nothing; a ready one makes its node
+		// unavailable until the new task is ready.
+		budget := ds.MaxUnavailable(status.DesiredNumberScheduled) - status.NumberUnavailable
+		for _, t := range outdated {
+			if t.IsReady() {
+				if budget <= 0 {
+					continue
+				}
+				budget--
+			}
+			if removeTask(c.taskManager, t, ReasonTemplateUpdated, "Daemon set "+ds.ID+" has a new template") {
+				log.Printf("Daemon set %s replacing task %s on node %s", ds.ID, t.ID, t.NodeID)
+			}
+		}
+	}
+
+	if status == ds.Status {
+		return
+	}
+	ds.Status = status
+	// A conflict means the daemon set changed, which triggers another pass.
+	if err := c.daemonSets.UpdateDaemonSetStatus(ds); err != nil && !errors.Is(err, datastore.ErrConflict) {
+		log.Printf("Error updating status of daemon set %s: %v", ds.ID, err)
+	}
+}
+
+// shouldRun reports whether a daemon set runs a task on a node.
+func shouldRun(ds models.DaemonSet, n models.Node) bool {
+	if !shouldContinue(ds, n) || ds.Template.UntoleratedTaint(n, models.TaintNoSchedule) != nil {
+		return false
+	}
+	// A task that would be evicted later is not started in the first place.
+	for _, taint := range n.Taints {
+		if _, evicted := ds.Template.EvictionTime(taint); taint.Effect == models.TaintNoExecute && evicted {
+			return false
+		}
+	}
+	return true
+}
+
+// shouldContinue reports whether the task of a daemon set already on a node
+// may stay there. Tasks that only tolerate a NoExecute taint for a while are
+// left for the node lifecycle controller to evict.
+func shouldContinue(ds models.DaemonSet, n models.Node) bool {
+	return n.Healthy && ds.NodeSelector.Matches(n.Labels) && ds.Template.MatchesNode(n) &&
+		ds.Template.UntoleratedTaint(n, models.TaintNoExecute) == nil
+}
+
+// createTask creates a task of a daemon set's current template, bound to
+// the given node. It reports whether it succeeded.
+func (c *DaemonSetController) createTask(ds models.DaemonSet, hash, nodeID string, taken map[string]bool) bool {
+	owner := models.OwnerReference{Kind: models.KindDaemonSet, ID: ds.ID}
+	t := newTaskFromTemplate(ds.Template, uniqueTaskID(ds.ID, taken), owner)
+	t.Labels = maps.Clone(ds.Template.Labels)
+	if t.Labels == nil {
+		t.Labels = make(map[string]string)
+	}
+	t.Labels[models.TemplateHashLabel] = hash
+	t.NodeID = nodeID
+	if err := c.taskManager.CreateTask(t); err != nil {
+		log.Printf("Error creating task for daemon set %s on node %s: %v", ds.ID, nodeID, err)
+		return false
+	}
+	log.Printf("Daemon set %s created task %s on node %s", ds.ID, t.ID, nodeID)
+	return true
+}
+
+// keepFirst orders the tasks of a daemon set on one node by which to keep:
+// those of the current template, then the furthest along, then the oldest.
+func keepFirst(a, b models.Task, hash string) bool {
+	if ca, cb := a.Labels[models.TemplateHashLabel] == hash, b.Labels[models.TemplateHashLabel] == hash; ca != cb {
+		return ca
+	}
+	if a.IsReady() != b.IsReady() {
+		return a.IsReady()
+	}
+	if ra, rb := phaseRank(a.Status), phaseRank(b.Status); ra != rb {
+		return ra > rb
+	}
+	return createdAt(a).Before(createdAt(b))
+}
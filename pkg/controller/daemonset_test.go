@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+func newDaemonSetController(t *testing.T, ds models.DaemonSet, nodes ...models.Node) (*DaemonSetController, workload.DaemonSetManager, taskmanager.TaskManager, node.NodeManager) {
+	t.Helper()
+	store := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(store)
+	nm := node.NewManager(store)
+	dsm := workload.NewDaemonSetManager(store)
+	for _, n := range nodes {
+		if err := nm.Register(n); err != nil {
+			t.Fatalf("Failed to register node: %v", err)
+		}
+	}
+	if err := dsm.CreateDaemonSet(ds); err != nil {
+		t.Fatalf("Failed to create daemon set: %v", err)
+	}
+	return NewDaemonSetController(dsm, tm, nm), dsm, tm, nm
+}
+
+// daemonTasksByNode returns the unfinished tasks of a daemon set by node.
+func daemonTasksByNode(t *testing.T, tm taskmanager.TaskManager, id string) map[string][]models.Task {
+	t.Helper()
+	byNode := make(map[string][]models.Task)
+	for _, task := range ownedTasks(t, tm, models.KindDaemonSet, id) {
+		if !task.Status.IsTerminal() {
+			byNode[task.NodeID] = append(byNode[task.NodeID], task)
+		}
+	}
+	return byNode
+}
+
+// nodeIDs returns the sorted keys of a map of tasks by node.
+func nodeIDs(byNode map[string][]models.Task) []string {
+	var ids []string
+	for id := range byNode {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// runReady starts a task bound to its node and marks it ready.
+func runReady(t *testing.T, tm taskmanager.TaskManager, id string) {
+	t.Helper()
+	for _, phase := range []models.TaskPhase{models.TaskScheduled, models.TaskRunning} {
+		task, err := tm.GetTask(id)
+		if err != nil {
+			t.Fatalf("Failed to get task %s: %v", id, err)
+		}
+		task.Status = phase
+		if phase == models.TaskRunning {
+			task.SetCondition(models.TaskCondition{Type: models.TaskReady, Status: models.ConditionTrue}, time.Now())
+		}
+		if err := tm.UpdateTask(*task); err != nil {
+			t.Fatalf("Failed to move task %s to %s: %v", id, phase, err)
+		}
+	}
+}
+
+// TestDaemonSetController_FollowsNodes runs a task on every matching healthy
+// node as nodes come and go, then deletes the daemon set.
+func TestDaemonSetController_FollowsNodes(t *testing.T) {
+	zone := map[string]string{"zone": "a"}
+	c, dsm, tm, nm := newDaemonSetController(t,
+		models.DaemonSet{ID: "logs", NodeSelector: models.LabelSelector{MatchLabels: zone}, Template: models.Task{Command: "ship-logs"}},
+		models.Node{ID: "node-1", Healthy: true, Labels: zone},
+		models.Node{ID: "node-2", Healthy: true, Labels: zone},
+		models.Node{ID: "node-3", Healthy: true, Labels: map[string]string{"zone": "b"}},
+		models.Node{ID: "node-4", Healthy: false, Labels: zone},
+	)
+
+	c.reconcile()
+	c.reconcile()
+	byNode := daemonTasksByNode(t, tm, "logs")
+	if ids := nodeIDs(byNode); len(ids) != 2 || ids[0] != "node-1" || ids[1] != "node-2" {
+		t.Fatalf("Expected one task on each of node-1 and node-2, got %v", byNode)
+	}
+	for _, tasks := range byNode {
+		if len(tasks) != 1 || tasks[0].Status != models.TaskPending || tasks[0].Command != "ship-logs" || tasks[0].RestartPolicy != models.RestartAlways {
+			t.Errorf("Expected a single pending copy of the template, got %+v", tasks)
+		}
+	}
+	ds, _ := dsm.GetDaemonSet("logs")
+	if ds.Status.DesiredNumberScheduled != 2 || ds.Status.CurrentNumberScheduled != 2 || ds.Status.NumberUnavailable != 2 {
+		t.Errorf("Expected 2 scheduled and unavailable tasks, got %+v", ds.Status)
+	}
+
+	// A new node gets a task, and so does one that becomes healthy.
+	if err := nm.Register(models.Node{ID: "node-5", Healthy: true, Labels: zone}); err != nil {
+		t.Fatalf("Failed to register node: %v", err)
+	}
+	if err := nm.UpdateHealth("node-4", true); err != nil {
+		t.Fatalf("Failed to update health: %v", err)
+	}
+	c.reconcile()
+	if ids := nodeIDs(daemonTasksByNode(t, tm, "logs")); len(ids) != 4 {
+		t.Fatalf("Expected tasks on 4 nodes, got %v", ids)
+	}
+
+	// Nodes that leave or stop matching have their task cancelled.
+	if err := nm.UpdateHealth("node-1", false); err != nil {
+		t.Fatalf("Failed to update health: %v", err)
+	}
+	relabelled, _ := nm.GetNode("node-2")
+	relabelled.Labels = map[string]string{"zone": "b"}
+	if err := nm.UpdateNode(*relabelled); err != nil {
+		t.Fatalf("Failed to relabel node: %v", err)
+	}
+	c.reconcile()
+	if ids := nodeIDs(daemonTasksByNode(t, tm, "logs")); len(ids) != 2 || ids[0] != "node-4" || ids[1] != "node-5" {
+		t.Fatalf("Expected tasks left on node-4 and node-5, got %v", ids)
+	}
+	for _, task := range ownedTasks(t, tm, models.KindDaemonSet, "logs") {
+		if task.Status == models.TaskCancelled && task.Reason != ReasonNodeIneligible {
+			t.Errorf("Expected reason %s, got %+v", ReasonNodeIneligible, task)
+		}
+	}
+	c.reconcile()
+	if owned := ownedTasks(t, tm, models.KindDaemonSet, "logs"); len(owned) != 2 {
+		t.Errorf("Expected the cancelled tasks to be deleted, got %d tasks", len(owned))
+	}
+
+	if err := dsm.DeleteDaemonSet("logs"); err != nil {
+		t.Fatalf("Failed to delete daemon set: %v", err)
+	}
+	c.reconcile()
+	c.reconcile()
+	if owned := ownedTasks(t, tm, models.KindDaemonSet, "logs"); len(owned) != 0 {
+		t.Errorf("Expected the tasks of the deleted daemon set to be deleted, got %d", len(owned))
+	}
+}
+
+// TestDaemonSetController_RollingUpdate replaces the tasks of an old
+// template one node at a time, waiting for each new task to be ready.
+func TestDaemonSetController_RollingUpdate(t *testing.T) {
+	c, dsm, tm, _ := newDaemonSetController(t,
+		models.DaemonSet{ID: "agent", Template: models.Task{Command: "agent-v1"}},
+		models.Node{ID: "node-1", Healthy: true},
+		models.Node{ID: "node-2", Healthy: true},
+		models.Node{ID: "node-3", Healthy: true},
+	)
+	c.reconcile()
+	for _, tasks := range daemonTasksByNode(t, tm, "agent") {
+		runReady(t, tm, tasks[0].ID)
+	}
+
+	ds, _ := dsm.GetDaemonSet("agent")
+	ds.Template.Command = "agent-v2"
+	ds.ResourceVersion = 0
+	if err := dsm.UpdateDaemonSet(*ds); err != nil {
+		t.Fatalf("Failed to update daemon set: %v", err)
+	}
+
+	for step := 1; step <= 3; step++ {
+		c.reconcile()
+		c.reconcile()
+		updated := 0
+		var fresh []models.Task
+		for _, tasks := range daemonTasksByNode(t, tm, "agent") {
+			if len(tasks) != 1 {
+				t.Fatalf("Step %d: expected one task per node, got %+v", step, tasks)
+			}
+			if tasks[0].Command == "agent-v2" {
+				updated++
+				if !tasks[0].IsReady() {
+					fresh = append(fresh, tasks[0])
+				}
+			}
+		}
+		if updated != step || len(fresh) != 1 {
+			t.Fatalf("Step %d: expected %d updated nodes, one of them not ready yet, got %d (%d unready)", step, step, updated, len(fresh))
+		}
+		runReady(t, tm, fresh[0].ID)
+	}
+	c.reconcile()
+	ds, _ = dsm.GetDaemonSet("agent")
+	if ds.Status.UpdatedNumberScheduled != 3 || ds.Status.NumberReady != 3 || ds.Status.NumberUnavailable != 0 {
+		t.Errorf("Expected a finished rollout, got %+v", ds.Status)
+	}
+}
+
+// TestDaemonSetController_OnDelete only uses a new template for tasks that
+// replace deleted ones.
+func TestDaemonSetController_OnDelete(t *testing.T) {
+	c, dsm, tm, _ := newDaemonSetController(t,
+		models.DaemonSet{ID: "agent", Template: models.Task{Command: "agent-v1"}, UpdateStrategy: models.DaemonSetUpdateStrategy{Type: models.DaemonSetOnDelete}},
+		models.Node{ID: "node-1", Healthy: true},
+		models.Node{ID: "node-2", Healthy: true},
+	)
+	c.reconcile()
+
+	ds, _ := dsm.GetDaemonSet("agent")
+	ds.Template.Command = "agent-v2"
+	ds.ResourceVersion = 0
+	if err := dsm.UpdateDaemonSet(*ds); err != nil {
+		t.Fatalf("Failed to update daemon set: %v", err)
+	}
+	c.reconcile()
+	byNode := daemonTasksByNode(t, tm, "agent")
+	for _, tasks := range byNode {
+		if tasks[0].Command != "agent-v1" {
+			t.Fatalf("Expected the old tasks to be kept, got %+v", tasks[0])
+		}
+	}
+
+	if err := tm.DeleteTask(byNode["node-1"][0].ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	c.reconcile()
+	byNode = daemonTasksByNode(t, tm, "agent")
+	if byNode["node-1"][0].Command != "agent-v2" || byNode["node-2"][0].Command != "agent-v1" {
+		t.Errorf("Expected only the deleted task to be replaced with the new template, got %+v", byNode)
+	}
+	ds, _ = dsm.GetDaemonSet("agent")
+	if ds.Status.UpdatedNumberScheduled != 1 {
+		t.Errorf("Expected 1 updated node, got %+v", ds.Status)
+	}
+}
+
+// TestDaemonSetController_TemplateNodeConstraints only runs tasks on nodes
+// that also satisfy the template's nodeSelector and node affinity.
+func TestDaemonSetController_TemplateNodeConstraints(t *testing.T) {
+	template := models.Task{
+		Command:      "collect",
+		NodeSelector: map[string]string{"disk": "ssd"},
+		Affinity: &models.Affinity{NodeAffinity: &models.NodeAffinity{Required: []models.LabelSelector{
+			{MatchExpressions: []models.LabelSelectorRequirement{{Key: "zone", Operator: models.LabelSelectorOpNotIn, Values: []string{"b"}}}},
+		}}},
+	}
+	c, _, tm, _ := newDaemonSetController(t,
+		models.DaemonSet{ID: "metrics", Template: template},
+		models.Node{ID: "node-1", Healthy: true, Labels: map[string]string{"disk": "ssd", "zone": "a"}},
+		models.Node{ID: "node-2", Healthy: true, Labels: map[string]string{"disk": "ssd", "zone": "b"}},
+		models.Node{ID: "node-3", Healthy: true, Labels: map[string]string{"disk": "hdd", "zone": "a"}},
+	)
+	c.reconcile()
+	if ids := nodeIDs(daemonTasksByNode(t, tm, "metrics")); len(ids) != 1 || ids[0] != "node-1" {
+		t.Errorf("Expected a task on node-1 only, got %v", ids)
+	}
+}
+
+// TestDaemonSetController_Taints only starts tasks on nodes whose taints the
+// template tolerates for good, and leaves tasks on nodes tainted later.
+func TestDaemonSetController_Taints(t *testing.T) {
+	minute := int64(60)
+	template := models.Task{
+		Command: "collect",
+		Tolerations: []models.Toleration{
+			{Key: "gpu", Operator: models.TolerationOpExists},
+			{Key: "maintenance", Operator: models.TolerationOpExists, Effect: models.TaintNoExecute, TolerationSeconds: &minute},
+		},
+	}
+	c, dsm, tm, nm := newDaemonSetController(t,
+		models.DaemonSet{ID: "metrics", Template: template},
+		models.Node{ID: "node-1", Healthy: true},
+		models.Node{ID: "node-2", Healthy: true, Taints: []models.Taint{{Key: "dedicated", Effect: models.TaintNoSchedule}}},
+		models.Node{ID: "node-3", Healthy: true, Taints: []models.Taint{{Key: "maintenance", Effect: models.TaintNoExecute}}},
+		models.Node{ID: "node-4", Healthy: true, Taints: []models.Taint{{Key: "gpu", Effect: models.TaintNoSchedule}}},
+	)
+	c.reconcile()
+	if ids := nodeIDs(daemonTasksByNode(t, tm, "metrics")); len(ids) != 2 || ids[0] != "node-1" || ids[1] != "node-4" {
+		t.Fatalf("Expected tasks on node-1 and node-4, got %v", ids)
+	}
+
+	for id, taint := range map[string]models.Taint{
+		"node-1": {Key: "dedicated", Effect: models.TaintNoSchedule},
+		"node-4": {Key: "maintenance", Effect: models.TaintNoExecute},
+	} {
+		if _, err := nm.UpdateTaints(id, []models.Taint{taint}); err != nil {
+			t.Fatalf("Failed to taint %s: %v", id, err)
+		}
+	}
+	c.reconcile()
+	if ids := nodeIDs(daemonTasksByNode(t, tm, "metrics")); len(ids) != 2 {
+		t.Errorf("Expected the tasks to stay on their nodes, got %v", ids)
+	}
+	ds, _ := dsm.GetDaemonSet("metrics")
+	if ds.Status.DesiredNumberScheduled != 0 {
+		t.Errorf("Expected no node to be wanted any more, got %+v", ds.Status)
+	}
+
+	// A taint that is not tolerated at all removes the task.
+	if _, err := nm.UpdateTaints("node-1", []models.Taint{{Key: "dedicated", Effect: models.TaintNoExecute}}); err != nil {
+		t.Fatalf("Failed to taint node-1: %v", err)
+	}
+	c.reconcile()
+	if ids := nodeIDs(daemonTasksByNode(t, tm, "metrics")); len(ids) != 1 || ids[0] != "node-4" {
+		t.Errorf("Expected only the task on node-4 to be left, got %v", ids)
+	}
+}
@@ -0,0 +1,311 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"log"
+	"maps"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// DeploymentController rolls out deployments through replica sets.
+//
+// Every revision of a deployment's template gets a replica set of its own,
+// named after the deployment and a hash of the template. When the template
+// changes, the controller scales the replica set of the new revision up and
+// the older ones down, within the bounds of the deployment's strategy, and
+// keeps the scaled-down replica sets of up to RevisionHistoryLimit old
+// revisions for rollbacks. The replica sets of deleted deployments are
+// deleted.
+type DeploymentController struct {
+	deployments workload.DeploymentManager
+	replicaSets workload.ReplicaSetManager
+
+	// ResyncPeriod overrides DefaultResyncPeriod when set before Run.
+	ResyncPeriod time.Duration
+
+	trigger chan struct{}
+}
+
+// NewDeploymentController creates a DeploymentController.
+func NewDeploymentController(dm workload.DeploymentManager, rsm workload.ReplicaSetManager) *DeploymentController {
+	return &DeploymentController{
+		deployments:  dm,
+		replicaSets:  rsm,
+		ResyncPeriod: DefaultResyncPeriod,
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// Run watches deployments and replica sets and reconciles whenever either
+// changes, and in any case once per resync period, until stopCh is closed.
+func (c *DeploymentController) Run(stopCh <-chan struct{}) {
+	go watchLoop("deployments", c.deployments.Watch, c.trigger, stopCh)
+	go watchLoop("replica sets", c.replicaSets.Watch, c.trigger, stopCh)
+
+	ticker := time.NewTicker(c.ResyncPeriod)
+	defer ticker.Stop()
+
+	c.reconcile()
+	for {
+		select {
+		case <-c.trigger:
+			c.reconcile()
+		case <-ticker.C:
+			c.reconcile()
+		case <-stopCh:
+			log.Println("Deployment controller stopped")
+			return
+		}
+	}
+}
+
+// reconcile syncs every deployment and deletes the replica sets of those
+// that are gone.
+func (c *DeploymentController) reconcile() {
+	deployments, err := c.deployments.GetDeployments()
+	if err != nil {
+		log.Printf("Error retrieving deployments: %v", err)
+		return
+	}
+	sets, err := c.replicaSets.GetReplicaSets()
+	if err != nil {
+		log.Printf("Error retrieving replica sets: %v", err)
+		return
+	}
+	owned := make(map[string][]models.ReplicaSet)
+	for _, rs := range sets {
+		if rs.Owner != nil && rs.Owner.Kind == models.KindDeployment {
+			owned[rs.Owner.ID] = append(owned[rs.Owner.ID], rs)
+		}
+	}
+
+	for _, d := range deployments {
+		c.sync(d, owned[d.ID])
+		delete(owned, d.ID)
+	}
+	for id, orphans := range owned {
+		for _, rs := range orphans {
+			if err := c.replicaSets.DeleteReplicaSet(rs.ID); err != nil && !errors.Is(err, workload.ErrReplicaSetNotFound) {
+				log.Printf("Error deleting replica set %s of deleted deployment %s: %v", rs.ID, id, err)
+			}
+		}
+	}
+}
+
+// sync moves a deployment one step closer to running Replicas tasks of its
+// current template, and records its status.
+func (c *DeploymentController) sync(d models.Deployment, owned []models.ReplicaSet) {
+	currentID := d.ID + "-" + templateHash(d.Template)
+	var current *models.ReplicaSet
+	var old []models.ReplicaSet
+	for i := range owned {
+		if owned[i].ID == currentID {
+			current = &owned[i]
+		} else {
+			old = append(old, owned[i])
+		}
+	}
+	sort.Slice(old, func(i, j int) bool { return old[i].Revision < old[j].Revision })
+
+	if !d.Paused {
+		current = c.rollout(d, currentID, current, old)
+	} else if current != nil && !hasReplicas(old) {
+		// A paused deployment can still be scaled once it has settled.
+		c.scale(current, d.Replicas)
+	} else if current == nil && len(old) > 0 && !hasReplicas(old[:len(old)-1]) {
+		// Its template may have changed while paused: the replica set of the
+		// latest rolled-out revision is scaled instead.
+		c.scale(&old[len(old)-1], d.Replicas)
+	}
+	c.cleanup(d, old)
+	c.updateStatus(d, current, old)
+}
+
+// rollout creates the replica set of the current revision if needed and
+// scales the replica sets as the deployment's strategy allows. It returns
+// the replica set of the current revision, or nil if it could not be
+// created.
+func (c *DeploymentController) rollout(d models.Deployment, currentID string, current *models.ReplicaSet, old []models.ReplicaSet) *models.ReplicaSet {
+	lastRevision := 0
+	for _, rs := range old {
+		lastRevision = max(lastRevision, rs.Revision)
+	}
+	replicas := 0
+	if current != nil {
+		replicas = current.Replicas
+	}
+
+	if d.Strategy.Type == models.Recreate {
+		// New tasks start once every old one is gone.
+		oldLeft := false
+		for i := range old {
+			c.scale(&old[i], 0)
+			oldLeft = oldLeft || old[i].Status.Replicas > 0
+		}
+		if !oldLeft {
+			replicas = d.Replicas
+		}
+	} else {
+		surge, unavailable := d.SurgeAndUnavailable()
+		total, available := replicas, 0
+		if current != nil {
+			available = min(current.Status.ReadyReplicas, current.Replicas)
+		}
+		for _, rs := range old {
+			total += rs.Replicas
+			available += min(rs.Status.ReadyReplicas, rs.Replicas)
+		}
+
+		// Scale the current revision up as far as the surge allows.
+		if replicas > d.Replicas {
+			replicas = d.Replicas
+		} else if room := d.Replicas + surge - total; room > 0 {
+			replicas += min(room, d.Replicas-replicas)
+		}
+
+		// Scale older revisions down: their unready tasks first, which costs
+		// no availability, then as many ready ones as may be unavailable.
+		budget := available - (d.Replicas - unavailable)
+		for i := range old {
+			rs := &old[i]
+			if rs.Replicas == 0 {
+				continue
+			}
+			unready := rs.Replicas - min(rs.Status.ReadyReplicas, rs.Replicas)
+			cut := unready + max(0, min(budget, rs.Replicas-unready))
+			budget -= cut - unready
+			c.scale(rs, rs.Replicas-cut)
+		}
+	}
+
+	if current == nil {
+		rs := newReplicaSet(d, currentID, replicas, lastRevision+1)
+		if err := c.replicaSets.CreateReplicaSet(rs); err != nil {
+			log.Printf("Error creating replica set %s for deployment %s: %v", rs.ID, d.ID, err)
+			return nil
+		}
+		log.Printf("Deployment %s created replica set %s for revision %d", d.ID, rs.ID, rs.Revision)
+		return &rs
+	}
+	if current.Revision <= lastRevision {
+		// An older template is back, as after a rollback: it becomes the
+		// newest revision.
+		current.Revision = lastRevision + 1
+		current.Replicas = replicas
+		if err := c.replicaSets.UpdateReplicaSet(*current); err != nil {
+			log.Printf("Error updating replica set %s of deployment %s: %v", current.ID, d.ID, err)
+		}
+		return current
+	}
+	c.scale(current, replicas)
+	return current
+}
+
+// scale sets the replica count of a replica set, if it differs.
+func (c *DeploymentController) scale(rs *models.ReplicaSet, replicas int) {
+	if rs.Replicas == replicas {
+		return
+	}
+	if err := c.replicaSets.Scale(rs.ID, replicas); err != nil {
+		log.Printf("Error scaling replica set %s to %d: %v", rs.ID, replicas, err)
+		return
+	}
+	log.Printf("Scaled replica set %s from %d to %d", rs.ID, rs.Replicas, replicas)
+	rs.Replicas = replicas
+}
+
+// cleanup deletes the replica sets of the oldest revisions once they have no
+// tasks left, keeping the deployment's revision history limit.
+func (c *DeploymentController) cleanup(d models.Deployment, old []models.ReplicaSet) {
+	var idle []models.ReplicaSet
+	for _, rs := range old {
+		if rs.Replicas == 0 && rs.Status.Replicas == 0 {
+			idle = append(idle, rs)
+		}
+	}
+	for i := 0; i < len(idle)-d.HistoryLimit(); i++ {
+		if err := c.replicaSets.DeleteReplicaSet(idle[i].ID); err != nil && !errors.Is(err, workload.ErrReplicaSetNotFound) {
+			log.Printf("Error deleting replica set %s of deployment %s: %v", idle[i].ID, d.ID, err)
+		}
+	}
+}
+
+// updateStatus records what the replica sets of a deployment last reported.
+func (c *DeploymentController) updateStatus(d models.Deployment, current *models.ReplicaSet, old []models.ReplicaSet) {
+	var status models.DeploymentStatus
+	if current != nil {
+		status.Revision = current.Revision
+		status.UpdatedReplicas = current.Status.Replicas
+		status.Replicas = current.Status.Replicas
+		status.ReadyReplicas = current.Status.ReadyReplicas
+	}
+	for _, rs := range old {
+		status.Replicas += rs.Status.Replicas
+		status.ReadyReplicas += rs.Status.ReadyReplicas
+	}
+	status.UnavailableReplicas = max(0, d.Replicas-status.ReadyReplicas)
+	if status == d.Status {
+		return
+	}
+	d.Status = status
+	// A conflict means the deployment changed, which triggers another pass.
+	if err := c.deployments.UpdateDeploymentStatus(d); err != nil && !errors.Is(err, datastore.ErrConflict) {
+		log.Printf("Error updating status of deployment %s: %v", d.ID, err)
+	}
+}
+
+// hasReplicas reports whether any of the replica sets wants or has tasks.
+func hasReplicas(sets []models.ReplicaSet) bool {
+	for _, rs := range sets {
+		if rs.Replicas > 0 || rs.Status.Replicas > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newReplicaSet makes the replica set of a deployment's current template.
+// Its selector and template carry the template hash, so that it only
+// selects its own tasks.
+func newReplicaSet(d models.Deployment, id string, replicas, revision int) models.ReplicaSet {
+	hash := templateHash(d.Template)
+	template := d.Template
+	template.Labels = maps.Clone(d.Template.Labels)
+	if template.Labels == nil {
+		template.Labels = make(map[string]string)
+	}
+	template.Labels[models.TemplateHashLabel] = hash
+	selector := maps.Clone(d.Selector.MatchLabels)
+	if selector == nil {
+		selector = make(map[string]string)
+	}
+	selector[models.TemplateHashLabel] = hash
+	return models.ReplicaSet{
+		ID:       id,
+		Replicas: replicas,
+		Selector: models.LabelSelector{MatchLabels: selector, MatchExpressions: d.Selector.MatchExpressions},
+		Template: template,
+		Owner:    &models.OwnerReference{Kind: models.KindDeployment, ID: d.ID},
+		Revision: revision,
+	}
+}
+
+// templateHash returns a short hash of a task template.
+func templateHash(template models.Task) string {
+	data, err := json.Marshal(template)
+	if err != nil {
+		// A Task always encodes.
+		panic(err)
+	}
+	h := fnv.New32a()
+	h.Write(data)
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+func newDeployment(id string, replicas int) models.Deployment {
+	labels := map[string]string{"app": id}
+	return models.Deployment{
+		ID:       id,
+		Replicas: replicas,
+		Selector: models.LabelSelector{MatchLabels: labels},
+		Template: models.Task{Labels: labels, Command: "nginx"},
+	}
+}
+
+// settle reports every replica of every replica set as running and ready,
+// standing in for the replica set controller.
+func settle(t *testing.T, rsm workload.ReplicaSetManager) {
+	t.Helper()
+	sets, err := rsm.GetReplicaSets()
+	if err != nil {
+		t.Fatalf("Failed to get replica sets: %v", err)
+	}
+	for _, rs := range sets {
+		rs.Status = models.ReplicaSetStatus{Replicas: rs.Replicas, ReadyReplicas: rs.Replicas}
+		if err := rsm.UpdateReplicaSetStatus(rs); err != nil {
+			t.Fatalf("Failed to update status of %s: %v", rs.ID, err)
+		}
+	}
+}
+
+// revisions returns the replica sets of a deployment, oldest first.
+func revisions(t *testing.T, dm workload.DeploymentManager, id string) []models.ReplicaSet {
+	t.Helper()
+	history, err := dm.Revisions(id)
+	if err != nil {
+		t.Fatalf("Failed to get revisions: %v", err)
+	}
+	return history
+}
+
+// changeTemplate updates the command of a deployment's template.
+func changeTemplate(t *testing.T, dm workload.DeploymentManager, id, command string) {
+	t.Helper()
+	d, err := dm.GetDeployment(id)
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	d.ResourceVersion = 0
+	d.Template.Command = command
+	if err := dm.UpdateDeployment(*d); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+}
+
+// TestDeploymentController_RollingUpdate rolls out a new template step by
+// step within maxSurge and maxUnavailable, then rolls it back.
+func TestDeploymentController_RollingUpdate(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	dm := workload.NewDeploymentManager(ds)
+	rsm := workload.NewReplicaSetManager(ds)
+	c := NewDeploymentController(dm, rsm)
+
+	// 25% of 4 replicas: one extra task and one unavailable task at most.
+	if err := dm.CreateDeployment(newDeployment("web", 4)); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	c.reconcile()
+	history := revisions(t, dm, "web")
+	if len(history) != 1 || history[0].Replicas != 4 || history[0].Revision != 1 {
+		t.Fatalf("Expected a first revision of 4 replicas, got %+v", history)
+	}
+	first := history[0]
+	if first.Selector.MatchLabels[models.TemplateHashLabel] == "" || first.Template.Labels[models.TemplateHashLabel] != first.Selector.MatchLabels[models.TemplateHashLabel] {
+		t.Errorf("Expected the selector and template to carry the template hash, got %+v", first)
+	}
+	settle(t, rsm)
+
+	changeTemplate(t, dm, "web", "httpd")
+	for step := 0; ; step++ {
+		if step == 10 {
+			t.Fatal("Expected the rollout to finish within 10 steps")
+		}
+		c.reconcile()
+		// Without ready replicas, a second pass must not go further.
+		c.reconcile()
+		history = revisions(t, dm, "web")
+		desired, ready := 0, 0
+		for _, rs := range history {
+			desired += rs.Replicas
+			ready += min(rs.Status.ReadyReplicas, rs.Replicas)
+		}
+		if desired > 5 || ready < 3 {
+			t.Fatalf("Step %d: expected at most 5 replicas with 3 ready, got %d with %d ready", step, desired, ready)
+		}
+		settle(t, rsm)
+		if len(history) == 2 && history[0].Replicas == 0 && history[1].Replicas == 4 {
+			break
+		}
+	}
+	c.reconcile()
+	d, _ := dm.GetDeployment("web")
+	if d.Status != (models.DeploymentStatus{Revision: 2, Replicas: 4, UpdatedReplicas: 4, ReadyReplicas: 4}) {
+		t.Errorf("Expected revision 2 fully rolled out, got %+v", d.Status)
+	}
+	if history[1].Template.Command != "httpd" {
+		t.Errorf("Expected the second revision to run httpd, got %q", history[1].Template.Command)
+	}
+
+	// Rolling back brings the first replica set back as revision 3.
+	if err := dm.Rollback("web", 0); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	for range 10 {
+		c.reconcile()
+		settle(t, rsm)
+	}
+	history = revisions(t, dm, "web")
+	if len(history) != 2 || history[1].ID != first.ID || history[1].Revision != 3 || history[1].Replicas != 4 || history[0].Replicas != 0 {
+		t.Errorf("Expected %s to be revision 3 with 4 replicas, got %+v", first.ID, history)
+	}
+	if err := dm.Rollback("web", 7); err == nil {
+		t.Error("Expected rolling back to a missing revision to fail")
+	}
+}
+
+// TestDeploymentController_Recreate stops every old task before starting new
+// ones.
+func TestDeploymentController_Recreate(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	dm := workload.NewDeploymentManager(ds)
+	rsm := workload.NewReplicaSetManager(ds)
+	c := NewDeploymentController(dm, rsm)
+
+	d := newDeployment("web", 3)
+	d.Strategy.Type = models.Recreate
+	if err := dm.CreateDeployment(d); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	c.reconcile()
+	settle(t, rsm)
+
+	changeTemplate(t, dm, "web", "httpd")
+	c.reconcile()
+	history := revisions(t, dm, "web")
+	if len(history) != 2 || history[0].Replicas != 0 || history[1].Replicas != 0 {
+		t.Fatalf("Expected the old revision scaled down before the new one starts, got %+v", history)
+	}
+	settle(t, rsm)
+	c.reconcile()
+	if history = revisions(t, dm, "web"); history[1].Replicas != 3 {
+		t.Errorf("Expected the new revision to start once the old tasks are gone, got %+v", history[1])
+	}
+}
+
+// TestDeploymentController_PauseHistoryAndDeletion checks that paused
+// deployments are not rolled out, that old revisions are pruned, and that
+// deleting a deployment deletes its replica sets.
+func TestDeploymentController_PauseHistoryAndDeletion(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	dm := workload.NewDeploymentManager(ds)
+	rsm := workload.NewReplicaSetManager(ds)
+	c := NewDeploymentController(dm, rsm)
+
+	d := newDeployment("web", 2)
+	limit := 1
+	d.RevisionHistoryLimit = &limit
+	if err := dm.CreateDeployment(d); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	c.reconcile()
+	settle(t, rsm)
+
+	if err := dm.SetPaused("web", true); err != nil {
+		t.Fatalf("Failed to pause: %v", err)
+	}
+	changeTemplate(t, dm, "web", "httpd")
+	if err := dm.Scale("web", 3); err != nil {
+		t.Fatalf("Failed to scale: %v", err)
+	}
+	c.reconcile()
+	history := revisions(t, dm, "web")
+	if len(history) != 1 || history[0].Replicas != 3 {
+		t.Fatalf("Expected a paused deployment to scale but not roll out, got %+v", history)
+	}
+
+	if err := dm.SetPaused("web", false); err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+	for _, command := range []string{"httpd", "apache2"} {
+		changeTemplate(t, dm, "web", command)
+		for range 10 {
+			c.reconcile()
+			settle(t, rsm)
+		}
+	}
+	history = revisions(t, dm, "web")
+	if len(history) != 2 || history[0].Revision != 2 || history[1].Revision != 3 {
+		t.Errorf("Expected revisions 2 and 3 to be kept, got %+v", history)
+	}
+
+	if err := dm.DeleteDeployment("web"); err != nil {
+		t.Fatalf("Failed to delete deployment: %v", err)
+	}
+	c.reconcile()
+	if sets, _ := rsm.GetReplicaSets(); len(sets) != 0 {
+		t.Errorf("Expected the replica sets to be deleted, got %+v", sets)
+	}
+}
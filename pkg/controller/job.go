@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// ReasonJobFinished is the Reason of a task cancelled because its job had
+// completed or failed.
+const ReasonJobFinished = "JobFinished"
+
+// JobController runs the tasks of every job until enough of them have
+// succeeded, or the job gives up.
+//
+// A job owns the tasks it created, as recorded in their Owner. It keeps up
+// to Parallelism of them running, but never more than the completions still
+// missing, and replaces the ones that fail. The job completes once
+// Completions tasks have succeeded, and fails once more tasks have failed or
+// been restarted than BackoffLimit allows, or once it has been running for
+// longer than ActiveDeadlineSeconds. Either way its unfinished tasks are
+// cancelled, and its finished ones are kept until the job is deleted.
+type JobController struct {
+	jobs        workload.JobManager
+	taskManager taskmanager.TaskManager
+
+	// ResyncPeriod overrides DefaultResyncPeriod when set before Run.
+	ResyncPeriod time.Duration
+
+	trigger       chan struct{}
+	deadlineTimer *time.Timer
+	now           func() time.Time
+}
+
+// NewJobController creates a JobController.
+func NewJobController(jm workload.JobManager, tm taskmanager.TaskManager) *JobController {
+	return &JobController{
+		jobs:         jm,
+		taskManager:  tm,
+		ResyncPeriod: DefaultResyncPeriod,
+		trigger:      make(chan struct{}, 1),
+		now:          time.Now,
+	}
+}
+
+// Run watches jobs and tasks and reconciles whenever either changes, when
+// the deadline of a job passes, and in any case once per resync period,
+// until stopCh is closed.
+func (c *JobController) Run(stopCh <-chan struct{}) {
+	go watchLoop("jobs", c.jobs.Watch, c.trigger, stopCh)
+	go watchLoop("tasks", c.taskManager.Watch, c.trigger, stopCh)
+
+	ticker := time.NewTicker(c.ResyncPeriod)
+	defer ticker.Stop()
+
+	c.reconcile()
+	for {
+		select {
+		case <-c.trigger:
+			c.reconcile()
+		case <-ticker.C:
+			c.reconcile()
+		case <-stopCh:
+			if c.deadlineTimer != nil {
+				c.deadlineTimer.Stop()
+			}
+			log.Println("Job controller stopped")
+			return
+		}
+	}
+}
+
+// reconcile syncs every job and removes the tasks of those that are gone.
+func (c *JobController) reconcile() {
+	jobs, err := c.jobs.GetJobs()
+	if err != nil {
+		log.Printf("Error retrieving jobs: %v", err)
+		return
+	}
+	tasks, err := c.taskManager.GetTasks()
+	if err != nil {
+		log.Printf("Error retrieving tasks: %v", err)
+		return
+	}
+	taken := make(map[string]bool, len(tasks))
+	owned := make(map[string][]models.Task)
+	for _, t := range tasks {
+		taken[t.ID] = true
+		if t.Owner != nil && t.Owner.Kind == models.KindJob {
+			owned[t.Owner.ID] = append(owned[t.Owner.ID], t)
+		}
+	}
+
+	var nextDeadline time.Duration
+	for _, j := range jobs {
+		if wait := c.sync(j, owned[j.ID], taken); wait > 0 && (nextDeadline == 0 || wait < nextDeadline) {
+			nextDeadline = wait
+		}
+		delete(owned, j.ID)
+	}
+	for id, orphans := range owned {
+		for _, t := range orphans {
+			removeTask(c.taskManager, t, ReasonOwnerDeleted, "Job "+id+" was deleted")
+		}
+	}
+	if nextDeadline > 0 {
+		c.wakeAfter(nextDeadline)
+	}
+}
+
+// sync creates the tasks a job is missing, decides whether it has completed
+// or failed, and records its status. taken holds the IDs in use, to which
+// the IDs of new tasks are added. It returns how long the job has left
+// before its deadline, or zero if it has none.
+func (c *JobController) sync(j models.Job, owned []models.Task, taken map[string]bool) time.Duration {
+	now := c.now()
+	status := j.Status
+	if status.StartTime.IsZero() {
+		status.StartTime = now
+	}
+
+	var active []models.Task
+	succeeded, failed := 0, 0
+	for _, t := range owned {
+		failed += t.RestartCount
+		switch {
+		case isActive(t):
+			active = append(active, t)
+		case t.Status == models.TaskSucceeded:
+			succeeded++
+		default:
+			failed++
+		}
+	}
+
+	var wait time.Duration
+	if !j.IsFinished() {
+		// The counts of a finished job stay as they were when it finished.
+		status.Succeeded, status.Failed = succeeded, failed
+		var deadline time.Time
+		if j.ActiveDeadlineSeconds != nil {
+			deadline = status.StartTime.Add(time.Duration(*j.ActiveDeadlineSeconds) * time.Second)
+		}
+		switch {
+		case failed > j.FailureLimit():
+			status.Conditions = withJobCondition(status.Conditions, models.JobFailed, models.ReasonBackoffLimitExceeded,
+				fmt.Sprintf("Job has failed %d times, more than its backoff limit of %d", failed, j.FailureLimit()), now)
+		case !deadline.IsZero() && !now.Before(deadline):
+			status.Conditions = withJobCondition(status.Conditions, models.JobFailed, models.ReasonDeadlineExceeded,
+				fmt.Sprintf("Job was active for longer than %ds", *j.ActiveDeadlineSeconds), now)
+		case succeeded >= j.WantedCompletions():
+			status.Conditions = withJobCondition(status.Conditions, models.JobComplete, "",
+				fmt.Sprintf("%d tasks succeeded", succeeded), now)
+			status.CompletionTime = now
+		default:
+			active = c.createTasks(j, active, min(j.MaxParallel(), j.WantedCompletions()-succeeded), taken)
+			if !deadline.IsZero() {
+				wait = deadline.Sub(now)
+			}
+		}
+	}
+
+	previous := j.Status
+	j.Status = status
+	j.Status.Active = len(active)
+	if j.IsFinished() {
+		// Whatever still runs is no longer needed.
+		j.Status.Active = 0
+		for _, t := range active {
+			if !removeTask(c.taskManager, t, ReasonJobFinished, "Job "+j.ID+" has finished") {
+				j.Status.Active++
+			}
+		}
+	}
+	if reflect.DeepEqual(j.Status, previous) {
+		return wait
+	}
+	if cond := j.Condition(models.JobFailed); cond != nil && cond.LastTransitionTime.Equal(now) {
+		log.Printf("Job %s failed: %s", j.ID, cond.Message)
+	} else if cond := j.Condition(models.JobComplete); cond != nil && cond.LastTransitionTime.Equal(now) {
+		log.Printf("Job %s completed", j.ID)
+	}
+	// A conflict means the job changed, which triggers another pass.
+	if err := c.jobs.UpdateJobStatus(j); err != nil && !errors.Is(err, datastore.ErrConflict) {
+		log.Printf("Error updating status of job %s: %v", j.ID, err)
+	}
+	return wait
+}
+
+// createTasks creates tasks of a job until it has the wanted number of
+// active ones, and returns the active tasks.
+func (c *JobController) createTasks(j models.Job, active []models.Task, wanted int, taken map[string]bool) []models.Task {
+	owner := models.OwnerReference{Kind: models.KindJob, ID: j.ID}
+	for len(active) < wanted {
+		t := newTaskFromTemplate(j.Template, uniqueTaskID(j.ID, taken), owner)
+		if err := c.taskManager.CreateTask(t); err != nil {
+			log.Printf("Error creating task for job %s: %v", j.ID, err)
+			break
+		}
+		log.Printf("Job %s created task %s", j.ID, t.ID)
+		active = append(active, t)
+	}
+	return active
+}
+
+// wakeAfter makes the controller reconcile once d has passed.
+func (c *JobController) wakeAfter(d time.Duration) {
+	if c.deadlineTimer != nil {
+		c.deadlineTimer.Stop()
+	}
+	c.deadlineTimer = time.AfterFunc(d, func() {
+		select {
+		case c.trigger <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// withJobCondition returns conditions with a true condition of the given
+// type added.
+func withJobCondition(conditions []models.JobCondition, typ models.JobConditionType, reason, message string, now time.Time) []models.JobCondition {
+	return append(slices.Clone(conditions), models.JobCondition{
+		Type:               typ,
+		Status:             models.ConditionTrue,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	})
+}
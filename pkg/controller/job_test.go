@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+func newJobController(t *testing.T, j models.Job) (*JobController, workload.JobManager, taskmanager.TaskManager) {
+	t.Helper()
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	jm := workload.NewJobManager(ds)
+	if err := jm.CreateJob(j); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	return NewJobController(jm, tm), jm, tm
+}
+
+func getJob(t *testing.T, jm workload.JobManager, id string) *models.Job {
+	t.Helper()
+	j, err := jm.GetJob(id)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	return j
+}
+
+// activeTaskIDs returns the IDs of the unfinished tasks of a job.
+func activeTaskIDs(t *testing.T, tm taskmanager.TaskManager, jobID string) []string {
+	t.Helper()
+	var ids []string
+	for id, task := range ownedTasks(t, tm, models.KindJob, jobID) {
+		if !task.Status.IsTerminal() {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// TestJobController_Completes runs three completions two at a time.
+func TestJobController_Completes(t *testing.T) {
+	completions, parallelism := 3, 2
+	c, jm, tm := newJobController(t, models.Job{
+		ID:          "batch",
+		Completions: &completions,
+		Parallelism: &parallelism,
+		Template:    models.Task{Command: "true"},
+	})
+
+	c.reconcile()
+	ids := activeTaskIDs(t, tm, "batch")
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 tasks running in parallel, got %d", len(ids))
+	}
+	j := getJob(t, jm, "batch")
+	if j.Status.StartTime.IsZero() || j.Status.Active != 2 {
+		t.Errorf("Expected a started job with 2 active tasks, got %+v", j.Status)
+	}
+
+	moveTask(t, tm, ids[0], models.TaskScheduled, models.TaskRunning, models.TaskSucceeded)
+	c.reconcile()
+	if ids = activeTaskIDs(t, tm, "batch"); len(ids) != 2 {
+		t.Fatalf("Expected a replacement for the finished task, got %d active", len(ids))
+	}
+	for _, id := range ids {
+		moveTask(t, tm, id, models.TaskScheduled, models.TaskRunning, models.TaskSucceeded)
+	}
+	c.reconcile()
+	c.reconcile()
+
+	j = getJob(t, jm, "batch")
+	cond := j.Condition(models.JobComplete)
+	if cond == nil || cond.Status != models.ConditionTrue || j.Condition(models.JobFailed) != nil {
+		t.Fatalf("Expected the job to be complete, got %+v", j.Status)
+	}
+	if j.Status.Succeeded != 3 || j.Status.Active != 0 || j.Status.CompletionTime.IsZero() {
+		t.Errorf("Expected 3 succeeded tasks and a completion time, got %+v", j.Status)
+	}
+	if owned := ownedTasks(t, tm, models.KindJob, "batch"); len(owned) != 3 {
+		t.Errorf("Expected the 3 finished tasks to be kept, got %d", len(owned))
+	}
+}
+
+// TestJobController_BackoffLimit replaces failed tasks until the backoff
+// limit is exceeded.
+func TestJobController_BackoffLimit(t *testing.T) {
+	backoffLimit := 1
+	c, jm, tm := newJobController(t, models.Job{ID: "batch", BackoffLimit: &backoffLimit, Template: models.Task{Command: "false"}})
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		c.reconcile()
+		ids := activeTaskIDs(t, tm, "batch")
+		if len(ids) != 1 {
+			t.Fatalf("Attempt %d: expected 1 task, got %d", attempt, len(ids))
+		}
+		moveTask(t, tm, ids[0], models.TaskScheduled, models.TaskRunning, models.TaskFailed)
+	}
+	c.reconcile()
+
+	j := getJob(t, jm, "batch")
+	cond := j.Condition(models.JobFailed)
+	if cond == nil || cond.Reason != models.ReasonBackoffLimitExceeded || j.Status.Failed != 2 {
+		t.Fatalf("Expected the job to fail after 2 failures, got %+v", j.Status)
+	}
+	c.reconcile()
+	if owned := ownedTasks(t, tm, models.KindJob, "batch"); len(owned) != 2 {
+		t.Errorf("Expected no task to be created after the job failed, got %d", len(owned))
+	}
+}
+
+// TestJobController_DeadlineAndDeletion fails a job that runs for too long,
+// then deletes it.
+func TestJobController_DeadlineAndDeletion(t *testing.T) {
+	deadline := int64(10)
+	c, jm, tm := newJobController(t, models.Job{ID: "batch", ActiveDeadlineSeconds: &deadline, Template: models.Task{Command: "sleep"}})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.reconcile()
+	ids := activeTaskIDs(t, tm, "batch")
+	if len(ids) != 1 {
+		t.Fatalf("Expected 1 task, got %d", len(ids))
+	}
+	moveTask(t, tm, ids[0], models.TaskScheduled, models.TaskRunning)
+
+	now = now.Add(9 * time.Second)
+	c.reconcile()
+	if j := getJob(t, jm, "batch"); j.IsFinished() {
+		t.Fatalf("Expected the job to run until its deadline, got %+v", j.Status)
+	}
+
+	now = now.Add(time.Second)
+	c.reconcile()
+	j := getJob(t, jm, "batch")
+	if cond := j.Condition(models.JobFailed); cond == nil || cond.Reason != models.ReasonDeadlineExceeded || j.Status.Active != 0 {
+		t.Fatalf("Expected the job to fail at its deadline, got %+v", j.Status)
+	}
+	task, _ := tm.GetTask(ids[0])
+	if task.Status != models.TaskCancelled || task.Reason != ReasonJobFinished {
+		t.Errorf("Expected the running task to be cancelled, got %s (%s)", task.Status, task.Reason)
+	}
+
+	if err := jm.DeleteJob("batch"); err != nil {
+		t.Fatalf("Failed to delete job: %v", err)
+	}
+	c.reconcile()
+	if owned := ownedTasks(t, tm, models.KindJob, "batch"); len(owned) != 0 {
+		t.Errorf("Expected the tasks of the deleted job to be deleted, got %d", len(owned))
+	}
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+)
+
+const (
+	// DefaultNodeGracePeriod is how long a node may go without a heartbeat
+	// before it is marked NotReady.
+	DefaultNodeGracePeriod = 40 * time.Second
+	// DefaultNodeUnknownPeriod is how long a node may go without a heartbeat
+	// before it is marked Unknown.
+	DefaultNodeUnknownPeriod = 5 * time.Minute
+	// DefaultNodeMonitorPeriod is how often node leases are checked.
+	DefaultNodeMonitorPeriod = 5 * time.Second
+	// DefaultEvictionTimeout is how long tasks tolerate their node being
+	// unhealthy before they are evicted.
+	DefaultEvictionTimeout = 5 * time.Minute
+	// DefaultEvictionRate is the number of tasks evicted per second while
+	// most of the cluster is healthy.
+	DefaultEvictionRate = 0.1
+	// DefaultSecondaryEvictionRate is the eviction rate used once the share
+	// of unhealthy nodes reaches the unhealthy threshold.
+	DefaultSecondaryEvictionRate = 0.01
+	// DefaultUnhealthyThreshold is the share of unhealthy nodes at which
+	// evictions slow down to the secondary rate.
+	DefaultUnhealthyThreshold = 0.55
+)
+
+// NodeLifecycleController derives node health from heartbeats. A node whose
+// lease has not been renewed within the grace period is marked NotReady, and
+// after the longer unknown period it is marked Unknown. Nodes that have never
+// sent a heartbeat are left alone.
+//
+// Tasks bound to a node with a NoExecute taint are evicted in the same way
+// as soon as they do not tolerate the taint, or once the tolerationSeconds
+// of their toleration has passed. These evictions are not rate limited.
+//
+// Once a node has been unhealthy for longer than EvictionTimeout, the tasks
+// bound to it are evicted: they are marked failed with reason Evicted, after
+// which the ControllerManager reschedules them. Evictions are rate limited,
+// more strictly when a large share of the cluster is unhealthy, and stop
+// altogether when every node is unhealthy, since that usually means the
+// control plane is cut off from the nodes rather than the nodes being down.
+type NodeLifecycleController struct {
+	nodeManager   node.NodeManager
+	taskManager   taskmanager.TaskManager
+	gracePeriod   time.Duration
+	unknownPeriod time.Duration
+
+	// The fields below override their defaults when set before Run.
+	MonitorPeriod         time.Duration
+	EvictionTimeout       time.Duration
+	EvictionRate          float64
+	SecondaryEvictionRate float64
+	UnhealthyThreshold    float64
+
+	now       func() time.Time
+	evictions *tokenBucket
+}
+
+// NewNodeLifecycleController creates a NodeLifecycleController. The unknown
+// period must be longer than the grace period.
+func NewNodeLifecycleController(nm node.NodeManager, tm taskmanager.TaskManager, gracePeriod, unknownPeriod time.Duration) *NodeLifecycleController {
+	return &NodeLifecycleController{
+		nodeManager:           nm,
+		taskManager:           tm,
+		gracePeriod:           gracePeriod,
+		unknownPeriod:         unknownPeriod,
+		MonitorPeriod:         DefaultNodeMonitorPeriod,
+		EvictionTimeout:       DefaultEvictionTimeout,
+		EvictionRate:          DefaultEvictionRate,
+		SecondaryEvictionRate: DefaultSecondaryEvictionRate,
+		UnhealthyThreshold:    DefaultUnhealthyThreshold,
+		now:                   time.Now,
+	}
+}
+
+// Run checks node leases every monitor period until stopCh is closed.
+func (c *NodeLifecycleController) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(c.MonitorPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.reconcile()
+		case <-stopCh:
+			log.Println("Node lifecycle controller stopped")
+			return
+		}
+	}
+}
+
+// reconcile updates the condition of every node whose lease state changed,
+// then evicts tasks from nodes that have been unhealthy for too long, and
+// from nodes with NoExecute taints they do not tolerate.
+func (c *NodeLifecycleController) reconcile() {
+	now := c.now()
+	nodes := c.nodeManager.GetNodes()
+	for i := range nodes {
+		c.updateCondition(&nodes[i], now)
+	}
+	c.evict(nodes, now)
+	c.evictTainted(nodes, now)
+}
+
+// updateCondition moves n to the condition its lease age calls for.
+func (c *NodeLifecycleController) updateCondition(n *models.Node, now time.Time) {
+	if n.LastHeartbeatTime.IsZero() {
+		return
+	}
+	condition := c.conditionFor(now.Sub(n.LastHeartbeatTime))
+	if condition == n.Condition {
+		return
+	}
+
+	previous := *n
+	n.Condition = condition
+	n.Healthy = condition == models.NodeReady
+	n.LastTransitionTime = now
+	// The write is conditional on the version just read, so a heartbeat
+	// that lands in between wins and is not overwritten.
+	if err := c.nodeManager.UpdateNode(*n); err != nil {
+		if !errors.Is(err, datastore.ErrConflict) {
+			log.Printf("Error updating condition of Node %s: %v", n.ID, err)
+		}
+		*n = previous
+		return
+	}
+	log.Printf("Node %s changed from %s to %s, last heartbeat %s ago", n.ID, previous.Condition, condition, now.Sub(n.LastHeartbeatTime).Round(time.Second))
+}
+
+// evict fails the tasks bound to nodes that have been unhealthy for longer
+// than the eviction timeout, as fast as the rate limiter allows.
+func (c *NodeLifecycleController) evict(nodes []models.Node, now time.Time) {
+	expired := make(map[string]bool)
+	unhealthy := 0
+	for _, n := range nodes {
+		if n.Healthy {
+			continue
+		}
+		unhealthy++
+		if now.Sub(n.LastTransitionTime) >= c.EvictionTimeout {
+			expired[n.ID] = true
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+	if unhealthy == len(nodes) {
+		log.Printf("All %d nodes are unhealthy, not evicting any tasks", len(nodes))
+		return
+	}
+
+	rate := c.EvictionRate
+	if float64(unhealthy)/float64(len(nodes)) >= c.UnhealthyThreshold {
+		rate = c.SecondaryEvictionRate
+	}
+	if c.evictions == nil {
+		c.evictions = newTokenBucket(rate, 1, now)
+	} else {
+		c.evictions.setRate(rate, now)
+	}
+
+	tasks, err := c.taskManager.GetTasks()
+	if err != nil {
+		log.Printf("Error retrieving tasks: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		if !expired[task.NodeID] || task.Status.IsTerminal() {
+			continue
+		}
+		if !c.evictions.allow(now) {
+			return
+		}
+		nodeID := task.NodeID
+		task.Status = models.TaskFailed
+		task.Reason = models.ReasonEvicted
+		task.Message = "Node " + nodeID + " was unhealthy for longer than " + c.EvictionTimeout.String()
+		if err := c.taskManager.UpdateTask(task); err != nil {
+			log.Printf("Error evicting task %s from Node %s: %v", task.ID, nodeID, err)
+			continue
+		}
+		log.Printf("Evicted task %s from unhealthy Node %s", task.ID, nodeID)
+	}
+}
+
+// evictTainted fails the tasks bound to nodes with NoExecute taints that
+// they do not tolerate, or no longer do.
+func (c *NodeLifecycleController) evictTainted(nodes []models.Node, now time.Time) {
+	tainted := make(map[string]models.Node)
+	for _, n := range nodes {
+		for _, taint := range n.Taints {
+			if taint.Effect == models.TaintNoExecute {
+				tainted[n.ID] = n
+				break
+			}
+		}
+	}
+	if len(tainted) == 0 {
+		return
+	}
+
+	tasks, err := c.taskManager.GetTasks()
+	if err != nil {
+		log.Printf("Error retrieving tasks: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		n, ok := tainted[task.NodeID]
+		if !ok || task.Status.IsTerminal() {
+			continue
+		}
+		for _, taint := range n.Taints {
+			if taint.Effect != models.TaintNoExecute {
+				continue
+			}
+			if at, evict := task.EvictionTime(taint); !evict || now.Before(at) {
+				continue
+			}
+			task.Status = models.TaskFailed
+			task.Reason = models.ReasonEvicted
+			task.Message = "Node " + n.ID + " has taint " + taint.String() + " that the task does not tolerate"
+			if task.Tolerates(taint) {
+				task.Message = "Node " + n.ID + " has taint " + taint.String() + " that the task no longer tolerates"
+			}
+			if err := c.taskManager.UpdateTask(task); err != nil {
+				log.Printf("Error evicting task %s from Node %s: %v", task.ID, n.ID, err)
+			} else {
+				log.Printf("Evicted task %s from Node %s for taint %s", task.ID, n.ID, taint)
+			}
+			break
+		}
+	}
+}
+
+// conditionFor maps the time since the last heartbeat to a node condition.
+func (c *NodeLifecycleController) conditionFor(sinceHeartbeat time.Duration) models.NodeCondition {
+	switch {
+	case sinceHeartbeat >= c.unknownPeriod:
+		return models.NodeUnknown
+	case sinceHeartbeat >= c.gracePeriod:
+		return models.NodeNotReady
+	}
+	return models.NodeReady
+}
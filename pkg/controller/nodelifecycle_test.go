@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/node"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+)
+
+// TestNodeLifecycleController_LeaseExpiry walks a node through Ready, NotReady
+// and Unknown as its heartbeat ages, and back to Ready when it returns.
+func TestNodeLifecycleController_LeaseExpiry(t *testing.T) {
+	nm := node.NewManager(datastore.NewInMemoryDatastore())
+	if err := nm.Register(models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("Failed to register node: %v", err)
+	}
+	// A node that never heartbeats is managed by hand and never expires.
+	if err := nm.Register(models.Node{ID: "manual", Healthy: true}); err != nil {
+		t.Fatalf("Failed to register node: %v", err)
+	}
+	hb, err := nm.Heartbeat("node-1")
+	if err != nil {
+		t.Fatalf("Failed to heartbeat: %v", err)
+	}
+	if hb.LastHeartbeatTime.IsZero() || hb.Condition != models.NodeReady {
+		t.Fatalf("Expected heartbeat to mark node Ready, got %+v", hb)
+	}
+
+	c := NewNodeLifecycleController(nm, taskmanager.NewTaskManager(datastore.NewInMemoryDatastore()), 40*time.Second, 5*time.Minute)
+	start := hb.LastHeartbeatTime
+	steps := []struct {
+		elapsed time.Duration
+		want    models.NodeCondition
+	}{
+		{10 * time.Second, models.NodeReady},
+		{41 * time.Second, models.NodeNotReady},
+		{4 * time.Minute, models.NodeNotReady},
+		{6 * time.Minute, models.NodeUnknown},
+	}
+	for _, step := range steps {
+		c.now = func() time.Time { return start.Add(step.elapsed) }
+		c.reconcile()
+
+		n, err := nm.GetNode("node-1")
+		if err != nil {
+			t.Fatalf("Failed to get node: %v", err)
+		}
+		if n.Condition != step.want || n.Healthy != (step.want == models.NodeReady) {
+			t.Errorf("After %s: expected %s (healthy=%v), got %s (healthy=%v)", step.elapsed, step.want, step.want == models.NodeReady, n.Condition, n.Healthy)
+		}
+		manual, _ := nm.GetNode("manual")
+		if !manual.Healthy {
+			t.Errorf("After %s: expected node without heartbeats to stay healthy", step.elapsed)
+		}
+	}
+
+	// A fresh heartbeat restores the node.
+	if _, err := nm.Heartbeat("node-1"); err != nil {
+		t.Fatalf("Failed to heartbeat: %v", err)
+	}
+	c.now = time.Now
+	c.reconcile()
+	n, _ := nm.GetNode("node-1")
+	if n.Condition != models.NodeReady || !n.Healthy {
+		t.Errorf("Expected node to be Ready again, got %s", n.Condition)
+	}
+}
+
+// newEvictionFixture registers the given nodes and binds one running task to
+// each. Only the heartbeating nodes send a heartbeat, so the others never
+// expire. It returns the controller and the time of the last heartbeat.
+func newEvictionFixture(t *testing.T, heartbeating []string, manual ...string) (*NodeLifecycleController, taskmanager.TaskManager, time.Time) {
+	t.Helper()
+	ds := datastore.NewInMemoryDatastore()
+	nm := node.NewManager(ds)
+	tm := taskmanager.NewTaskManager(ds)
+	var start time.Time
+	for _, id := range append(append([]string(nil), heartbeating...), manual...) {
+		if err := nm.Register(models.Node{ID: id, Healthy: true}); err != nil {
+			t.Fatalf("Failed to register node: %v", err)
+		}
+		taskID := "task-" + id
+		if err := tm.CreateTask(models.Task{ID: taskID}); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		for _, phase := range []models.TaskPhase{models.TaskScheduled, models.TaskRunning} {
+			task, _ := tm.GetTask(taskID)
+			task.Status = phase
+			task.NodeID = id
+			if err := tm.UpdateTask(*task); err != nil {
+				t.Fatalf("Failed to move task to %s: %v", phase, err)
+			}
+		}
+	}
+	for _, id := range heartbeating {
+		hb, err := nm.Heartbeat(id)
+		if err != nil {
+			t.Fatalf("Failed to heartbeat: %v", err)
+		}
+		start = hb.LastHeartbeatTime
+	}
+	c := NewNodeLifecycleController(nm, tm, 40*time.Second, 5*time.Minute)
+	c.EvictionTimeout = time.Minute
+	return c, tm, start
+}
+
+// TestNodeLifecycleController_EvictsAfterTimeout checks that tasks survive a
+// short outage and are evicted once their node stays unhealthy.
+func TestNodeLifecycleController_EvictsAfterTimeout(t *testing.T) {
+	c, tm, start := newEvictionFixture(t, []string{"node-1"}, "node-2")
+
+	c.now = func() time.Time { return start.Add(41 * time.Second) }
+	c.reconcile()
+	task, _ := tm.GetTask("task-node-1")
+	if task.Status != models.TaskRunning {
+		t.Fatalf("Expected task to keep running within the eviction timeout, got %s", task.Status)
+	}
+	n, _ := c.nodeManager.GetNode("node-1")
+	if n.Healthy || !n.LastTransitionTime.Equal(start.Add(41*time.Second)) {
+		t.Fatalf("Expected node-1 NotReady since the reconcile, got %+v", n)
+	}
+
+	c.now = func() time.Time { return start.Add(41*time.Second + time.Minute) }
+	c.reconcile()
+	task, _ = tm.GetTask("task-node-1")
+	if task.Status != models.TaskFailed || task.Reason != models.ReasonEvicted || task.Message == "" {
+		t.Errorf("Expected task to be evicted, got %+v", task)
+	}
+	if other, _ := tm.GetTask("task-node-2"); other.Status != models.TaskRunning {
+		t.Errorf("Expected task on the healthy node to keep running, got %s", other.Status)
+	}
+}
+
+// TestNodeLifecycleController_EvictionRateLimit checks that evictions are
+// spread out according to the eviction rate.
+func TestNodeLifecycleController_EvictionRateLimit(t *testing.T) {
+	c, tm, start := newEvictionFixture(t, []string{"a", "b", "c", "d"}, "e")
+	c.EvictionRate = 0.1
+	c.UnhealthyThreshold = 1
+
+	evicted := func() int {
+		tasks, _ := tm.GetTasks()
+		count := 0
+		for _, task := range tasks {
+			if task.Reason == models.ReasonEvicted {
+				count++
+			}
+		}
+		return count
+	}
+
+	c.now = func() time.Time { return start.Add(41 * time.Second) }
+	c.reconcile()
+	steps := []struct {
+		elapsed time.Duration
+		want    int
+	}{
+		{2 * time.Minute, 1},
+		{2*time.Minute + time.Second, 1},
+		{2*time.Minute + 10*time.Second, 2},
+		{2*time.Minute + 20*time.Second, 3},
+		// Tokens do not pile up beyond a burst of one.
+		{2*time.Minute + 50*time.Second, 4},
+	}
+	for _, step := range steps {
+		c.now = func() time.Time { return start.Add(step.elapsed) }
+		c.reconcile()
+		if got := evicted(); got != step.want {
+			t.Errorf("After %s: expected %d evictions, got %d", step.elapsed, step.want, got)
+		}
+	}
+}
+
+// TestNodeLifecycleController_NoEvictionWhenAllUnhealthy checks that a
+// cluster-wide outage, which usually means the control plane lost contact
+// with the nodes, does not trigger evictions.
+func TestNodeLifecycleController_NoEvictionWhenAllUnhealthy(t *testing.T) {
+	c, tm, start := newEvictionFixture(t, []string{"node-1", "node-2"})
+	c.now = func() time.Time { return start.Add(41 * time.Second) }
+	c.reconcile()
+	c.now = func() time.Time { return start.Add(10 * time.Minute) }
+	c.reconcile()
+
+	tasks, _ := tm.GetTasks()
+	for _, task := range tasks {
+		if task.Status != models.TaskRunning {
+			t.Errorf("Expected task %s to keep running, got %s", task.ID, task.Status)
+		}
+	}
+}
+
+// TestNodeLifecycleController_NoExecuteTaint evicts the tasks of a node with
+// a NoExecute taint right away when they do not tolerate it, after their
+// tolerationSeconds when they do for a while, and never when they do for
+// good.
+func TestNodeLifecycleController_NoExecuteTaint(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	nm := node.NewManager(ds)
+	tm := taskmanager.NewTaskManager(ds)
+	if err := nm.Register(models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("Failed to register node: %v", err)
+	}
+	minute := int64(60)
+	for id, tolerations := range map[string][]models.Toleration{
+		"intolerant":  nil,
+		"for-a-while": {{Key: "maintenance", Operator: models.TolerationOpExists, Effect: models.TaintNoExecute, TolerationSeconds: &minute}},
+		"for-good":    {{Key: "maintenance", Operator: models.TolerationOpExists}},
+	} {
+		if err := tm.CreateTask(models.Task{ID: id, Tolerations: tolerations}); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		if err := tm.UpdateTask(models.Task{ID: id, Status: models.TaskScheduled, NodeID: "node-1", Tolerations: tolerations}); err != nil {
+			t.Fatalf("Failed to bind task: %v", err)
+		}
+	}
+	n, err := nm.UpdateTaints("node-1", []models.Taint{{Key: "maintenance", Effect: models.TaintNoExecute}})
+	if err != nil {
+		t.Fatalf("Failed to taint node: %v", err)
+	}
+	added := n.Taints[0].TimeAdded
+
+	c := NewNodeLifecycleController(nm, tm, 40*time.Second, 5*time.Minute)
+	phases := func() map[string]models.TaskPhase {
+		out := make(map[string]models.TaskPhase)
+		for _, id := range []string{"intolerant", "for-a-while", "for-good"} {
+			task, _ := tm.GetTask(id)
+			out[id] = task.Status
+		}
+		return out
+	}
+
+	c.now = func() time.Time { return added.Add(time.Second) }
+	c.reconcile()
+	if got := phases(); got["intolerant"] != models.TaskFailed || got["for-a-while"] != models.TaskScheduled || got["for-good"] != models.TaskScheduled {
+		t.Fatalf("Expected only the intolerant task to be evicted, got %v", got)
+	}
+	evicted, _ := tm.GetTask("intolerant")
+	if evicted.Reason != models.ReasonEvicted || evicted.Message != "Node node-1 has taint maintenance:NoExecute that the task does not tolerate" {
+		t.Errorf("Expected an eviction for the taint, got %+v", evicted)
+	}
+
+	c.now = func() time.Time { return added.Add(time.Minute) }
+	c.reconcile()
+	if got := phases(); got["for-a-while"] != models.TaskFailed || got["for-good"] != models.TaskScheduled {
+		t.Errorf("Expected the task tolerating the taint for a minute to be evicted after it, got %v", got)
+	}
+}
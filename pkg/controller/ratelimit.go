@@ -0,0 +1,42 @@
+package controller
+
+import "time"
+
+// tokenBucket is a rate limiter refilled at a fixed rate up to a burst size.
+// It takes the current time as an argument so callers can inject a clock.
+type tokenBucket struct {
+	rate   float64 // tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// setRate changes the refill rate, keeping the tokens accumulated so far.
+func (b *tokenBucket) setRate(rate float64, now time.Time) {
+	b.refill(now)
+	b.rate = rate
+}
+
+// allow takes a token if one is available.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	b := newTokenBucket(0.5, 2, start)
+
+	// The bucket starts full.
+	if !b.allow(start) || !b.allow(start) {
+		t.Fatal("Expected the initial burst to be allowed")
+	}
+	if b.allow(start) {
+		t.Fatal("Expected the bucket to be empty after the burst")
+	}
+	if b.allow(start.Add(time.Second)) {
+		t.Error("Expected half a token after one second to be refused")
+	}
+	if !b.allow(start.Add(2 * time.Second)) {
+		t.Error("Expected a token after two seconds")
+	}
+
+	// Tokens never accumulate beyond the burst.
+	later := start.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if !b.allow(later) {
+			t.Fatalf("Expected token %d of the refilled burst", i+1)
+		}
+	}
+	if b.allow(later) {
+		t.Error("Expected the burst to cap accumulated tokens")
+	}
+
+	// Lowering the rate slows down the refill.
+	b.setRate(0.1, later)
+	if b.allow(later.Add(5 * time.Second)) {
+		t.Error("Expected no token after five seconds at the lower rate")
+	}
+	if !b.allow(later.Add(10 * time.Second)) {
+		t.Error("Expected a token after ten seconds at the lower rate")
+	}
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// ReasonScaledDown is the Reason of a task cancelled because its replica set
+// wanted fewer replicas, and ReasonOwnerDeleted of one cancelled because the
+// object that owned it was deleted.
+const (
+	ReasonScaledDown   = "ScaledDown"
+	ReasonOwnerDeleted = "OwnerDeleted"
+)
+
+// ReplicaSetController creates and removes the tasks of every replica set
+// until each has as many as it asks for.
+//
+// A replica set owns the tasks it created, as recorded in their Owner, as
+// long as they match its selector. It adopts tasks without an owner that
+// match, and releases its tasks once they no longer match. Tasks that are
+// no longer needed are cancelled, so that their node agents stop them, and
+// deleted once they have finished; so are the tasks of deleted replica sets.
+type ReplicaSetController struct {
+	replicaSets workload.ReplicaSetManager
+	taskManager taskmanager.TaskManager
+
+	// ResyncPeriod overrides DefaultResyncPeriod when set before Run.
+	ResyncPeriod time.Duration
+
+	trigger chan struct{}
+}
+
+// NewReplicaSetController creates a ReplicaSetController.
+func NewReplicaSetController(rsm workload.ReplicaSetManager, tm taskmanager.TaskManager) *ReplicaSetController {
+	return &ReplicaSetController{
+		replicaSets:  rsm,
+		taskManager:  tm,
+		ResyncPeriod: DefaultResyncPeriod,
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// Run watches replica sets and tasks and reconciles whenever either
+// changes, and in any case once per resync period, until stopCh is closed.
+func (c *ReplicaSetController) Run(stopCh <-chan struct{}) {
+	go watchLoop("replica sets", c.replicaSets.Watch, c.trigger, stopCh)
+	go watchLoop("tasks", c.taskManager.Watch, c.trigger, stopCh)
+
+	ticker := time.NewTicker(c.ResyncPeriod)
+	defer ticker.Stop()
+
+	c.reconcile()
+	for {
+		select {
+		case <-c.trigger:
+			c.reconcile()
+		case <-ticker.C:
+			c.reconcile()
+		case <-stopCh:
+			log.Println("Replica set controller stopped")
+			return
+		}
+	}
+}
+
+// reconcile syncs every replica set and removes the tasks of those that are
+// gone.
+func (c *ReplicaSetController) reconcile() {
+	sets, err := c.replicaSets.GetReplicaSets()
+	if err != nil {
+		log.Printf("Error retrieving replica sets: %v", err)
+		return
+	}
+	tasks, err := c.taskManager.GetTasks()
+	if err != nil {
+		log.Printf("Error retrieving tasks: %v", err)
+		return
+	}
+	taken := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		taken[t.ID] = true
+	}
+
+	exists := make(map[string]bool, len(sets))
+	for _, rs := range sets {
+		exists[rs.ID] = true
+		c.sync(rs, c.claim(rs, tasks), taken)
+	}
+	for _, t := range tasks {
+		if t.Owner != nil && t.Owner.Kind == models.KindReplicaSet && !exists[t.Owner.ID] {
+			removeTask(c.taskManager, t, ReasonOwnerDeleted, "Replica set "+t.Owner.ID+" was deleted")
+		}
+	}
+}
+
+// claim returns the tasks of a replica set, adopting and releasing tasks as
+// the selector says.
+func (c *ReplicaSetController) claim(rs models.ReplicaSet, tasks []models.Task) []models.Task {
+	var owned []models.Task
+	for _, t := range tasks {
+		ours := t.Owner != nil && t.Owner.Kind == models.KindReplicaSet && t.Owner.ID == rs.ID
+		matches := rs.Selector.Matches(t.Labels)
+		switch {
+		case ours && matches:
+			owned = append(owned, t)
+		case ours:
+			t.Owner = nil
+			if err := c.taskManager.UpdateTask(t); err != nil {
+				log.Printf("Error releasing task %s from replica set %s: %v", t.ID, rs.ID, err)
+			}
+		case t.Owner == nil && matches && !t.Status.IsTerminal():
+			t.Owner = &models.OwnerReference{Kind: models.KindReplicaSet, ID: rs.ID}
+			if err := c.taskManager.UpdateTask(t); err != nil {
+				log.Printf("Error adopting task %s into replica set %s: %v", t.ID, rs.ID, err)
+				continue
+			}
+			// Pick up the new resource version for later updates.
+			if adopted, err := c.taskManager.GetTask(t.ID); err == nil {
+				t = *adopted
+			}
+			owned = append(owned, t)
+		}
+	}
+	return owned
+}
+
+// sync creates or cancels tasks of a replica set to match its replica count,
+// deletes its finished tasks and records its status. taken holds the IDs in
+// use, to which the IDs of new tasks are added.
+func (c *ReplicaSetController) sync(rs models.ReplicaSet, owned []models.Task, taken map[string]bool) {
+	var active []models.Task
+	ready := 0
+	for _, t := range owned {
+		if !isActive(t) {
+			removeTask(c.taskManager, t, "", "")
+			continue
+		}
+		active = append(active, t)
+		if t.IsReady() {
+			ready++
+		}
+	}
+
+	switch diff := rs.Replicas - len(active); {
+	case diff > 0:
+		owner := models.OwnerReference{Kind: models.KindReplicaSet, ID: rs.ID}
+		for range diff {
+			t := newTaskFromTemplate(rs.Template, uniqueTaskID(rs.ID, taken), owner)
+			if err := c.taskManager.CreateTask(t); err != nil {
+				log.Printf("Error creating task for replica set %s: %v", rs.ID, err)
+				break
+			}
+			log.Printf("Replica set %s created task %s", rs.ID, t.ID)
+		}
+	case diff < 0:
+		sort.SliceStable(active, func(i, j int) bool { return deleteFirst(active[i], active[j]) })
+		for _, t := range active[:-diff] {
+			if removeTask(c.taskManager, t, ReasonScaledDown, "Replica set "+rs.ID+" was scaled down") {
+				log.Printf("Replica set %s removed task %s", rs.ID, t.ID)
+			}
+		}
+	}
+
+	status := models.ReplicaSetStatus{Replicas: len(active), ReadyReplicas: ready}
+	if status == rs.Status {
+		return
+	}
+	rs.Status = status
+	// A conflict means the replica set changed, which triggers another pass.
+	if err := c.replicaSets.UpdateReplicaSetStatus(rs); err != nil && !errors.Is(err, datastore.ErrConflict) {
+		log.Printf("Error updating status of replica set %s: %v", rs.ID, err)
+	}
+}
+
+// removeTask cancels a task that has not finished, with the given reason,
+// and deletes one that has. It reports whether it succeeded.
+func removeTask(tm taskmanager.TaskManager, t models.Task, reason, message string) bool {
+	if t.Status.IsTerminal() {
+		if err := tm.DeleteTask(t.ID); err != nil && !errors.Is(err, taskmanager.ErrTaskNotFound) {
+			log.Printf("Error deleting task %s: %v", t.ID, err)
+			return false
+		}
+		return true
+	}
+	t.Status = models.TaskCancelled
+	t.Reason = reason
+	t.Message = message
+	if err := tm.UpdateTask(t); err != nil {
+		log.Printf("Error cancelling task %s: %v", t.ID, err)
+		return false
+	}
+	return true
+}
+
+// isActive reports whether a task is running or will run again, including a
+// failed task the controller manager is going to reschedule.
+func isActive(t models.Task) bool {
+	if !t.Status.IsTerminal() {
+		return true
+	}
+	return t.Status == models.TaskFailed && (t.Reason == models.ReasonEvicted || t.RestartAllowed(true))
+}
+
+// deleteFirst orders the tasks of a workload by how cheap they are to give
+// up: unscheduled before scheduled, pending before running, unready before
+// ready, more restarts before fewer, and newer before older.
+func deleteFirst(a, b models.Task) bool {
+	if (a.NodeID == "") != (b.NodeID == "") {
+		return a.NodeID == ""
+	}
+	if ra, rb := phaseRank(a.Status), phaseRank(b.Status); ra != rb {
+		return ra < rb
+	}
+	if a.IsReady() != b.IsReady() {
+		return !a.IsReady()
+	}
+	if a.RestartCount != b.RestartCount {
+		return a.RestartCount > b.RestartCount
+	}
+	return createdAt(a).After(createdAt(b))
+}
+
+// phaseRank orders phases by how far a task in them has got.
+func phaseRank(p models.TaskPhase) int {
+	switch p {
+	case models.TaskPending:
+		return 0
+	case models.TaskScheduled:
+		return 1
+	case models.TaskUnknown:
+		return 2
+	}
+	return 3
+}
+
+// createdAt returns when a task was created, as recorded in its first
+// transition.
+func createdAt(t models.Task) time.Time {
+	if len(t.Transitions) == 0 {
+		return time.Time{}
+	}
+	return t.Transitions[0].Time
+}
+
+// newTaskFromTemplate makes a task from a workload's template, dropping
+// whatever state the template carries.
+func newTaskFromTemplate(template models.Task, id string, owner models.OwnerReference) models.Task {
+	t := template
+	t.ID = id
+	t.Owner = &owner
+	t.Status = ""
+	t.ResourceVersion = 0
+	t.NodeID = ""
+	t.Reason = ""
+	t.Message = ""
+	t.ExitCode = nil
+	t.RestartCount = 0
+	t.LastTerminationReason = ""
+	t.Conditions = nil
+	t.Transitions = nil
+	return t
+}
+
+// idAlphabet is used for the random part of generated task IDs.
+const idAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+// uniqueTaskID returns prefix followed by a random suffix that is not in
+// taken, and marks it taken.
+func uniqueTaskID(prefix string, taken map[string]bool) string {
+	for {
+		suffix := make([]byte, 5)
+		for i := range suffix {
+			suffix[i] = idAlphabet[rand.IntN(len(idAlphabet))]
+		}
+		id := prefix + "-" + string(suffix)
+		if !taken[id] {
+			taken[id] = true
+			return id
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/taskmanager"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+// ownedTasks returns the tasks of an owner by ID.
+func ownedTasks(t *testing.T, tm taskmanager.TaskManager, kind, ownerID string) map[string]models.Task {
+	t.Helper()
+	tasks, err := tm.GetTasks()
+	if err != nil {
+		t.Fatalf("Failed to get tasks: %v", err)
+	}
+	owned := make(map[string]models.Task)
+	for _, task := range tasks {
+		if task.Owner != nil && task.Owner.Kind == kind && task.Owner.ID == ownerID {
+			owned[task.ID] = task
+		}
+	}
+	return owned
+}
+
+// moveTask walks a task through the given phases.
+func moveTask(t *testing.T, tm taskmanager.TaskManager, id string, phases ...models.TaskPhase) {
+	t.Helper()
+	for _, phase := range phases {
+		task, err := tm.GetTask(id)
+		if err != nil {
+			t.Fatalf("Failed to get task %s: %v", id, err)
+		}
+		task.Status = phase
+		task.NodeID = "node-1"
+		if phase == models.TaskRunning {
+			task.SetCondition(models.TaskCondition{Type: models.TaskReady, Status: models.ConditionTrue}, time.Now())
+		}
+		if err := tm.UpdateTask(*task); err != nil {
+			t.Fatalf("Failed to move task %s to %s: %v", id, phase, err)
+		}
+	}
+}
+
+// TestReplicaSetController_Converges scales a replica set up and down and
+// checks which tasks are given up, then deletes it.
+func TestReplicaSetController_Converges(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	rsm := workload.NewReplicaSetManager(ds)
+	c := NewReplicaSetController(rsm, tm)
+
+	labels := map[string]string{"app": "web"}
+	if err := rsm.CreateReplicaSet(models.ReplicaSet{
+		ID:       "web",
+		Replicas: 3,
+		Selector: models.LabelSelector{MatchLabels: labels},
+		Template: models.Task{Labels: labels, Command: "nginx"},
+	}); err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+
+	c.reconcile()
+	owned := ownedTasks(t, tm, models.KindReplicaSet, "web")
+	if len(owned) != 3 {
+		t.Fatalf("Expected 3 tasks, got %d", len(owned))
+	}
+	var ids []string
+	for id, task := range owned {
+		if task.Status != models.TaskPending || task.Labels["app"] != "web" || task.Command != "nginx" || task.RestartPolicy != models.RestartAlways {
+			t.Errorf("Expected a pending copy of the template, got %+v", task)
+		}
+		ids = append(ids, id)
+	}
+	c.reconcile()
+	if len(ownedTasks(t, tm, models.KindReplicaSet, "web")) != 3 {
+		t.Fatal("Expected a second pass not to create more tasks")
+	}
+
+	// One replica runs and is ready, one is bound to a node, one is pending.
+	moveTask(t, tm, ids[0], models.TaskScheduled, models.TaskRunning)
+	moveTask(t, tm, ids[1], models.TaskScheduled)
+	c.reconcile()
+	rs, _ := rsm.GetReplicaSet("web")
+	if rs.Status != (models.ReplicaSetStatus{Replicas: 3, ReadyReplicas: 1}) {
+		t.Errorf("Expected 3 replicas with 1 ready, got %+v", rs.Status)
+	}
+
+	// A matching task without an owner is adopted, and being pending it is
+	// among the first to go when scaling down.
+	if err := tm.CreateTask(models.Task{ID: "stray", Labels: map[string]string{"app": "web"}}); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if err := rsm.Scale("web", 2); err != nil {
+		t.Fatalf("Failed to scale: %v", err)
+	}
+	c.reconcile()
+	owned = ownedTasks(t, tm, models.KindReplicaSet, "web")
+	for _, id := range []string{"stray", ids[2]} {
+		if owned[id].Status != models.TaskCancelled || owned[id].Reason != ReasonScaledDown {
+			t.Errorf("Expected pending task %s to be cancelled, got %+v", id, owned[id])
+		}
+	}
+	for _, id := range ids[:2] {
+		if owned[id].Status.IsTerminal() {
+			t.Errorf("Expected task %s to be kept, got %s", id, owned[id].Status)
+		}
+	}
+
+	// Cancelled tasks are deleted on the next pass.
+	c.reconcile()
+	if owned = ownedTasks(t, tm, models.KindReplicaSet, "web"); len(owned) != 2 {
+		t.Errorf("Expected 2 tasks left, got %d", len(owned))
+	}
+
+	// Deleting the replica set stops its tasks, then deletes them.
+	if err := rsm.DeleteReplicaSet("web"); err != nil {
+		t.Fatalf("Failed to delete replica set: %v", err)
+	}
+	c.reconcile()
+	for id, task := range ownedTasks(t, tm, models.KindReplicaSet, "web") {
+		if task.Status != models.TaskCancelled || task.Reason != ReasonOwnerDeleted {
+			t.Errorf("Expected task %s of the deleted replica set to be cancelled, got %+v", id, task)
+		}
+	}
+	c.reconcile()
+	if owned = ownedTasks(t, tm, models.KindReplicaSet, "web"); len(owned) != 0 {
+		t.Errorf("Expected no tasks left, got %d", len(owned))
+	}
+}
+
+// TestReplicaSetController_ReplacesFinishedTasks checks that only tasks that
+// will not run again are replaced.
+func TestReplicaSetController_ReplacesFinishedTasks(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+	rsm := workload.NewReplicaSetManager(ds)
+	c := NewReplicaSetController(rsm, tm)
+
+	labels := map[string]string{"app": "web"}
+	if err := rsm.CreateReplicaSet(models.ReplicaSet{
+		ID:       "web",
+		Replicas: 2,
+		Selector: models.LabelSelector{MatchLabels: labels},
+		Template: models.Task{Labels: labels},
+	}); err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	c.reconcile()
+	var ids []string
+	for id := range ownedTasks(t, tm, models.KindReplicaSet, "web") {
+		ids = append(ids, id)
+	}
+
+	// A failed task that restarts under its policy still counts; one that
+	// was cancelled by hand is replaced.
+	moveTask(t, tm, ids[0], models.TaskScheduled, models.TaskRunning, models.TaskFailed)
+	moveTask(t, tm, ids[1], models.TaskCancelled)
+	c.reconcile()
+
+	owned := ownedTasks(t, tm, models.KindReplicaSet, "web")
+	if _, ok := owned[ids[0]]; !ok {
+		t.Errorf("Expected the restartable task %s to be kept", ids[0])
+	}
+	if _, ok := owned[ids[1]]; ok {
+		t.Errorf("Expected the cancelled task %s to be deleted", ids[1])
+	}
+	if len(owned) != 2 {
+		t.Errorf("Expected 2 tasks, got %d", len(owned))
+	}
+}
@@ -0,0 +1,87 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@every 5m",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	utc := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	for _, tc := range []struct {
+		expr, from, want string
+	}{
+		{"* * * * *", "2025-03-10 12:00", "2025-03-10 12:01"},
+		{"*/15 * * * *", "2025-03-10 12:07", "2025-03-10 12:15"},
+		{"0 * * * *", "2025-03-10 12:00", "2025-03-10 13:00"},
+		{"30 2 * * *", "2025-03-10 12:00", "2025-03-11 02:30"},
+		{"0 9-17/4 * * *", "2025-03-10 14:00", "2025-03-10 17:00"},
+		{"0 0 1,15 * *", "2025-03-02 00:00", "2025-03-15 00:00"},
+		{"0 0 * * MON-FRI", "2025-03-08 10:00", "2025-03-10 00:00"},
+		{"0 0 * * 7", "2025-03-10 00:00", "2025-03-16 00:00"},
+		{"0 0 1 jan *", "2025-03-10 00:00", "2026-01-01 00:00"},
+		{"@monthly", "2025-12-31 23:59", "2026-01-01 00:00"},
+		{"@hourly", "2025-03-10 23:30", "2025-03-11 00:00"},
+		// Restricted day of month and day of week: either one fires.
+		{"0 0 13 * fri", "2025-03-10 00:00", "2025-03-13 00:00"},
+		{"0 0 13 * fri", "2025-03-13 00:00", "2025-03-14 00:00"},
+		{"0 0 29 2 *", "2025-03-01 00:00", "2028-02-29 00:00"},
+	} {
+		s, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.expr, err)
+		}
+		if got := s.Next(utc(tc.from)); !got.Equal(utc(tc.want)) {
+			t.Errorf("%q after %s: got %s, want %s", tc.expr, tc.from, got.Format("2006-01-02 15:04"), tc.want)
+		}
+	}
+
+	never, _ := Parse("0 0 30 2 *")
+	if got := never.Next(utc("2025-01-01 00:00")); !got.IsZero() {
+		t.Errorf("expected February 30th never to fire, got %s", got)
+	}
+}
+
+func TestSchedule_NextInTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	s, _ := Parse("30 2 * * *")
+	// 2:30 does not exist on the day clocks spring forward.
+	from := time.Date(2025, 3, 8, 12, 0, 0, 0, loc)
+	if got, want := s.Next(from), time.Date(2025, 3, 10, 2, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	daily, _ := Parse("0 9 * * *")
+	if got, want := daily.Next(from), time.Date(2025, 3, 9, 9, 0, 0, 0, loc); !got.Equal(want) || got.UTC().Hour() != 13 {
+		t.Errorf("got %s, want %s at 13:00 UTC", got, want)
+	}
+}
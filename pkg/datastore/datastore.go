@@ -1,68 +1,370 @@
 package datastore
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/fntkg/container-orchestrator/pkg/models"
 )
 
+// ErrNotFound is returned when deleting an object that does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// ErrExists is returned when creating an object whose ID is already in use.
+var ErrExists = errors.New("object already exists")
+
+// ErrConflict is matched by every ConflictError.
+var ErrConflict = errors.New("resource version conflict")
+
+// ConflictError is returned when an object is saved with a resource version
+// that no longer matches the stored one, meaning someone else wrote it since
+// the caller read it.
+type ConflictError struct {
+	Kind     string
+	ID       string
+	Expected uint64
+	// Actual is the stored version, or zero if the object does not exist.
+	Actual uint64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s: resource version %d does not match current version %d", e.Kind, e.ID, e.Expected, e.Actual)
+}
+
+// Is makes errors.Is(err, ErrConflict) true for any ConflictError.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
 // Datastore defines the methods to store and retrieve the cluster state.
+// Every save stamps the object with a new, monotonically increasing resource
+// version. Saving an object whose ResourceVersion is non-zero succeeds only
+// if it matches the stored version; otherwise a *ConflictError is returned.
+//
+// Watch streams changes of one kind of object; see InMemoryDatastore.Watch.
 type Datastore interface {
 	SaveNode(n models.Node) error
 	GetNodes() ([]models.Node, error)
+	DeleteNode(id string) error
 	SaveTask(t models.Task) error
+	CreateTask(t models.Task) error
 	GetTasks() ([]models.Task, error)
+	ListTasks() ([]models.Task, uint64, error)
+	DeleteTask(id string) error
+	SaveReplicaSet(rs models.ReplicaSet) error
+	GetReplicaSets() ([]models.ReplicaSet, error)
+	DeleteReplicaSet(id string) error
+	SaveDeployment(d models.Deployment) error
+	GetDeployments() ([]models.Deployment, error)
+	DeleteDeployment(id string) error
+	SaveJob(j models.Job) error
+	GetJobs() ([]models.Job, error)
+	DeleteJob(id string) error
+	SaveCronJob(cj models.CronJob) error
+	GetCronJobs() ([]models.CronJob, error)
+	DeleteCronJob(id string) error
+	SaveDaemonSet(ds models.DaemonSet) error
+	GetDaemonSets() ([]models.DaemonSet, error)
+	DeleteDaemonSet(id string) error
+	Watch(kind string, fromVersion uint64) (Watcher, error)
+}
+
+// Kinds of objects kept in the datastore.
+const (
+	KindNode       = "node"
+	KindTask       = "task"
+	KindReplicaSet = "replicaset"
+	KindDeployment = "deployment"
+	KindJob        = "job"
+	KindCronJob    = "cronjob"
+	KindDaemonSet  = "daemonset"
+)
+
+// mutation is a single change to the stored state. It is the unit written to
+// the write-ahead log of FileDatastore.
+type mutation struct {
+	Op      string          `json:"op"`
+	Kind    string          `json:"kind"`
+	ID      string          `json:"id"`
+	Version uint64          `json:"version"`
+	Data    json.RawMessage `json:"data,omitempty"`
 }
 
+// storedObject is an encoded object together with its resource version.
+type storedObject struct {
+	version uint64
+	data    []byte
+}
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
 // InMemoryDatastore is a simple in-memory implementation of Datastore.
+// Objects are kept in their JSON encoding so that callers never share maps or
+// slices with the stored copy.
 type InMemoryDatastore struct {
-	nodes map[string]models.Node
-	tasks map[string]models.Task
-	mu    sync.RWMutex
+	objects map[string]map[string]storedObject
+	// revision is the last resource version handed out.
+	revision uint64
+	mu       sync.RWMutex
+
+	// persist, when set, is called with the lock held before a mutation is
+	// applied. If it fails the mutation is discarded.
+	persist func(m mutation) error
+
+	// history holds the most recent events, oldest first, for watchers
+	// resuming from a past version. historyStart is the newest version that
+	// is no longer covered by it.
+	history      []Event
+	historyStart uint64
+	historyLimit int
+	watchers     map[*watcher]struct{}
 }
 
 // NewInMemoryDatastore creates a new instance of InMemoryDatastore.
 func NewInMemoryDatastore() *InMemoryDatastore {
 	return &InMemoryDatastore{
-		nodes: make(map[string]models.Node),
-		tasks: make(map[string]models.Task),
+		objects: map[string]map[string]storedObject{
+			KindNode:       make(map[string]storedObject),
+			KindTask:       make(map[string]storedObject),
+			KindReplicaSet: make(map[string]storedObject),
+			KindDeployment: make(map[string]storedObject),
+			KindJob:        make(map[string]storedObject),
+			KindCronJob:    make(map[string]storedObject),
+			KindDaemonSet:  make(map[string]storedObject),
+		},
+		historyLimit: DefaultWatchHistory,
+		watchers:     make(map[*watcher]struct{}),
 	}
 }
 
 // SaveNode stores a node in the datastore.
 func (ds *InMemoryDatastore) SaveNode(n models.Node) error {
-	ds.mu.Lock()
-	defer ds.mu.Unlock()
-	ds.nodes[n.ID] = n
-	return nil
+	return ds.put(KindNode, &n)
 }
 
 // GetNodes retrieves all nodes from the datastore.
 func (ds *InMemoryDatastore) GetNodes() ([]models.Node, error) {
-	ds.mu.RLock()
-	defer ds.mu.RUnlock()
-	nodes := make([]models.Node, 0, len(ds.nodes))
-	for _, n := range ds.nodes {
-		nodes = append(nodes, n)
-	}
-	return nodes, nil
+	return list[models.Node](ds, KindNode)
+}
+
+// DeleteNode removes a node from the datastore.
+func (ds *InMemoryDatastore) DeleteNode(id string) error {
+	return ds.delete(KindNode, id)
 }
 
 // SaveTask stores a task in the datastore.
 func (ds *InMemoryDatastore) SaveTask(t models.Task) error {
+	return ds.put(KindTask, &t)
+}
+
+// CreateTask stores a new task, failing with ErrExists if a task with its ID
+// is already stored.
+func (ds *InMemoryDatastore) CreateTask(t models.Task) error {
+	return ds.create(KindTask, &t)
+}
+
+// GetTasks retrieves all tasks from the datastore.
+func (ds *InMemoryDatastore) GetTasks() ([]models.Task, error) {
+	return list[models.Task](ds, KindTask)
+}
+
+// ListTasks retrieves all tasks together with the revision they reflect. A
+// watch from that revision delivers every later change, and none that the
+// list already shows.
+func (ds *InMemoryDatastore) ListTasks() ([]models.Task, uint64, error) {
+	return listWithRevision[models.Task](ds, KindTask)
+}
+
+// DeleteTask removes a task from the datastore.
+func (ds *InMemoryDatastore) DeleteTask(id string) error {
+	return ds.delete(KindTask, id)
+}
+
+// SaveReplicaSet stores a replica set in the datastore.
+func (ds *InMemoryDatastore) SaveReplicaSet(rs models.ReplicaSet) error {
+	return ds.put(KindReplicaSet, &rs)
+}
+
+// GetReplicaSets retrieves all replica sets from the datastore.
+func (ds *InMemoryDatastore) GetReplicaSets() ([]models.ReplicaSet, error) {
+	return list[models.ReplicaSet](ds, KindReplicaSet)
+}
+
+// DeleteReplicaSet removes a replica set from the datastore.
+func (ds *InMemoryDatastore) DeleteReplicaSet(id string) error {
+	return ds.delete(KindReplicaSet, id)
+}
+
+// SaveDeployment stores a deployment in the datastore.
+func (ds *InMemoryDatastore) SaveDeployment(d models.Deployment) error {
+	return ds.put(KindDeployment, &d)
+}
+
+// GetDeployments retrieves all deployments from the datastore.
+func (ds *InMemoryDatastore) GetDeployments() ([]models.Deployment, error) {
+	return list[models.Deployment](ds, KindDeployment)
+}
+
+// DeleteDeployment removes a deployment from the datastore.
+func (ds *InMemoryDatastore) DeleteDeployment(id string) error {
+	return ds.delete(KindDeployment, id)
+}
+
+// SaveJob stores a job in the datastore.
+func (ds *InMemoryDatastore) SaveJob(j models.Job) error {
+	return ds.put(KindJob, &j)
+}
+
+// GetJobs retrieves all jobs from the datastore.
+func (ds *InMemoryDatastore) GetJobs() ([]models.Job, error) {
+	return list[models.Job](ds, KindJob)
+}
+
+// DeleteJob removes a job from the datastore.
+func (ds *InMemoryDatastore) DeleteJob(id string) error {
+	return ds.delete(KindJob, id)
+}
+
+// SaveCronJob stores a cron job in the datastore.
+func (ds *InMemoryDatastore) SaveCronJob(cj models.CronJob) error {
+	return ds.put(KindCronJob, &cj)
+}
+
+// GetCronJobs retrieves all cron jobs from the datastore.
+func (ds *InMemoryDatastore) GetCronJobs() ([]models.CronJob, error) {
+	return list[models.CronJob](ds, KindCronJob)
+}
+
+// DeleteCronJob removes a cron job from the datastore.
+func (ds *InMemoryDatastore) DeleteCronJob(id string) error {
+	return ds.delete(KindCronJob, id)
+}
+
+// SaveDaemonSet stores a daemon set in the datastore.
+func (ds *InMemoryDatastore) SaveDaemonSet(d models.DaemonSet) error {
+	return ds.put(KindDaemonSet, &d)
+}
+
+// GetDaemonSets retrieves all daemon sets from the datastore.
+func (ds *InMemoryDatastore) GetDaemonSets() ([]models.DaemonSet, error) {
+	return list[models.DaemonSet](ds, KindDaemonSet)
+}
+
+// DeleteDaemonSet removes a daemon set from the datastore.
+func (ds *InMemoryDatastore) DeleteDaemonSet(id string) error {
+	return ds.delete(KindDaemonSet, id)
+}
+
+// put stamps obj with the next resource version, encodes it and stores it
+// under the given kind, enforcing the caller's expected version if any.
+func (ds *InMemoryDatastore) put(kind string, obj models.Object) error {
+	return ds.save(kind, obj, false)
+}
+
+// create is put for an object that must not be stored yet.
+func (ds *InMemoryDatastore) create(kind string, obj models.Object) error {
+	return ds.save(kind, obj, true)
+}
+
+func (ds *InMemoryDatastore) save(kind string, obj models.Object, create bool) error {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
-	ds.tasks[t.ID] = t
+
+	id := obj.GetID()
+	stored, exists := ds.objects[kind][id]
+	if create && exists {
+		return fmt.Errorf("%s %s: %w", kind, id, ErrExists)
+	}
+	current := stored.version
+	if expected := obj.GetResourceVersion(); expected != 0 && expected != current {
+		return &ConflictError{Kind: kind, ID: id, Expected: expected, Actual: current}
+	}
+
+	version := ds.revision + 1
+	obj.SetResourceVersion(version)
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return ds.commit(mutation{Op: opPut, Kind: kind, ID: id, Version: version, Data: data})
+}
+
+// delete removes an object, consuming a resource version so that watchers
+// see the deletion in order with other changes.
+func (ds *InMemoryDatastore) delete(kind, id string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if _, ok := ds.objects[kind][id]; !ok {
+		return fmt.Errorf("%s %s: %w", kind, id, ErrNotFound)
+	}
+	return ds.commit(mutation{Op: opDelete, Kind: kind, ID: id, Version: ds.revision + 1})
+}
+
+// commit persists and applies a mutation and notifies watchers. The caller
+// must hold the write lock.
+func (ds *InMemoryDatastore) commit(m mutation) error {
+	if ds.persist != nil {
+		if err := ds.persist(m); err != nil {
+			return err
+		}
+	}
+	previous, existed := ds.objects[m.Kind][m.ID]
+	ds.apply(m)
+
+	ev := Event{Kind: m.Kind, ID: m.ID, ResourceVersion: m.Version, Object: m.Data}
+	switch {
+	case m.Op == opDelete:
+		ev.Type, ev.Object = Deleted, previous.data
+	case existed:
+		ev.Type = Modified
+	default:
+		ev.Type = Added
+	}
+	ds.record(ev)
 	return nil
 }
 
-// GetTasks retrieves all tasks from the datastore.
-func (ds *InMemoryDatastore) GetTasks() ([]models.Task, error) {
+// apply changes the in-memory state. The caller must hold the write lock.
+func (ds *InMemoryDatastore) apply(m mutation) {
+	objs, ok := ds.objects[m.Kind]
+	if !ok {
+		objs = make(map[string]storedObject)
+		ds.objects[m.Kind] = objs
+	}
+	switch m.Op {
+	case opPut:
+		objs[m.ID] = storedObject{version: m.Version, data: m.Data}
+	case opDelete:
+		delete(objs, m.ID)
+	}
+	if m.Version > ds.revision {
+		ds.revision = m.Version
+	}
+}
+
+// list decodes every stored object of the given kind.
+func list[T any](ds *InMemoryDatastore, kind string) ([]T, error) {
+	out, _, err := listWithRevision[T](ds, kind)
+	return out, err
+}
+
+// listWithRevision decodes every stored object of a kind, and returns them
+// with the revision read under the same lock.
+func listWithRevision[T any](ds *InMemoryDatastore, kind string) ([]T, uint64, error) {
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
-	tasks := make([]models.Task, 0, len(ds.tasks))
-	for _, t := range ds.tasks {
-		tasks = append(tasks, t)
+	out := make([]T, 0, len(ds.objects[kind]))
+	for _, stored := range ds.objects[kind] {
+		var obj T
+		if err := json.Unmarshal(stored.data, &obj); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, obj)
 	}
-	return tasks, nil
+	return out, ds.revision, nil
 }
@@ -2,10 +2,12 @@
 package datastore_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/fntkg/container-orchestrator/pkg/datastore"
 	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
 )
 
 func TestInMemoryDatastore_SaveAndGetNodes(t *testing.T) {
@@ -89,3 +91,163 @@ func TestInMemoryDatastore_SaveAndGetTasks(t *testing.T) {
 		}
 	}
 }
+
+func TestInMemoryDatastore_ReplicaSets(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	rs := models.ReplicaSet{
+		ID:       "web",
+		Replicas: 3,
+		Selector: models.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		Template: models.Task{Labels: map[string]string{"app": "web"}, Command: "nginx"},
+	}
+	if err := ds.SaveReplicaSet(rs); err != nil {
+		t.Fatalf("Failed to save replica set: %v", err)
+	}
+
+	stored, err := ds.GetReplicaSets()
+	if err != nil {
+		t.Fatalf("Error retrieving replica sets: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Replicas != 3 || stored[0].Template.Labels["app"] != "web" || stored[0].ResourceVersion == 0 {
+		t.Fatalf("Unexpected replica sets: %+v", stored)
+	}
+
+	// A stale version is rejected like for any other kind.
+	rs.Replicas = 5
+	rs.ResourceVersion = stored[0].ResourceVersion + 1
+	if err := ds.SaveReplicaSet(rs); !errors.Is(err, datastore.ErrConflict) {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+
+	if err := ds.DeleteReplicaSet("web"); err != nil {
+		t.Fatalf("Failed to delete replica set: %v", err)
+	}
+	if err := ds.DeleteReplicaSet("web"); !errors.Is(err, datastore.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestInMemoryDatastore_ResourcesRoundTrip(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+
+	node := models.Node{
+		ID:       "node-1",
+		Healthy:  true,
+		Capacity: resource.List{resource.CPU: resource.MustParse("8"), resource.Memory: resource.MustParse("16Gi")},
+		Allocatable: resource.List{
+			resource.CPU:    resource.MustParse("7500m"),
+			resource.Memory: resource.MustParse("15Gi"),
+		},
+	}
+	task := models.Task{
+		ID: "task-1",
+		Resources: models.ResourceRequirements{
+			Requests: resource.List{resource.CPU: resource.MustParse("2"), resource.Memory: resource.MustParse("512Mi")},
+			Limits:   resource.List{"example.com/gpu": resource.MustParse("1")},
+		},
+	}
+	if err := ds.SaveNode(node); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	if err := ds.SaveTask(task); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+
+	// Mutating the caller's copy must not leak into the stored object.
+	node.Allocatable[resource.CPU] = resource.MustParse("1")
+	task.Resources.Requests[resource.CPU] = resource.MustParse("100m")
+
+	nodes, err := ds.GetNodes()
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("Error retrieving nodes: %v (%d nodes)", err, len(nodes))
+	}
+	if got := nodes[0].Allocatable[resource.CPU].String(); got != "7500m" {
+		t.Errorf("Expected allocatable cpu 7500m, got %s", got)
+	}
+	if got := nodes[0].Capacity[resource.Memory].String(); got != "16Gi" {
+		t.Errorf("Expected memory capacity 16Gi, got %s", got)
+	}
+
+	tasks, err := ds.GetTasks()
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("Error retrieving tasks: %v (%d tasks)", err, len(tasks))
+	}
+	if got := tasks[0].Resources.Requests[resource.CPU].String(); got != "2" {
+		t.Errorf("Expected cpu request 2, got %s", got)
+	}
+	if got := tasks[0].Resources.Limits["example.com/gpu"].Value(); got != 1 {
+		t.Errorf("Expected gpu limit 1, got %d", got)
+	}
+}
+
+func TestInMemoryDatastore_ResourceVersions(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+
+	if err := ds.SaveTask(models.Task{ID: "task-1"}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.SaveNode(models.Node{ID: "node-1"}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	tasks, _ := ds.GetTasks()
+	nodes, _ := ds.GetNodes()
+	first := tasks[0].ResourceVersion
+	if first == 0 || nodes[0].ResourceVersion <= first {
+		t.Fatalf("Expected increasing versions, got task %d node %d", first, nodes[0].ResourceVersion)
+	}
+
+	// A save with the current version succeeds and bumps the version.
+	current := tasks[0]
+	current.Status = models.TaskScheduled
+	if err := ds.SaveTask(current); err != nil {
+		t.Fatalf("Expected save with current version to succeed, got %v", err)
+	}
+	tasks, _ = ds.GetTasks()
+	if tasks[0].ResourceVersion <= nodes[0].ResourceVersion {
+		t.Errorf("Expected version to increase past %d, got %d", nodes[0].ResourceVersion, tasks[0].ResourceVersion)
+	}
+
+	// Writing with the stale version is rejected.
+	stale := current
+	stale.Status = models.TaskCancelled
+	err := ds.SaveTask(stale)
+	var conflict *datastore.ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, datastore.ErrConflict) {
+		t.Fatalf("Expected ConflictError, got %v", err)
+	}
+	if conflict.Expected != first || conflict.Actual != tasks[0].ResourceVersion {
+		t.Errorf("Unexpected conflict details: %+v", conflict)
+	}
+
+	// A version of zero is an unconditional write.
+	if err := ds.SaveTask(models.Task{ID: "task-1", Status: models.TaskFailed}); err != nil {
+		t.Errorf("Expected unconditional save to succeed, got %v", err)
+	}
+	// Conditional writes to objects that do not exist also conflict.
+	if err := ds.SaveNode(models.Node{ID: "node-2", ResourceVersion: 7}); !errors.Is(err, datastore.ErrConflict) {
+		t.Errorf("Expected conflict for missing node, got %v", err)
+	}
+}
+
+func TestInMemoryDatastore_CreateTask(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+
+	if err := ds.CreateTask(models.Task{ID: "task-1", Command: "first"}); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if err := ds.CreateTask(models.Task{ID: "task-1", Command: "second"}); !errors.Is(err, datastore.ErrExists) {
+		t.Fatalf("Expected ErrExists, got %v", err)
+	}
+	tasks, _ := ds.GetTasks()
+	if len(tasks) != 1 || tasks[0].Command != "first" {
+		t.Errorf("Expected the first task to be kept, got %+v", tasks)
+	}
+
+	// Once deleted, the ID can be used again.
+	if err := ds.DeleteTask("task-1"); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	if err := ds.CreateTask(models.Task{ID: "task-1", Command: "third"}); err != nil {
+		t.Errorf("Expected the ID to be free again, got %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package datastore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+
+	// DefaultSnapshotEvery is the number of logged mutations after which the
+	// write-ahead log is compacted into a snapshot.
+	DefaultSnapshotEvery = 1000
+
+	// walHeaderSize is the length prefix plus the CRC of every log record.
+	walHeaderSize = 8
+	// maxRecordSize guards against allocating huge buffers for a corrupt length.
+	maxRecordSize = 64 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrWALFailed is returned for every write to a FileDatastore after a failed
+// append could not be rolled back, leaving the log in an unknown state.
+var ErrWALFailed = errors.New("write-ahead log failed")
+
+// ErrWALCorrupt is returned when opening a FileDatastore whose log has a
+// damaged record followed by more data. Only the last record can be torn by
+// a crash, so dropping everything after it could lose committed mutations.
+var ErrWALCorrupt = errors.New("write-ahead log is corrupt")
+
+// walFile is the part of *os.File the write-ahead log uses.
+type walFile interface {
+	io.Writer
+	io.Seeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// snapshot is the on-disk form of the whole datastore state.
+type snapshot struct {
+	// Revision is the last resource version handed out, which may belong to
+	// an object that no longer exists.
+	Revision uint64                           `json:"revision"`
+	Objects  map[string]map[string]snapObject `json:"objects"`
+}
+
+type snapObject struct {
+	Version uint64          `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// FileDatastore is a durable Datastore. Every mutation is appended to a
+// write-ahead log and fsync'd before it becomes visible; the log is
+// periodically compacted into a snapshot. On startup the snapshot is loaded
+// and the log replayed on top of it. A torn record at the end of the log,
+// left by a crash in the middle of a write, is detected and truncated; a
+// damaged record anywhere else fails the open with ErrWALCorrupt.
+//
+// An append that fails is cut back off the log, so that no part of a
+// rejected mutation is replayed. If that fails too, the datastore refuses
+// all further writes with ErrWALFailed.
+type FileDatastore struct {
+	*InMemoryDatastore
+
+	dir           string
+	wal           walFile
+	walRecords    int
+	snapshotEvery int
+	// failed is set once the log could not be restored after a failed append.
+	failed error
+}
+
+// NewFileDatastore opens or creates a datastore in dir. A snapshotEvery of
+// zero or less selects DefaultSnapshotEvery.
+func NewFileDatastore(dir string, snapshotEvery int) (*FileDatastore, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating data directory: %w", err)
+	}
+
+	fds := &FileDatastore{
+		InMemoryDatastore: NewInMemoryDatastore(),
+		dir:               dir,
+		snapshotEvery:     snapshotEvery,
+	}
+	if err := fds.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := fds.replayWAL(); err != nil {
+		return nil, err
+	}
+	// Changes made before this process started are not in the watch history.
+	fds.historyStart = fds.revision
+	fds.persist = fds.appendRecord
+	return fds, nil
+}
+
+// Close flushes and closes the write-ahead log. The datastore must not be
+// used afterwards.
+func (fds *FileDatastore) Close() error {
+	fds.mu.Lock()
+	defer fds.mu.Unlock()
+	fds.persist = func(mutation) error { return errors.New("datastore is closed") }
+	if fds.wal == nil {
+		return nil
+	}
+	err := fds.wal.Sync()
+	if cerr := fds.wal.Close(); err == nil {
+		err = cerr
+	}
+	fds.wal = nil
+	return err
+}
+
+// Compact writes a snapshot of the current state and empties the write-ahead log.
+func (fds *FileDatastore) Compact() error {
+	fds.mu.Lock()
+	defer fds.mu.Unlock()
+	return fds.compactLocked()
+}
+
+// loadSnapshot restores the state saved by the last compaction, if any.
+func (fds *FileDatastore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(fds.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decoding snapshot: %w", err)
+	}
+	for kind, objs := range snap.Objects {
+		for id, obj := range objs {
+			fds.apply(mutation{Op: opPut, Kind: kind, ID: id, Version: obj.Version, Data: obj.Data})
+		}
+	}
+	if snap.Revision > fds.revision {
+		fds.revision = snap.Revision
+	}
+	return nil
+}
+
+// replayWAL applies every intact record of the log and truncates a torn
+// record at its end, then leaves the log open for appending.
+func (fds *FileDatastore) replayWAL() error {
+	f, err := os.OpenFile(filepath.Join(fds.dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening write-ahead log: %w", err)
+	}
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		m, n, err := readRecord(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// A record is torn when it runs past the end of the log, or ends
+			// there with a checksum that does not match.
+			if _, peekErr := r.Peek(1); !errors.Is(err, io.ErrUnexpectedEOF) && peekErr != io.EOF {
+				_ = f.Close()
+				return fmt.Errorf("%w: record at offset %d: %v", ErrWALCorrupt, offset, err)
+			}
+			log.Printf("Datastore: truncating write-ahead log at offset %d: %v", offset, err)
+			if err := f.Truncate(offset); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("truncating torn write-ahead log: %w", err)
+			}
+			if err := f.Sync(); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("syncing write-ahead log: %w", err)
+			}
+			break
+		}
+		fds.apply(m)
+		fds.walRecords++
+		offset += n
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("seeking write-ahead log: %w", err)
+	}
+	fds.wal = f
+	return nil
+}
+
+// readRecord decodes one log record and returns it with its size on disk.
+// io.EOF is returned only at a clean record boundary.
+func readRecord(r io.Reader) (mutation, int64, error) {
+	var m mutation
+	var header [walHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return m, 0, io.EOF
+		}
+		return m, 0, fmt.Errorf("short record header: %w", err)
+	}
+	size := binary.BigEndian.Uint32(header[0:4])
+	sum := binary.BigEndian.Uint32(header[4:8])
+	if size > maxRecordSize {
+		return m, 0, fmt.Errorf("record length %d exceeds limit", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return m, 0, fmt.Errorf("short record payload: %w", err)
+	}
+	if crc32.Checksum(payload, crcTable) != sum {
+		return m, 0, errors.New("record checksum mismatch")
+	}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return m, 0, fmt.Errorf("decoding record: %w", err)
+	}
+	return m, int64(walHeaderSize + size), nil
+}
+
+// appendRecord durably logs a mutation before it is applied, compacting the
+// log first when it has grown past the snapshot threshold. It runs with the
+// datastore lock held.
+func (fds *FileDatastore) appendRecord(m mutation) error {
+	if fds.failed != nil {
+		return fds.failed
+	}
+	if fds.walRecords >= fds.snapshotEvery {
+		if err := fds.compactLocked(); err != nil {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	copy(buf[walHeaderSize:], payload)
+
+	offset, err := fds.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seeking write-ahead log: %w", err)
+	}
+	if _, err := fds.wal.Write(buf); err != nil {
+		return fds.rollback(offset, fmt.Errorf("appending to write-ahead log: %w", err))
+	}
+	if err := fds.wal.Sync(); err != nil {
+		return fds.rollback(offset, fmt.Errorf("syncing write-ahead log: %w", err))
+	}
+	fds.walRecords++
+	return nil
+}
+
+// rollback cuts the log back to offset after an append failed with cause, so
+// that neither a torn nor a complete copy of the rejected record is replayed
+// on restart. When the log cannot be restored, the datastore is marked as
+// failed and rejects every later write.
+func (fds *FileDatastore) rollback(offset int64, cause error) error {
+	err := fds.wal.Truncate(offset)
+	if err == nil {
+		_, err = fds.wal.Seek(offset, io.SeekStart)
+	}
+	if err == nil {
+		err = fds.wal.Sync()
+	}
+	if err != nil {
+		fds.failed = fmt.Errorf("%w: %v, then rolling back: %v", ErrWALFailed, cause, err)
+		log.Printf("Datastore: %v", fds.failed)
+		return fds.failed
+	}
+	return cause
+}
+
+// compactLocked replaces the snapshot with the current state and truncates
+// the log. The caller must hold the write lock. If the process dies after the
+// new snapshot is in place but before the log is truncated, replaying the old
+// records on top of it is harmless because each one stores a whole object or
+// deletes one.
+func (fds *FileDatastore) compactLocked() error {
+	snap := snapshot{Revision: fds.revision, Objects: make(map[string]map[string]snapObject)}
+	for kind, objs := range fds.objects {
+		snap.Objects[kind] = make(map[string]snapObject, len(objs))
+		for id, stored := range objs {
+			snap.Objects[kind][id] = snapObject{Version: stored.version, Data: stored.data}
+		}
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fds.dir, snapshotFileName, data); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	if err := fds.wal.Truncate(0); err != nil {
+		return fmt.Errorf("truncating write-ahead log: %w", err)
+	}
+	if _, err := fds.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking write-ahead log: %w", err)
+	}
+	if err := fds.wal.Sync(); err != nil {
+		return fmt.Errorf("syncing write-ahead log: %w", err)
+	}
+	fds.walRecords = 0
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file, fsyncs it and renames it
+// over name, then fsyncs the directory so the rename itself is durable.
+func writeFileAtomic(dir, name string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return err
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,124 @@
+package datastore
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+// faultyWAL wraps the real log file, failing the next write halfway through
+// or the next sync, and optionally every truncation.
+type faultyWAL struct {
+	walFile
+	failWrite, failSync, failTruncate bool
+}
+
+var errInjected = errors.New("injected failure")
+
+func (f *faultyWAL) Write(p []byte) (int, error) {
+	if f.failWrite {
+		f.failWrite = false
+		n, _ := f.walFile.Write(p[:len(p)/2])
+		return n, errInjected
+	}
+	return f.walFile.Write(p)
+}
+
+func (f *faultyWAL) Sync() error {
+	if f.failSync {
+		f.failSync = false
+		return errInjected
+	}
+	return f.walFile.Sync()
+}
+
+func (f *faultyWAL) Truncate(size int64) error {
+	if f.failTruncate {
+		return errInjected
+	}
+	return f.walFile.Truncate(size)
+}
+
+func openWithFaultyWAL(t *testing.T, dir string) (*FileDatastore, *faultyWAL) {
+	t.Helper()
+	ds, err := NewFileDatastore(dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to open file datastore: %v", err)
+	}
+	wal := &faultyWAL{walFile: ds.wal}
+	ds.wal = wal
+	return ds, wal
+}
+
+func TestFileDatastore_FailedAppendIsRolledBack(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fail func(*faultyWAL)
+	}{
+		{"torn write", func(w *faultyWAL) { w.failWrite = true }},
+		{"failed sync", func(w *faultyWAL) { w.failSync = true }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ds, wal := openWithFaultyWAL(t, dir)
+			if err := ds.SaveTask(models.Task{ID: "task-1"}); err != nil {
+				t.Fatalf("SaveTask failed: %v", err)
+			}
+			tc.fail(wal)
+			if err := ds.SaveTask(models.Task{ID: "rejected"}); !errors.Is(err, errInjected) {
+				t.Fatalf("Expected the injected error, got %v", err)
+			}
+			// Writes acknowledged after the failure must survive a restart.
+			if err := ds.SaveTask(models.Task{ID: "task-2"}); err != nil {
+				t.Fatalf("SaveTask after rollback failed: %v", err)
+			}
+			if err := ds.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			reopened, err := NewFileDatastore(dir, 0)
+			if err != nil {
+				t.Fatalf("Failed to reopen file datastore: %v", err)
+			}
+			defer reopened.Close()
+			tasks, err := reopened.GetTasks()
+			if err != nil {
+				t.Fatalf("GetTasks failed: %v", err)
+			}
+			var ids []string
+			for _, task := range tasks {
+				ids = append(ids, task.ID)
+			}
+			slices.Sort(ids)
+			if len(ids) != 2 || ids[0] != "task-1" || ids[1] != "task-2" {
+				t.Errorf("Expected task-1 and task-2 after restart, got %v", ids)
+			}
+		})
+	}
+}
+
+func TestFileDatastore_FailedRollbackStopsWrites(t *testing.T) {
+	ds, wal := openWithFaultyWAL(t, t.TempDir())
+	defer ds.Close()
+	if err := ds.SaveTask(models.Task{ID: "task-0"}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+	wal.failWrite, wal.failTruncate = true, true
+
+	if err := ds.SaveTask(models.Task{ID: "task-1"}); !errors.Is(err, ErrWALFailed) {
+		t.Fatalf("Expected ErrWALFailed, got %v", err)
+	}
+	wal.failTruncate = false
+	if err := ds.SaveTask(models.Task{ID: "task-2"}); !errors.Is(err, ErrWALFailed) {
+		t.Errorf("Expected later writes to fail with ErrWALFailed, got %v", err)
+	}
+	if err := ds.DeleteTask("task-0"); !errors.Is(err, ErrWALFailed) {
+		t.Errorf("Expected the delete to fail with ErrWALFailed, got %v", err)
+	}
+	tasks, _ := ds.GetTasks()
+	if len(tasks) != 1 || tasks[0].ID != "task-0" {
+		t.Errorf("Expected only task-0 to be stored, got %v", tasks)
+	}
+}
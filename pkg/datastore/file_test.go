@@ -0,0 +1,261 @@
+package datastore_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+func openFileDatastore(t *testing.T, dir string, snapshotEvery int) *datastore.FileDatastore {
+	t.Helper()
+	ds, err := datastore.NewFileDatastore(dir, snapshotEvery)
+	if err != nil {
+		t.Fatalf("Failed to open file datastore: %v", err)
+	}
+	return ds
+}
+
+func taskStatuses(t *testing.T, ds datastore.Datastore) map[string]models.TaskPhase {
+	t.Helper()
+	tasks, err := ds.GetTasks()
+	if err != nil {
+		t.Fatalf("Error retrieving tasks: %v", err)
+	}
+	out := make(map[string]models.TaskPhase, len(tasks))
+	for _, task := range tasks {
+		out[task.ID] = task.Status
+	}
+	return out
+}
+
+func TestFileDatastore_ReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	ds := openFileDatastore(t, dir, 0)
+
+	node := models.Node{ID: "node-1", Healthy: true, Capacity: resource.List{resource.CPU: resource.MustParse("4")}}
+	if err := ds.SaveNode(node); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	for _, task := range []models.Task{
+		{ID: "task-1", Status: models.TaskPending},
+		{ID: "task-2", Status: models.TaskPending},
+		{ID: "task-1", Status: models.TaskScheduled, NodeID: "node-1"},
+	} {
+		if err := ds.SaveTask(task); err != nil {
+			t.Fatalf("Failed to save task %s: %v", task.ID, err)
+		}
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Failed to close datastore: %v", err)
+	}
+
+	reopened := openFileDatastore(t, dir, 0)
+	defer reopened.Close()
+
+	nodes, err := reopened.GetNodes()
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("Expected 1 node after restart, got %d (%v)", len(nodes), err)
+	}
+	if got := nodes[0].Capacity[resource.CPU].String(); got != "4" {
+		t.Errorf("Expected cpu capacity 4 after restart, got %s", got)
+	}
+	statuses := taskStatuses(t, reopened)
+	if statuses["task-1"] != models.TaskScheduled || statuses["task-2"] != models.TaskPending {
+		t.Errorf("Unexpected task statuses after restart: %v", statuses)
+	}
+}
+
+func TestFileDatastore_TruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	ds := openFileDatastore(t, dir, 0)
+	for _, id := range []string{"task-1", "task-2"} {
+		if err := ds.SaveTask(models.Task{ID: id, Status: models.TaskPending}); err != nil {
+			t.Fatalf("Failed to save task: %v", err)
+		}
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Failed to close datastore: %v", err)
+	}
+
+	// Simulate a crash half-way through appending a third record.
+	walPath := filepath.Join(dir, "wal.log")
+	intact, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	torn := append(append([]byte{}, intact...), 0, 0, 0, 200, 1, 2, 3, 4, '{', '"')
+	if err := os.WriteFile(walPath, torn, 0o644); err != nil {
+		t.Fatalf("Failed to write torn log: %v", err)
+	}
+
+	reopened := openFileDatastore(t, dir, 0)
+	if got := len(taskStatuses(t, reopened)); got != 2 {
+		t.Errorf("Expected the 2 intact tasks to survive, got %d", got)
+	}
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatalf("Failed to stat log: %v", err)
+	}
+	if info.Size() != int64(len(intact)) {
+		t.Errorf("Expected log to be truncated to %d bytes, got %d", len(intact), info.Size())
+	}
+
+	// New writes must land after the last intact record.
+	if err := reopened.SaveTask(models.Task{ID: "task-3", Status: models.TaskPending}); err != nil {
+		t.Fatalf("Failed to save task after recovery: %v", err)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("Failed to close datastore: %v", err)
+	}
+	final := openFileDatastore(t, dir, 0)
+	defer final.Close()
+	if got := len(taskStatuses(t, final)); got != 3 {
+		t.Errorf("Expected 3 tasks after second restart, got %d", got)
+	}
+}
+
+func TestFileDatastore_CorruptRecordIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	ds := openFileDatastore(t, dir, 0)
+	if err := ds.SaveTask(models.Task{ID: "task-1", Status: models.TaskPending}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.SaveTask(models.Task{ID: "task-2", Status: models.TaskPending}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Failed to close datastore: %v", err)
+	}
+
+	// Flip a byte in the last record so its checksum no longer matches.
+	walPath := filepath.Join(dir, "wal.log")
+	data, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	data[len(data)-3] ^= 0xff
+	if err := os.WriteFile(walPath, data, 0o644); err != nil {
+		t.Fatalf("Failed to write corrupt log: %v", err)
+	}
+
+	reopened := openFileDatastore(t, dir, 0)
+	defer reopened.Close()
+	statuses := taskStatuses(t, reopened)
+	if _, ok := statuses["task-1"]; !ok || len(statuses) != 1 {
+		t.Errorf("Expected only task-1 to survive, got %v", statuses)
+	}
+}
+
+func TestFileDatastore_CorruptRecordBeforeOthersFailsOpen(t *testing.T) {
+	dir := t.TempDir()
+	ds := openFileDatastore(t, dir, 0)
+	if err := ds.SaveTask(models.Task{ID: "task-1", Status: models.TaskPending}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.SaveTask(models.Task{ID: "task-2", Status: models.TaskPending}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Failed to close datastore: %v", err)
+	}
+
+	// Flip a byte in the first record, which the second one follows.
+	walPath := filepath.Join(dir, "wal.log")
+	data, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	data[12] ^= 0xff
+	if err := os.WriteFile(walPath, data, 0o644); err != nil {
+		t.Fatalf("Failed to write corrupt log: %v", err)
+	}
+
+	if _, err := datastore.NewFileDatastore(dir, 0); !errors.Is(err, datastore.ErrWALCorrupt) {
+		t.Fatalf("Expected ErrWALCorrupt, got %v", err)
+	}
+	// The records after the damage are left for an operator to recover.
+	after, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if len(after) != len(data) {
+		t.Errorf("Expected the log to keep its %d bytes, got %d", len(data), len(after))
+	}
+}
+
+func TestFileDatastore_Compaction(t *testing.T) {
+	dir := t.TempDir()
+	ds := openFileDatastore(t, dir, 3)
+	for i := 0; i < 10; i++ {
+		phase := models.TaskPending
+		if i%2 == 1 {
+			phase = models.TaskScheduled
+		}
+		if err := ds.SaveTask(models.Task{ID: "task-1", Status: phase}); err != nil {
+			t.Fatalf("Failed to save task: %v", err)
+		}
+	}
+	if err := ds.SaveNode(models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "snapshot.json")); err != nil {
+		t.Fatalf("Expected a snapshot to be written: %v", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Failed to close datastore: %v", err)
+	}
+
+	reopened := openFileDatastore(t, dir, 3)
+	defer reopened.Close()
+	if statuses := taskStatuses(t, reopened); statuses["task-1"] != models.TaskScheduled {
+		t.Errorf("Expected task-1 to be scheduled after restart, got %v", statuses)
+	}
+	nodes, _ := reopened.GetNodes()
+	if len(nodes) != 1 {
+		t.Errorf("Expected 1 node after restart, got %d", len(nodes))
+	}
+
+	if err := reopened.Compact(); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "wal.log"))
+	if err != nil || info.Size() != 0 {
+		t.Errorf("Expected empty log after explicit compaction, got %v (%v)", info.Size(), err)
+	}
+}
+
+func TestFileDatastore_VersionsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	ds := openFileDatastore(t, dir, 2)
+	for _, id := range []string{"task-1", "task-2", "task-3"} {
+		if err := ds.SaveTask(models.Task{ID: id, Status: models.TaskPending}); err != nil {
+			t.Fatalf("Failed to save task: %v", err)
+		}
+	}
+	tasks, _ := ds.GetTasks()
+	var highest uint64
+	for _, task := range tasks {
+		if task.ResourceVersion > highest {
+			highest = task.ResourceVersion
+		}
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Failed to close datastore: %v", err)
+	}
+
+	reopened := openFileDatastore(t, dir, 2)
+	defer reopened.Close()
+	if err := reopened.SaveNode(models.Node{ID: "node-1"}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	nodes, _ := reopened.GetNodes()
+	if nodes[0].ResourceVersion <= highest {
+		t.Errorf("Expected versions to keep increasing after restart: %d <= %d", nodes[0].ResourceVersion, highest)
+	}
+}
@@ -0,0 +1,184 @@
+package datastore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// EventType describes what happened to an object.
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+)
+
+// DefaultWatchHistory is the number of past events kept so that watchers can
+// resume from an earlier resource version.
+const DefaultWatchHistory = 1000
+
+// watchBuffer is the number of events a watcher may fall behind by before it
+// is terminated.
+const watchBuffer = 100
+
+// ErrResourceVersionTooOld is matched by every TooOldError.
+var ErrResourceVersionTooOld = errors.New("resource version too old")
+
+// ErrWatcherOverflow is reported by Watcher.Err when a watcher was closed
+// because its consumer did not keep up with the event stream.
+var ErrWatcherOverflow = errors.New("watcher fell too far behind and was closed")
+
+// TooOldError is returned by Watch when the requested resource version has
+// already been dropped from the event history. The caller must list the
+// current state again and watch from the version it returns.
+type TooOldError struct {
+	Requested uint64
+	// Oldest is the lowest version a watch can currently resume from.
+	Oldest uint64
+}
+
+func (e *TooOldError) Error() string {
+	return fmt.Sprintf("resource version %d is too old, the oldest available is %d", e.Requested, e.Oldest)
+}
+
+// Is makes errors.Is(err, ErrResourceVersionTooOld) true for any TooOldError.
+func (e *TooOldError) Is(target error) bool {
+	return target == ErrResourceVersionTooOld
+}
+
+// Event is a single change observed by a watcher.
+type Event struct {
+	Type EventType `json:"type"`
+	Kind string    `json:"kind"`
+	ID   string    `json:"id"`
+	// ResourceVersion is the version of the change. Watching from it resumes
+	// right after this event.
+	ResourceVersion uint64 `json:"resourceVersion"`
+	// Object is the JSON encoding of the object after the change, or its last
+	// state for a deletion.
+	Object json.RawMessage `json:"object"`
+}
+
+// Decode unmarshals the event's object into v.
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Object, v)
+}
+
+// Watcher delivers events until it is stopped. Its channel is closed when
+// Stop is called or when the watcher is terminated, in which case Err
+// reports why; the caller can resume by watching from the last version seen.
+type Watcher interface {
+	ResultChan() <-chan Event
+	Stop()
+	Err() error
+}
+
+type watcher struct {
+	ds   *InMemoryDatastore
+	kind string
+	ch   chan Event
+
+	once sync.Once
+	mu   sync.Mutex
+	err  error
+}
+
+func (w *watcher) ResultChan() <-chan Event {
+	return w.ch
+}
+
+func (w *watcher) Stop() {
+	w.ds.mu.Lock()
+	defer w.ds.mu.Unlock()
+	w.ds.removeWatcher(w)
+	w.close(nil)
+}
+
+func (w *watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *watcher) close(err error) {
+	w.once.Do(func() {
+		w.mu.Lock()
+		w.err = err
+		w.mu.Unlock()
+		close(w.ch)
+	})
+}
+
+// SetWatchHistoryLimit changes how many past events are kept for resuming
+// watchers. It is meant to be called before the datastore is used.
+func (ds *InMemoryDatastore) SetWatchHistoryLimit(limit int) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.historyLimit = limit
+	ds.trimHistory()
+}
+
+// Watch streams changes to objects of the given kind, or of every kind when
+// kind is empty. With a fromVersion of zero only changes made after the call
+// are delivered; otherwise every retained change newer than fromVersion is
+// replayed first. If those changes are no longer retained a *TooOldError is
+// returned.
+func (ds *InMemoryDatastore) Watch(kind string, fromVersion uint64) (Watcher, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	var replay []Event
+	if fromVersion != 0 {
+		if fromVersion < ds.historyStart {
+			return nil, &TooOldError{Requested: fromVersion, Oldest: ds.historyStart}
+		}
+		for _, ev := range ds.history {
+			if ev.ResourceVersion > fromVersion && (kind == "" || ev.Kind == kind) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	w := &watcher{ds: ds, kind: kind, ch: make(chan Event, len(replay)+watchBuffer)}
+	for _, ev := range replay {
+		w.ch <- ev
+	}
+	ds.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// record adds an event to the history and hands it to every interested
+// watcher. Watchers whose buffer is full are closed rather than allowed to
+// block writers. The caller must hold the write lock.
+func (ds *InMemoryDatastore) record(ev Event) {
+	ds.history = append(ds.history, ev)
+	ds.trimHistory()
+	for w := range ds.watchers {
+		if w.kind != "" && w.kind != ev.Kind {
+			continue
+		}
+		select {
+		case w.ch <- ev:
+		default:
+			ds.removeWatcher(w)
+			w.close(ErrWatcherOverflow)
+		}
+	}
+}
+
+// trimHistory drops the oldest events beyond the history limit. The caller
+// must hold the write lock.
+func (ds *InMemoryDatastore) trimHistory() {
+	if excess := len(ds.history) - ds.historyLimit; excess > 0 {
+		ds.historyStart = ds.history[excess-1].ResourceVersion
+		ds.history = append([]Event(nil), ds.history[excess:]...)
+	}
+}
+
+// removeWatcher unregisters w. The caller must hold the write lock.
+func (ds *InMemoryDatastore) removeWatcher(w *watcher) {
+	delete(ds.watchers, w)
+}
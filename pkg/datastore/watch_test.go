@@ -0,0 +1,200 @@
+package datastore_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+func nextEvent(t *testing.T, w datastore.Watcher) datastore.Event {
+	t.Helper()
+	select {
+	case ev, ok := <-w.ResultChan():
+		if !ok {
+			t.Fatalf("Watcher closed unexpectedly: %v", w.Err())
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	return datastore.Event{}
+}
+
+func TestWatch_DeliversChangesOfKind(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	w, err := ds.Watch(datastore.KindTask, 0)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer w.Stop()
+
+	if err := ds.SaveNode(models.Node{ID: "node-1"}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	if err := ds.SaveTask(models.Task{ID: "task-1", Status: models.TaskPending}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.SaveTask(models.Task{ID: "task-1", Status: models.TaskScheduled}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.DeleteTask("task-1"); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+
+	var last uint64
+	for _, want := range []datastore.EventType{datastore.Added, datastore.Modified, datastore.Deleted} {
+		ev := nextEvent(t, w)
+		if ev.Type != want || ev.Kind != datastore.KindTask || ev.ID != "task-1" {
+			t.Errorf("Expected %s task-1, got %s %s %s", want, ev.Type, ev.Kind, ev.ID)
+		}
+		if ev.ResourceVersion <= last {
+			t.Errorf("Expected increasing versions, got %d after %d", ev.ResourceVersion, last)
+		}
+		last = ev.ResourceVersion
+		var task models.Task
+		if err := ev.Decode(&task); err != nil || task.ID != "task-1" {
+			t.Errorf("Failed to decode event object: %v", err)
+		}
+	}
+
+	if err := ds.DeleteTask("task-1"); !errors.Is(err, datastore.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestWatch_ResumeFromVersion(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	for _, id := range []string{"task-1", "task-2", "task-3"} {
+		if err := ds.SaveTask(models.Task{ID: id}); err != nil {
+			t.Fatalf("Failed to save task: %v", err)
+		}
+	}
+	tasks, _ := ds.GetTasks()
+	var first uint64
+	for _, task := range tasks {
+		if first == 0 || task.ResourceVersion < first {
+			first = task.ResourceVersion
+		}
+	}
+
+	// Resuming after the first change replays the two later ones.
+	w, err := ds.Watch(datastore.KindTask, first)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer w.Stop()
+	if ev := nextEvent(t, w); ev.ID != "task-2" {
+		t.Errorf("Expected replay of task-2, got %s", ev.ID)
+	}
+	if ev := nextEvent(t, w); ev.ID != "task-3" {
+		t.Errorf("Expected replay of task-3, got %s", ev.ID)
+	}
+	if err := ds.SaveTask(models.Task{ID: "task-4"}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if ev := nextEvent(t, w); ev.ID != "task-4" {
+		t.Errorf("Expected live event for task-4, got %s", ev.ID)
+	}
+}
+
+func TestWatch_TooOld(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	ds.SetWatchHistoryLimit(2)
+	for i := 0; i < 5; i++ {
+		if err := ds.SaveTask(models.Task{ID: "task-1"}); err != nil {
+			t.Fatalf("Failed to save task: %v", err)
+		}
+	}
+
+	_, err := ds.Watch(datastore.KindTask, 1)
+	var tooOld *datastore.TooOldError
+	if !errors.As(err, &tooOld) || !errors.Is(err, datastore.ErrResourceVersionTooOld) {
+		t.Fatalf("Expected TooOldError, got %v", err)
+	}
+	if tooOld.Oldest != 3 {
+		t.Errorf("Expected oldest resumable version 3, got %d", tooOld.Oldest)
+	}
+
+	w, err := ds.Watch(datastore.KindTask, tooOld.Oldest)
+	if err != nil {
+		t.Fatalf("Expected watch from oldest version to succeed, got %v", err)
+	}
+	defer w.Stop()
+	if ev := nextEvent(t, w); ev.ResourceVersion != 4 {
+		t.Errorf("Expected replay to start at version 4, got %d", ev.ResourceVersion)
+	}
+}
+
+func TestWatch_SlowWatcherIsClosed(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	w, err := ds.Watch("", 0)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	// Writers must never block on a watcher that is not reading.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			_ = ds.SaveTask(models.Task{ID: "task-1"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Writes blocked on a slow watcher")
+	}
+
+	count := 0
+	for range w.ResultChan() {
+		count++
+	}
+	if !errors.Is(w.Err(), datastore.ErrWatcherOverflow) {
+		t.Errorf("Expected overflow error, got %v", w.Err())
+	}
+	if count == 0 || count >= 500 {
+		t.Errorf("Expected a partial stream before the watcher was closed, got %d events", count)
+	}
+	w.Stop()
+}
+
+func TestWatch_FileDatastoreHistoryStartsAtRestart(t *testing.T) {
+	dir := t.TempDir()
+	ds := openFileDatastore(t, dir, 0)
+	if err := ds.SaveTask(models.Task{ID: "task-1"}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.SaveTask(models.Task{ID: "task-2"}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if err := ds.DeleteTask("task-1"); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Failed to close datastore: %v", err)
+	}
+
+	reopened := openFileDatastore(t, dir, 0)
+	defer reopened.Close()
+	if got := len(taskStatuses(t, reopened)); got != 1 {
+		t.Errorf("Expected the deletion to be replayed, got %d tasks", got)
+	}
+	if _, err := reopened.Watch(datastore.KindTask, 1); !errors.Is(err, datastore.ErrResourceVersionTooOld) {
+		t.Errorf("Expected history from before the restart to be too old, got %v", err)
+	}
+	w, err := reopened.Watch(datastore.KindTask, 3)
+	if err != nil {
+		t.Fatalf("Expected watch from the current version to succeed, got %v", err)
+	}
+	defer w.Stop()
+	if err := reopened.SaveTask(models.Task{ID: "task-3"}); err != nil {
+		t.Fatalf("Failed to save task: %v", err)
+	}
+	if ev := nextEvent(t, w); ev.ID != "task-3" || ev.ResourceVersion != 4 {
+		t.Errorf("Expected task-3 at version 4, got %s at %d", ev.ID, ev.ResourceVersion)
+	}
+}
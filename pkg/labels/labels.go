@@ -0,0 +1,126 @@
+// Package labels validates the labels and annotations of objects, and
+// parses and evaluates the label selectors that pick objects by their
+// labels.
+package labels
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxNameLength is the longest the name part of a key, or a label value,
+	// may be.
+	MaxNameLength = 63
+	// MaxPrefixLength is the longest the prefix part of a key may be.
+	MaxPrefixLength = 253
+	// MaxAnnotationsSize is the most bytes the keys and values of an
+	// object's annotations may add up to.
+	MaxAnnotationsSize = 256 * 1024
+)
+
+// ValidateKey checks the key of a label or annotation. A key is a name,
+// optionally preceded by a prefix and a slash, as in "example.com/team".
+// Names are at most 63 characters: letters, digits, '-', '_' and '.',
+// beginning and ending with a letter or digit. Prefixes are DNS subdomains
+// of at most 253 characters.
+func ValidateKey(key string) error {
+	name := key
+	if prefix, rest, ok := strings.Cut(key, "/"); ok {
+		if err := validatePrefix(prefix); err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+		name = rest
+	}
+	if name == "" {
+		return fmt.Errorf("key %q: name must not be empty", key)
+	}
+	if err := validateName(name); err != nil {
+		return fmt.Errorf("key %q: %w", key, err)
+	}
+	return nil
+}
+
+// ValidateValue checks the value of a label, which is empty or follows the
+// rules for the name part of a key.
+func ValidateValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	if err := validateName(value); err != nil {
+		return fmt.Errorf("value %q: %w", value, err)
+	}
+	return nil
+}
+
+// Validate checks every key and value of a set of labels, and returns the
+// problems found by key.
+func Validate(labels map[string]string) map[string]error {
+	errs := make(map[string]error)
+	for k, v := range labels {
+		if err := ValidateKey(k); err != nil {
+			errs[k] = err
+		} else if err := ValidateValue(v); err != nil {
+			errs[k] = err
+		}
+	}
+	return errs
+}
+
+// ValidateAnnotations checks the keys of a set of annotations, whose values
+// are free-form, and their total size. It returns the problems found by
+// key, with the size limit reported under the empty key.
+func ValidateAnnotations(annotations map[string]string) map[string]error {
+	errs := make(map[string]error)
+	size := 0
+	for k, v := range annotations {
+		size += len(k) + len(v)
+		if err := ValidateKey(k); err != nil {
+			errs[k] = err
+		}
+	}
+	if size > MaxAnnotationsSize {
+		errs[""] = fmt.Errorf("total size %d bytes exceeds %d", size, MaxAnnotationsSize)
+	}
+	return errs
+}
+
+// validateName checks the name part of a key, or a label value.
+func validateName(name string) error {
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("must be at most %d characters", MaxNameLength)
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		alnum := isAlphanumeric(c)
+		if (i == 0 || i == len(name)-1) && !alnum {
+			return fmt.Errorf("must begin and end with a letter or digit")
+		}
+		if !alnum && c != '-' && c != '_' && c != '.' {
+			return fmt.Errorf("must consist of letters, digits, '-', '_' and '.'")
+		}
+	}
+	return nil
+}
+
+// validatePrefix checks the prefix part of a key.
+func validatePrefix(prefix string) error {
+	if len(prefix) > MaxPrefixLength {
+		return fmt.Errorf("prefix must be at most %d characters", MaxPrefixLength)
+	}
+	for _, part := range strings.Split(prefix, ".") {
+		if part == "" || part[0] == '-' || part[len(part)-1] == '-' {
+			return fmt.Errorf("prefix must be a DNS subdomain such as example.com")
+		}
+		for i := 0; i < len(part); i++ {
+			if c := part[i]; !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("prefix must be a DNS subdomain such as example.com")
+			}
+		}
+	}
+	return nil
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
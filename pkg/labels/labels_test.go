@@ -0,0 +1,42 @@
+package labels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"app", "app.kubernetes_io-name", "example.com/team", "a", "A1", strings.Repeat("x", 63)} {
+		if err := ValidateKey(key); err != nil {
+			t.Errorf("ValidateKey(%q): %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "-app", "app-", "a b", "team/", "/team", "Example.com/team", "a..b/c", "a/b/c", strings.Repeat("x", 64)} {
+		if err := ValidateKey(key); err == nil {
+			t.Errorf("ValidateKey(%q): expected an error", key)
+		}
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	for _, v := range []string{"", "prod", "v1.2_3-rc"} {
+		if err := ValidateValue(v); err != nil {
+			t.Errorf("ValidateValue(%q): %v", v, err)
+		}
+	}
+	for _, v := range []string{"-prod", "prod.", "a/b", "a b", strings.Repeat("v", 64)} {
+		if err := ValidateValue(v); err == nil {
+			t.Errorf("ValidateValue(%q): expected an error", v)
+		}
+	}
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	if errs := ValidateAnnotations(map[string]string{"example.com/note": "free text: anything goes!"}); len(errs) != 0 {
+		t.Errorf("Expected free-form values to be accepted, got %v", errs)
+	}
+	errs := ValidateAnnotations(map[string]string{"bad key": "", "big": strings.Repeat("x", MaxAnnotationsSize)})
+	if errs["bad key"] == nil || errs[""] == nil || len(errs) != 2 {
+		t.Errorf("Expected the bad key and the size to be reported, got %v", errs)
+	}
+}
@@ -0,0 +1,207 @@
+package labels
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Operator is how a Requirement tests a label.
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+// Requirement is a single test of the labels of an object.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	// Values holds the one value Equals and NotEquals compare against, and
+	// the set of values of In and NotIn. Exists and DoesNotExist take none.
+	Values []string
+}
+
+// NewRequirement checks the key, operator and values of a requirement and
+// returns it. The values of In and NotIn are sorted.
+func NewRequirement(key string, op Operator, values []string) (Requirement, error) {
+	if err := ValidateKey(key); err != nil {
+		return Requirement{}, err
+	}
+	switch op {
+	case Equals, NotEquals:
+		if len(values) != 1 {
+			return Requirement{}, fmt.Errorf("%s: operator %q takes exactly one value", key, op)
+		}
+	case In, NotIn:
+		if len(values) == 0 {
+			return Requirement{}, fmt.Errorf("%s: operator %q takes at least one value", key, op)
+		}
+	case Exists, DoesNotExist:
+		if len(values) != 0 {
+			return Requirement{}, fmt.Errorf("%s: operator %q takes no values", key, op)
+		}
+	default:
+		return Requirement{}, fmt.Errorf("%s: unknown operator %q", key, op)
+	}
+	for _, v := range values {
+		if err := ValidateValue(v); err != nil {
+			return Requirement{}, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return Requirement{Key: key, Operator: op, Values: values}, nil
+}
+
+// Matches reports whether a set of labels satisfies the requirement. Labels
+// without the key satisfy NotEquals and NotIn.
+func (r Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Operator {
+	case Equals, In:
+		return ok && slices.Contains(r.Values, v)
+	case NotEquals, NotIn:
+		return !ok || !slices.Contains(r.Values, v)
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+// String returns the requirement in the syntax Parse accepts.
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Equals, NotEquals:
+		return r.Key + string(r.Operator) + strings.Join(r.Values, "")
+	case In, NotIn:
+		return r.Key + " " + string(r.Operator) + " (" + strings.Join(r.Values, ",") + ")"
+	case DoesNotExist:
+		return "!" + r.Key
+	}
+	return r.Key
+}
+
+// Selector picks objects whose labels satisfy every one of its
+// requirements. The empty selector matches everything.
+type Selector []Requirement
+
+// Matches reports whether a set of labels satisfies the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the selector in the syntax Parse accepts.
+func (s Selector) String() string {
+	terms := make([]string, len(s))
+	for i, r := range s {
+		terms[i] = r.String()
+	}
+	return strings.Join(terms, ",")
+}
+
+// Parse parses a selector written as a comma-separated list of
+// requirements, each one of:
+//
+//	key=value, key==value   the label is present with the value
+//	key!=value              the label is absent or has another value
+//	key in (v1,v2)          the label is present with one of the values
+//	key notin (v1,v2)       the label is absent or has none of the values
+//	key                     the label is present
+//	!key                    the label is absent
+//
+// as in "env=prod,tier in (web,api),!legacy". The empty string parses to
+// the empty selector.
+func Parse(expr string) (Selector, error) {
+	if strings.TrimSpace(expr) == "" {
+		return Selector{}, nil
+	}
+	terms, err := splitTerms(expr)
+	if err != nil {
+		return nil, err
+	}
+	s := make(Selector, 0, len(terms))
+	for _, term := range terms {
+		r, err := parseRequirement(strings.TrimSpace(term))
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// splitTerms splits a selector at the commas outside parentheses.
+func splitTerms(expr string) ([]string, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '(':
+			if depth++; depth > 1 {
+				return nil, fmt.Errorf("nested parentheses in %q", expr)
+			}
+		case ')':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in %q", expr)
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in %q", expr)
+	}
+	return append(terms, expr[start:]), nil
+}
+
+// parseRequirement parses a single requirement of a selector.
+func parseRequirement(term string) (Requirement, error) {
+	if term == "" {
+		return Requirement{}, fmt.Errorf("empty requirement")
+	}
+	if key, ok := strings.CutPrefix(term, "!"); ok && !strings.ContainsAny(key, "=()") {
+		return NewRequirement(strings.TrimSpace(key), DoesNotExist, nil)
+	}
+	if open := strings.IndexByte(term, '('); open >= 0 {
+		if !strings.HasSuffix(term, ")") {
+			return Requirement{}, fmt.Errorf("%q: expected ')' at the end", term)
+		}
+		head := strings.Fields(term[:open])
+		if len(head) != 2 || (head[1] != string(In) && head[1] != string(NotIn)) {
+			return Requirement{}, fmt.Errorf("%q: expected \"key in (...)\" or \"key notin (...)\"", term)
+		}
+		var values []string
+		if set := term[open+1 : len(term)-1]; strings.TrimSpace(set) != "" {
+			for _, v := range strings.Split(set, ",") {
+				values = append(values, strings.TrimSpace(v))
+			}
+		}
+		return NewRequirement(head[0], Operator(head[1]), values)
+	}
+	for _, op := range []string{"!=", "==", "="} {
+		if key, value, ok := strings.Cut(term, op); ok {
+			operator := Equals
+			if op == "!=" {
+				operator = NotEquals
+			}
+			return NewRequirement(strings.TrimSpace(key), operator, []string{strings.TrimSpace(value)})
+		}
+	}
+	return NewRequirement(term, Exists, nil)
+}
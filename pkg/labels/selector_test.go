@@ -0,0 +1,72 @@
+package labels
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	labels := map[string]string{"env": "prod", "tier": "web", "example.com/gpu": ""}
+	for _, tc := range []struct {
+		expr  string
+		match bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env==prod", true},
+		{"env=dev", false},
+		{"env!=dev", true},
+		{"missing!=dev", true},
+		{"tier in (web, api)", true},
+		{"tier in (api)", false},
+		{"tier notin (api,batch)", true},
+		{"missing notin (api)", true},
+		{"example.com/gpu", true},
+		{"missing", false},
+		{"!missing", true},
+		{"!env", false},
+		{" env = prod , tier in (web) , !legacy ", true},
+		{"env=prod,tier=api", false},
+	} {
+		s, err := Parse(tc.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.expr, err)
+			continue
+		}
+		if got := s.Matches(labels); got != tc.match {
+			t.Errorf("Parse(%q).Matches = %v, want %v", tc.expr, got, tc.match)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"env=prod,",
+		",env",
+		"env in ()",
+		"env in (a",
+		"env in a)",
+		"env in ((a))",
+		"env between (a,b)",
+		"env in (a) extra",
+		"bad key=x",
+		"env=bad value",
+		"!env=prod",
+		"=prod",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestSelector_String(t *testing.T) {
+	s, err := Parse("env=prod,tier notin (web,api),gpu,!legacy,zone!=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "env=prod,tier notin (api,web),gpu,!legacy,zone!=b"
+	if got := s.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if again, err := Parse(s.String()); err != nil || again.String() != want {
+		t.Errorf("Expected String to parse back to the same selector, got %v, %v", again, err)
+	}
+}
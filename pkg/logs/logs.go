@@ -0,0 +1,188 @@
+// Package logs stores the output of tasks in size-capped rotating files, one
+// timestamped line per entry, and reads it back with the filters offered by
+// the logs API.
+package logs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Stdout and Stderr name the streams a task writes to.
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// Entry is one line of output.
+type Entry struct {
+	Time   time.Time
+	Stream string
+	// Line is the text of the line, without its trailing newline.
+	Line string
+}
+
+// Format renders the entry for a reader, optionally prefixed with its
+// timestamp, and terminated by a newline.
+func (e Entry) Format(timestamps bool) string {
+	if timestamps {
+		return e.Time.UTC().Format(time.RFC3339Nano) + " " + e.Line + "\n"
+	}
+	return e.Line + "\n"
+}
+
+// marshal encodes the entry as it is stored in a log file.
+func (e Entry) marshal() string {
+	return e.Time.UTC().Format(time.RFC3339Nano) + " " + e.Stream + " " + e.Line + "\n"
+}
+
+// parseEntry decodes a line of a log file.
+func parseEntry(line string) (Entry, error) {
+	ts, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return Entry{}, fmt.Errorf("malformed log line %q", line)
+	}
+	stream, text, ok := strings.Cut(rest, " ")
+	if !ok {
+		return Entry{}, fmt.Errorf("malformed log line %q", line)
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return Entry{}, fmt.Errorf("malformed log line %q: %w", line, err)
+	}
+	return Entry{Time: t, Stream: stream, Line: text}, nil
+}
+
+// Options select which entries to return.
+type Options struct {
+	// Tail limits the result to the last Tail entries. Zero or a negative
+	// value returns all of them.
+	Tail int
+	// Since drops entries older than this time when it is not zero.
+	Since time.Time
+	// Timestamps asks for each line to be prefixed with its timestamp.
+	Timestamps bool
+	// Follow keeps the stream open for new entries until the task exits.
+	Follow bool
+}
+
+// Filter applies Since and Tail to entries, which must be oldest first.
+func Filter(entries []Entry, opts Options) []Entry {
+	if !opts.Since.IsZero() {
+		i := sort.Search(len(entries), func(i int) bool { return !entries[i].Time.Before(opts.Since) })
+		entries = entries[i:]
+	}
+	if opts.Tail > 0 && len(entries) > opts.Tail {
+		entries = entries[len(entries)-opts.Tail:]
+	}
+	return entries
+}
+
+// ReadFiles reads the log at path together with its rotated predecessors,
+// oldest first, and applies opts. A log that does not exist is empty.
+func ReadFiles(path string, opts Options) ([]Entry, error) {
+	var entries []Entry
+	for _, file := range logFiles(path) {
+		f, err := os.Open(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries, err = appendEntries(entries, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Filter(entries, opts), nil
+}
+
+// Remove deletes the log at path and its rotated predecessors.
+func Remove(path string) error {
+	for _, file := range logFiles(path) {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// logFiles returns the rotated files of the log at path, oldest first,
+// followed by path itself.
+func logFiles(path string) []string {
+	matches, _ := filepath.Glob(path + ".*")
+	var rotated []int
+	for _, m := range matches {
+		if n, err := strconv.Atoi(strings.TrimPrefix(m, path+".")); err == nil && n > 0 {
+			rotated = append(rotated, n)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(rotated)))
+	files := make([]string, 0, len(rotated)+1)
+	for _, n := range rotated {
+		files = append(files, rotatedName(path, n))
+	}
+	return append(files, path)
+}
+
+func rotatedName(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// appendEntries parses every line of r. A torn last line, left by a crash
+// in the middle of a write, is ignored.
+func appendEntries(entries []Entry, r io.Reader) ([]Entry, error) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		e, err := parseEntry(strings.TrimSuffix(line, "\n"))
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// ParseOptions reads Options from the tail, since, timestamps and follow
+// query parameters.
+func ParseOptions(q url.Values) (Options, error) {
+	var opts Options
+	var err error
+	if v := q.Get("tail"); v != "" {
+		if opts.Tail, err = strconv.Atoi(v); err != nil {
+			return Options{}, fmt.Errorf("invalid tail %q", v)
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if opts.Since, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return Options{}, fmt.Errorf("invalid since %q: want an RFC 3339 timestamp", v)
+		}
+	}
+	if v := q.Get("timestamps"); v != "" {
+		if opts.Timestamps, err = strconv.ParseBool(v); err != nil {
+			return Options{}, fmt.Errorf("invalid timestamps %q", v)
+		}
+	}
+	if v := q.Get("follow"); v != "" {
+		if opts.Follow, err = strconv.ParseBool(v); err != nil {
+			return Options{}, fmt.Errorf("invalid follow %q", v)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,119 @@
+package logs_test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+)
+
+func lines(entries []logs.Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Line
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFilter(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var entries []logs.Entry
+	for i, line := range []string{"a", "b", "c", "d"} {
+		entries = append(entries, logs.Entry{Time: base.Add(time.Duration(i) * time.Second), Stream: logs.Stdout, Line: line})
+	}
+	tests := []struct {
+		name string
+		opts logs.Options
+		want []string
+	}{
+		{"all", logs.Options{}, []string{"a", "b", "c", "d"}},
+		{"tail", logs.Options{Tail: 2}, []string{"c", "d"}},
+		{"tail beyond length", logs.Options{Tail: 10}, []string{"a", "b", "c", "d"}},
+		{"since", logs.Options{Since: base.Add(time.Second)}, []string{"b", "c", "d"}},
+		{"since and tail", logs.Options{Since: base.Add(time.Second), Tail: 1}, []string{"d"}},
+		{"since after last", logs.Options{Since: base.Add(time.Minute)}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := lines(logs.Filter(entries, tt.opts)); !equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEntry_Format(t *testing.T) {
+	e := logs.Entry{Time: time.Date(2024, 1, 1, 0, 0, 1, 500, time.UTC), Stream: logs.Stderr, Line: "oops"}
+	if got := e.Format(false); got != "oops\n" {
+		t.Errorf("unexpected %q", got)
+	}
+	if got := e.Format(true); got != "2024-01-01T00:00:01.0000005Z oops\n" {
+		t.Errorf("unexpected %q", got)
+	}
+}
+
+func TestReadFiles_RotatedAndTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task.log")
+	write := func(name, data string) {
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(path+".2", "2024-01-01T00:00:00Z stdout one\n")
+	write(path+".1", "2024-01-01T00:00:01Z stderr two\n")
+	write(path, "2024-01-01T00:00:02Z stdout three has spaces\n2024-01-01T00:00:03Z std")
+
+	entries, err := logs.ReadFiles(path, logs.Options{})
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if got := lines(entries); !equal(got, []string{"one", "two", "three has spaces"}) {
+		t.Fatalf("unexpected entries %v", got)
+	}
+	if entries[1].Stream != logs.Stderr {
+		t.Errorf("expected the stream to be kept, got %+v", entries[1])
+	}
+
+	if err := logs.Remove(path); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if entries, err := logs.ReadFiles(path, logs.Options{}); err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries after Remove, got %v (%v)", entries, err)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	q := url.Values{"tail": {"5"}, "since": {"2024-01-01T00:00:00Z"}, "timestamps": {"true"}, "follow": {"1"}}
+	opts, err := logs.ParseOptions(q)
+	if err != nil {
+		t.Fatalf("ParseOptions: %v", err)
+	}
+	want := logs.Options{Tail: 5, Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Timestamps: true, Follow: true}
+	if opts != want {
+		t.Errorf("got %+v, want %+v", opts, want)
+	}
+
+	for _, q := range []url.Values{
+		{"tail": {"x"}},
+		{"since": {"yesterday"}},
+		{"timestamps": {"maybe"}},
+		{"follow": {"sure"}},
+	} {
+		if _, err := logs.ParseOptions(q); err == nil {
+			t.Errorf("expected an error for %v", q)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the size a log file may grow to before it is
+	// rotated.
+	DefaultMaxSize = 10 << 20
+	// DefaultMaxFiles is the number of rotated files kept besides the
+	// current one.
+	DefaultMaxFiles = 4
+	// maxLineSize is the longest line kept in one entry; longer lines are
+	// split.
+	maxLineSize = 16 << 10
+	// subscriptionBuffer is the number of entries a follower may fall behind
+	// by before it is dropped.
+	subscriptionBuffer = 256
+)
+
+// ErrFollowerOverflow is reported by Subscription.Err when a follower was
+// dropped because it did not keep up.
+var ErrFollowerOverflow = errors.New("log follower fell too far behind and was dropped")
+
+// Writer appends timestamped entries to a log file. Once the file reaches
+// its maximum size it is renamed to path.1, older files move up by one, and
+// the oldest beyond the maximum count is deleted.
+type Writer struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	streams []*streamWriter
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// NewWriter opens the log at path for appending. A maxSize or maxFiles of
+// zero selects the default.
+func NewWriter(path string, maxSize int64, maxFiles int) (*Writer, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	w := &Writer{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		now:      time.Now,
+		subs:     make(map[*Subscription]struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// Stream returns a writer whose output is split into lines and logged
+// under the given stream name. It is not safe for concurrent use, but
+// different streams of one Writer are.
+func (w *Writer) Stream(name string) io.Writer {
+	s := &streamWriter{w: w, name: name}
+	w.mu.Lock()
+	w.streams = append(w.streams, s)
+	w.mu.Unlock()
+	return s
+}
+
+// Follow returns the entries logged so far that match opts and subscribes
+// to the ones logged after them, with nothing lost or repeated in between.
+func (w *Writer) Follow(opts Options) ([]Entry, *Subscription, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entries, err := ReadFiles(w.path, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	sub := &Subscription{w: w, ch: make(chan Entry, subscriptionBuffer)}
+	if w.closed {
+		close(sub.ch)
+		return entries, sub, nil
+	}
+	w.subs[sub] = struct{}{}
+	return entries, sub, nil
+}
+
+// Close flushes partial lines and closes the file. Subscriptions end once
+// they have delivered everything logged before.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	for _, s := range w.streams {
+		if len(s.buf) > 0 {
+			w.appendLocked(Entry{Time: w.now(), Stream: s.name, Line: string(s.buf)})
+			s.buf = nil
+		}
+	}
+	w.closed = true
+	for sub := range w.subs {
+		close(sub.ch)
+	}
+	w.subs = nil
+	return w.file.Close()
+}
+
+// append logs one entry.
+func (w *Writer) append(e Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.appendLocked(e)
+}
+
+// appendLocked logs one entry. The caller must hold w.mu.
+func (w *Writer) appendLocked(e Entry) error {
+	data := e.marshal()
+	if w.size > 0 && w.size+int64(len(data)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.WriteString(data)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	for sub := range w.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.err = ErrFollowerOverflow
+			close(sub.ch)
+			delete(w.subs, sub)
+		}
+	}
+	return nil
+}
+
+// rotate shifts every file up by one, dropping the oldest, and starts a new
+// current file. The caller must hold w.mu.
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(rotatedName(w.path, w.maxFiles)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := w.maxFiles - 1; n >= 1; n-- {
+		if err := os.Rename(rotatedName(w.path, n), rotatedName(w.path, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(w.path, rotatedName(w.path, 1)); err != nil {
+		return fmt.Errorf("rotating %s: %w", w.path, err)
+	}
+	return w.open()
+}
+
+// streamWriter splits the output of one stream into lines.
+type streamWriter struct {
+	w    *Writer
+	name string
+	// buf holds the start of a line whose newline has not arrived yet. It
+	// is only touched by the stream's writer, and by Close under w.mu once
+	// writing has finished.
+	buf []byte
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.buf = append(s.buf, p...)
+			p = nil
+		} else {
+			s.buf = append(s.buf, p[:i]...)
+			p = p[i+1:]
+		}
+		for len(s.buf) > maxLineSize {
+			if err := s.emit(s.buf[:maxLineSize]); err != nil {
+				return 0, err
+			}
+			s.buf = s.buf[maxLineSize:]
+		}
+		if i >= 0 {
+			if err := s.emit(s.buf); err != nil {
+				return 0, err
+			}
+			s.buf = s.buf[:0]
+		}
+	}
+	return written, nil
+}
+
+func (s *streamWriter) emit(line []byte) error {
+	return s.w.append(Entry{Time: s.w.now(), Stream: s.name, Line: string(line)})
+}
+
+// Subscription delivers the entries logged after it was created.
+type Subscription struct {
+	w   *Writer
+	ch  chan Entry
+	err error
+}
+
+// C returns the channel of new entries. It is closed when the Writer is
+// closed, the subscription is stopped, or the follower falls behind, in
+// which case Err says so.
+func (s *Subscription) C() <-chan Entry {
+	return s.ch
+}
+
+// Err reports why the channel was closed early, if it was.
+func (s *Subscription) Err() error {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	return s.err
+}
+
+// Stop ends the subscription.
+func (s *Subscription) Stop() {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	if _, ok := s.w.subs[s]; ok {
+		delete(s.w.subs, s)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,167 @@
+package logs_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+)
+
+func newWriter(t *testing.T, maxSize int64, maxFiles int) (*logs.Writer, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "task.log")
+	w, err := logs.NewWriter(path, maxSize, maxFiles)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w, path
+}
+
+func TestWriter_SplitsLinesAcrossWrites(t *testing.T) {
+	w, path := newWriter(t, 0, 0)
+	stdout, stderr := w.Stream(logs.Stdout), w.Stream(logs.Stderr)
+	io.WriteString(stdout, "hel")
+	io.WriteString(stderr, "err\n")
+	io.WriteString(stdout, "lo\nworld\npartial")
+	w.Close()
+
+	entries, err := logs.ReadFiles(path, logs.Options{})
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if got := lines(entries); !equal(got, []string{"err", "hello", "world", "partial"}) {
+		t.Fatalf("unexpected lines %v", got)
+	}
+	if entries[0].Stream != logs.Stderr || entries[1].Stream != logs.Stdout {
+		t.Errorf("unexpected streams %+v", entries)
+	}
+}
+
+func TestWriter_SplitsLongLines(t *testing.T) {
+	w, path := newWriter(t, 0, 0)
+	long := strings.Repeat("x", 40<<10)
+	io.WriteString(w.Stream(logs.Stdout), long+"\n")
+	w.Close()
+
+	entries, _ := logs.ReadFiles(path, logs.Options{})
+	if len(entries) != 3 {
+		t.Fatalf("expected the line to be split in 3, got %d entries", len(entries))
+	}
+	var joined strings.Builder
+	for _, e := range entries {
+		joined.WriteString(e.Line)
+	}
+	if joined.String() != long {
+		t.Error("split line does not add up to the original")
+	}
+}
+
+func TestWriter_Rotation(t *testing.T) {
+	w, path := newWriter(t, 200, 2)
+	out := w.Stream(logs.Stdout)
+	for i := range 20 {
+		fmt.Fprintf(out, "line %02d\n", i)
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 rotated files, stat .3: %v", err)
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("%s is %d bytes, over the limit", name, info.Size())
+		}
+	}
+
+	entries, err := logs.ReadFiles(path, logs.Options{})
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	got := lines(entries)
+	if len(got) == 0 || len(got) >= 20 || got[len(got)-1] != "line 19" {
+		t.Fatalf("expected the newest lines to survive rotation, got %v", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Fatalf("lines out of order: %v", got)
+		}
+	}
+}
+
+func TestWriter_Follow(t *testing.T) {
+	w, _ := newWriter(t, 0, 0)
+	out := w.Stream(logs.Stdout)
+	io.WriteString(out, "before\n")
+
+	entries, sub, err := w.Follow(logs.Options{})
+	if err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+	if got := lines(entries); !equal(got, []string{"before"}) {
+		t.Fatalf("unexpected backlog %v", got)
+	}
+	io.WriteString(out, "after\nunfinished")
+	w.Close()
+
+	var followed []logs.Entry
+	for e := range sub.C() {
+		followed = append(followed, e)
+	}
+	if got := lines(followed); !equal(got, []string{"after", "unfinished"}) {
+		t.Errorf("unexpected followed lines %v", got)
+	}
+	if sub.Err() != nil {
+		t.Errorf("unexpected error %v", sub.Err())
+	}
+
+	// Following a closed writer returns the backlog and an ended channel.
+	entries, sub, err = w.Follow(logs.Options{Tail: 1})
+	if err != nil || !equal(lines(entries), []string{"unfinished"}) {
+		t.Fatalf("unexpected Follow after Close: %v (%v)", lines(entries), err)
+	}
+	if _, ok := <-sub.C(); ok {
+		t.Error("expected the channel of a closed writer to be closed")
+	}
+}
+
+func TestWriter_FollowerOverflow(t *testing.T) {
+	w, _ := newWriter(t, 0, 0)
+	_, sub, err := w.Follow(logs.Options{})
+	if err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+	out := w.Stream(logs.Stdout)
+	for range 1000 {
+		io.WriteString(out, "spam\n")
+	}
+	n := 0
+	for range sub.C() {
+		n++
+	}
+	if n == 0 || n >= 1000 {
+		t.Errorf("expected the follower to be dropped after some entries, got %d", n)
+	}
+	if !errors.Is(sub.Err(), logs.ErrFollowerOverflow) {
+		t.Errorf("expected ErrFollowerOverflow, got %v", sub.Err())
+	}
+}
+
+func TestSubscription_Stop(t *testing.T) {
+	w, _ := newWriter(t, 0, 0)
+	_, sub, _ := w.Follow(logs.Options{})
+	sub.Stop()
+	sub.Stop()
+	io.WriteString(w.Stream(logs.Stdout), "ignored\n")
+	if _, ok := <-sub.C(); ok {
+		t.Error("expected a stopped subscription to deliver nothing")
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+// Affinity groups the scheduling constraints of a task that go beyond its
+// resource requests.
+type Affinity struct {
+	NodeAffinity *NodeAffinity `json:"nodeAffinity,omitempty"`
+	// TaskAffinity draws the task to the topology domains of other tasks,
+	// and TaskAntiAffinity keeps it away from them.
+	TaskAffinity     *TaskAffinity `json:"taskAffinity,omitempty"`
+	TaskAntiAffinity *TaskAffinity `json:"taskAntiAffinity,omitempty"`
+}
+
+// NodeAffinity constrains the nodes a task is scheduled onto by their labels.
+// It is only considered when the task is scheduled: a task keeps running on
+// its node when the node's labels change later.
+type NodeAffinity struct {
+	// Required lists alternative node selectors: a node is only eligible if
+	// it matches at least one of them. No terms means every node is.
+	Required []LabelSelector `json:"required,omitempty"`
+	// Preferred terms rank the eligible nodes: the more weight of the terms
+	// a node matches, the more it is preferred.
+	Preferred []PreferredNodeTerm `json:"preferred,omitempty"`
+}
+
+// PreferredNodeTerm is a node selector with a weight between 1 and 100.
+type PreferredNodeTerm struct {
+	Weight     int           `json:"weight"`
+	Preference LabelSelector `json:"preference"`
+}
+
+// MatchesNode reports whether a node satisfies the task's nodeSelector and
+// required node affinity, and so may run the task.
+func (t Task) MatchesNode(n Node) bool {
+	if !(LabelSelector{MatchLabels: t.NodeSelector}).Matches(n.Labels) {
+		return false
+	}
+	if t.Affinity == nil || t.Affinity.NodeAffinity == nil || len(t.Affinity.NodeAffinity.Required) == 0 {
+		return true
+	}
+	for _, term := range t.Affinity.NodeAffinity.Required {
+		if term.Matches(n.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// TopologyKeyNode is the topology key whose domains are the individual
+// nodes. Any other topology key names a node label, such as "zone", and
+// groups the nodes by its value.
+const TopologyKeyNode = "node"
+
+// TopologyValue returns the topology domain of the node for a topology key,
+// and false when the node has no label of that name.
+func (n Node) TopologyValue(key string) (string, bool) {
+	if key == TopologyKeyNode {
+		return n.ID, true
+	}
+	v, ok := n.Labels[key]
+	return v, ok
+}
+
+// TaskAffinity is a set of inter-task affinity or anti-affinity terms. Like
+// node affinity, they are only considered when the task is scheduled.
+type TaskAffinity struct {
+	// Required terms must all hold for a node to be eligible.
+	Required []TaskAffinityTerm `json:"required,omitempty"`
+	// Preferred terms rank the eligible nodes by the weight of the terms
+	// that hold.
+	Preferred []WeightedTaskAffinityTerm `json:"preferred,omitempty"`
+}
+
+// TaskAffinityTerm holds for a node when the node's topology domain for
+// TopologyKey runs an unfinished task whose labels match LabelSelector, or,
+// for anti-affinity, runs none.
+type TaskAffinityTerm struct {
+	LabelSelector LabelSelector `json:"labelSelector"`
+	TopologyKey   string        `json:"topologyKey"`
+}
+
+// WeightedTaskAffinityTerm is a task affinity term with a weight between 1
+// and 100.
+type WeightedTaskAffinityTerm struct {
+	Weight int              `json:"weight"`
+	Term   TaskAffinityTerm `json:"term"`
+}
+
+// UnsatisfiableConstraintAction says what the scheduler does when a
+// topology spread constraint cannot be met.
+type UnsatisfiableConstraintAction string
+
+const (
+	// DoNotSchedule leaves the task pending rather than break the
+	// constraint. It is the default.
+	DoNotSchedule UnsatisfiableConstraintAction = "DoNotSchedule"
+	// ScheduleAnyway only ranks the nodes by how little they break it.
+	ScheduleAnyway UnsatisfiableConstraintAction = "ScheduleAnyway"
+)
+
+// TopologySpreadConstraint spreads the tasks matching LabelSelector evenly
+// across the topology domains for TopologyKey: placing the task must not
+// leave a domain with more than MaxSkew such tasks more than the domain
+// with the fewest.
+type TopologySpreadConstraint struct {
+	MaxSkew           int                           `json:"maxSkew"`
+	TopologyKey       string                        `json:"topologyKey"`
+	WhenUnsatisfiable UnsatisfiableConstraintAction `json:"whenUnsatisfiable,omitempty"`
+	LabelSelector     LabelSelector                 `json:"labelSelector"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// KindCronJob is the Kind of the OwnerReference of jobs created by a
+// CronJob.
+const KindCronJob = "CronJob"
+
+// ConcurrencyPolicy says what a cron job does when it is due while a job it
+// started earlier is still running.
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent starts the new job alongside the running ones. It is
+	// the default.
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent skips the new job.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent deletes the running jobs and starts the new one.
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// Defaults of CronJob.
+const (
+	DefaultSuccessfulJobsHistoryLimit = 3
+	DefaultFailedJobsHistoryLimit     = 1
+)
+
+// CronJob creates a job from a template every time its schedule is due.
+type CronJob struct {
+	ID string `json:"id"`
+	// ResourceVersion changes every time the cron job is saved. Supplying a
+	// non-zero version on save makes the write conditional on it.
+	ResourceVersion uint64 `json:"resourceVersion,omitempty"`
+	// Labels and Annotations describe the cron job; see Task.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Schedule is a five-field cron expression, such as "*/15 * * * *",
+	// evaluated in TimeZone, an IANA time zone name such as "Europe/Madrid".
+	// Without a time zone, the orchestrator's local time is used.
+	Schedule string `json:"schedule"`
+	TimeZone string `json:"timeZone,omitempty"`
+	// ConcurrencyPolicy applies when a run is due while earlier jobs are
+	// still active.
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// StartingDeadlineSeconds, when set, is how late a run may start after
+	// its scheduled time. Runs missed by more are skipped.
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+	// Suspend stops new runs; jobs already started are not affected.
+	Suspend bool `json:"suspend,omitempty"`
+	// SuccessfulJobsHistoryLimit and FailedJobsHistoryLimit are how many
+	// finished jobs of each kind are kept, DefaultSuccessfulJobsHistoryLimit
+	// and DefaultFailedJobsHistoryLimit when nil.
+	SuccessfulJobsHistoryLimit *int `json:"successfulJobsHistoryLimit,omitempty"`
+	FailedJobsHistoryLimit     *int `json:"failedJobsHistoryLimit,omitempty"`
+	// JobTemplate is the job every run is created from. Its ID, owner and
+	// status are ignored.
+	JobTemplate Job `json:"jobTemplate"`
+	// CreationTime is when the cron job was created. No run is due before.
+	CreationTime time.Time `json:"creationTime,omitzero"`
+	// Status is maintained by the cron job controller and ignored on
+	// updates.
+	Status CronJobStatus `json:"status"`
+}
+
+// CronJobStatus is the last observed state of a cron job.
+type CronJobStatus struct {
+	// Active lists the IDs of the jobs that have not finished.
+	Active []string `json:"active,omitempty"`
+	// LastScheduleTime is the scheduled time of the last run started, and
+	// LastSuccessfulTime when the last job to complete did so.
+	LastScheduleTime   time.Time `json:"lastScheduleTime,omitzero"`
+	LastSuccessfulTime time.Time `json:"lastSuccessfulTime,omitzero"`
+}
+
+// SuccessfulHistoryLimit returns SuccessfulJobsHistoryLimit or its default.
+func (cj CronJob) SuccessfulHistoryLimit() int {
+	if cj.SuccessfulJobsHistoryLimit == nil {
+		return DefaultSuccessfulJobsHistoryLimit
+	}
+	return *cj.SuccessfulJobsHistoryLimit
+}
+
+// FailedHistoryLimit returns FailedJobsHistoryLimit or its default.
+func (cj CronJob) FailedHistoryLimit() int {
+	if cj.FailedJobsHistoryLimit == nil {
+		return DefaultFailedJobsHistoryLimit
+	}
+	return *cj.FailedJobsHistoryLimit
+}
+
+// Location returns the time zone the schedule is evaluated in.
+func (cj CronJob) Location() (*time.Location, error) {
+	if cj.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(cj.TimeZone)
+}
+
+func (cj *CronJob) GetID() string               { return cj.ID }
+func (cj *CronJob) GetResourceVersion() uint64  { return cj.ResourceVersion }
+func (cj *CronJob) SetResourceVersion(v uint64) { cj.ResourceVersion = v }
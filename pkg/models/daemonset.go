@@ -0,0 +1,85 @@
+package models
+
+// KindDaemonSet is the Kind of the OwnerReference of tasks created by a
+// DaemonSet.
+const KindDaemonSet = "DaemonSet"
+
+// DaemonSetUpdateStrategyType says how a daemon set replaces the tasks of
+// an old template.
+type DaemonSetUpdateStrategyType string
+
+const (
+	// DaemonSetRollingUpdate replaces old tasks a few nodes at a time. It is
+	// the default.
+	DaemonSetRollingUpdate DaemonSetUpdateStrategyType = "RollingUpdate"
+	// DaemonSetOnDelete only uses the new template for tasks created after
+	// the old ones were deleted by hand.
+	DaemonSetOnDelete DaemonSetUpdateStrategyType = "OnDelete"
+)
+
+// DefaultDaemonSetMaxUnavailable is how many nodes may be without a ready
+// task during a rolling update unless the daemon set says otherwise.
+var DefaultDaemonSetMaxUnavailable = IntOrPercent{Value: 1}
+
+// DaemonSetUpdateStrategy describes how a daemon set rolls out a new
+// template.
+type DaemonSetUpdateStrategy struct {
+	Type          DaemonSetUpdateStrategyType `json:"type,omitempty"`
+	RollingUpdate *RollingUpdateDaemonSet     `json:"rollingUpdate,omitempty"`
+}
+
+// RollingUpdateDaemonSet bounds a rolling update. MaxUnavailable is how many
+// of the nodes that should run the task may be without a ready one, as a
+// number or a percentage of those nodes rounded down. It defaults to 1 and
+// is at least 1 once resolved, so that the update can progress.
+type RollingUpdateDaemonSet struct {
+	MaxUnavailable *IntOrPercent `json:"maxUnavailable,omitempty"`
+}
+
+// DaemonSet runs one copy of a task on every healthy node its node selector
+// matches.
+type DaemonSet struct {
+	ID string `json:"id"`
+	// ResourceVersion changes every time the daemon set is saved. Supplying
+	// a non-zero version on save makes the write conditional on it.
+	ResourceVersion uint64 `json:"resourceVersion,omitempty"`
+	// Labels and Annotations describe the daemon set; see Task.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// NodeSelector picks the nodes by their labels. When empty, every node
+	// runs the task.
+	NodeSelector LabelSelector `json:"nodeSelector,omitzero"`
+	// Template is the task run on every node. Changing it starts a rollout.
+	Template       Task                    `json:"template"`
+	UpdateStrategy DaemonSetUpdateStrategy `json:"updateStrategy,omitzero"`
+	// Status is maintained by the daemon set controller and ignored on
+	// updates.
+	Status DaemonSetStatus `json:"status"`
+}
+
+// DaemonSetStatus is the last observed state of a daemon set.
+type DaemonSetStatus struct {
+	// DesiredNumberScheduled is the number of nodes that should run the
+	// task, CurrentNumberScheduled how many of them do, UpdatedNumberScheduled
+	// how many run the current template, NumberReady how many run a ready
+	// task and NumberUnavailable how many do not.
+	DesiredNumberScheduled int `json:"desiredNumberScheduled"`
+	CurrentNumberScheduled int `json:"currentNumberScheduled"`
+	UpdatedNumberScheduled int `json:"updatedNumberScheduled"`
+	NumberReady            int `json:"numberReady"`
+	NumberUnavailable      int `json:"numberUnavailable"`
+}
+
+// MaxUnavailable resolves the rolling update bound against the number of
+// nodes that should run the task.
+func (ds DaemonSet) MaxUnavailable(desired int) int {
+	maxUnavailable := DefaultDaemonSetMaxUnavailable
+	if ru := ds.UpdateStrategy.RollingUpdate; ru != nil && ru.MaxUnavailable != nil {
+		maxUnavailable = *ru.MaxUnavailable
+	}
+	return max(maxUnavailable.Scaled(desired, false), 1)
+}
+
+func (ds *DaemonSet) GetID() string               { return ds.ID }
+func (ds *DaemonSet) GetResourceVersion() uint64  { return ds.ResourceVersion }
+func (ds *DaemonSet) SetResourceVersion(v uint64) { ds.ResourceVersion = v }
@@ -0,0 +1,123 @@
+package models
+
+// KindDeployment is the Kind of the OwnerReference of replica sets created
+// by a Deployment.
+const KindDeployment = "Deployment"
+
+// TemplateHashLabel is added to the selector and template of every replica
+// set a deployment creates, with a hash of the deployment's template as its
+// value, so that the tasks of different revisions are told apart.
+const TemplateHashLabel = "template-hash"
+
+// DeploymentStrategyType says how a deployment replaces old tasks with new
+// ones.
+type DeploymentStrategyType string
+
+const (
+	// RollingUpdate replaces tasks a few at a time, keeping the service up.
+	// It is the default.
+	RollingUpdate DeploymentStrategyType = "RollingUpdate"
+	// Recreate stops every old task before starting new ones.
+	Recreate DeploymentStrategyType = "Recreate"
+)
+
+// Defaults of RollingUpdateStrategy.
+var (
+	DefaultMaxSurge       = IntOrPercent{Value: 25, Percent: true}
+	DefaultMaxUnavailable = IntOrPercent{Value: 25, Percent: true}
+)
+
+// DefaultRevisionHistoryLimit is how many old replica sets a deployment
+// keeps for rollbacks unless it says otherwise.
+const DefaultRevisionHistoryLimit = 10
+
+// DeploymentStrategy describes how a deployment rolls out a new template.
+type DeploymentStrategy struct {
+	Type          DeploymentStrategyType `json:"type,omitempty"`
+	RollingUpdate *RollingUpdateStrategy `json:"rollingUpdate,omitempty"`
+}
+
+// RollingUpdateStrategy bounds a rolling update. MaxSurge is how many tasks
+// may exist above the wanted replica count, and MaxUnavailable how many of
+// the wanted replicas may be unready. Percentages are of the replica count,
+// MaxSurge rounded up and MaxUnavailable down. Both default to 25%, and they
+// may not both be zero.
+type RollingUpdateStrategy struct {
+	MaxSurge       *IntOrPercent `json:"maxSurge,omitempty"`
+	MaxUnavailable *IntOrPercent `json:"maxUnavailable,omitempty"`
+}
+
+// Deployment runs a number of copies of a task through replica sets, one
+// per revision of its template, and moves between revisions gradually.
+type Deployment struct {
+	ID string `json:"id"`
+	// ResourceVersion changes every time the deployment is saved. Supplying
+	// a non-zero version on save makes the write conditional on it.
+	ResourceVersion uint64 `json:"resourceVersion,omitempty"`
+	// Labels and Annotations describe the deployment; see Task.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Replicas is the number of tasks wanted.
+	Replicas int `json:"replicas"`
+	// Selector picks the tasks of the deployment. It must match the labels
+	// of Template and cannot change.
+	Selector LabelSelector `json:"selector"`
+	// Template is the task every replica is created from. Changing it starts
+	// a rollout.
+	Template Task               `json:"template"`
+	Strategy DeploymentStrategy `json:"strategy,omitzero"`
+	// Paused stops rollouts: template changes wait until it is cleared.
+	Paused bool `json:"paused,omitempty"`
+	// RevisionHistoryLimit is how many replica sets of old revisions are
+	// kept for rollbacks, DefaultRevisionHistoryLimit when nil.
+	RevisionHistoryLimit *int `json:"revisionHistoryLimit,omitempty"`
+	// Status is maintained by the deployment controller and ignored on
+	// updates.
+	Status DeploymentStatus `json:"status"`
+}
+
+// DeploymentStatus is the last observed state of a deployment.
+type DeploymentStatus struct {
+	// Revision is the revision of the current template.
+	Revision int `json:"revision,omitempty"`
+	// Replicas is the number of tasks of every revision, UpdatedReplicas how
+	// many of them run the current template, ReadyReplicas how many are
+	// ready, and UnavailableReplicas how many of the wanted replicas are not.
+	Replicas            int `json:"replicas"`
+	UpdatedReplicas     int `json:"updatedReplicas"`
+	ReadyReplicas       int `json:"readyReplicas"`
+	UnavailableReplicas int `json:"unavailableReplicas"`
+}
+
+// HistoryLimit returns RevisionHistoryLimit or its default.
+func (d Deployment) HistoryLimit() int {
+	if d.RevisionHistoryLimit == nil {
+		return DefaultRevisionHistoryLimit
+	}
+	return *d.RevisionHistoryLimit
+}
+
+// SurgeAndUnavailable resolves the rolling update bounds against the
+// replica count. When both come out as zero, one task may be unavailable so
+// that the rollout can progress.
+func (d Deployment) SurgeAndUnavailable() (surge, unavailable int) {
+	maxSurge, maxUnavailable := DefaultMaxSurge, DefaultMaxUnavailable
+	if ru := d.Strategy.RollingUpdate; ru != nil {
+		if ru.MaxSurge != nil {
+			maxSurge = *ru.MaxSurge
+		}
+		if ru.MaxUnavailable != nil {
+			maxUnavailable = *ru.MaxUnavailable
+		}
+	}
+	surge = maxSurge.Scaled(d.Replicas, true)
+	unavailable = min(maxUnavailable.Scaled(d.Replicas, false), d.Replicas)
+	if surge == 0 && unavailable == 0 {
+		unavailable = 1
+	}
+	return surge, unavailable
+}
+
+func (d *Deployment) GetID() string               { return d.ID }
+func (d *Deployment) GetResourceVersion() uint64  { return d.ResourceVersion }
+func (d *Deployment) SetResourceVersion(v uint64) { d.ResourceVersion = v }
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// IntOrPercent is an absolute number or a percentage of some total, written
+// in JSON as a number such as 2 or a string such as "25%".
+type IntOrPercent struct {
+	Value int
+	// Percent is true when Value is a percentage.
+	Percent bool
+}
+
+// FromInt returns an absolute IntOrPercent.
+func FromInt(v int) *IntOrPercent {
+	return &IntOrPercent{Value: v}
+}
+
+// FromPercent returns a percentage.
+func FromPercent(v int) *IntOrPercent {
+	return &IntOrPercent{Value: v, Percent: true}
+}
+
+// Scaled resolves the value against total, rounding a percentage up or
+// down.
+func (v IntOrPercent) Scaled(total int, roundUp bool) int {
+	if !v.Percent {
+		return v.Value
+	}
+	f := float64(v.Value) * float64(total) / 100
+	if roundUp {
+		return int(math.Ceil(f))
+	}
+	return int(math.Floor(f))
+}
+
+func (v IntOrPercent) String() string {
+	if v.Percent {
+		return strconv.Itoa(v.Value) + "%"
+	}
+	return strconv.Itoa(v.Value)
+}
+
+// MarshalJSON writes a percentage as a string and a number as a number.
+func (v IntOrPercent) MarshalJSON() ([]byte, error) {
+	if v.Percent {
+		return json.Marshal(v.String())
+	}
+	return json.Marshal(v.Value)
+}
+
+// UnmarshalJSON accepts a number, or a string holding a number optionally
+// followed by "%".
+func (v *IntOrPercent) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*v = IntOrPercent{Value: n}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("must be a number or a percentage: %s", data)
+	}
+	digits, percent := strings.CutSuffix(s, "%")
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return fmt.Errorf("must be a number or a percentage: %q", s)
+	}
+	*v = IntOrPercent{Value: n, Percent: percent}
+	return nil
+}
@@ -0,0 +1,139 @@
+package models
+
+import "time"
+
+// KindJob is the Kind of the OwnerReference of tasks created by a Job.
+const KindJob = "Job"
+
+// Defaults of Job.
+const (
+	DefaultCompletions  = 1
+	DefaultParallelism  = 1
+	DefaultBackoffLimit = 6
+)
+
+// JobConditionType names a final state of a job.
+type JobConditionType string
+
+const (
+	// JobComplete is true once the job has as many successful tasks as it
+	// asks for.
+	JobComplete JobConditionType = "Complete"
+	// JobFailed is true once the job has given up.
+	JobFailed JobConditionType = "Failed"
+)
+
+// Reasons of the JobFailed condition.
+const (
+	// ReasonBackoffLimitExceeded means more tasks failed than BackoffLimit
+	// allows.
+	ReasonBackoffLimitExceeded = "BackoffLimitExceeded"
+	// ReasonDeadlineExceeded means the job ran for longer than
+	// ActiveDeadlineSeconds.
+	ReasonDeadlineExceeded = "DeadlineExceeded"
+)
+
+// JobCondition is one observation of a job.
+type JobCondition struct {
+	Type   JobConditionType `json:"type"`
+	Status ConditionStatus  `json:"status"`
+	// LastTransitionTime is when Status last changed.
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitzero"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+}
+
+// Job runs a task until a number of copies of it have succeeded.
+type Job struct {
+	ID string `json:"id"`
+	// ResourceVersion changes every time the job is saved. Supplying a
+	// non-zero version on save makes the write conditional on it.
+	ResourceVersion uint64 `json:"resourceVersion,omitempty"`
+	// Labels and Annotations describe the job; see Task.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Completions is how many tasks have to succeed, DefaultCompletions when
+	// nil, and Parallelism how many may run at once, DefaultParallelism when
+	// nil.
+	Completions *int `json:"completions,omitempty"`
+	Parallelism *int `json:"parallelism,omitempty"`
+	// BackoffLimit is how many failures are tolerated before the job fails,
+	// DefaultBackoffLimit when nil. Every failed task and every restart of a
+	// task counts.
+	BackoffLimit *int `json:"backoffLimit,omitempty"`
+	// ActiveDeadlineSeconds, when set, is how long the job may run before it
+	// fails, counted from when it started.
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// Template is the task every copy is created from. Its restart policy
+	// must be Never, the default, or OnFailure.
+	Template Task `json:"template"`
+	// Owner is the cron job that created the job, if any.
+	Owner *OwnerReference `json:"owner,omitempty"`
+	// Status is maintained by the job controller and ignored on updates.
+	Status JobStatus `json:"status"`
+}
+
+// JobStatus is the last observed state of a job.
+type JobStatus struct {
+	// StartTime is when the job controller first saw the job, and
+	// CompletionTime when the job completed.
+	StartTime      time.Time `json:"startTime,omitzero"`
+	CompletionTime time.Time `json:"completionTime,omitzero"`
+	// Active is the number of tasks that have not finished, Succeeded the
+	// number of tasks that succeeded, and Failed the number of failures
+	// counted against BackoffLimit.
+	Active    int `json:"active"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Conditions holds the final state of the job once it has one.
+	Conditions []JobCondition `json:"conditions,omitempty"`
+}
+
+// WantedCompletions returns Completions or its default.
+func (j Job) WantedCompletions() int {
+	if j.Completions == nil {
+		return DefaultCompletions
+	}
+	return *j.Completions
+}
+
+// MaxParallel returns Parallelism or its default.
+func (j Job) MaxParallel() int {
+	if j.Parallelism == nil {
+		return DefaultParallelism
+	}
+	return *j.Parallelism
+}
+
+// FailureLimit returns BackoffLimit or its default.
+func (j Job) FailureLimit() int {
+	if j.BackoffLimit == nil {
+		return DefaultBackoffLimit
+	}
+	return *j.BackoffLimit
+}
+
+// Condition returns the condition of the given type, or nil if the job has
+// none.
+func (j Job) Condition(typ JobConditionType) *JobCondition {
+	for i := range j.Status.Conditions {
+		if j.Status.Conditions[i].Type == typ {
+			return &j.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsFinished reports whether the job has completed or failed.
+func (j Job) IsFinished() bool {
+	for _, typ := range []JobConditionType{JobComplete, JobFailed} {
+		if c := j.Condition(typ); c != nil && c.Status == ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *Job) GetID() string               { return j.ID }
+func (j *Job) GetResourceVersion() uint64  { return j.ResourceVersion }
+func (j *Job) SetResourceVersion(v uint64) { j.ResourceVersion = v }
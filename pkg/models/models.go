@@ -1,14 +1,342 @@
 // pkg/models/models.go
 package models
 
+import (
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+// Object is implemented by every resource kept in the datastore.
+type Object interface {
+	GetID() string
+	// GetResourceVersion returns the version stamped by the datastore when
+	// the object was last saved, or zero for an object never saved.
+	GetResourceVersion() uint64
+	SetResourceVersion(version uint64)
+}
+
+// NodeCondition summarises whether a node can currently run tasks.
+type NodeCondition string
+
+const (
+	// NodeReady nodes are healthy and renewing their lease.
+	NodeReady NodeCondition = "Ready"
+	// NodeNotReady nodes are unhealthy or missed their heartbeat grace period.
+	NodeNotReady NodeCondition = "NotReady"
+	// NodeUnknown nodes have not been heard from for so long that the state
+	// of their tasks cannot be known.
+	NodeUnknown NodeCondition = "Unknown"
+)
+
 // Node represents a cluster node.
 type Node struct {
-	ID      string
-	Healthy bool
+	ID string `json:"id"`
+	// Healthy is true exactly when Condition is NodeReady; only healthy nodes
+	// receive new tasks.
+	Healthy bool `json:"healthy"`
+	// Address is the base URL of the node's agent, which serves the logs of
+	// the tasks that ran there.
+	Address string `json:"address,omitempty"`
+	// Labels are identifying key/value pairs, such as the node's zone, that
+	// label selectors match against, and Annotations free-form key/value
+	// pairs for tools and people.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Taints keep the tasks that do not tolerate them off the node.
+	Taints    []Taint       `json:"taints,omitempty"`
+	Condition NodeCondition `json:"condition,omitempty"`
+	// LastTransitionTime is when Condition last changed.
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitzero"`
+	// LastHeartbeatTime is when the node last renewed its lease. Nodes that
+	// have never sent a heartbeat are not subject to lease expiry.
+	LastHeartbeatTime time.Time `json:"lastHeartbeatTime,omitzero"`
+	// ResourceVersion changes every time the node is saved. Supplying a
+	// non-zero version on save makes the write conditional on it.
+	ResourceVersion uint64 `json:"resourceVersion,omitempty"`
+	// Capacity is the total amount of each resource the node has.
+	Capacity resource.List `json:"capacity,omitempty"`
+	// Allocatable is the part of Capacity that can be handed out to tasks.
+	// When empty, the whole Capacity is considered allocatable.
+	Allocatable resource.List `json:"allocatable,omitempty"`
+}
+
+func (n *Node) GetID() string               { return n.ID }
+func (n *Node) GetResourceVersion() uint64  { return n.ResourceVersion }
+func (n *Node) SetResourceVersion(v uint64) { n.ResourceVersion = v }
+
+// AllocatableResources returns the resources tasks may be scheduled against.
+func (n Node) AllocatableResources() resource.List {
+	if len(n.Allocatable) > 0 {
+		return n.Allocatable
+	}
+	return n.Capacity
+}
+
+// ResourceRequirements describes the compute resources a task needs.
+type ResourceRequirements struct {
+	// Requests is the amount of each resource reserved for the task when it
+	// is placed on a node.
+	Requests resource.List `json:"requests,omitempty"`
+	// Limits is the maximum amount of each resource the task may use.
+	Limits resource.List `json:"limits,omitempty"`
+}
+
+// EffectiveRequests returns the requests of the task, falling back to the
+// limit for any resource that has a limit but no explicit request.
+func (r ResourceRequirements) EffectiveRequests() resource.List {
+	out := r.Requests.Copy()
+	for name, q := range r.Limits {
+		if _, ok := out[name]; !ok {
+			if out == nil {
+				out = resource.List{}
+			}
+			out[name] = q
+		}
+	}
+	return out
+}
+
+// TaskPhase is a step in the lifecycle of a task.
+type TaskPhase string
+
+const (
+	// TaskPending tasks are waiting to be bound to a node.
+	TaskPending TaskPhase = "pending"
+	// TaskScheduled tasks are bound to a node but have not started yet.
+	TaskScheduled TaskPhase = "scheduled"
+	// TaskRunning tasks have been started on their node.
+	TaskRunning TaskPhase = "running"
+	// TaskSucceeded tasks finished successfully.
+	TaskSucceeded TaskPhase = "succeeded"
+	// TaskFailed tasks finished unsuccessfully or could not be run.
+	TaskFailed TaskPhase = "failed"
+	// TaskCancelled tasks were stopped on request before finishing.
+	TaskCancelled TaskPhase = "cancelled"
+	// TaskUnknown tasks are bound to a node whose state cannot be observed.
+	TaskUnknown TaskPhase = "unknown"
+)
+
+// TaskPhases lists every valid phase.
+var TaskPhases = []TaskPhase{TaskPending, TaskScheduled, TaskRunning, TaskSucceeded, TaskFailed, TaskCancelled, TaskUnknown}
+
+// IsValid reports whether p is one of the defined phases.
+func (p TaskPhase) IsValid() bool {
+	for _, phase := range TaskPhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether a task in this phase has finished for good and
+// no longer holds resources on its node.
+func (p TaskPhase) IsTerminal() bool {
+	return p == TaskSucceeded || p == TaskFailed || p == TaskCancelled
+}
+
+// ReasonEvicted is the Reason of a task that failed because it was evicted
+// from an unhealthy node, or from a node with a NoExecute taint it does not
+// tolerate. Evicted tasks may be rescheduled elsewhere.
+const ReasonEvicted = "Evicted"
+
+// RestartPolicy says whether a task is run again once its process ends.
+type RestartPolicy string
+
+const (
+	// RestartNever leaves a task in the phase its process ended in. It is
+	// the default.
+	RestartNever RestartPolicy = "Never"
+	// RestartOnFailure runs a task again when it fails.
+	RestartOnFailure RestartPolicy = "OnFailure"
+	// RestartAlways runs a task again whenever its process ends.
+	RestartAlways RestartPolicy = "Always"
+)
+
+// IsValid reports whether p is one of the defined policies. The empty
+// policy is valid and means RestartNever.
+func (p RestartPolicy) IsValid() bool {
+	return p == "" || p == RestartNever || p == RestartOnFailure || p == RestartAlways
+}
+
+// TaskConditionType names an aspect of a task's state.
+type TaskConditionType string
+
+// TaskReady is true while a running task passes its readiness probe, or
+// simply runs when it has none.
+const TaskReady TaskConditionType = "Ready"
+
+// ConditionStatus is the value of a condition.
+type ConditionStatus string
+
+const (
+	ConditionTrue  ConditionStatus = "True"
+	ConditionFalse ConditionStatus = "False"
+)
+
+// TaskCondition is one observation of a task.
+type TaskCondition struct {
+	Type   TaskConditionType `json:"type"`
+	Status ConditionStatus   `json:"status"`
+	// LastTransitionTime is when Status last changed.
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitzero"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+}
+
+// PhaseTransition records when a task moved from one phase to another.
+type PhaseTransition struct {
+	// From is empty for the transition recorded when the task is created.
+	From TaskPhase `json:"from,omitempty"`
+	To   TaskPhase `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// EnvVar is an environment variable set for a task's process.
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// OwnerReference names the object that created a task and manages its
+// lifetime, such as a ReplicaSet.
+type OwnerReference struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
 }
 
 // Task represents a task that needs scheduling.
 type Task struct {
-	ID     string
-	Status string
+	ID     string    `json:"id"`
+	Status TaskPhase `json:"status"`
+	// Labels are identifying key/value pairs that label selectors match
+	// against, and Annotations free-form key/value pairs for tools and
+	// people. Label keys and values, and annotation keys, follow the rules
+	// of the labels package.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Owner, when set, is the object that created the task, and which
+	// deletes it when it is no longer needed.
+	Owner *OwnerReference `json:"owner,omitempty"`
+	// ResourceVersion changes every time the task is saved. Supplying a
+	// non-zero version on save makes the write conditional on it.
+	ResourceVersion uint64               `json:"resourceVersion,omitempty"`
+	Resources       ResourceRequirements `json:"resources"`
+	// Command is the executable the node agent runs, looked up in PATH when
+	// it contains no slash, and Args are the arguments passed to it.
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// Env is the environment of the process, in addition to a default PATH.
+	Env []EnvVar `json:"env,omitempty"`
+	// WorkingDir is the directory the process starts in. When empty, the
+	// node agent's own working directory is used.
+	WorkingDir string `json:"workingDir,omitempty"`
+	// RestartPolicy says when the task is run again after its process ends,
+	// and MaxRetries, when set, how many times at most.
+	RestartPolicy RestartPolicy `json:"restartPolicy,omitempty"`
+	MaxRetries    *int          `json:"maxRetries,omitempty"`
+	// RestartCount is how many times the task has been restarted, and
+	// LastTerminationReason why its process last ended, such as "Error".
+	RestartCount          int    `json:"restartCount,omitempty"`
+	LastTerminationReason string `json:"lastTerminationReason,omitempty"`
+	// LivenessProbe, when set, checks that the running task still works;
+	// the task is stopped when it fails, and restarted if its restart policy
+	// allows. ReadinessProbe decides the task's Ready condition.
+	LivenessProbe  *Probe `json:"livenessProbe,omitempty"`
+	ReadinessProbe *Probe `json:"readinessProbe,omitempty"`
+	// NodeSelector restricts the nodes the task is scheduled onto to those
+	// with all of the given labels, and Affinity further constrains and
+	// ranks them.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	Affinity     *Affinity         `json:"affinity,omitempty"`
+	// Tolerations let the task run on nodes with matching taints.
+	Tolerations []Toleration `json:"tolerations,omitempty"`
+	// TopologySpreadConstraints spread the task and its peers across nodes
+	// or zones.
+	TopologySpreadConstraints []TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// NodeID is the node the task is bound to, or empty while unscheduled.
+	NodeID string `json:"nodeId,omitempty"`
+	// Reason is a short machine-readable explanation of the current status,
+	// such as ReasonEvicted, and Message a human-readable one.
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// ExitCode is the exit code reported by the node agent once the task's
+	// process has finished, or nil while it has not.
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Conditions are observations of the task made by its node agent, such
+	// as whether it is ready.
+	Conditions []TaskCondition `json:"conditions,omitempty"`
+	// Transitions is the history of phase changes, oldest first. It is
+	// maintained by the task manager and ignored on updates.
+	Transitions []PhaseTransition `json:"transitions,omitempty"`
+}
+
+// RestartAllowed reports whether the restart policy asks for the task to be
+// run again after its process ended, unsuccessfully when failed is true,
+// and the task has retries left.
+func (t Task) RestartAllowed(failed bool) bool {
+	switch t.RestartPolicy {
+	case RestartAlways:
+	case RestartOnFailure:
+		if !failed {
+			return false
+		}
+	default:
+		return false
+	}
+	return t.MaxRetries == nil || t.RestartCount < *t.MaxRetries
+}
+
+// Condition returns the condition of the given type, or nil if the task
+// has none.
+func (t Task) Condition(typ TaskConditionType) *TaskCondition {
+	for i := range t.Conditions {
+		if t.Conditions[i].Type == typ {
+			return &t.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsReady reports whether the task's Ready condition is true.
+func (t Task) IsReady() bool {
+	c := t.Condition(TaskReady)
+	return c != nil && c.Status == ConditionTrue
+}
+
+// SetCondition adds c to the task's conditions or replaces the one of the
+// same type. LastTransitionTime is set to now when the status changes, and
+// kept otherwise.
+func (t *Task) SetCondition(c TaskCondition, now time.Time) {
+	for i := range t.Conditions {
+		if t.Conditions[i].Type != c.Type {
+			continue
+		}
+		c.LastTransitionTime = t.Conditions[i].LastTransitionTime
+		if t.Conditions[i].Status != c.Status {
+			c.LastTransitionTime = now
+		}
+		t.Conditions[i] = c
+		return
+	}
+	c.LastTransitionTime = now
+	t.Conditions = append(t.Conditions, c)
 }
+
+// TaskUsage is the resource usage of a task's container, as read by the
+// agent of its node.
+type TaskUsage struct {
+	TaskID string `json:"taskId"`
+	// CPUSeconds is the CPU time used so far, across all cores.
+	CPUSeconds float64 `json:"cpuSeconds"`
+	// MemoryBytes is the memory currently in use.
+	MemoryBytes int64 `json:"memoryBytes"`
+	// MemoryPeakBytes is the highest memory use seen, or zero when unknown.
+	MemoryPeakBytes int64     `json:"memoryPeakBytes,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+func (t *Task) GetID() string               { return t.ID }
+func (t *Task) GetResourceVersion() uint64  { return t.ResourceVersion }
+func (t *Task) SetResourceVersion(v uint64) { t.ResourceVersion = v }
@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// Probe describes a periodic check of a running task, performed by the node
+// agent. Exactly one of Exec, HTTPGet and TCPSocket must be set.
+type Probe struct {
+	Exec      *ExecAction      `json:"exec,omitempty"`
+	HTTPGet   *HTTPGetAction   `json:"httpGet,omitempty"`
+	TCPSocket *TCPSocketAction `json:"tcpSocket,omitempty"`
+	// InitialDelaySeconds is how long after the task starts the first check
+	// is made.
+	InitialDelaySeconds int `json:"initialDelaySeconds,omitempty"`
+	// PeriodSeconds is how often the check is made. Defaults to 10.
+	PeriodSeconds int `json:"periodSeconds,omitempty"`
+	// TimeoutSeconds is how long a check may take before it counts as
+	// failed. Defaults to 1.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// SuccessThreshold is how many checks in a row must succeed after a
+	// failure for the probe to pass again. Defaults to 1, and must be 1 for
+	// liveness probes.
+	SuccessThreshold int `json:"successThreshold,omitempty"`
+	// FailureThreshold is how many checks in a row must fail for the probe
+	// to fail. Defaults to 3.
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+}
+
+// ExecAction runs a command next to the task, with its environment and
+// working directory. The check succeeds when the command exits with 0.
+type ExecAction struct {
+	Command []string `json:"command"`
+}
+
+// HTTPGetAction requests a path from a port on the node's loopback
+// interface. The check succeeds on a status code from 200 to 399.
+type HTTPGetAction struct {
+	Path string `json:"path,omitempty"`
+	Port int    `json:"port"`
+}
+
+// TCPSocketAction opens a connection to a port on the node's loopback
+// interface. The check succeeds when the connection is accepted.
+type TCPSocketAction struct {
+	Port int `json:"port"`
+}
+
+// Probe defaults applied to unset fields.
+const (
+	DefaultProbePeriod           = 10 * time.Second
+	DefaultProbeTimeout          = time.Second
+	DefaultProbeSuccessThreshold = 1
+	DefaultProbeFailureThreshold = 3
+)
+
+// InitialDelay returns the delay before the first check.
+func (p Probe) InitialDelay() time.Duration {
+	return time.Duration(p.InitialDelaySeconds) * time.Second
+}
+
+// Period returns the time between checks.
+func (p Probe) Period() time.Duration {
+	return secondsOr(p.PeriodSeconds, DefaultProbePeriod)
+}
+
+// Timeout returns how long a single check may take.
+func (p Probe) Timeout() time.Duration {
+	return secondsOr(p.TimeoutSeconds, DefaultProbeTimeout)
+}
+
+// Successes returns the success threshold.
+func (p Probe) Successes() int {
+	if p.SuccessThreshold > 0 {
+		return p.SuccessThreshold
+	}
+	return DefaultProbeSuccessThreshold
+}
+
+// Failures returns the failure threshold.
+func (p Probe) Failures() int {
+	if p.FailureThreshold > 0 {
+		return p.FailureThreshold
+	}
+	return DefaultProbeFailureThreshold
+}
+
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return def
+}
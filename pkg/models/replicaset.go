@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/fntkg/container-orchestrator/pkg/labels"
+)
+
+// KindReplicaSet is the Kind of the OwnerReference of tasks created by a
+// ReplicaSet.
+const KindReplicaSet = "ReplicaSet"
+
+// LabelSelectorOperator is how a LabelSelectorRequirement tests a label.
+type LabelSelectorOperator string
+
+const (
+	LabelSelectorOpIn           LabelSelectorOperator = "In"
+	LabelSelectorOpNotIn        LabelSelectorOperator = "NotIn"
+	LabelSelectorOpExists       LabelSelectorOperator = "Exists"
+	LabelSelectorOpDoesNotExist LabelSelectorOperator = "DoesNotExist"
+)
+
+// selectorOperators maps the operators of LabelSelectorRequirement to those
+// of the labels package.
+var selectorOperators = map[LabelSelectorOperator]labels.Operator{
+	LabelSelectorOpIn:           labels.In,
+	LabelSelectorOpNotIn:        labels.NotIn,
+	LabelSelectorOpExists:       labels.Exists,
+	LabelSelectorOpDoesNotExist: labels.DoesNotExist,
+}
+
+// LabelSelectorRequirement is a set-based test of one label. In and NotIn
+// take a non-empty set of Values; Exists and DoesNotExist take none.
+type LabelSelectorRequirement struct {
+	Key      string                `json:"key"`
+	Operator LabelSelectorOperator `json:"operator"`
+	Values   []string              `json:"values,omitempty"`
+}
+
+// LabelSelector selects objects by their labels. An object must satisfy
+// both MatchLabels and every one of MatchExpressions.
+type LabelSelector struct {
+	// MatchLabels requires every key to be present with the given value.
+	MatchLabels      map[string]string          `json:"matchLabels,omitempty"`
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// IsEmpty reports whether the selector has no requirements, in which case
+// it matches everything.
+func (s LabelSelector) IsEmpty() bool {
+	return len(s.MatchLabels) == 0 && len(s.MatchExpressions) == 0
+}
+
+// Matches reports whether a set of labels satisfies the selector.
+func (s LabelSelector) Matches(set map[string]string) bool {
+	for k, v := range s.MatchLabels {
+		if got, ok := set[k]; !ok || got != v {
+			return false
+		}
+	}
+	for _, e := range s.MatchExpressions {
+		r := labels.Requirement{Key: e.Key, Operator: selectorOperators[e.Operator], Values: e.Values}
+		if !r.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+// AsSelector checks the selector and converts it to a labels.Selector,
+// MatchLabels first in key order.
+func (s LabelSelector) AsSelector() (labels.Selector, error) {
+	var sel labels.Selector
+	for _, k := range slices.Sorted(maps.Keys(s.MatchLabels)) {
+		r, err := labels.NewRequirement(k, labels.Equals, []string{s.MatchLabels[k]})
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+	}
+	for _, e := range s.MatchExpressions {
+		op, ok := selectorOperators[e.Operator]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown operator %q", e.Key, e.Operator)
+		}
+		r, err := labels.NewRequirement(e.Key, op, e.Values)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// ReplicaSet keeps a number of copies of a task running.
+type ReplicaSet struct {
+	ID string `json:"id"`
+	// ResourceVersion changes every time the replica set is saved. Supplying
+	// a non-zero version on save makes the write conditional on it.
+	ResourceVersion uint64 `json:"resourceVersion,omitempty"`
+	// Labels and Annotations describe the replica set; see Task.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Replicas is the number of tasks wanted.
+	Replicas int `json:"replicas"`
+	// Selector picks the tasks the replica set counts. It must match the
+	// labels of Template.
+	Selector LabelSelector `json:"selector"`
+	// Template is the task every replica is created from. Its ID, status and
+	// the fields maintained by the system are ignored.
+	Template Task `json:"template"`
+	// Owner is the deployment that created the replica set, if any, and
+	// Revision the revision of the deployment's template it runs.
+	Owner    *OwnerReference `json:"owner,omitempty"`
+	Revision int             `json:"revision,omitempty"`
+	// Status is maintained by the replica set controller and ignored on
+	// updates.
+	Status ReplicaSetStatus `json:"status"`
+}
+
+// ReplicaSetStatus is the last observed state of a replica set.
+type ReplicaSetStatus struct {
+	// Replicas is the number of tasks the replica set has that have not
+	// finished for good, and ReadyReplicas how many of them are ready.
+	Replicas      int `json:"replicas"`
+	ReadyReplicas int `json:"readyReplicas"`
+}
+
+func (rs *ReplicaSet) GetID() string               { return rs.ID }
+func (rs *ReplicaSet) GetResourceVersion() uint64  { return rs.ResourceVersion }
+func (rs *ReplicaSet) SetResourceVersion(v uint64) { rs.ResourceVersion = v }
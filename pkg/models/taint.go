@@ -0,0 +1,152 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TaintEffect is what a taint does to the tasks that do not tolerate it.
+type TaintEffect string
+
+const (
+	// TaintNoSchedule keeps new tasks off the node.
+	TaintNoSchedule TaintEffect = "NoSchedule"
+	// TaintPreferNoSchedule makes the scheduler avoid the node when it can.
+	TaintPreferNoSchedule TaintEffect = "PreferNoSchedule"
+	// TaintNoExecute keeps new tasks off the node and evicts the tasks
+	// already on it.
+	TaintNoExecute TaintEffect = "NoExecute"
+)
+
+// IsValid reports whether e is one of the defined effects.
+func (e TaintEffect) IsValid() bool {
+	return e == TaintNoSchedule || e == TaintPreferNoSchedule || e == TaintNoExecute
+}
+
+// Taint marks a node so that tasks without a matching toleration are kept
+// off it.
+type Taint struct {
+	Key    string      `json:"key"`
+	Value  string      `json:"value,omitempty"`
+	Effect TaintEffect `json:"effect"`
+	// TimeAdded is when the taint was put on the node. It is set by the
+	// node manager, and is what the tolerationSeconds of NoExecute
+	// tolerations count from.
+	TimeAdded time.Time `json:"timeAdded,omitzero"`
+}
+
+// String returns the taint as key=value:Effect, the syntax ParseTaint
+// accepts.
+func (t Taint) String() string {
+	s := t.Key
+	if t.Value != "" {
+		s += "=" + t.Value
+	}
+	return s + ":" + string(t.Effect)
+}
+
+// ParseTaint parses a taint written as key=value:Effect, or key:Effect for
+// a taint without a value.
+func ParseTaint(s string) (Taint, error) {
+	spec, effect, ok := strings.Cut(s, ":")
+	if !ok {
+		return Taint{}, fmt.Errorf("taint %q: expected key=value:Effect", s)
+	}
+	key, value, _ := strings.Cut(spec, "=")
+	t := Taint{Key: key, Value: value, Effect: TaintEffect(effect)}
+	if !t.Effect.IsValid() {
+		return Taint{}, fmt.Errorf("taint %q: effect must be one of %q, %q or %q", s, TaintNoSchedule, TaintPreferNoSchedule, TaintNoExecute)
+	}
+	return t, nil
+}
+
+// TolerationOperator is how a Toleration compares the value of a taint.
+type TolerationOperator string
+
+const (
+	// TolerationOpEqual tolerates taints with the toleration's key and
+	// value. It is the default.
+	TolerationOpEqual TolerationOperator = "Equal"
+	// TolerationOpExists tolerates taints with the toleration's key, or
+	// every taint when the key is empty, whatever their value.
+	TolerationOpExists TolerationOperator = "Exists"
+)
+
+// Toleration lets a task be scheduled onto, and keep running on, nodes with
+// matching taints.
+type Toleration struct {
+	Key      string             `json:"key,omitempty"`
+	Operator TolerationOperator `json:"operator,omitempty"`
+	Value    string             `json:"value,omitempty"`
+	// Effect restricts the toleration to taints with this effect. When
+	// empty, taints of every effect are tolerated.
+	Effect TaintEffect `json:"effect,omitempty"`
+	// TolerationSeconds, only allowed with the NoExecute effect, is how long
+	// the task may keep running on a node after the taint was added. A task
+	// whose matching tolerations all leave it unset is never evicted for the
+	// taint.
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
+
+// Tolerates reports whether the toleration matches a taint.
+func (tol Toleration) Tolerates(taint Taint) bool {
+	if tol.Effect != "" && tol.Effect != taint.Effect {
+		return false
+	}
+	if tol.Operator == TolerationOpExists {
+		return tol.Key == "" || tol.Key == taint.Key
+	}
+	return tol.Key == taint.Key && tol.Value == taint.Value
+}
+
+// Tolerates reports whether any of the task's tolerations matches a taint.
+func (t Task) Tolerates(taint Taint) bool {
+	for _, tol := range t.Tolerations {
+		if tol.Tolerates(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// UntoleratedTaint returns the first of a node's taints with one of the
+// given effects that the task does not tolerate, or nil if it tolerates
+// them all.
+func (t Task) UntoleratedTaint(n Node, effects ...TaintEffect) *Taint {
+	for i, taint := range n.Taints {
+		for _, e := range effects {
+			if taint.Effect == e && !t.Tolerates(taint) {
+				return &n.Taints[i]
+			}
+		}
+	}
+	return nil
+}
+
+// EvictionTime returns when a task on a node with the given NoExecute taint
+// is to be evicted: right away when the task does not tolerate it, and
+// otherwise once the shortest tolerationSeconds of the matching
+// tolerations has passed since the taint was added. It returns false when
+// no matching toleration sets tolerationSeconds, in which case the task is
+// never evicted.
+func (t Task) EvictionTime(taint Taint) (time.Time, bool) {
+	tolerated := false
+	var shortest *int64
+	for _, tol := range t.Tolerations {
+		if !tol.Tolerates(taint) {
+			continue
+		}
+		tolerated = true
+		if s := tol.TolerationSeconds; s != nil && (shortest == nil || *s < *shortest) {
+			shortest = s
+		}
+	}
+	switch {
+	case !tolerated:
+		return taint.TimeAdded, true
+	case shortest == nil:
+		return time.Time{}, false
+	}
+	return taint.TimeAdded.Add(time.Duration(*shortest) * time.Second), true
+}
@@ -0,0 +1,601 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fntkg/container-orchestrator/pkg/cron"
+	"github.com/fntkg/container-orchestrator/pkg/labels"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+// FieldError describes a single invalid field of an object.
+type FieldError struct {
+	Field  string
+	Detail string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Detail)
+}
+
+// ValidationError collects every problem found while validating an object.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// asError returns nil for an empty list so callers can return it directly.
+func (e ValidationError) asError() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// MaxIDLength is the longest the ID of a task or workload may be, and
+// MaxNodeIDLength that of a node.
+const (
+	MaxIDLength     = 63
+	MaxNodeIDLength = 253
+)
+
+// validateID checks the ID of an object, which nodes also use to name files
+// and cgroups: lowercase letters, digits and '-', beginning and ending with
+// a letter or digit, like a DNS label. Node IDs are host names, and may be
+// several such labels joined by dots.
+func validateID(id string, node bool) []FieldError {
+	if id == "" {
+		return []FieldError{{"id", "must not be empty"}}
+	}
+	maxLen, parts, detail := MaxIDLength, []string{id}, "must consist of lowercase letters, digits and '-', and begin and end with a letter or digit"
+	if node {
+		maxLen, parts, detail = MaxNodeIDLength, strings.Split(id, "."), "must be a host name: labels of lowercase letters, digits and '-', each beginning and ending with a letter or digit, joined by dots"
+	}
+	if len(id) > maxLen {
+		return []FieldError{{"id", fmt.Sprintf("must be at most %d characters", maxLen)}}
+	}
+	for _, part := range parts {
+		if !isDNSLabel(part) {
+			return []FieldError{{"id", detail}}
+		}
+	}
+	return nil
+}
+
+// isDNSLabel reports whether s is a non-empty run of lowercase letters,
+// digits and '-' that begins and ends with a letter or digit.
+func isDNSLabel(s string) bool {
+	if s == "" || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateNode checks that a node is well formed before it is registered.
+func ValidateNode(n Node) error {
+	var errs ValidationError
+	errs = append(errs, validateID(n.ID, true)...)
+	if n.Address != "" {
+		if u, err := url.Parse(n.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, FieldError{"address", "must be an absolute http or https URL"})
+		}
+	}
+	errs = append(errs, validateMetadata(n.Labels, n.Annotations)...)
+	errs = append(errs, validateTaints(n.Taints)...)
+	errs = append(errs, validateResourceList("capacity", n.Capacity)...)
+	errs = append(errs, validateResourceList("allocatable", n.Allocatable)...)
+	if len(n.Capacity) > 0 {
+		for _, name := range n.Allocatable.Exceeding(n.Capacity) {
+			errs = append(errs, FieldError{"allocatable." + string(name), "must not exceed capacity"})
+		}
+	}
+	return errs.asError()
+}
+
+// ValidateTask checks that a task is well formed before it is created.
+func ValidateTask(t Task) error {
+	var errs ValidationError
+	errs = append(errs, validateID(t.ID, false)...)
+	errs = append(errs, validateMetadata(t.Labels, t.Annotations)...)
+	errs = append(errs, validateResourceList("resources.requests", t.Resources.Requests)...)
+	errs = append(errs, validateResourceList("resources.limits", t.Resources.Limits)...)
+	for _, name := range t.Resources.Requests.Names() {
+		limit, ok := t.Resources.Limits[name]
+		if ok && t.Resources.Requests[name].Cmp(limit) > 0 {
+			errs = append(errs, FieldError{"resources.requests." + string(name), "must not exceed the limit " + limit.String()})
+		}
+	}
+	if t.Command == "" && len(t.Args) > 0 {
+		errs = append(errs, FieldError{"args", "require a command"})
+	}
+	for i, env := range t.Env {
+		if env.Name == "" || strings.ContainsAny(env.Name, "=\x00") {
+			errs = append(errs, FieldError{fmt.Sprintf("env[%d].name", i), "must be non-empty and must not contain '=' or NUL"})
+		}
+	}
+	if !t.RestartPolicy.IsValid() {
+		errs = append(errs, FieldError{"restartPolicy", fmt.Sprintf("must be one of %q, %q or %q", RestartNever, RestartOnFailure, RestartAlways)})
+	}
+	if t.MaxRetries != nil {
+		if *t.MaxRetries < 0 {
+			errs = append(errs, FieldError{"maxRetries", "must not be negative"})
+		}
+		if t.RestartPolicy != RestartOnFailure && t.RestartPolicy != RestartAlways {
+			errs = append(errs, FieldError{"maxRetries", "requires a restartPolicy of OnFailure or Always"})
+		}
+	}
+	if t.RestartCount < 0 {
+		errs = append(errs, FieldError{"restartCount", "must not be negative"})
+	}
+	if t.LivenessProbe != nil {
+		errs = append(errs, validateProbe("livenessProbe", *t.LivenessProbe)...)
+		if t.LivenessProbe.SuccessThreshold > 1 {
+			errs = append(errs, FieldError{"livenessProbe.successThreshold", "must be 1"})
+		}
+	}
+	if t.ReadinessProbe != nil {
+		errs = append(errs, validateProbe("readinessProbe", *t.ReadinessProbe)...)
+	}
+	errs = append(errs, validateLabelSelector("nodeSelector", LabelSelector{MatchLabels: t.NodeSelector})...)
+	if t.Affinity != nil {
+		if t.Affinity.NodeAffinity != nil {
+			errs = append(errs, validateNodeAffinity("affinity.nodeAffinity", *t.Affinity.NodeAffinity)...)
+		}
+		if t.Affinity.TaskAffinity != nil {
+			errs = append(errs, validateTaskAffinity("affinity.taskAffinity", *t.Affinity.TaskAffinity)...)
+		}
+		if t.Affinity.TaskAntiAffinity != nil {
+			errs = append(errs, validateTaskAffinity("affinity.taskAntiAffinity", *t.Affinity.TaskAntiAffinity)...)
+		}
+	}
+	for i, c := range t.TopologySpreadConstraints {
+		errs = append(errs, validateSpreadConstraint(fmt.Sprintf("topologySpreadConstraints[%d]", i), c)...)
+	}
+	for i, tol := range t.Tolerations {
+		errs = append(errs, validateToleration(fmt.Sprintf("tolerations[%d]", i), tol)...)
+	}
+	return errs.asError()
+}
+
+// ValidateReplicaSet checks that a replica set is well formed before it is
+// created or updated.
+func ValidateReplicaSet(rs ReplicaSet) error {
+	var errs ValidationError
+	errs = append(errs, validateID(rs.ID, false)...)
+	errs = append(errs, validateMetadata(rs.Labels, rs.Annotations)...)
+	if rs.Replicas < 0 {
+		errs = append(errs, FieldError{"replicas", "must not be negative"})
+	}
+	errs = append(errs, validateTemplate("template", rs.Selector, rs.Template)...)
+	errs = append(errs, validateReplicaTemplate("template", rs.Template)...)
+	return errs.asError()
+}
+
+// ValidateDeployment checks that a deployment is well formed before it is
+// created or updated.
+func ValidateDeployment(d Deployment) error {
+	var errs ValidationError
+	errs = append(errs, validateID(d.ID, false)...)
+	errs = append(errs, validateMetadata(d.Labels, d.Annotations)...)
+	if d.Replicas < 0 {
+		errs = append(errs, FieldError{"replicas", "must not be negative"})
+	}
+	errs = append(errs, validateTemplate("template", d.Selector, d.Template)...)
+	errs = append(errs, validateReplicaTemplate("template", d.Template)...)
+	if _, ok := d.Template.Labels[TemplateHashLabel]; ok {
+		errs = append(errs, FieldError{"template.labels", fmt.Sprintf("%q is reserved", TemplateHashLabel)})
+	}
+	switch d.Strategy.Type {
+	case "", RollingUpdate:
+		if ru := d.Strategy.RollingUpdate; ru != nil {
+			for _, f := range []struct {
+				name  string
+				value *IntOrPercent
+			}{
+				{"maxSurge", ru.MaxSurge},
+				{"maxUnavailable", ru.MaxUnavailable},
+			} {
+				if f.value != nil && f.value.Value < 0 {
+					errs = append(errs, FieldError{"strategy.rollingUpdate." + f.name, "must not be negative"})
+				}
+			}
+			if ru.MaxUnavailable != nil && ru.MaxUnavailable.Percent && ru.MaxUnavailable.Value > 100 {
+				errs = append(errs, FieldError{"strategy.rollingUpdate.maxUnavailable", "must not exceed 100%"})
+			}
+			if ru.MaxSurge != nil && ru.MaxSurge.Value == 0 && ru.MaxUnavailable != nil && ru.MaxUnavailable.Value == 0 {
+				errs = append(errs, FieldError{"strategy.rollingUpdate", "maxSurge and maxUnavailable must not both be zero"})
+			}
+		}
+	case Recreate:
+		if d.Strategy.RollingUpdate != nil {
+			errs = append(errs, FieldError{"strategy.rollingUpdate", fmt.Sprintf("must not be set with the %s strategy", Recreate)})
+		}
+	default:
+		errs = append(errs, FieldError{"strategy.type", fmt.Sprintf("must be %q or %q", RollingUpdate, Recreate)})
+	}
+	if d.RevisionHistoryLimit != nil && *d.RevisionHistoryLimit < 0 {
+		errs = append(errs, FieldError{"revisionHistoryLimit", "must not be negative"})
+	}
+	return errs.asError()
+}
+
+// ValidateDaemonSet checks that a daemon set is well formed before it is
+// created or updated.
+func ValidateDaemonSet(ds DaemonSet) error {
+	var errs ValidationError
+	errs = append(errs, validateID(ds.ID, false)...)
+	errs = append(errs, validateMetadata(ds.Labels, ds.Annotations)...)
+	errs = append(errs, validateTaskTemplate("template", ds.Template)...)
+	errs = append(errs, validateReplicaTemplate("template", ds.Template)...)
+	if _, ok := ds.Template.Labels[TemplateHashLabel]; ok {
+		errs = append(errs, FieldError{"template.labels", fmt.Sprintf("%q is reserved", TemplateHashLabel)})
+	}
+	if ds.Template.NodeID != "" {
+		errs = append(errs, FieldError{"template.nodeId", "must not be set"})
+	}
+	errs = append(errs, validateLabelSelector("nodeSelector", ds.NodeSelector)...)
+	switch ds.UpdateStrategy.Type {
+	case "", DaemonSetRollingUpdate:
+		if ru := ds.UpdateStrategy.RollingUpdate; ru != nil && ru.MaxUnavailable != nil {
+			if v := *ru.MaxUnavailable; v.Value < 1 || v.Percent && v.Value > 100 {
+				errs = append(errs, FieldError{"updateStrategy.rollingUpdate.maxUnavailable", "must be at least 1 and at most 100%"})
+			}
+		}
+	case DaemonSetOnDelete:
+		if ds.UpdateStrategy.RollingUpdate != nil {
+			errs = append(errs, FieldError{"updateStrategy.rollingUpdate", fmt.Sprintf("must not be set with the %s strategy", DaemonSetOnDelete)})
+		}
+	default:
+		errs = append(errs, FieldError{"updateStrategy.type", fmt.Sprintf("must be %q or %q", DaemonSetRollingUpdate, DaemonSetOnDelete)})
+	}
+	return errs.asError()
+}
+
+// ValidateJob checks that a job is well formed before it is created.
+func ValidateJob(j Job) error {
+	var errs ValidationError
+	errs = append(errs, validateID(j.ID, false)...)
+	errs = append(errs, validateMetadata(j.Labels, j.Annotations)...)
+	if j.Completions != nil && *j.Completions < 1 {
+		errs = append(errs, FieldError{"completions", "must be at least 1"})
+	}
+	if j.Parallelism != nil && *j.Parallelism < 1 {
+		errs = append(errs, FieldError{"parallelism", "must be at least 1"})
+	}
+	if j.BackoffLimit != nil && *j.BackoffLimit < 0 {
+		errs = append(errs, FieldError{"backoffLimit", "must not be negative"})
+	}
+	if j.ActiveDeadlineSeconds != nil && *j.ActiveDeadlineSeconds < 1 {
+		errs = append(errs, FieldError{"activeDeadlineSeconds", "must be at least 1"})
+	}
+	errs = append(errs, validateTaskTemplate("template", j.Template)...)
+	if j.Template.RestartPolicy == RestartAlways {
+		// A task that is always restarted never completes.
+		errs = append(errs, FieldError{"template.restartPolicy", fmt.Sprintf("must be %q or %q", RestartNever, RestartOnFailure)})
+	}
+	return errs.asError()
+}
+
+// ValidateCronJob checks that a cron job is well formed before it is created
+// or updated.
+func ValidateCronJob(cj CronJob) error {
+	var errs ValidationError
+	errs = append(errs, validateID(cj.ID, false)...)
+	errs = append(errs, validateMetadata(cj.Labels, cj.Annotations)...)
+	if _, err := cron.Parse(cj.Schedule); err != nil {
+		errs = append(errs, FieldError{"schedule", err.Error()})
+	}
+	if _, err := cj.Location(); err != nil {
+		errs = append(errs, FieldError{"timeZone", "unknown time zone " + strconv.Quote(cj.TimeZone)})
+	}
+	switch cj.ConcurrencyPolicy {
+	case "", AllowConcurrent, ForbidConcurrent, ReplaceConcurrent:
+	default:
+		errs = append(errs, FieldError{"concurrencyPolicy", fmt.Sprintf("must be one of %q, %q or %q", AllowConcurrent, ForbidConcurrent, ReplaceConcurrent)})
+	}
+	if cj.StartingDeadlineSeconds != nil && *cj.StartingDeadlineSeconds < 0 {
+		errs = append(errs, FieldError{"startingDeadlineSeconds", "must not be negative"})
+	}
+	if cj.SuccessfulJobsHistoryLimit != nil && *cj.SuccessfulJobsHistoryLimit < 0 {
+		errs = append(errs, FieldError{"successfulJobsHistoryLimit", "must not be negative"})
+	}
+	if cj.FailedJobsHistoryLimit != nil && *cj.FailedJobsHistoryLimit < 0 {
+		errs = append(errs, FieldError{"failedJobsHistoryLimit", "must not be negative"})
+	}
+	// The ID is filled in for every job made from the template.
+	template := cj.JobTemplate
+	template.ID = "template"
+	var jobErrs ValidationError
+	if errors.As(ValidateJob(template), &jobErrs) {
+		for _, fe := range jobErrs {
+			errs = append(errs, FieldError{"jobTemplate." + fe.Field, fe.Detail})
+		}
+	}
+	return errs.asError()
+}
+
+// validateReplicaTemplate checks the template of a workload that keeps its
+// tasks running.
+func validateReplicaTemplate(field string, template Task) ValidationError {
+	var errs ValidationError
+	if p := template.RestartPolicy; p != "" && p != RestartAlways {
+		errs = append(errs, FieldError{field + ".restartPolicy", fmt.Sprintf("must be %q", RestartAlways)})
+	}
+	if template.MaxRetries != nil {
+		// A replica that gives up would only be replaced straight away.
+		errs = append(errs, FieldError{field + ".maxRetries", "must not be set"})
+	}
+	return errs
+}
+
+// validateTemplate checks the task template of a workload and the selector
+// that has to match the tasks made from it.
+func validateTemplate(field string, selector LabelSelector, template Task) ValidationError {
+	errs := validateLabelSelector("selector", selector)
+	if selector.IsEmpty() {
+		errs = append(errs, FieldError{"selector", "must not be empty"})
+	} else if len(errs) == 0 && !selector.Matches(template.Labels) {
+		errs = append(errs, FieldError{"selector", "must match the labels of the " + field})
+	}
+	return append(errs, validateTaskTemplate(field, template)...)
+}
+
+// validateLabelSelector checks the keys, values and operators of a label
+// selector.
+func validateLabelSelector(field string, selector LabelSelector) ValidationError {
+	var errs ValidationError
+	for _, k := range slices.Sorted(maps.Keys(selector.MatchLabels)) {
+		if err := labels.ValidateKey(k); err != nil {
+			errs = append(errs, FieldError{field + ".matchLabels", err.Error()})
+		} else if err := labels.ValidateValue(selector.MatchLabels[k]); err != nil {
+			errs = append(errs, FieldError{field + ".matchLabels." + k, err.Error()})
+		}
+	}
+	for i, e := range selector.MatchExpressions {
+		if _, err := (LabelSelector{MatchExpressions: []LabelSelectorRequirement{e}}).AsSelector(); err != nil {
+			errs = append(errs, FieldError{fmt.Sprintf("%s.matchExpressions[%d]", field, i), err.Error()})
+		}
+	}
+	return errs
+}
+
+// validateTaints checks the taints of a node, which must each have a valid
+// key, value and effect, and be the only one with their key and effect.
+func validateTaints(taints []Taint) ValidationError {
+	var errs ValidationError
+	seen := make(map[string]bool, len(taints))
+	for i, t := range taints {
+		field := fmt.Sprintf("taints[%d]", i)
+		if err := labels.ValidateKey(t.Key); err != nil {
+			errs = append(errs, FieldError{field + ".key", err.Error()})
+		}
+		if err := labels.ValidateValue(t.Value); err != nil {
+			errs = append(errs, FieldError{field + ".value", err.Error()})
+		}
+		if !t.Effect.IsValid() {
+			errs = append(errs, FieldError{field + ".effect", fmt.Sprintf("must be one of %q, %q or %q", TaintNoSchedule, TaintPreferNoSchedule, TaintNoExecute)})
+		}
+		if id := t.Key + ":" + string(t.Effect); seen[id] {
+			errs = append(errs, FieldError{field, "duplicates the key and effect of another taint"})
+		} else {
+			seen[id] = true
+		}
+	}
+	return errs
+}
+
+func validateToleration(field string, tol Toleration) ValidationError {
+	var errs ValidationError
+	switch tol.Operator {
+	case "", TolerationOpEqual:
+		if tol.Key == "" {
+			errs = append(errs, FieldError{field + ".key", fmt.Sprintf("must be set unless the operator is %q", TolerationOpExists)})
+		}
+		if err := labels.ValidateValue(tol.Value); err != nil {
+			errs = append(errs, FieldError{field + ".value", err.Error()})
+		}
+	case TolerationOpExists:
+		if tol.Value != "" {
+			errs = append(errs, FieldError{field + ".value", fmt.Sprintf("must be empty with the %q operator", TolerationOpExists)})
+		}
+	default:
+		errs = append(errs, FieldError{field + ".operator", fmt.Sprintf("must be %q or %q", TolerationOpEqual, TolerationOpExists)})
+	}
+	if tol.Key != "" {
+		if err := labels.ValidateKey(tol.Key); err != nil {
+			errs = append(errs, FieldError{field + ".key", err.Error()})
+		}
+	}
+	if tol.Effect != "" && !tol.Effect.IsValid() {
+		errs = append(errs, FieldError{field + ".effect", fmt.Sprintf("must be empty or one of %q, %q or %q", TaintNoSchedule, TaintPreferNoSchedule, TaintNoExecute)})
+	}
+	if tol.TolerationSeconds != nil {
+		if tol.Effect != TaintNoExecute {
+			errs = append(errs, FieldError{field + ".tolerationSeconds", fmt.Sprintf("requires the %q effect", TaintNoExecute)})
+		}
+		if *tol.TolerationSeconds < 0 {
+			errs = append(errs, FieldError{field + ".tolerationSeconds", "must not be negative"})
+		}
+	}
+	return errs
+}
+
+// validateNodeAffinity checks the node selector terms of a node affinity.
+func validateNodeAffinity(field string, a NodeAffinity) ValidationError {
+	var errs ValidationError
+	for i, term := range a.Required {
+		f := fmt.Sprintf("%s.required[%d]", field, i)
+		if term.IsEmpty() {
+			errs = append(errs, FieldError{f, "must not be empty"})
+		}
+		errs = append(errs, validateLabelSelector(f, term)...)
+	}
+	for i, term := range a.Preferred {
+		f := fmt.Sprintf("%s.preferred[%d]", field, i)
+		if term.Weight < 1 || term.Weight > 100 {
+			errs = append(errs, FieldError{f + ".weight", "must be between 1 and 100"})
+		}
+		if term.Preference.IsEmpty() {
+			errs = append(errs, FieldError{f + ".preference", "must not be empty"})
+		}
+		errs = append(errs, validateLabelSelector(f+".preference", term.Preference)...)
+	}
+	return errs
+}
+
+// validateTaskAffinity checks the terms of a task affinity or anti-affinity.
+func validateTaskAffinity(field string, a TaskAffinity) ValidationError {
+	var errs ValidationError
+	for i, term := range a.Required {
+		errs = append(errs, validateTaskAffinityTerm(fmt.Sprintf("%s.required[%d]", field, i), term)...)
+	}
+	for i, term := range a.Preferred {
+		f := fmt.Sprintf("%s.preferred[%d]", field, i)
+		if term.Weight < 1 || term.Weight > 100 {
+			errs = append(errs, FieldError{f + ".weight", "must be between 1 and 100"})
+		}
+		errs = append(errs, validateTaskAffinityTerm(f+".term", term.Term)...)
+	}
+	return errs
+}
+
+func validateTaskAffinityTerm(field string, term TaskAffinityTerm) ValidationError {
+	var errs ValidationError
+	if term.LabelSelector.IsEmpty() {
+		errs = append(errs, FieldError{field + ".labelSelector", "must not be empty"})
+	}
+	errs = append(errs, validateLabelSelector(field+".labelSelector", term.LabelSelector)...)
+	return append(errs, validateTopologyKey(field+".topologyKey", term.TopologyKey)...)
+}
+
+func validateSpreadConstraint(field string, c TopologySpreadConstraint) ValidationError {
+	var errs ValidationError
+	if c.MaxSkew < 1 {
+		errs = append(errs, FieldError{field + ".maxSkew", "must be at least 1"})
+	}
+	errs = append(errs, validateTopologyKey(field+".topologyKey", c.TopologyKey)...)
+	switch c.WhenUnsatisfiable {
+	case "", DoNotSchedule, ScheduleAnyway:
+	default:
+		errs = append(errs, FieldError{field + ".whenUnsatisfiable", fmt.Sprintf("must be %q or %q", DoNotSchedule, ScheduleAnyway)})
+	}
+	if c.LabelSelector.IsEmpty() {
+		errs = append(errs, FieldError{field + ".labelSelector", "must not be empty"})
+	}
+	return append(errs, validateLabelSelector(field+".labelSelector", c.LabelSelector)...)
+}
+
+func validateTopologyKey(field, key string) ValidationError {
+	if key == "" {
+		return ValidationError{{field, "must not be empty"}}
+	}
+	if err := labels.ValidateKey(key); err != nil {
+		return ValidationError{{field, err.Error()}}
+	}
+	return nil
+}
+
+// validateMetadata checks the labels and annotations of an object.
+func validateMetadata(objLabels, annotations map[string]string) ValidationError {
+	var errs ValidationError
+	labelErrs := labels.Validate(objLabels)
+	for _, k := range slices.Sorted(maps.Keys(labelErrs)) {
+		errs = append(errs, FieldError{"labels", labelErrs[k].Error()})
+	}
+	annotationErrs := labels.ValidateAnnotations(annotations)
+	for _, k := range slices.Sorted(maps.Keys(annotationErrs)) {
+		errs = append(errs, FieldError{"annotations", annotationErrs[k].Error()})
+	}
+	return errs
+}
+
+// validateTaskTemplate checks the task template of a workload.
+func validateTaskTemplate(field string, template Task) ValidationError {
+	var errs ValidationError
+	// The ID is filled in for every task made from the template.
+	template.ID = "template"
+	var taskErrs ValidationError
+	if errors.As(ValidateTask(template), &taskErrs) {
+		for _, fe := range taskErrs {
+			errs = append(errs, FieldError{field + "." + fe.Field, fe.Detail})
+		}
+	}
+	return errs
+}
+
+func validateProbe(field string, p Probe) ValidationError {
+	var errs ValidationError
+	handlers := 0
+	if p.Exec != nil {
+		handlers++
+		if len(p.Exec.Command) == 0 || p.Exec.Command[0] == "" {
+			errs = append(errs, FieldError{field + ".exec.command", "must not be empty"})
+		}
+	}
+	if p.HTTPGet != nil {
+		handlers++
+		errs = append(errs, validatePort(field+".httpGet.port", p.HTTPGet.Port)...)
+		if p.HTTPGet.Path != "" && !strings.HasPrefix(p.HTTPGet.Path, "/") {
+			errs = append(errs, FieldError{field + ".httpGet.path", "must start with /"})
+		}
+	}
+	if p.TCPSocket != nil {
+		handlers++
+		errs = append(errs, validatePort(field+".tcpSocket.port", p.TCPSocket.Port)...)
+	}
+	if handlers != 1 {
+		errs = append(errs, FieldError{field, "must set exactly one of exec, httpGet and tcpSocket"})
+	}
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"initialDelaySeconds", p.InitialDelaySeconds},
+		{"periodSeconds", p.PeriodSeconds},
+		{"timeoutSeconds", p.TimeoutSeconds},
+		{"successThreshold", p.SuccessThreshold},
+		{"failureThreshold", p.FailureThreshold},
+	} {
+		if f.value < 0 {
+			errs = append(errs, FieldError{field + "." + f.name, "must not be negative"})
+		}
+	}
+	return errs
+}
+
+func validatePort(field string, port int) ValidationError {
+	if port < 1 || port > 65535 {
+		return ValidationError{{field, "must be between 1 and 65535"}}
+	}
+	return nil
+}
+
+func validateResourceList(field string, list resource.List) ValidationError {
+	var errs ValidationError
+	for _, name := range list.Names() {
+		if err := resource.ValidateName(name); err != nil {
+			errs = append(errs, FieldError{field, err.Error()})
+			continue
+		}
+		if list[name].Sign() < 0 {
+			errs = append(errs, FieldError{field + "." + string(name), "must not be negative"})
+		}
+	}
+	return errs
+}
@@ -2,32 +2,69 @@ package node
 
 import (
 	"errors"
+	"time"
+
 	"github.com/fntkg/container-orchestrator/pkg/models"
 
 	"github.com/fntkg/container-orchestrator/pkg/datastore"
 )
 
+// ErrNodeNotFound is returned when a node ID does not match any registered node.
+var ErrNodeNotFound = errors.New("node not found")
+
+// maxUpdateAttempts bounds how often a read-modify-write is retried after
+// losing a race with another writer.
+const maxUpdateAttempts = 5
+
 // NodeManager is an interface that defines the behavior of a node manager.
 type NodeManager interface {
 	Register(n models.Node) error
 	GetNodes() []models.Node
+	GetNode(nodeID string) (*models.Node, error)
+	UpdateNode(n models.Node) error
 	UpdateHealth(nodeID string, healthy bool) error
+	UpdateTaints(nodeID string, taints []models.Taint) (*models.Node, error)
+	UpdateLabels(nodeID string, labels, annotations map[string]string) (*models.Node, error)
+	Heartbeat(nodeID string) (*models.Node, error)
+	Watch(fromVersion uint64) (datastore.Watcher, error)
 }
 
 // DefaultNodeManager manages the nodes in the cluster.
 type DefaultNodeManager struct {
-	ds datastore.Datastore
+	ds  datastore.Datastore
+	now func() time.Time
 }
 
 // NewManager // NewManager creates a new instance of DefaultNodeManager with the given datastore.
 func NewManager(ds datastore.Datastore) *DefaultNodeManager {
 	return &DefaultNodeManager{
-		ds: ds,
+		ds:  ds,
+		now: time.Now,
 	}
 }
 
-// Register adds a new node to the manager.
+// Register adds a new node to the manager. A node registered without a
+// condition gets one matching its Healthy flag. Taints the node already had
+// keep the time they were added, and a node registered again without labels
+// or annotations, as a restarted agent is, keeps those it had.
 func (m *DefaultNodeManager) Register(n models.Node) error {
+	n.ResourceVersion = 0
+	var previous []models.Taint
+	if stored, err := m.GetNode(n.ID); err == nil {
+		previous = stored.Taints
+		if len(n.Labels) == 0 {
+			n.Labels = stored.Labels
+		}
+		if len(n.Annotations) == 0 {
+			n.Annotations = stored.Annotations
+		}
+	}
+	n.Taints = m.stampTaints(n.Taints, previous)
+	if n.Condition == "" {
+		n.Condition = conditionFor(n.Healthy)
+	}
+	n.Healthy = n.Condition == models.NodeReady
+	n.LastTransitionTime = m.now()
 	return m.ds.SaveNode(n)
 }
 
@@ -41,29 +78,131 @@ func (m *DefaultNodeManager) GetNodes() []models.Node {
 	return nodes
 }
 
-// UpdateHealth updates the health status of a node.
-func (m *DefaultNodeManager) UpdateHealth(nodeID string, healthy bool) error {
-	// Retrieve all nodes from the datastore.
+// GetNode returns the node with the given ID.
+func (m *DefaultNodeManager) GetNode(nodeID string) (*models.Node, error) {
 	nodes, err := m.ds.GetNodes()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Find the node with the given nodeID.
-	var found bool
-	var updatedNode models.Node
 	for _, n := range nodes {
 		if n.ID == nodeID {
-			updatedNode = n
-			updatedNode.Healthy = healthy
-			found = true
-			break
+			return &n, nil
 		}
 	}
-	if !found {
-		return errors.New("node not found")
+	return nil, ErrNodeNotFound
+}
+
+// UpdateNode replaces an existing node. If n.ResourceVersion is set the write
+// only succeeds when it matches the stored version; otherwise a
+// datastore.ConflictError is returned.
+func (m *DefaultNodeManager) UpdateNode(n models.Node) error {
+	stored, err := m.GetNode(n.ID)
+	if err != nil {
+		return err
 	}
+	n.Taints = m.stampTaints(n.Taints, stored.Taints)
+	return m.ds.SaveNode(n)
+}
 
-	// Save the updated node back to the datastore.
-	return m.ds.SaveNode(updatedNode)
+// UpdateHealth updates the health status of a node. The read-modify-write is
+// retried if another writer updates the node in between.
+func (m *DefaultNodeManager) UpdateHealth(nodeID string, healthy bool) error {
+	_, err := m.modify(nodeID, func(n *models.Node) {
+		m.setCondition(n, conditionFor(healthy))
+	})
+	return err
+}
+
+// UpdateTaints replaces the taints of a node and returns the updated node.
+func (m *DefaultNodeManager) UpdateTaints(nodeID string, taints []models.Taint) (*models.Node, error) {
+	return m.modify(nodeID, func(n *models.Node) {
+		n.Taints = m.stampTaints(taints, n.Taints)
+	})
+}
+
+// UpdateLabels replaces the labels and annotations of a node and returns the
+// updated node. A nil map leaves the current ones alone, and an empty one
+// removes them.
+func (m *DefaultNodeManager) UpdateLabels(nodeID string, labels, annotations map[string]string) (*models.Node, error) {
+	return m.modify(nodeID, func(n *models.Node) {
+		if labels != nil {
+			n.Labels = labels
+		}
+		if annotations != nil {
+			n.Annotations = annotations
+		}
+	})
+}
+
+// Heartbeat renews the lease of a node, marking it Ready.
+func (m *DefaultNodeManager) Heartbeat(nodeID string) (*models.Node, error) {
+	return m.modify(nodeID, func(n *models.Node) {
+		n.LastHeartbeatTime = m.now()
+		m.setCondition(n, models.NodeReady)
+	})
+}
+
+// Watch streams changes to nodes after fromVersion; see datastore.Datastore.
+func (m *DefaultNodeManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return m.ds.Watch(datastore.KindNode, fromVersion)
+}
+
+// modify applies change to the stored node and saves it conditionally on the
+// version that was read, retrying when another writer gets in between.
+func (m *DefaultNodeManager) modify(nodeID string, change func(n *models.Node)) (*models.Node, error) {
+	for attempt := 0; ; attempt++ {
+		updatedNode, err := m.GetNode(nodeID)
+		if err != nil {
+			return nil, err
+		}
+		change(updatedNode)
+
+		// Save the updated node back to the datastore.
+		err = m.ds.SaveNode(*updatedNode)
+		if err == nil {
+			return m.GetNode(nodeID)
+		}
+		if !errors.Is(err, datastore.ErrConflict) || attempt+1 >= maxUpdateAttempts {
+			return nil, err
+		}
+	}
+}
+
+// setCondition updates the condition of n, stamping the transition time when
+// it actually changes.
+func (m *DefaultNodeManager) setCondition(n *models.Node, condition models.NodeCondition) {
+	if n.Condition != condition {
+		n.LastTransitionTime = m.now()
+	}
+	n.Condition = condition
+	n.Healthy = condition == models.NodeReady
+}
+
+// stampTaints returns a copy of taints where each taint that was already in
+// previous, with the same key, value and effect, keeps its TimeAdded, and
+// each new one is added now.
+func (m *DefaultNodeManager) stampTaints(taints, previous []models.Taint) []models.Taint {
+	if len(taints) == 0 {
+		return nil
+	}
+	now := m.now()
+	out := make([]models.Taint, len(taints))
+	for i, t := range taints {
+		t.TimeAdded = now
+		for _, p := range previous {
+			if p.Key == t.Key && p.Value == t.Value && p.Effect == t.Effect {
+				t.TimeAdded = p.TimeAdded
+				break
+			}
+		}
+		out[i] = t
+	}
+	return out
+}
+
+func conditionFor(healthy bool) models.NodeCondition {
+	if healthy {
+		return models.NodeReady
+	}
+	return models.NodeNotReady
 }
@@ -2,9 +2,12 @@
 package node
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
 	"github.com/fntkg/container-orchestrator/pkg/models"
 )
 
@@ -49,6 +52,13 @@ func (fds *FakeDatastore) SaveTask(t models.Task) error {
 	return nil
 }
 
+func (fds *FakeDatastore) CreateTask(t models.Task) error {
+	if _, ok := fds.tasks[t.ID]; ok {
+		return datastore.ErrExists
+	}
+	return fds.SaveTask(t)
+}
+
 func (fds *FakeDatastore) GetTasks() ([]models.Task, error) {
 	tasks := make([]models.Task, 0, len(fds.tasks))
 	for _, t := range fds.tasks {
@@ -57,6 +67,98 @@ func (fds *FakeDatastore) GetTasks() ([]models.Task, error) {
 	return tasks, nil
 }
 
+func (fds *FakeDatastore) ListTasks() ([]models.Task, uint64, error) {
+	tasks, err := fds.GetTasks()
+	return tasks, 0, err
+}
+
+// DeleteNode removes a stored node.
+func (fds *FakeDatastore) DeleteNode(id string) error {
+	delete(fds.nodes, id)
+	return nil
+}
+
+// DeleteTask removes a stored task.
+func (fds *FakeDatastore) DeleteTask(id string) error {
+	delete(fds.tasks, id)
+	return nil
+}
+
+// SaveReplicaSet, GetReplicaSets and DeleteReplicaSet are stubs to satisfy
+// the datastore.Datastore interface.
+func (fds *FakeDatastore) SaveReplicaSet(rs models.ReplicaSet) error {
+	return nil
+}
+
+func (fds *FakeDatastore) GetReplicaSets() ([]models.ReplicaSet, error) {
+	return nil, nil
+}
+
+func (fds *FakeDatastore) DeleteReplicaSet(id string) error {
+	return nil
+}
+
+// SaveDeployment, GetDeployments and DeleteDeployment are stubs to satisfy
+// the datastore.Datastore interface.
+func (fds *FakeDatastore) SaveDeployment(d models.Deployment) error {
+	return nil
+}
+
+func (fds *FakeDatastore) GetDeployments() ([]models.Deployment, error) {
+	return nil, nil
+}
+
+func (fds *FakeDatastore) DeleteDeployment(id string) error {
+	return nil
+}
+
+// SaveJob, GetJobs and DeleteJob are stubs to satisfy the
+// datastore.Datastore interface.
+func (fds *FakeDatastore) SaveJob(j models.Job) error {
+	return nil
+}
+
+func (fds *FakeDatastore) GetJobs() ([]models.Job, error) {
+	return nil, nil
+}
+
+func (fds *FakeDatastore) DeleteJob(id string) error {
+	return nil
+}
+
+// SaveCronJob, GetCronJobs and DeleteCronJob are stubs to satisfy the
+// datastore.Datastore interface.
+func (fds *FakeDatastore) SaveCronJob(cj models.CronJob) error {
+	return nil
+}
+
+func (fds *FakeDatastore) GetCronJobs() ([]models.CronJob, error) {
+	return nil, nil
+}
+
+func (fds *FakeDatastore) DeleteCronJob(id string) error {
+	return nil
+}
+
+// SaveDaemonSet, GetDaemonSets and DeleteDaemonSet are stubs to satisfy the
+// datastore.Datastore interface.
+func (fds *FakeDatastore) SaveDaemonSet(ds models.DaemonSet) error {
+	return nil
+}
+
+func (fds *FakeDatastore) GetDaemonSets() ([]models.DaemonSet, error) {
+	return nil, nil
+}
+
+func (fds *FakeDatastore) DeleteDaemonSet(id string) error {
+	return nil
+}
+
+// Watch is not supported by the fake.
+func (fds *FakeDatastore) Watch(kind string, fromVersion uint64) (datastore.Watcher, error) {
+	return nil, fmt.Errorf("watch not supported")
+}
+
 func TestNodeManager_RegisterAndGetNodes(t *testing.T) {
 	ds := NewFakeDatastore()
 	manager := NewManager(ds)
@@ -125,3 +227,110 @@ func TestNodeManager_RegisterDuplicate(t *testing.T) {
 		t.Errorf("expected error when registering duplicate node, got nil")
 	}
 }
+
+func TestNodeManager_UpdateNodeConflict(t *testing.T) {
+	manager := NewManager(datastore.NewInMemoryDatastore())
+	if err := manager.Register(models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+	read, err := manager.GetNode("node-1")
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+
+	// Another writer changes the node after it was read.
+	if err := manager.UpdateHealth("node-1", false); err != nil {
+		t.Fatalf("failed to update node health: %v", err)
+	}
+
+	read.Healthy = true
+	if err := manager.UpdateNode(*read); !errors.Is(err, datastore.ErrConflict) {
+		t.Errorf("expected conflict updating a stale node, got %v", err)
+	}
+	current, _ := manager.GetNode("node-1")
+	if current.Healthy {
+		t.Errorf("expected stale update to be rejected")
+	}
+
+	if _, err := manager.GetNode("missing"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("expected ErrNodeNotFound, got %v", err)
+	}
+}
+
+func TestNodeManager_UpdateTaintsKeepsTimeAdded(t *testing.T) {
+	manager := NewManager(datastore.NewInMemoryDatastore())
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time { return start }
+	dedicated := models.Taint{Key: "dedicated", Value: "team-a", Effect: models.TaintNoExecute}
+	if err := manager.Register(models.Node{ID: "node-1", Healthy: true, Taints: []models.Taint{dedicated}}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+
+	manager.now = func() time.Time { return start.Add(time.Hour) }
+	maintenance := models.Taint{Key: "maintenance", Effect: models.TaintNoSchedule}
+	n, err := manager.UpdateTaints("node-1", []models.Taint{dedicated, maintenance})
+	if err != nil {
+		t.Fatalf("failed to update taints: %v", err)
+	}
+	if len(n.Taints) != 2 || !n.Taints[0].TimeAdded.Equal(start) || !n.Taints[1].TimeAdded.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected the existing taint to keep its time and the new one to be stamped now, got %+v", n.Taints)
+	}
+
+	// Registering again, as a restarted agent does, keeps the time too.
+	manager.now = func() time.Time { return start.Add(2 * time.Hour) }
+	if err := manager.Register(models.Node{ID: "node-1", Healthy: true, Taints: []models.Taint{dedicated}}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+	n, _ = manager.GetNode("node-1")
+	if len(n.Taints) != 1 || !n.Taints[0].TimeAdded.Equal(start) {
+		t.Errorf("expected the taint to keep its time across registrations, got %+v", n.Taints)
+	}
+
+	if _, err := manager.UpdateTaints("missing", nil); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("expected ErrNodeNotFound, got %v", err)
+	}
+}
+
+func TestNodeManager_RegisterAgainKeepsLabelsAndAnnotations(t *testing.T) {
+	manager := NewManager(datastore.NewInMemoryDatastore())
+	if err := manager.Register(models.Node{ID: "node-1", Healthy: true, Labels: map[string]string{"zone": "a"}}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+	if _, err := manager.UpdateLabels("node-1", map[string]string{"zone": "a", "disk": "ssd"}, map[string]string{"example.com/owner": "ops"}); err != nil {
+		t.Fatalf("failed to update labels: %v", err)
+	}
+
+	// A restarted agent given no labels keeps those set since.
+	if err := manager.Register(models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+	n, _ := manager.GetNode("node-1")
+	if len(n.Labels) != 2 || n.Labels["disk"] != "ssd" || n.Annotations["example.com/owner"] != "ops" {
+		t.Errorf("expected the labels and annotations to be kept, got %v and %v", n.Labels, n.Annotations)
+	}
+
+	// Labels given on registration replace the stored ones.
+	if err := manager.Register(models.Node{ID: "node-1", Healthy: true, Labels: map[string]string{"zone": "b"}}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+	n, _ = manager.GetNode("node-1")
+	if len(n.Labels) != 1 || n.Labels["zone"] != "b" || n.Annotations["example.com/owner"] != "ops" {
+		t.Errorf("expected the given labels to replace the stored ones, got %v and %v", n.Labels, n.Annotations)
+	}
+
+	// Labels removed on purpose stay removed.
+	if _, err := manager.UpdateLabels("node-1", map[string]string{}, nil); err != nil {
+		t.Fatalf("failed to update labels: %v", err)
+	}
+	if err := manager.Register(models.Node{ID: "node-1", Healthy: true}); err != nil {
+		t.Fatalf("failed to register node: %v", err)
+	}
+	n, _ = manager.GetNode("node-1")
+	if len(n.Labels) != 0 || n.Annotations["example.com/owner"] != "ops" {
+		t.Errorf("expected the labels to stay removed and the annotations kept, got %v and %v", n.Labels, n.Annotations)
+	}
+
+	if _, err := manager.UpdateLabels("missing", nil, nil); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("expected ErrNodeNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package resource
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Name identifies a kind of resource, such as "cpu" or "memory". Names other
+// than the built-in ones describe extended resources and must be qualified
+// with a domain prefix, for example "example.com/gpu".
+type Name string
+
+const (
+	// CPU is measured in cores; "500m" is half a core.
+	CPU Name = "cpu"
+	// Memory is measured in bytes.
+	Memory Name = "memory"
+)
+
+var extendedNameRE = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?/[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+
+// ValidateName reports whether name is a built-in resource or a well-formed
+// extended resource name.
+func ValidateName(name Name) error {
+	if name == CPU || name == Memory {
+		return nil
+	}
+	if !extendedNameRE.MatchString(string(name)) {
+		return fmt.Errorf("resource name %q must be %q, %q or a domain-qualified name such as \"example.com/gpu\"", name, CPU, Memory)
+	}
+	return nil
+}
+
+// List maps resource names to quantities.
+type List map[Name]Quantity
+
+// Names returns the resource names in the list in sorted order.
+func (l List) Names() []Name {
+	names := make([]Name, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Get returns the quantity for name, or zero when it is not present.
+func (l List) Get(name Name) Quantity {
+	return l[name]
+}
+
+// Copy returns an independent copy of the list.
+func (l List) Copy() List {
+	if l == nil {
+		return nil
+	}
+	out := make(List, len(l))
+	for name, q := range l {
+		out[name] = q
+	}
+	return out
+}
+
+// Add returns a new list holding the sum of l and other for every resource
+// present in either.
+func (l List) Add(other List) List {
+	out := l.Copy()
+	if out == nil {
+		out = List{}
+	}
+	for name, q := range other {
+		if cur, ok := out[name]; ok {
+			out[name] = cur.Add(q)
+		} else {
+			out[name] = q
+		}
+	}
+	return out
+}
+
+// Sub returns a new list holding l minus other for every resource present in
+// either.
+func (l List) Sub(other List) List {
+	out := l.Copy()
+	if out == nil {
+		out = List{}
+	}
+	for name, q := range other {
+		cur := out[name]
+		if _, ok := out[name]; !ok {
+			cur.format = q.format
+		}
+		out[name] = cur.Sub(q)
+	}
+	return out
+}
+
+// Exceeding returns, in sorted order, the names of resources for which l asks
+// for more than available holds. Resources missing from available count as
+// zero.
+func (l List) Exceeding(available List) []Name {
+	var names []Name
+	for _, name := range l.Names() {
+		if l[name].Cmp(available[name]) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
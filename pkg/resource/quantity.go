@@ -0,0 +1,293 @@
+// Package resource implements the quantities used to express CPU, memory and
+// other countable resources requested by tasks and offered by nodes.
+package resource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Format records how a quantity was written so that it can be printed back in
+// the same family of suffixes.
+type Format int
+
+const (
+	// DecimalSI uses the power-of-ten suffixes m, k, M, G, T, P and E.
+	DecimalSI Format = iota
+	// BinarySI uses the power-of-two suffixes Ki, Mi, Gi, Ti, Pi and Ei.
+	BinarySI
+)
+
+// ErrFormatWrong is returned when a string cannot be parsed as a quantity.
+var ErrFormatWrong = errors.New("quantities must match the regular expression '^[+-]?([0-9]+(\\.[0-9]*)?|\\.[0-9]+)(e[+-]?[0-9]+|[mkMGTPE]|[KMGTPE]i)?$'")
+
+// ErrTooLarge is returned when a quantity does not fit in the internal representation.
+var ErrTooLarge = errors.New("quantity is too large")
+
+// Quantity is a fixed-point amount of a resource, stored in thousandths of a
+// unit. A CPU quantity of "500m" is half a core; a memory quantity of "1Gi" is
+// 1073741824 bytes.
+type Quantity struct {
+	milli  int64
+	format Format
+}
+
+type suffix struct {
+	text   string
+	format Format
+	// multiplier is the value of one unit with this suffix, in thousandths.
+	multiplier *big.Int
+}
+
+var suffixes = []suffix{
+	{"Ei", BinarySI, pow(2, 60)},
+	{"Pi", BinarySI, pow(2, 50)},
+	{"Ti", BinarySI, pow(2, 40)},
+	{"Gi", BinarySI, pow(2, 30)},
+	{"Mi", BinarySI, pow(2, 20)},
+	{"Ki", BinarySI, pow(2, 10)},
+	{"E", DecimalSI, pow(10, 18)},
+	{"P", DecimalSI, pow(10, 15)},
+	{"T", DecimalSI, pow(10, 12)},
+	{"G", DecimalSI, pow(10, 9)},
+	{"M", DecimalSI, pow(10, 6)},
+	{"k", DecimalSI, pow(10, 3)},
+}
+
+// pow returns base^exp expressed in thousandths.
+func pow(base, exp int64) *big.Int {
+	v := new(big.Int).Exp(big.NewInt(base), big.NewInt(exp), nil)
+	return v.Mul(v, big.NewInt(1000))
+}
+
+// NewQuantity returns a quantity of value whole units.
+func NewQuantity(value int64, format Format) Quantity {
+	return Quantity{milli: value * 1000, format: format}
+}
+
+// NewMilliQuantity returns a quantity of milli thousandths of a unit.
+func NewMilliQuantity(milli int64, format Format) Quantity {
+	return Quantity{milli: milli, format: format}
+}
+
+// ParseQuantity parses a human-readable quantity such as "2", "500m", "1.5",
+// "1Gi" or "1e3". Fractions of a thousandth are rounded up.
+func ParseQuantity(s string) (Quantity, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Quantity{}, ErrFormatWrong
+	}
+
+	number, format, multiplier, err := splitSuffix(s)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if !validNumber(number) {
+		return Quantity{}, ErrFormatWrong
+	}
+
+	value, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return Quantity{}, ErrFormatWrong
+	}
+	value.Mul(value, new(big.Rat).SetInt(multiplier))
+
+	// Round away from zero so that a request is never silently reduced.
+	milli := new(big.Int).Quo(value.Num(), value.Denom())
+	if new(big.Int).Mul(milli, value.Denom()).Cmp(value.Num()) != 0 {
+		if value.Sign() > 0 {
+			milli.Add(milli, big.NewInt(1))
+		} else {
+			milli.Sub(milli, big.NewInt(1))
+		}
+	}
+	if !milli.IsInt64() {
+		return Quantity{}, ErrTooLarge
+	}
+	return Quantity{milli: milli.Int64(), format: format}, nil
+}
+
+// MustParse is like ParseQuantity but panics on error. It is intended for
+// constants in code and tests.
+func MustParse(s string) Quantity {
+	q, err := ParseQuantity(s)
+	if err != nil {
+		panic(fmt.Sprintf("cannot parse %q: %v", s, err))
+	}
+	return q
+}
+
+// splitSuffix separates the numeric part of s from its suffix and returns the
+// multiplier, in thousandths, that the suffix stands for.
+func splitSuffix(s string) (string, Format, *big.Int, error) {
+	for _, sfx := range suffixes {
+		if strings.HasSuffix(s, sfx.text) {
+			return strings.TrimSuffix(s, sfx.text), sfx.format, sfx.multiplier, nil
+		}
+	}
+	if strings.HasSuffix(s, "m") {
+		return strings.TrimSuffix(s, "m"), DecimalSI, big.NewInt(1), nil
+	}
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		var exp int64
+		if _, err := fmt.Sscanf(s[i+1:], "%d", &exp); err != nil || !validExponent(s[i+1:]) {
+			return "", 0, nil, ErrFormatWrong
+		}
+		if exp < -3 || exp > 18 {
+			return "", 0, nil, ErrTooLarge
+		}
+		mult := new(big.Rat).SetFrac(big.NewInt(1000), big.NewInt(1))
+		ten := new(big.Rat).SetInt64(10)
+		for ; exp > 0; exp-- {
+			mult.Mul(mult, ten)
+		}
+		for ; exp < 0; exp++ {
+			mult.Quo(mult, ten)
+		}
+		return s[:i], DecimalSI, mult.Num(), nil
+	}
+	return s, DecimalSI, big.NewInt(1000), nil
+}
+
+func validExponent(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func validNumber(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if len(s) == 0 || s == "." {
+		return false
+	}
+	seenDot := false
+	for _, c := range s {
+		switch {
+		case c == '.' && !seenDot:
+			seenDot = true
+		case c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// MilliValue returns the quantity in thousandths of a unit.
+func (q Quantity) MilliValue() int64 {
+	return q.milli
+}
+
+// Value returns the quantity in whole units, rounded up.
+func (q Quantity) Value() int64 {
+	if q.milli%1000 == 0 || q.milli < 0 {
+		return q.milli / 1000
+	}
+	return q.milli/1000 + 1
+}
+
+// Format returns the suffix family used when the quantity is printed.
+func (q Quantity) Format() Format {
+	return q.format
+}
+
+// IsZero reports whether the quantity is zero.
+func (q Quantity) IsZero() bool {
+	return q.milli == 0
+}
+
+// Sign returns -1, 0 or 1 depending on the sign of the quantity.
+func (q Quantity) Sign() int {
+	switch {
+	case q.milli < 0:
+		return -1
+	case q.milli > 0:
+		return 1
+	}
+	return 0
+}
+
+// Cmp compares q with other and returns -1, 0 or 1.
+func (q Quantity) Cmp(other Quantity) int {
+	switch {
+	case q.milli < other.milli:
+		return -1
+	case q.milli > other.milli:
+		return 1
+	}
+	return 0
+}
+
+// Add returns q + other, saturating instead of overflowing.
+func (q Quantity) Add(other Quantity) Quantity {
+	sum := q.milli + other.milli
+	if other.milli > 0 && sum < q.milli {
+		sum = math.MaxInt64
+	} else if other.milli < 0 && sum > q.milli {
+		sum = math.MinInt64
+	}
+	return Quantity{milli: sum, format: q.format}
+}
+
+// Sub returns q - other, saturating instead of overflowing.
+func (q Quantity) Sub(other Quantity) Quantity {
+	if other.milli == math.MinInt64 {
+		return q.Add(Quantity{milli: math.MaxInt64})
+	}
+	return q.Add(Quantity{milli: -other.milli})
+}
+
+// String returns the canonical form of the quantity, which ParseQuantity
+// accepts and maps back to the same value.
+func (q Quantity) String() string {
+	if q.milli%1000 != 0 {
+		return fmt.Sprintf("%dm", q.milli)
+	}
+	if q.milli != 0 {
+		value := big.NewInt(q.milli)
+		for _, sfx := range suffixes {
+			if sfx.format != q.format {
+				continue
+			}
+			quo, rem := new(big.Int).QuoRem(value, sfx.multiplier, new(big.Int))
+			if rem.Sign() == 0 {
+				return quo.String() + sfx.text
+			}
+		}
+	}
+	return fmt.Sprintf("%d", q.milli/1000)
+}
+
+// MarshalJSON encodes the quantity as its canonical string.
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.String())
+}
+
+// UnmarshalJSON accepts either a quoted quantity string or a bare JSON number.
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+	parsed, err := ParseQuantity(s)
+	if err != nil {
+		return fmt.Errorf("invalid quantity %q: %w", s, err)
+	}
+	*q = parsed
+	return nil
+}
@@ -0,0 +1,114 @@
+package resource_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+func TestParseQuantity(t *testing.T) {
+	cases := []struct {
+		in        string
+		milli     int64
+		canonical string
+	}{
+		{"0", 0, "0"},
+		{"1", 1000, "1"},
+		{"500m", 500, "500m"},
+		{"1.5", 1500, "1500m"},
+		{".25", 250, "250m"},
+		{"0.0001", 1, "1m"},
+		{"2k", 2000000, "2k"},
+		{"1000", 1000000, "1k"},
+		{"1Ki", 1024000, "1Ki"},
+		{"1Gi", 1073741824000, "1Gi"},
+		{"512Mi", 536870912000, "512Mi"},
+		{"1.5Gi", 1610612736000, "1536Mi"},
+		{"128M", 128000000000, "128M"},
+		{"1e3", 1000000, "1k"},
+		{"+3", 3000, "3"},
+		{"-2", -2000, "-2"},
+	}
+	for _, tc := range cases {
+		q, err := resource.ParseQuantity(tc.in)
+		if err != nil {
+			t.Errorf("ParseQuantity(%q) returned error: %v", tc.in, err)
+			continue
+		}
+		if q.MilliValue() != tc.milli {
+			t.Errorf("ParseQuantity(%q) = %dm, want %dm", tc.in, q.MilliValue(), tc.milli)
+		}
+		if q.String() != tc.canonical {
+			t.Errorf("ParseQuantity(%q).String() = %q, want %q", tc.in, q.String(), tc.canonical)
+		}
+		back, err := resource.ParseQuantity(q.String())
+		if err != nil || back.Cmp(q) != 0 {
+			t.Errorf("canonical form %q of %q does not parse back to the same value", q.String(), tc.in)
+		}
+	}
+}
+
+func TestParseQuantity_Invalid(t *testing.T) {
+	for _, in := range []string{"", "abc", "1.2.3", "5x", "Gi", "1e", "1eX", "--1", "1 Gi", "100Ei"} {
+		if _, err := resource.ParseQuantity(in); err == nil {
+			t.Errorf("expected error parsing %q", in)
+		}
+	}
+}
+
+func TestQuantity_JSON(t *testing.T) {
+	var list resource.List
+	if err := json.Unmarshal([]byte(`{"cpu":"250m","memory":"1Gi","example.com/gpu":2}`), &list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if got := list[resource.CPU].MilliValue(); got != 250 {
+		t.Errorf("expected 250m cpu, got %dm", got)
+	}
+	if got := list[resource.Memory].Value(); got != 1<<30 {
+		t.Errorf("expected 1Gi memory, got %d", got)
+	}
+	if got := list["example.com/gpu"].Value(); got != 2 {
+		t.Errorf("expected 2 gpus, got %d", got)
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatalf("failed to encode list: %v", err)
+	}
+	if string(data) != `{"cpu":"250m","example.com/gpu":"2","memory":"1Gi"}` {
+		t.Errorf("unexpected encoding: %s", data)
+	}
+}
+
+func TestList_Arithmetic(t *testing.T) {
+	capacity := resource.List{resource.CPU: resource.MustParse("4"), resource.Memory: resource.MustParse("8Gi")}
+	used := resource.List{resource.CPU: resource.MustParse("1500m")}.Add(resource.List{resource.CPU: resource.MustParse("2"), resource.Memory: resource.MustParse("1Gi")})
+
+	free := capacity.Sub(used)
+	if got := free[resource.CPU].MilliValue(); got != 500 {
+		t.Errorf("expected 500m free cpu, got %dm", got)
+	}
+	if got := free[resource.Memory].String(); got != "7Gi" {
+		t.Errorf("expected 7Gi free memory, got %s", got)
+	}
+
+	request := resource.List{resource.CPU: resource.MustParse("1"), "example.com/gpu": resource.MustParse("1")}
+	exceeding := request.Exceeding(free)
+	if len(exceeding) != 2 || exceeding[0] != resource.CPU || exceeding[1] != "example.com/gpu" {
+		t.Errorf("unexpected exceeding resources: %v", exceeding)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []resource.Name{resource.CPU, resource.Memory, "example.com/gpu", "vendor.io/fpga-x1"} {
+		if err := resource.ValidateName(name); err != nil {
+			t.Errorf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []resource.Name{"", "gpu", "example.com/", "/gpu", "Example.com/gpu"} {
+		if err := resource.ValidateName(name); err == nil {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
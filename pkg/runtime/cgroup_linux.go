@@ -0,0 +1,217 @@
+//go:build linux
+
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+const (
+	// cgroup2SuperMagic identifies a cgroup v2 filesystem in statfs.
+	cgroup2SuperMagic = 0x63677270
+	// cpuPeriod is the cpu.max period, in microseconds.
+	cpuPeriod = 100000
+	// minCPUQuota is the smallest quota the kernel accepts, in microseconds.
+	minCPUQuota = 1000
+	// maxCPUQuota is the largest quota the kernel accepts, in microseconds.
+	maxCPUQuota = 1<<44 - 1
+)
+
+// setupCgroupRoot prepares root as the parent cgroup of every container:
+// it must live on a cgroup v2 filesystem, and the cpu and memory
+// controllers are enabled for its children.
+func setupCgroupRoot(root string) error {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(filepath.Dir(root), &fs); err != nil {
+		return fmt.Errorf("checking cgroup filesystem: %w", err)
+	}
+	if fs.Type != cgroup2SuperMagic {
+		return fmt.Errorf("%s is not on a cgroup v2 filesystem", filepath.Dir(root))
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return err
+	}
+	// The controllers must be delegated by the parent before root can hand
+	// them on. A parent that already delegates them may refuse the write.
+	parentErr := enableControllers(filepath.Dir(root))
+	if err := enableControllers(root); err != nil {
+		return errors.Join(err, parentErr)
+	}
+	return nil
+}
+
+// enableControllers enables the cpu and memory controllers for the children
+// of the cgroup at dir.
+func enableControllers(dir string) error {
+	return writeCgroupFile(dir, "cgroup.subtree_control", "+cpu +memory")
+}
+
+// cgroup is the cgroup of one container.
+type cgroup struct {
+	path string
+	// fd is an open handle on path, passed to the child process so that it
+	// starts inside the cgroup. It is closed once the process has started.
+	fd *os.File
+}
+
+// newCgroup creates the cgroup of a container under root and applies its
+// limits.
+func newCgroup(root, id string, limits resource.List) (*cgroup, error) {
+	path := filepath.Join(root, filepath.Base(id))
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return nil, err
+	}
+	cg := &cgroup{path: path}
+	if err := writeLimits(path, limits); err != nil {
+		cg.remove()
+		return nil, err
+	}
+	return cg, nil
+}
+
+// writeLimits writes cpu.max and memory.max from the CPU and memory limits.
+// Resources without a limit are left unbounded.
+func writeLimits(dir string, limits resource.List) error {
+	cpuMax := "max " + strconv.Itoa(cpuPeriod)
+	if q, ok := limits[resource.CPU]; ok {
+		cpuMax = cpuMaxFor(q)
+	}
+	if err := writeCgroupFile(dir, "cpu.max", cpuMax); err != nil {
+		return err
+	}
+	memoryMax := "max"
+	if q, ok := limits[resource.Memory]; ok {
+		memoryMax = strconv.FormatInt(q.Value(), 10)
+		// Without this the limit only pushes memory out to swap.
+		_ = writeCgroupFile(dir, "memory.swap.max", "0")
+	}
+	return writeCgroupFile(dir, "memory.max", memoryMax)
+}
+
+// cpuMaxFor returns the cpu.max setting for a CPU limit: the share of each
+// period the container may run for. A limit too large for the kernel to
+// take leaves the CPU unlimited, which is what it amounts to.
+func cpuMaxFor(q resource.Quantity) string {
+	if q.MilliValue() > maxCPUQuota*1000/cpuPeriod {
+		return fmt.Sprintf("max %d", cpuPeriod)
+	}
+	quota := max(q.MilliValue()*cpuPeriod/1000, minCPUQuota)
+	return fmt.Sprintf("%d %d", quota, cpuPeriod)
+}
+
+// prepare makes cmd start inside the cgroup.
+func (cg *cgroup) prepare(cmd *exec.Cmd) error {
+	fd, err := os.Open(cg.path)
+	if err != nil {
+		return err
+	}
+	cg.fd = fd
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(fd.Fd())
+	return nil
+}
+
+// started releases what prepare held on to.
+func (cg *cgroup) started() {
+	if cg.fd != nil {
+		cg.fd.Close()
+		cg.fd = nil
+	}
+}
+
+// kill kills every process in the cgroup, including any that left the
+// container's process group. It needs cgroup.kill, from Linux 5.14.
+func (cg *cgroup) kill() error {
+	return writeCgroupFile(cg.path, "cgroup.kill", "1")
+}
+
+// oomKilled reports whether the kernel killed a process of the cgroup for
+// exceeding memory.max.
+func (cg *cgroup) oomKilled() bool {
+	events, err := readKeyedFile(filepath.Join(cg.path, "memory.events"))
+	return err == nil && events["oom_kill"] > 0
+}
+
+// stats reads the resource usage of the cgroup.
+func (cg *cgroup) stats() (Stats, error) {
+	return readStats(cg.path)
+}
+
+// readStats reads usage from the cpu.stat, memory.current and, where the
+// kernel has it, memory.peak files in dir.
+func readStats(dir string) (Stats, error) {
+	cpu, err := readKeyedFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return Stats{}, err
+	}
+	current, err := readIntFile(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return Stats{}, err
+	}
+	peak, _ := readIntFile(filepath.Join(dir, "memory.peak"))
+	return Stats{
+		CPUUsage:    time.Duration(cpu["usage_usec"]) * time.Microsecond,
+		MemoryUsage: current,
+		MemoryPeak:  peak,
+	}, nil
+}
+
+// remove deletes the cgroup, which must have no processes left.
+func (cg *cgroup) remove() error {
+	cg.started()
+	if err := os.Remove(cg.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("writing %q to %s: %w", value, name, err)
+	}
+	return nil
+}
+
+// readKeyedFile parses a file of "key value" lines such as cpu.stat.
+func readKeyedFile(path string) (map[string]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]int64)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		key, raw, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64); err == nil {
+			values[key] = v
+		}
+	}
+	return values, sc.Err()
+}
+
+// readIntFile parses a file holding a single integer such as memory.current.
+func readIntFile(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
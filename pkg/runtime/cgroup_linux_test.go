@@ -0,0 +1,163 @@
+//go:build linux
+
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestWriteLimits(t *testing.T) {
+	dir := t.TempDir()
+	limits := resource.List{
+		resource.CPU:    resource.MustParse("250m"),
+		resource.Memory: resource.MustParse("64Mi"),
+	}
+	if err := writeLimits(dir, limits); err != nil {
+		t.Fatalf("writeLimits: %v", err)
+	}
+	if got := readFile(t, dir, "cpu.max"); got != "25000 100000" {
+		t.Errorf("cpu.max = %q, want 25000 100000", got)
+	}
+	if got := readFile(t, dir, "memory.max"); got != "67108864" {
+		t.Errorf("memory.max = %q, want 67108864", got)
+	}
+	if got := readFile(t, dir, "memory.swap.max"); got != "0" {
+		t.Errorf("memory.swap.max = %q, want 0", got)
+	}
+
+	// Without limits both are unbounded.
+	if err := writeLimits(dir, nil); err != nil {
+		t.Fatalf("writeLimits: %v", err)
+	}
+	if got := readFile(t, dir, "cpu.max"); got != "max 100000" {
+		t.Errorf("cpu.max = %q, want max 100000", got)
+	}
+	if got := readFile(t, dir, "memory.max"); got != "max" {
+		t.Errorf("memory.max = %q, want max", got)
+	}
+}
+
+func TestCPUMaxFor(t *testing.T) {
+	cases := map[string]string{
+		"2":   "200000 100000",
+		"1m":  "1000 100000", // raised to the kernel minimum
+		"1.5": "150000 100000",
+		// Beyond what the kernel accepts, and what fits the arithmetic.
+		"200M": "max 100000",
+		"9P":   "max 100000",
+	}
+	for limit, want := range cases {
+		if got := cpuMaxFor(resource.MustParse(limit)); got != want {
+			t.Errorf("cpuMaxFor(%s) = %q, want %q", limit, got, want)
+		}
+	}
+}
+
+func TestReadStatsAndOOM(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cpu.stat":       "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n",
+		"memory.current": "4096\n",
+		"memory.peak":    "8192\n",
+		"memory.events":  "low 0\nhigh 0\nmax 3\noom 1\noom_kill 0\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cg := &cgroup{path: dir}
+	stats, err := cg.stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	want := Stats{CPUUsage: 1500 * time.Millisecond, MemoryUsage: 4096, MemoryPeak: 8192}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	if cg.oomKilled() {
+		t.Error("expected no OOM kill with oom_kill 0")
+	}
+	os.WriteFile(filepath.Join(dir, "memory.events"), []byte("oom 1\noom_kill 1\n"), 0o644)
+	if !cg.oomKilled() {
+		t.Error("expected an OOM kill with oom_kill 1")
+	}
+}
+
+// cgroupRootForTest returns a cgroup the test may create children in, or
+// skips the test when there is none.
+func cgroupRootForTest(t *testing.T) string {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to manage cgroups")
+	}
+	controllers, err := os.ReadFile("/sys/fs/cgroup/cgroup.controllers")
+	if err != nil || !strings.Contains(string(controllers), "memory") || !strings.Contains(string(controllers), "cpu") {
+		t.Skip("needs a cgroup v2 hierarchy with the cpu and memory controllers at /sys/fs/cgroup")
+	}
+	root := filepath.Join("/sys/fs/cgroup", "orchestrator-test-"+strings.ReplaceAll(t.Name(), "/", "-"))
+	t.Cleanup(func() { os.Remove(root) })
+	return root
+}
+
+// TestProcessRuntime_CgroupLimits runs a process that outgrows its memory
+// limit on a real cgroup v2 hierarchy.
+func TestProcessRuntime_CgroupLimits(t *testing.T) {
+	root := cgroupRootForTest(t)
+	rt, err := NewProcessRuntime(t.TempDir(), WithCgroupRoot(root))
+	if err != nil {
+		t.Skipf("cgroups unavailable: %v", err)
+	}
+	spec := Spec{
+		ID:      "hog",
+		Command: "sh",
+		// Doubling a string quickly needs far more than 16Mi.
+		Args:   []string{"-c", `x=xxxxxxxxxxxxxxxx; while :; do x="$x$x"; done`},
+		Limits: resource.List{resource.CPU: resource.MustParse("500m"), resource.Memory: resource.MustParse("16Mi")},
+	}
+	if err := rt.Create(spec); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer rt.Remove("hog")
+	if got := readFile(t, filepath.Join(root, "hog"), "cpu.max"); strings.TrimSpace(got) != "50000 100000" {
+		t.Errorf("cpu.max = %q", got)
+	}
+	if err := rt.Start("hog"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if stats, err := rt.Stats("hog"); err != nil || stats.MemoryUsage < 0 {
+		t.Errorf("Stats: %+v, %v", stats, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	status, err := rt.Wait(ctx, "hog")
+	if err != nil {
+		rt.Stop("hog", 0)
+		t.Fatalf("Wait: %v", err)
+	}
+	if !status.OOMKilled {
+		t.Errorf("expected the process to be OOM killed, got %+v", status)
+	}
+}
+
+func TestWithCgroupRoot_RejectsNonCgroupFilesystem(t *testing.T) {
+	if _, err := NewProcessRuntime(t.TempDir(), WithCgroupRoot(filepath.Join(t.TempDir(), "cg"))); err == nil {
+		t.Error("expected a cgroup root outside cgroupfs to be rejected")
+	}
+}
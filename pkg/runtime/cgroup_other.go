@@ -0,0 +1,33 @@
+//go:build !linux
+
+package runtime
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+// errCgroupsUnsupported is returned when resource enforcement is requested
+// on a platform without cgroups.
+var errCgroupsUnsupported = errors.New("cgroups are only supported on Linux")
+
+func setupCgroupRoot(root string) error {
+	return errCgroupsUnsupported
+}
+
+// cgroup is never created on this platform; its methods exist so that the
+// process runtime compiles everywhere.
+type cgroup struct{}
+
+func newCgroup(root, id string, limits resource.List) (*cgroup, error) {
+	return nil, errCgroupsUnsupported
+}
+
+func (cg *cgroup) prepare(cmd *exec.Cmd) error { return errCgroupsUnsupported }
+func (cg *cgroup) started()                    {}
+func (cg *cgroup) kill() error                 { return errCgroupsUnsupported }
+func (cg *cgroup) oomKilled() bool             { return false }
+func (cg *cgroup) stats() (Stats, error)       { return Stats{}, ErrStatsUnavailable }
+func (cg *cgroup) remove() error               { return nil }
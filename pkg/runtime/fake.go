@@ -0,0 +1,281 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+)
+
+// Fake is an in-memory Runtime for tests. Started containers keep running
+// until Exit or Stop is called on them. Failures can be injected with
+// FailStart.
+type Fake struct {
+	mu         sync.Mutex
+	containers map[string]*fakeContainer
+	startErrs  map[string]error
+	// removedLogs keeps the logs of removed containers, to be read and for
+	// when they are created again.
+	removedLogs map[string][]logs.Entry
+	// started lists container IDs in the order they were started.
+	started []string
+}
+
+type fakeContainer struct {
+	spec   Spec
+	status Status
+	stats  *Stats
+	logs   []logs.Entry
+	exited chan struct{}
+}
+
+// NewFake creates an empty Fake.
+func NewFake() *Fake {
+	return &Fake{
+		containers:  make(map[string]*fakeContainer),
+		startErrs:   make(map[string]error),
+		removedLogs: make(map[string][]logs.Entry),
+	}
+}
+
+// FailStart makes the next Start of id fail with err.
+func (f *Fake) FailStart(id string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.startErrs[id] = err
+}
+
+// Exit makes a running container exit with code after writing output, one
+// log line per line of it, to its standard output.
+func (f *Fake) Exit(id string, code int, output string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	if c.status.State != StateRunning {
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, id, c.status.State)
+	}
+	now := time.Now()
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line != "" {
+			c.logs = append(c.logs, logs.Entry{Time: now, Stream: logs.Stdout, Line: strings.TrimSuffix(line, "\n")})
+		}
+	}
+	c.exit(code)
+	return nil
+}
+
+// OOMKill makes a running container exit as if the kernel killed it for
+// exceeding its memory limit.
+func (f *Fake) OOMKill(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	if c.status.State != StateRunning {
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, id, c.status.State)
+	}
+	c.status.OOMKilled = true
+	c.exit(128 + 9)
+	return nil
+}
+
+// SetStats sets the resource usage reported for a container. Until it is
+// called, Stats returns ErrStatsUnavailable.
+func (f *Fake) SetStats(id string, stats Stats) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	c.stats = &stats
+	return nil
+}
+
+// Started returns the IDs of the containers started so far, in order.
+func (f *Fake) Started() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.started...)
+}
+
+// Spec returns the spec a container was created with.
+func (f *Fake) Spec(id string) (Spec, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return Spec{}, err
+	}
+	return c.spec, nil
+}
+
+func (f *Fake) Create(spec Spec) error {
+	if err := validateSpec(spec); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.containers[spec.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, spec.ID)
+	}
+	f.containers[spec.ID] = &fakeContainer{
+		spec:   spec,
+		status: Status{ID: spec.ID, State: StateCreated},
+		logs:   f.removedLogs[spec.ID],
+		exited: make(chan struct{}),
+	}
+	delete(f.removedLogs, spec.ID)
+	return nil
+}
+
+func (f *Fake) Start(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	if c.status.State != StateCreated {
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, id, c.status.State)
+	}
+	c.status.StartedAt = time.Now()
+	if err, ok := f.startErrs[id]; ok {
+		delete(f.startErrs, id)
+		c.status.Error = err.Error()
+		c.exit(127)
+		return err
+	}
+	c.status.State = StateRunning
+	c.status.Pid = len(f.started) + 1
+	f.started = append(f.started, id)
+	return nil
+}
+
+// Stop makes a running container exit as if killed by SIGTERM.
+func (f *Fake) Stop(id string, timeout time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	if c.status.State == StateRunning {
+		c.exit(128 + 15)
+	}
+	return nil
+}
+
+func (f *Fake) Wait(ctx context.Context, id string) (Status, error) {
+	f.mu.Lock()
+	c, err := f.get(id)
+	f.mu.Unlock()
+	if err != nil {
+		return Status{}, err
+	}
+	select {
+	case <-c.exited:
+		return f.Status(id)
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	}
+}
+
+func (f *Fake) Status(id string) (Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return Status{}, err
+	}
+	return c.status, nil
+}
+
+func (f *Fake) Stats(id string) (Stats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return Stats{}, err
+	}
+	if c.stats == nil {
+		return Stats{}, ErrStatsUnavailable
+	}
+	return *c.stats, nil
+}
+
+// Logs writes the output given to Exit. When following a running
+// container, the output appears once it exits.
+func (f *Fake) Logs(ctx context.Context, id string, opts logs.Options, w io.Writer) error {
+	f.mu.Lock()
+	c, err := f.get(id)
+	var entries []logs.Entry
+	var seen int
+	if err == nil {
+		entries = logs.Filter(c.logs, opts)
+		seen = len(c.logs)
+	}
+	removed, wasRemoved := f.removedLogs[id]
+	f.mu.Unlock()
+	if err != nil && wasRemoved {
+		return writeEntries(w, logs.Filter(removed, opts), opts)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeEntries(w, entries, opts); err != nil || !opts.Follow {
+		return err
+	}
+
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		return nil
+	}
+	f.mu.Lock()
+	rest := c.logs[min(seen, len(c.logs)):]
+	f.mu.Unlock()
+	return writeEntries(w, rest, opts)
+}
+
+func (f *Fake) Remove(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(id)
+	if err != nil {
+		return err
+	}
+	if c.status.State == StateRunning {
+		return fmt.Errorf("%w: %s is still running", ErrInvalidState, id)
+	}
+	delete(f.containers, id)
+	f.removedLogs[id] = c.logs
+	return nil
+}
+
+// get returns a container. The caller must hold f.mu.
+func (f *Fake) get(id string) (*fakeContainer, error) {
+	c, ok := f.containers[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return c, nil
+}
+
+// exit records the end of a container. The caller must hold f.mu.
+func (c *fakeContainer) exit(code int) {
+	c.status.State = StateExited
+	c.status.Pid = 0
+	c.status.ExitCode = code
+	c.status.FinishedAt = time.Now()
+	close(c.exited)
+}
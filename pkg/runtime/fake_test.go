@@ -0,0 +1,111 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+)
+
+func TestFake_Lifecycle(t *testing.T) {
+	rt := runtime.NewFake()
+	spec := runtime.SpecFromTask(models.Task{ID: "task-1", Command: "echo", Args: []string{"hi"}})
+	if err := rt.Create(spec); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rt.Create(spec); !errors.Is(err, runtime.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+	if err := rt.Start("task-1"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if status, _ := rt.Status("task-1"); status.State != runtime.StateRunning {
+		t.Errorf("expected running, got %+v", status)
+	}
+
+	done := make(chan runtime.Status, 1)
+	go func() {
+		status, _ := rt.Wait(context.Background(), "task-1")
+		done <- status
+	}()
+	if err := rt.Exit("task-1", 2, "hi\n"); err != nil {
+		t.Fatalf("Exit: %v", err)
+	}
+	select {
+	case status := <-done:
+		if status.State != runtime.StateExited || status.ExitCode != 2 {
+			t.Errorf("expected exit code 2, got %+v", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Exit")
+	}
+	var out strings.Builder
+	if err := rt.Logs(context.Background(), "task-1", logs.Options{}, &out); err != nil || out.String() != "hi\n" {
+		t.Errorf("unexpected logs %q (%v)", out.String(), err)
+	}
+	if err := rt.Remove("task-1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	out.Reset()
+	if err := rt.Logs(context.Background(), "task-1", logs.Options{}, &out); err != nil || out.String() != "hi\n" {
+		t.Errorf("expected the logs to outlive the container, got %q (%v)", out.String(), err)
+	}
+	if got := rt.Started(); len(got) != 1 || got[0] != "task-1" {
+		t.Errorf("expected task-1 to have been started once, got %v", got)
+	}
+}
+
+func TestFake_FailStartAndStop(t *testing.T) {
+	rt := runtime.NewFake()
+	rt.FailStart("bad", errors.New("boom"))
+	for _, id := range []string{"bad", "good"} {
+		if err := rt.Create(runtime.Spec{ID: id, Command: "true"}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := rt.Start("bad"); err == nil {
+		t.Error("expected injected start failure")
+	}
+	if status, _ := rt.Status("bad"); status.State != runtime.StateExited || status.Error != "boom" {
+		t.Errorf("expected exited container with error, got %+v", status)
+	}
+
+	if err := rt.Start("good"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := rt.Stop("good", time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if status, _ := rt.Status("good"); status.State != runtime.StateExited || status.ExitCode != 143 {
+		t.Errorf("expected stopped container, got %+v", status)
+	}
+}
+
+func TestFake_OOMKillAndStats(t *testing.T) {
+	rt := runtime.NewFake()
+	if err := rt.Create(runtime.Spec{ID: "hog", Command: "true"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rt.Start("hog"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := rt.Stats("hog"); !errors.Is(err, runtime.ErrStatsUnavailable) {
+		t.Errorf("expected ErrStatsUnavailable before SetStats, got %v", err)
+	}
+	want := runtime.Stats{CPUUsage: time.Second, MemoryUsage: 1 << 20}
+	rt.SetStats("hog", want)
+	if got, err := rt.Stats("hog"); err != nil || got != want {
+		t.Errorf("Stats = %+v, %v; want %+v", got, err, want)
+	}
+	if err := rt.OOMKill("hog"); err != nil {
+		t.Fatalf("OOMKill: %v", err)
+	}
+	if status, _ := rt.Status("hog"); !status.OOMKilled || status.ExitCode != 137 {
+		t.Errorf("expected OOM killed container, got %+v", status)
+	}
+}
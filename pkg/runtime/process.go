@@ -0,0 +1,413 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+)
+
+// defaultPath is the PATH given to processes whose Spec does not set one.
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// drainTimeout bounds how long the output of an exited container is still
+// read, in case a process that escaped its group keeps the pipes open.
+const drainTimeout = 2 * time.Second
+
+// ProcessRuntime runs each container as a child process of the agent, in a
+// process group of its own so that stopping it also stops anything it
+// spawned. Output goes to a rotating log file per container in its
+// directory.
+//
+// With a cgroup root, each container also gets a cgroup v2 of its own under
+// it, which enforces the CPU and memory limits of the Spec and tracks the
+// container's resource usage.
+type ProcessRuntime struct {
+	dir         string
+	cgroupRoot  string
+	logMaxSize  int64
+	logMaxFiles int
+
+	mu         sync.Mutex
+	containers map[string]*process
+}
+
+// process is the state of one container of a ProcessRuntime.
+type process struct {
+	spec    Spec
+	logPath string
+	// log captures the output of the process once it has been started.
+	log *logs.Writer
+	cmd *exec.Cmd
+	// cgroup is nil when the runtime has no cgroup root.
+	cgroup *cgroup
+	status Status
+	// exited is closed once status holds the final result.
+	exited chan struct{}
+}
+
+// ProcessOption configures optional behaviour of a ProcessRuntime.
+type ProcessOption func(*ProcessRuntime)
+
+// WithCgroupRoot places every container in a cgroup under root, such as
+// /sys/fs/cgroup/orchestrator. The root is created if needed; its parent
+// must be a cgroup v2 the agent may delegate the cpu and memory
+// controllers from.
+func WithCgroupRoot(root string) ProcessOption {
+	return func(r *ProcessRuntime) { r.cgroupRoot = root }
+}
+
+// WithLogRotation sets the size at which a container's log file is rotated
+// and how many rotated files are kept. By default logs.DefaultMaxSize and
+// logs.DefaultMaxFiles apply.
+func WithLogRotation(maxSize int64, maxFiles int) ProcessOption {
+	return func(r *ProcessRuntime) {
+		r.logMaxSize = maxSize
+		r.logMaxFiles = maxFiles
+	}
+}
+
+// NewProcessRuntime creates a ProcessRuntime that keeps logs in dir,
+// creating it if needed.
+func NewProcessRuntime(dir string, opts ...ProcessOption) (*ProcessRuntime, error) {
+	r := &ProcessRuntime{dir: dir, containers: make(map[string]*process)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if r.cgroupRoot != "" {
+		if err := setupCgroupRoot(r.cgroupRoot); err != nil {
+			return nil, fmt.Errorf("setting up cgroup root %s: %w", r.cgroupRoot, err)
+		}
+	}
+	return r, nil
+}
+
+// Create prepares the log file, and cgroup if enabled, of a container.
+func (r *ProcessRuntime) Create(spec Spec) error {
+	if err := validateSpec(spec); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.containers[spec.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, spec.ID)
+	}
+	var cg *cgroup
+	if r.cgroupRoot != "" {
+		var err error
+		if cg, err = newCgroup(r.cgroupRoot, spec.ID, spec.Limits); err != nil {
+			return fmt.Errorf("creating cgroup for %s: %w", spec.ID, err)
+		}
+	}
+	// The log of an earlier container with the same ID is added to.
+	logPath := r.logPath(spec.ID)
+	f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		if cg != nil {
+			cg.remove()
+		}
+		return err
+	}
+	f.Close()
+	r.containers[spec.ID] = &process{
+		spec:    spec,
+		logPath: logPath,
+		cgroup:  cg,
+		status:  Status{ID: spec.ID, State: StateCreated},
+		exited:  make(chan struct{}),
+	}
+	return nil
+}
+
+// Start starts the process of a created container. A process that cannot
+// be started leaves the container exited with an Error and exit code 127.
+func (r *ProcessRuntime) Start(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, err := r.get(id)
+	if err != nil {
+		return err
+	}
+	if p.status.State != StateCreated {
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, id, p.status.State)
+	}
+
+	logWriter, err := logs.NewWriter(p.logPath, r.logMaxSize, r.logMaxFiles)
+	if err != nil {
+		return err
+	}
+	p.log = logWriter
+	stdout, err := newOutputPipe(logWriter.Stream(logs.Stdout))
+	if err != nil {
+		logWriter.Close()
+		return err
+	}
+	stderr, err := newOutputPipe(logWriter.Stream(logs.Stderr))
+	if err != nil {
+		stdout.closeWriter()
+		stdout.drain(0)
+		logWriter.Close()
+		return err
+	}
+	cmd := exec.Command(p.spec.Command, p.spec.Args...)
+	cmd.Dir = p.spec.WorkingDir
+	cmd.Env = Environ(p.spec)
+	cmd.Stdout = stdout.w
+	cmd.Stderr = stderr.w
+	setProcessGroup(cmd)
+
+	p.status.StartedAt = time.Now()
+	if p.cgroup != nil {
+		if err = p.cgroup.prepare(cmd); err == nil {
+			err = cmd.Start()
+		}
+		p.cgroup.started()
+	} else {
+		err = cmd.Start()
+	}
+	// The process has its own copies of the write ends now.
+	stdout.closeWriter()
+	stderr.closeWriter()
+	if err != nil {
+		stdout.drain(0)
+		stderr.drain(0)
+		logWriter.Close()
+		p.status.State = StateExited
+		p.status.ExitCode = 127
+		p.status.FinishedAt = p.status.StartedAt
+		p.status.Error = err.Error()
+		close(p.exited)
+		return err
+	}
+	p.cmd = cmd
+	p.status.State = StateRunning
+	p.status.Pid = cmd.Process.Pid
+
+	go func() {
+		// The output goes to files, so this returns as soon as the main
+		// process exits, even if processes it started still hold them.
+		waitErr := cmd.Wait()
+		// The container ends with its main process; anything it left
+		// behind in its group or cgroup goes with it, which lets the
+		// output be drained.
+		_ = kill(cmd.Process)
+		oomKilled := false
+		if p.cgroup != nil {
+			_ = p.cgroup.kill()
+			oomKilled = p.cgroup.oomKilled()
+		}
+		stdout.drain(drainTimeout)
+		stderr.drain(drainTimeout)
+		logWriter.Close()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		p.status.State = StateExited
+		p.status.OOMKilled = oomKilled
+		p.status.Pid = 0
+		p.status.ExitCode = exitCode(cmd.ProcessState)
+		p.status.FinishedAt = time.Now()
+		if cmd.ProcessState == nil && waitErr != nil {
+			p.status.Error = waitErr.Error()
+		}
+		close(p.exited)
+	}()
+	return nil
+}
+
+// outputPipe copies what a process writes to it into a log stream. Unlike
+// the pipes exec.Cmd makes for writers that are not files, it is drained
+// apart from waiting for the process.
+type outputPipe struct {
+	r, w *os.File
+	// done is closed once the copy has ended.
+	done chan struct{}
+}
+
+func newOutputPipe(dst io.Writer) (*outputPipe, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &outputPipe{r: r, w: w, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		_, _ = io.Copy(dst, r)
+	}()
+	return p, nil
+}
+
+// closeWriter closes the runtime's copy of the write end.
+func (p *outputPipe) closeWriter() {
+	p.w.Close()
+}
+
+// drain waits up to timeout for every writer to close the pipe and its
+// output to be copied, and then closes the pipe.
+func (p *outputPipe) drain(timeout time.Duration) {
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+	}
+	p.r.Close()
+	<-p.done
+}
+
+// Stop terminates the process group of a container, and kills it once the
+// timeout has passed.
+func (r *ProcessRuntime) Stop(id string, timeout time.Duration) error {
+	r.mu.Lock()
+	p, err := r.get(id)
+	if err != nil || p.status.State != StateRunning {
+		r.mu.Unlock()
+		return err
+	}
+	proc := p.cmd.Process
+	r.mu.Unlock()
+
+	if err := terminate(proc); err != nil {
+		return err
+	}
+	select {
+	case <-p.exited:
+		return nil
+	case <-time.After(timeout):
+	}
+	if err := kill(proc); err != nil {
+		return err
+	}
+	<-p.exited
+	return nil
+}
+
+// Stats reads the resource usage of a container from its cgroup.
+func (r *ProcessRuntime) Stats(id string) (Stats, error) {
+	r.mu.Lock()
+	p, err := r.get(id)
+	r.mu.Unlock()
+	if err != nil {
+		return Stats{}, err
+	}
+	if p.cgroup == nil {
+		return Stats{}, ErrStatsUnavailable
+	}
+	return p.cgroup.stats()
+}
+
+// Wait blocks until the process of a container has exited.
+func (r *ProcessRuntime) Wait(ctx context.Context, id string) (Status, error) {
+	r.mu.Lock()
+	p, err := r.get(id)
+	r.mu.Unlock()
+	if err != nil {
+		return Status{}, err
+	}
+	select {
+	case <-p.exited:
+		return r.Status(id)
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	}
+}
+
+// Status returns the current status of a container.
+func (r *ProcessRuntime) Status(id string) (Status, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, err := r.get(id)
+	if err != nil {
+		return Status{}, err
+	}
+	return p.status, nil
+}
+
+// logPath returns the path of the log file of a container.
+func (r *ProcessRuntime) logPath(id string) string {
+	return filepath.Join(r.dir, filepath.Base(id)+".log")
+}
+
+// Logs writes the output of a container from its log files. The logs of a
+// removed container are read from the files it left behind.
+func (r *ProcessRuntime) Logs(ctx context.Context, id string, opts logs.Options, w io.Writer) error {
+	r.mu.Lock()
+	p, err := r.get(id)
+	logPath := r.logPath(id)
+	var lw *logs.Writer
+	if err == nil {
+		lw = p.log
+	}
+	r.mu.Unlock()
+	if errors.Is(err, ErrNotFound) {
+		if _, statErr := os.Stat(logPath); statErr == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if lw == nil {
+		// Never started or already removed, so there is nothing to follow.
+		entries, err := logs.ReadFiles(logPath, opts)
+		if err != nil {
+			return err
+		}
+		return writeEntries(w, entries, opts)
+	}
+	return followLogs(ctx, lw, opts, w)
+}
+
+// Remove forgets a container that is not running. Its log files stay in the
+// runtime's directory.
+func (r *ProcessRuntime) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, err := r.get(id)
+	if err != nil {
+		return err
+	}
+	if p.status.State == StateRunning {
+		return fmt.Errorf("%w: %s is still running", ErrInvalidState, id)
+	}
+	if p.cgroup != nil {
+		if err := p.cgroup.remove(); err != nil {
+			return err
+		}
+	}
+	delete(r.containers, id)
+	return nil
+}
+
+// get returns a container. The caller must hold r.mu.
+func (r *ProcessRuntime) get(id string) (*process, error) {
+	p, ok := r.containers[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return p, nil
+}
+
+// Environ builds the environment of a process from its Spec, adding a
+// default PATH when the Spec does not set one.
+func Environ(spec Spec) []string {
+	env := make([]string, 0, len(spec.Env)+1)
+	hasPath := false
+	for _, e := range spec.Env {
+		if e.Name == "PATH" {
+			hasPath = true
+		}
+		env = append(env, e.Name+"="+e.Value)
+	}
+	if !hasPath {
+		env = append(env, "PATH="+defaultPath)
+	}
+	return env
+}
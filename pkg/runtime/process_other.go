@@ -0,0 +1,33 @@
+//go:build !unix
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing on platforms without process groups.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate kills p, as there is no portable way to ask it to exit.
+func terminate(p *os.Process) error {
+	return kill(p)
+}
+
+// kill kills p.
+func kill(p *os.Process) error {
+	if err := p.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// exitCode returns the exit code of a finished process.
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	return state.ExitCode()
+}
@@ -0,0 +1,250 @@
+//go:build unix
+
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/runtime"
+)
+
+func newProcessRuntime(t *testing.T) *runtime.ProcessRuntime {
+	t.Helper()
+	rt, err := runtime.NewProcessRuntime(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewProcessRuntime: %v", err)
+	}
+	return rt
+}
+
+// run creates and starts a container and waits for it to exit.
+func run(t *testing.T, rt runtime.Runtime, spec runtime.Spec) runtime.Status {
+	t.Helper()
+	if err := rt.Create(spec); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rt.Start(spec.ID); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := rt.Wait(ctx, spec.ID)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	return status
+}
+
+func logsOf(t *testing.T, rt runtime.Runtime, id string, opts logs.Options) string {
+	t.Helper()
+	var out strings.Builder
+	if err := rt.Logs(context.Background(), id, opts, &out); err != nil {
+		t.Fatalf("Logs: %v", err)
+	}
+	return out.String()
+}
+
+func TestProcessRuntime_EnvWorkingDirAndLogs(t *testing.T) {
+	rt := newProcessRuntime(t)
+	dir := t.TempDir()
+	status := run(t, rt, runtime.Spec{
+		ID:         "task-1",
+		Command:    "sh",
+		Args:       []string{"-c", `echo "$GREETING from $(pwd)"; echo oops >&2; exit 3`},
+		Env:        []models.EnvVar{{Name: "GREETING", Value: "hello"}},
+		WorkingDir: dir,
+	})
+	if status.State != runtime.StateExited || status.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %+v", status)
+	}
+	if status.StartedAt.IsZero() || status.FinishedAt.Before(status.StartedAt) {
+		t.Errorf("expected start and finish times, got %+v", status)
+	}
+	// The working directory may be reached through a symlink.
+	realDir, _ := filepath.EvalSymlinks(dir)
+	output := logsOf(t, rt, "task-1", logs.Options{})
+	if !strings.Contains(output, "hello from ") || !strings.Contains(output, "oops\n") {
+		t.Errorf("unexpected logs %q", output)
+	}
+	if !strings.Contains(output, dir) && !strings.Contains(output, realDir) {
+		t.Errorf("expected process to run in %s, logs %q", dir, output)
+	}
+	if tail := logsOf(t, rt, "task-1", logs.Options{Tail: 1}); strings.Count(tail, "\n") != 1 {
+		t.Errorf("expected a single line with tail=1, got %q", tail)
+	}
+
+	if err := rt.Start("task-1"); !errors.Is(err, runtime.ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState when starting twice, got %v", err)
+	}
+	if err := rt.Remove("task-1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := rt.Status("task-1"); !errors.Is(err, runtime.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after Remove, got %v", err)
+	}
+	if after := logsOf(t, rt, "task-1", logs.Options{}); after != output {
+		t.Errorf("expected the logs to outlive the container, got %q", after)
+	}
+	if err := rt.Logs(context.Background(), "never", logs.Options{}, io.Discard); !errors.Is(err, runtime.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown container, got %v", err)
+	}
+}
+
+func TestProcessRuntime_StartFailure(t *testing.T) {
+	rt := newProcessRuntime(t)
+	if err := rt.Create(runtime.Spec{ID: "task-1", Command: "/does/not/exist"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rt.Start("task-1"); err == nil {
+		t.Fatal("expected Start to fail for a missing executable")
+	}
+	status, _ := rt.Status("task-1")
+	if status.State != runtime.StateExited || status.Error == "" {
+		t.Errorf("expected an exited container with an error, got %+v", status)
+	}
+	if err := rt.Create(runtime.Spec{ID: "task-2"}); err == nil {
+		t.Error("expected Create to reject a spec without a command")
+	}
+	for _, id := range []string{"..", "a/x"} {
+		if err := rt.Create(runtime.Spec{ID: id, Command: "true"}); err == nil {
+			t.Errorf("expected Create to reject the ID %q", id)
+		}
+	}
+}
+
+// TestProcessRuntime_StopKillsProcessGroup checks that Stop reaches children
+// of the process, and kills processes that ignore SIGTERM.
+func TestProcessRuntime_StopKillsProcessGroup(t *testing.T) {
+	rt := newProcessRuntime(t)
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	spec := runtime.Spec{
+		ID:      "task-1",
+		Command: "sh",
+		Args:    []string{"-c", `trap '' TERM; sleep 60 & echo $! > ` + pidFile + `; wait`},
+	}
+	if err := rt.Create(spec); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rt.Start("task-1"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	var childPid string
+	deadline := time.Now().Add(5 * time.Second)
+	for childPid == "" && time.Now().Before(deadline) {
+		data, _ := os.ReadFile(pidFile)
+		childPid = strings.TrimSpace(string(data))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if childPid == "" {
+		t.Fatal("child process did not start")
+	}
+
+	start := time.Now()
+	if err := rt.Stop("task-1", 200*time.Millisecond); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected Stop to wait for the timeout before killing, took %s", elapsed)
+	}
+	status, _ := rt.Status("task-1")
+	if status.State != runtime.StateExited || status.ExitCode != 128+9 {
+		t.Errorf("expected the process to be killed, got %+v", status)
+	}
+	if _, err := os.Stat("/proc/" + childPid); err == nil {
+		// The child may linger briefly as a zombie of init.
+		time.Sleep(100 * time.Millisecond)
+		if data, err := os.ReadFile("/proc/" + childPid + "/stat"); err == nil && !strings.Contains(string(data), ") Z") {
+			t.Errorf("expected child %s to be killed with its group", childPid)
+		}
+	}
+}
+
+func TestProcessRuntime_WaitHonoursContext(t *testing.T) {
+	rt := newProcessRuntime(t)
+	if err := rt.Create(runtime.Spec{ID: "task-1", Command: "sleep", Args: []string{"60"}}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rt.Start("task-1"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer rt.Stop("task-1", time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := rt.Wait(ctx, "task-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Wait to end with the context, got %v", err)
+	}
+	if err := rt.Remove("task-1"); !errors.Is(err, runtime.ErrInvalidState) {
+		t.Errorf("expected Remove of a running container to fail, got %v", err)
+	}
+	if err := rt.Stop("task-1", time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	// sleep exits on SIGTERM.
+	if status, _ := rt.Status("task-1"); status.ExitCode != 128+15 {
+		t.Errorf("expected exit code 143, got %+v", status)
+	}
+}
+
+func TestProcessRuntime_FollowLogs(t *testing.T) {
+	rt := newProcessRuntime(t)
+	spec := runtime.Spec{ID: "task-1", Command: "sh", Args: []string{"-c", "echo one; sleep 0.2; echo two"}}
+	if err := rt.Create(spec); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rt.Start("task-1"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// Following returns once the process exits, with everything it printed.
+	output := logsOf(t, rt, "task-1", logs.Options{Follow: true, Timestamps: true})
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " one") || !strings.HasSuffix(lines[1], " two") {
+		t.Fatalf("unexpected followed logs %q", output)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, strings.Fields(lines[0])[0]); err != nil {
+		t.Errorf("expected a timestamp prefix, got %q", lines[0])
+	}
+}
+
+func TestProcessRuntime_LogsSurviveRecreate(t *testing.T) {
+	rt := newProcessRuntime(t)
+	for _, word := range []string{"first", "second"} {
+		run(t, rt, runtime.Spec{ID: "task-1", Command: "echo", Args: []string{word}})
+		if err := rt.Remove("task-1"); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+	}
+	if err := rt.Create(runtime.Spec{ID: "task-1", Command: "true"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if output := logsOf(t, rt, "task-1", logs.Options{}); output != "first\nsecond\n" {
+		t.Errorf("expected the output of both runs, got %q", output)
+	}
+}
+
+// TestProcessRuntime_ExitLeavingChildBehind checks that a container exits
+// with its main process, even though a child it started in the background
+// still holds its output.
+func TestProcessRuntime_ExitLeavingChildBehind(t *testing.T) {
+	rt := newProcessRuntime(t)
+	status := run(t, rt, runtime.Spec{
+		ID:      "task-1",
+		Command: "sh",
+		Args:    []string{"-c", `sleep 30 & echo started; exit 3`},
+	})
+	if status.State != runtime.StateExited || status.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %+v", status)
+	}
+	if output := logsOf(t, rt, "task-1", logs.Options{}); output != "started\n" {
+		t.Errorf("expected the output written before the exit, got %q", output)
+	}
+}
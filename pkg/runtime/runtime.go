@@ -0,0 +1,179 @@
+// Package runtime defines how a node agent executes tasks, and provides a
+// runtime that runs them as local processes and an in-memory one for tests.
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/logs"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+var (
+	// ErrNotFound is returned for an ID that was never created or has been
+	// removed.
+	ErrNotFound = errors.New("container not found")
+	// ErrAlreadyExists is returned by Create for an ID that is still in use.
+	ErrAlreadyExists = errors.New("container already exists")
+	// ErrInvalidState is returned for an operation the container's current
+	// state does not allow, such as starting it twice.
+	ErrInvalidState = errors.New("invalid container state")
+	// ErrStatsUnavailable is returned by Stats when the runtime does not
+	// track resource usage for the container.
+	ErrStatsUnavailable = errors.New("resource usage is not available")
+)
+
+// State is a step in the lifecycle of a container.
+type State string
+
+const (
+	// StateCreated containers have been prepared but not started.
+	StateCreated State = "created"
+	// StateRunning containers have a live process.
+	StateRunning State = "running"
+	// StateExited containers have finished, or failed to start.
+	StateExited State = "exited"
+)
+
+// Spec describes what to run. The runtime calls each instance of a Spec a
+// container, whether or not it is isolated from the host.
+type Spec struct {
+	// ID names the container; the agent uses the task ID.
+	ID         string
+	Command    string
+	Args       []string
+	Env        []models.EnvVar
+	WorkingDir string
+	// Limits caps the resources the container may use, where the runtime
+	// supports it. Only CPU and memory are enforced.
+	Limits resource.List
+}
+
+// SpecFromTask returns the Spec that runs t.
+func SpecFromTask(t models.Task) Spec {
+	return Spec{
+		ID:         t.ID,
+		Command:    t.Command,
+		Args:       t.Args,
+		Env:        t.Env,
+		WorkingDir: t.WorkingDir,
+		Limits:     t.Resources.Limits,
+	}
+}
+
+// Status describes a container at one point in time.
+type Status struct {
+	ID    string
+	State State
+	// Pid is the process ID while the container is running.
+	Pid int
+	// ExitCode is set once the container has exited. A process killed by a
+	// signal exits with 128 plus the signal number, as in a shell.
+	ExitCode   int
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Error explains why a container exited without its process running.
+	Error string
+	// OOMKilled is set when the container was killed for exceeding its
+	// memory limit.
+	OOMKilled bool
+}
+
+// Stats is the resource usage of a container.
+type Stats struct {
+	// CPUUsage is the CPU time consumed so far, across all cores.
+	CPUUsage time.Duration
+	// MemoryUsage is the memory currently in use, in bytes.
+	MemoryUsage int64
+	// MemoryPeak is the highest memory usage seen, in bytes, or zero when
+	// unknown.
+	MemoryPeak int64
+}
+
+// Runtime creates and runs containers. All methods are safe for concurrent
+// use.
+type Runtime interface {
+	// Create prepares a container for spec without starting it.
+	Create(spec Spec) error
+	// Start starts a created container.
+	Start(id string) error
+	// Stop asks a running container to terminate and kills it if it is
+	// still running after timeout. Stopping a container that is not running
+	// is not an error.
+	Stop(id string, timeout time.Duration) error
+	// Wait blocks until the container exits or ctx is done, and returns its
+	// final status.
+	Wait(ctx context.Context, id string) (Status, error)
+	// Status returns the current status of a container.
+	Status(id string) (Status, error)
+	// Stats returns the resource usage of a container, or
+	// ErrStatsUnavailable if the runtime does not track it.
+	Stats(id string) (Stats, error)
+	// Logs writes the lines the container printed on its standard output
+	// and error that match opts to w. With opts.Follow it keeps writing new
+	// lines until the container exits or ctx is done. The logs of a removed
+	// container can still be read.
+	Logs(ctx context.Context, id string, opts logs.Options, w io.Writer) error
+	// Remove deletes a container that is not running. Its logs are kept, so
+	// that a container created again under the same ID, such as a restarted
+	// task, adds to them.
+	Remove(id string) error
+}
+
+// validateSpec checks the parts of a Spec every runtime relies on.
+func validateSpec(spec Spec) error {
+	if spec.ID == "" {
+		return fmt.Errorf("container ID must not be empty")
+	}
+	// IDs name log files and cgroups, so they must stay within their
+	// directory.
+	if spec.ID == "." || spec.ID == ".." || strings.ContainsAny(spec.ID, `/\`) {
+		return fmt.Errorf("container ID %q is not a valid file name", spec.ID)
+	}
+	if spec.Command == "" {
+		return fmt.Errorf("container %s has no command", spec.ID)
+	}
+	return nil
+}
+
+// writeEntries writes entries to w in the format opts asks for.
+func writeEntries(w io.Writer, entries []logs.Entry, opts logs.Options) error {
+	for _, e := range entries {
+		if _, err := io.WriteString(w, e.Format(opts.Timestamps)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// followLogs writes the entries of lw that match opts to w and, with
+// opts.Follow, every later one until lw is closed or ctx is done.
+func followLogs(ctx context.Context, lw *logs.Writer, opts logs.Options, w io.Writer) error {
+	entries, sub, err := lw.Follow(opts)
+	if err != nil {
+		return err
+	}
+	defer sub.Stop()
+	if err := writeEntries(w, entries, opts); err != nil || !opts.Follow {
+		return err
+	}
+	for {
+		select {
+		case e, ok := <-sub.C():
+			if !ok {
+				return sub.Err()
+			}
+			if _, err := io.WriteString(w, e.Format(opts.Timestamps)); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import "github.com/fntkg/container-orchestrator/pkg/models"
+
+// maxNodeAffinityScore is what a node matching every preferred node
+// affinity term of a task scores, on the same scale as resource scores.
+const maxNodeAffinityScore = 100
+
+// nodeAffinityMismatch returns why a node does not satisfy a task's
+// nodeSelector or required node affinity, or "" when it does.
+func nodeAffinityMismatch(task models.Task, n models.Node) string {
+	if task.MatchesNode(n) {
+		return ""
+	}
+	if !(models.LabelSelector{MatchLabels: task.NodeSelector}).Matches(n.Labels) {
+		return "node does not match the task's nodeSelector"
+	}
+	return "node does not match the task's required node affinity"
+}
+
+// nodeAffinityScore rates a node between 0 and maxNodeAffinityScore by the
+// share of the weight of the task's preferred node affinity terms that it
+// matches. Tasks without preferred terms score 0 everywhere.
+func nodeAffinityScore(task models.Task, n models.Node) float64 {
+	if task.Affinity == nil || task.Affinity.NodeAffinity == nil {
+		return 0
+	}
+	var matched, total int
+	for _, term := range task.Affinity.NodeAffinity.Preferred {
+		total += term.Weight
+		if term.Preference.Matches(n.Labels) {
+			matched += term.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(matched) / float64(total) * maxNodeAffinityScore
+}
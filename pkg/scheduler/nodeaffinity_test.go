@@ -0,0 +1,104 @@
+package scheduler_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/scheduler"
+)
+
+func labelledNodes() []models.Node {
+	return []models.Node{
+		{ID: "node-1", Healthy: true, Capacity: cpuMem("4", "8Gi"), Labels: map[string]string{"disk": "hdd", "zone": "a"}},
+		{ID: "node-2", Healthy: true, Capacity: cpuMem("4", "8Gi"), Labels: map[string]string{"disk": "ssd", "zone": "b"}},
+		{ID: "node-3", Healthy: true, Capacity: cpuMem("4", "8Gi"), Labels: map[string]string{"disk": "ssd", "zone": "c"}},
+	}
+}
+
+func TestResourceFitScheduler_NodeSelector(t *testing.T) {
+	sched := scheduler.NewResourceFitScheduler(datastore.NewInMemoryDatastore(), scheduler.LeastAllocated)
+
+	task := newTask("task-1", "1", "1Gi")
+	task.NodeSelector = map[string]string{"disk": "ssd", "zone": "c"}
+	assigned, err := sched.Schedule(task, labelledNodes())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-3" {
+		t.Errorf("expected node-3, got %s", assigned.ID)
+	}
+
+	task.NodeSelector = map[string]string{"disk": "nvme"}
+	_, err = sched.Schedule(task, labelledNodes())
+	var fitErr *scheduler.FitError
+	if !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if len(fitErr.Reasons) != 3 || fitErr.Reasons["node-1"] != "node does not match the task's nodeSelector" {
+		t.Errorf("expected every node to be rejected for its labels, got %v", fitErr.Reasons)
+	}
+}
+
+func TestResourceFitScheduler_RequiredNodeAffinity(t *testing.T) {
+	sched := scheduler.NewResourceFitScheduler(datastore.NewInMemoryDatastore(), scheduler.LeastAllocated)
+
+	// Terms are alternatives: node-1 matches the second one.
+	task := newTask("task-1", "1", "1Gi")
+	task.Affinity = &models.Affinity{NodeAffinity: &models.NodeAffinity{Required: []models.LabelSelector{
+		{MatchExpressions: []models.LabelSelectorRequirement{{Key: "zone", Operator: models.LabelSelectorOpIn, Values: []string{"x", "y"}}}},
+		{MatchExpressions: []models.LabelSelectorRequirement{{Key: "disk", Operator: models.LabelSelectorOpNotIn, Values: []string{"ssd"}}}},
+	}}}
+	assigned, err := sched.Schedule(task, labelledNodes())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-1" {
+		t.Errorf("expected node-1, got %s", assigned.ID)
+	}
+
+	task.Affinity.NodeAffinity.Required = task.Affinity.NodeAffinity.Required[:1]
+	var fitErr *scheduler.FitError
+	if _, err := sched.Schedule(task, labelledNodes()); !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if fitErr.Reasons["node-2"] != "node does not match the task's required node affinity" {
+		t.Errorf("expected node-2 to be rejected for its affinity, got %v", fitErr.Reasons)
+	}
+}
+
+func TestResourceFitScheduler_PreferredNodeAffinity(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	// node-3 is the least allocated, but the task prefers zone b.
+	busy := newTask("busy", "1", "2Gi")
+	busy.NodeID = "node-2"
+	if err := ds.SaveTask(busy); err != nil {
+		t.Fatalf("failed to save task: %v", err)
+	}
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+
+	task := newTask("task-1", "1", "1Gi")
+	task.Affinity = &models.Affinity{NodeAffinity: &models.NodeAffinity{Preferred: []models.PreferredNodeTerm{
+		{Weight: 80, Preference: models.LabelSelector{MatchLabels: map[string]string{"zone": "b"}}},
+		{Weight: 20, Preference: models.LabelSelector{MatchLabels: map[string]string{"disk": "ssd"}}},
+	}}}
+	assigned, err := sched.Schedule(task, labelledNodes())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-2" {
+		t.Errorf("expected the preferred node-2, got %s", assigned.ID)
+	}
+
+	// Preferences never make a node eligible.
+	nodes := labelledNodes()
+	nodes[1].Capacity = cpuMem("1", "1Gi")
+	assigned, err = sched.Schedule(task, nodes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-3" {
+		t.Errorf("expected node-3 once node-2 is full, got %s", assigned.ID)
+	}
+}
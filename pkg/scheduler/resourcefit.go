@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+)
+
+// ScoringStrategy decides how ResourceFitScheduler ranks the nodes a task fits on.
+type ScoringStrategy int
+
+const (
+	// LeastAllocated prefers the node with the most free resources left after
+	// placing the task, spreading load across the cluster.
+	LeastAllocated ScoringStrategy = iota
+	// MostAllocated prefers the node with the least free resources left after
+	// placing the task, packing tasks onto as few nodes as possible.
+	MostAllocated
+)
+
+// String returns the name of the strategy.
+func (s ScoringStrategy) String() string {
+	switch s {
+	case LeastAllocated:
+		return "least-allocated"
+	case MostAllocated:
+		return "most-allocated"
+	}
+	return fmt.Sprintf("ScoringStrategy(%d)", int(s))
+}
+
+// ParseScoringStrategy maps a strategy name such as "least-allocated" to its value.
+func ParseScoringStrategy(name string) (ScoringStrategy, error) {
+	for _, s := range []ScoringStrategy{LeastAllocated, MostAllocated} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scoring strategy %q", name)
+}
+
+// scoredResources are the resources weighed when scoring nodes. Extended
+// resources are only checked for fit.
+var scoredResources = []resource.Name{resource.CPU, resource.Memory}
+
+// FitError is returned when no node can run a task, because none has enough
+// free resources, satisfies the task's node constraints, has only taints the
+// task tolerates or satisfies its inter-task and spread constraints.
+type FitError struct {
+	TaskID string
+	// Reasons maps each rejected node ID to why the task did not fit.
+	Reasons map[string]string
+}
+
+func (e *FitError) Error() string {
+	if len(e.Reasons) == 0 {
+		return fmt.Sprintf("no nodes available to schedule the task %s", e.TaskID)
+	}
+	ids := make([]string, 0, len(e.Reasons))
+	for id := range e.Reasons {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	reasons := make([]string, len(ids))
+	for i, id := range ids {
+		reasons[i] = id + ": " + e.Reasons[id]
+	}
+	return fmt.Sprintf("task %s does not fit on any of %d nodes (%s)", e.TaskID, len(ids), strings.Join(reasons, "; "))
+}
+
+// ResourceFitScheduler places tasks only on nodes with enough unallocated
+// resources that satisfy the task's nodeSelector and required node affinity,
+// and whose NoSchedule and NoExecute taints the task tolerates. It ranks
+// those nodes according to a ScoringStrategy, plus the weight of the task's
+// preferred node affinity terms each node matches, minus a penalty for each
+// PreferNoSchedule taint the task does not tolerate.
+//
+// Inter-task affinity, anti-affinity and topology spread constraints are
+// checked against the unfinished tasks bound to any node in the datastore,
+// candidate or not, while only the domains of the candidate nodes count as
+// places to spread to. Nodes breaking required terms and DoNotSchedule
+// constraints are skipped, and preferred terms and ScheduleAnyway
+// constraints adjust the score. Allocation is derived from the tasks
+// already bound to each node in the datastore.
+type ResourceFitScheduler struct {
+	ds       datastore.Datastore
+	strategy ScoringStrategy
+}
+
+// NewResourceFitScheduler returns a ResourceFitScheduler reading bound tasks from ds.
+func NewResourceFitScheduler(ds datastore.Datastore, strategy ScoringStrategy) *ResourceFitScheduler {
+	return &ResourceFitScheduler{
+		ds:       ds,
+		strategy: strategy,
+	}
+}
+
+// Schedule returns the best node for the task among those it fits on.
+func (s *ResourceFitScheduler) Schedule(task models.Task, nodes []models.Node) (*models.Node, error) {
+	tasks, err := s.ds.GetTasks()
+	if err != nil {
+		return nil, fmt.Errorf("listing bound tasks: %w", err)
+	}
+	stored, err := s.ds.GetNodes()
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+	allocated := allocatedByNode(tasks, task.ID)
+	topology := newTaskTopology(append(stored, nodes...), tasks, task.ID)
+
+	requests := task.Resources.EffectiveRequests()
+	fitErr := &FitError{TaskID: task.ID, Reasons: make(map[string]string)}
+	var best *models.Node
+	var bestScore float64
+	for i := range nodes {
+		n := &nodes[i]
+		if reason := nodeAffinityMismatch(task, *n); reason != "" {
+			fitErr.Reasons[n.ID] = reason
+			continue
+		}
+		if reason := taintMismatch(task, *n); reason != "" {
+			fitErr.Reasons[n.ID] = reason
+			continue
+		}
+		free := n.AllocatableResources().Sub(allocated[n.ID])
+		if insufficient := requests.Exceeding(free); len(insufficient) > 0 {
+			fitErr.Reasons[n.ID] = "insufficient " + joinNames(insufficient)
+			continue
+		}
+		if reason := topology.affinityMismatch(task, *n); reason != "" {
+			fitErr.Reasons[n.ID] = reason
+			continue
+		}
+		if reason := topology.spreadMismatch(task, *n, nodes); reason != "" {
+			fitErr.Reasons[n.ID] = reason
+			continue
+		}
+		score := s.score(requests, n.AllocatableResources(), free) +
+			nodeAffinityScore(task, *n) - taintPenalty(task, *n) +
+			topology.affinityScore(task, *n) - topology.spreadPenalty(task, *n, nodes)
+		if best == nil || score > bestScore || (score == bestScore && n.ID < best.ID) {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		return nil, fitErr
+	}
+	return best, nil
+}
+
+// allocatedByNode sums the requests of every non-terminal task bound to a
+// node, skipping the task being scheduled.
+func allocatedByNode(tasks []models.Task, skipTaskID string) map[string]resource.List {
+	allocated := make(map[string]resource.List)
+	for _, t := range tasks {
+		if t.NodeID == "" || t.ID == skipTaskID || t.Status.IsTerminal() {
+			continue
+		}
+		allocated[t.NodeID] = allocated[t.NodeID].Add(t.Resources.EffectiveRequests())
+	}
+	return allocated
+}
+
+// score rates a node between 0 and 100 according to the strategy, based on
+// the fraction of each scored resource left free once the task is placed.
+func (s *ResourceFitScheduler) score(requests, allocatable, free resource.List) float64 {
+	var total float64
+	var counted int
+	for _, name := range scoredResources {
+		capacity := allocatable[name].MilliValue()
+		if capacity <= 0 {
+			continue
+		}
+		remaining := free[name].Sub(requests[name]).MilliValue()
+		fraction := float64(remaining) / float64(capacity)
+		if s.strategy == MostAllocated {
+			fraction = 1 - fraction
+		}
+		total += fraction
+		counted++
+	}
+	if counted == 0 {
+		return 0
+	}
+	return total / float64(counted) * 100
+}
+
+func joinNames(names []resource.Name) string {
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = string(n)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,129 @@
+package scheduler_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/resource"
+	"github.com/fntkg/container-orchestrator/pkg/scheduler"
+)
+
+func cpuMem(cpu, mem string) resource.List {
+	return resource.List{resource.CPU: resource.MustParse(cpu), resource.Memory: resource.MustParse(mem)}
+}
+
+func newTask(id, cpu, mem string) models.Task {
+	return models.Task{ID: id, Status: "pending", Resources: models.ResourceRequirements{Requests: cpuMem(cpu, mem)}}
+}
+
+func TestResourceFitScheduler_FiltersNodesWithoutRoom(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	// node-1 already runs a task using most of its CPU.
+	bound := newTask("bound", "3", "1Gi")
+	bound.NodeID = "node-1"
+	if err := ds.SaveTask(bound); err != nil {
+		t.Fatalf("failed to save task: %v", err)
+	}
+
+	nodes := []models.Node{
+		{ID: "node-1", Healthy: true, Capacity: cpuMem("4", "8Gi")},
+		{ID: "node-2", Healthy: true, Capacity: cpuMem("2", "8Gi")},
+	}
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+
+	assigned, err := sched.Schedule(newTask("task-1", "1500m", "1Gi"), nodes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-2" {
+		t.Errorf("expected node-2, got %s", assigned.ID)
+	}
+
+	_, err = sched.Schedule(newTask("task-2", "3", "1Gi"), nodes)
+	var fitErr *scheduler.FitError
+	if !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if len(fitErr.Reasons) != 2 {
+		t.Errorf("expected a reason per node, got %v", fitErr.Reasons)
+	}
+}
+
+func TestResourceFitScheduler_IgnoresTerminalTasks(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	done := newTask("done", "4", "8Gi")
+	done.NodeID = "node-1"
+	done.Status = "succeeded"
+	if err := ds.SaveTask(done); err != nil {
+		t.Fatalf("failed to save task: %v", err)
+	}
+
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	nodes := []models.Node{{ID: "node-1", Healthy: true, Capacity: cpuMem("4", "8Gi")}}
+	if _, err := sched.Schedule(newTask("task-1", "4", "8Gi"), nodes); err != nil {
+		t.Errorf("expected finished task to release its resources, got %v", err)
+	}
+}
+
+func TestResourceFitScheduler_Strategies(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	busy := newTask("busy", "2", "4Gi")
+	busy.NodeID = "node-busy"
+	if err := ds.SaveTask(busy); err != nil {
+		t.Fatalf("failed to save task: %v", err)
+	}
+	nodes := []models.Node{
+		{ID: "node-busy", Healthy: true, Capacity: cpuMem("4", "8Gi")},
+		{ID: "node-idle", Healthy: true, Capacity: cpuMem("4", "8Gi")},
+	}
+	task := newTask("task-1", "1", "1Gi")
+
+	spread, err := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated).Schedule(task, nodes)
+	if err != nil {
+		t.Fatalf("least-allocated: %v", err)
+	}
+	if spread.ID != "node-idle" {
+		t.Errorf("least-allocated: expected node-idle, got %s", spread.ID)
+	}
+
+	packed, err := scheduler.NewResourceFitScheduler(ds, scheduler.MostAllocated).Schedule(task, nodes)
+	if err != nil {
+		t.Fatalf("most-allocated: %v", err)
+	}
+	if packed.ID != "node-busy" {
+		t.Errorf("most-allocated: expected node-busy, got %s", packed.ID)
+	}
+}
+
+func TestResourceFitScheduler_ExtendedResources(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	gpuNode := models.Node{ID: "node-gpu", Healthy: true, Capacity: cpuMem("4", "8Gi")}
+	gpuNode.Capacity["example.com/gpu"] = resource.MustParse("1")
+	nodes := []models.Node{
+		{ID: "node-a", Healthy: true, Capacity: cpuMem("16", "64Gi")},
+		gpuNode,
+	}
+
+	task := newTask("task-1", "1", "1Gi")
+	task.Resources.Limits = resource.List{"example.com/gpu": resource.MustParse("1")}
+
+	assigned, err := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated).Schedule(task, nodes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-gpu" {
+		t.Errorf("expected node-gpu, got %s", assigned.ID)
+	}
+}
+
+func TestParseScoringStrategy(t *testing.T) {
+	s, err := scheduler.ParseScoringStrategy("most-allocated")
+	if err != nil || s != scheduler.MostAllocated {
+		t.Errorf("expected MostAllocated, got %v (%v)", s, err)
+	}
+	if _, err := scheduler.ParseScoringStrategy("random"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
@@ -0,0 +1,30 @@
+package scheduler
+
+import "github.com/fntkg/container-orchestrator/pkg/models"
+
+// preferNoSchedulePenalty is the score a node loses for each
+// PreferNoSchedule taint the task does not tolerate. It is as much as the
+// whole resource score, so such a node only wins over an untainted one when
+// the task's preferred node affinity favours it.
+const preferNoSchedulePenalty = 100
+
+// taintMismatch returns why a task may not be scheduled onto a node because
+// of its NoSchedule and NoExecute taints, or "" when it may.
+func taintMismatch(task models.Task, n models.Node) string {
+	if taint := task.UntoleratedTaint(n, models.TaintNoSchedule, models.TaintNoExecute); taint != nil {
+		return "node has taint " + taint.String() + " that the task does not tolerate"
+	}
+	return ""
+}
+
+// taintPenalty returns how much score a node loses for the PreferNoSchedule
+// taints the task does not tolerate.
+func taintPenalty(task models.Task, n models.Node) float64 {
+	var penalty float64
+	for _, taint := range n.Taints {
+		if taint.Effect == models.TaintPreferNoSchedule && !task.Tolerates(taint) {
+			penalty += preferNoSchedulePenalty
+		}
+	}
+	return penalty
+}
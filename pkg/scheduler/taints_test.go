@@ -0,0 +1,98 @@
+package scheduler_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/scheduler"
+)
+
+func TestResourceFitScheduler_Taints(t *testing.T) {
+	sched := scheduler.NewResourceFitScheduler(datastore.NewInMemoryDatastore(), scheduler.LeastAllocated)
+	nodes := []models.Node{
+		{ID: "node-1", Healthy: true, Capacity: cpuMem("4", "8Gi"), Taints: []models.Taint{{Key: "dedicated", Value: "team-a", Effect: models.TaintNoSchedule}}},
+		{ID: "node-2", Healthy: true, Capacity: cpuMem("4", "8Gi"), Taints: []models.Taint{{Key: "maintenance", Effect: models.TaintNoExecute}}},
+	}
+
+	_, err := sched.Schedule(newTask("task-1", "1", "1Gi"), nodes)
+	var fitErr *scheduler.FitError
+	if !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if fitErr.Reasons["node-1"] != "node has taint dedicated=team-a:NoSchedule that the task does not tolerate" {
+		t.Errorf("expected node-1 to be rejected for its taint, got %v", fitErr.Reasons)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		toleration models.Toleration
+		want       string
+	}{
+		{"equal", models.Toleration{Key: "dedicated", Value: "team-a"}, "node-1"},
+		{"exists with effect", models.Toleration{Key: "maintenance", Operator: models.TolerationOpExists, Effect: models.TaintNoExecute}, "node-2"},
+	} {
+		task := newTask("task-1", "1", "1Gi")
+		task.Tolerations = []models.Toleration{tc.toleration}
+		assigned, err := sched.Schedule(task, nodes)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tc.name, err)
+		}
+		if assigned.ID != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, assigned.ID)
+		}
+	}
+
+	// A toleration of the wrong value or effect does not help.
+	task := newTask("task-1", "1", "1Gi")
+	task.Tolerations = []models.Toleration{
+		{Key: "dedicated", Value: "team-b"},
+		{Key: "maintenance", Operator: models.TolerationOpExists, Effect: models.TaintNoSchedule},
+	}
+	if _, err := sched.Schedule(task, nodes); err == nil {
+		t.Error("expected the task not to be scheduled")
+	}
+}
+
+func TestResourceFitScheduler_PreferNoSchedule(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	// node-2 is busier, but node-1 is to be avoided.
+	busy := newTask("busy", "3", "6Gi")
+	busy.NodeID = "node-2"
+	if err := ds.SaveTask(busy); err != nil {
+		t.Fatalf("failed to save task: %v", err)
+	}
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	nodes := []models.Node{
+		{ID: "node-1", Healthy: true, Capacity: cpuMem("4", "8Gi"), Taints: []models.Taint{{Key: "spot", Effect: models.TaintPreferNoSchedule}}},
+		{ID: "node-2", Healthy: true, Capacity: cpuMem("4", "8Gi")},
+	}
+
+	assigned, err := sched.Schedule(newTask("task-1", "500m", "1Gi"), nodes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-2" {
+		t.Errorf("expected the untainted node-2, got %s", assigned.ID)
+	}
+
+	// The tainted node is still used when nothing else fits.
+	assigned, err = sched.Schedule(newTask("task-2", "2", "1Gi"), nodes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-1" {
+		t.Errorf("expected node-1, got %s", assigned.ID)
+	}
+
+	tolerant := newTask("task-3", "500m", "1Gi")
+	tolerant.Tolerations = []models.Toleration{{Key: "spot", Operator: models.TolerationOpExists}}
+	assigned, err = sched.Schedule(tolerant, nodes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-1" {
+		t.Errorf("expected a tolerating task to go to the least allocated node-1, got %s", assigned.ID)
+	}
+}
@@ -0,0 +1,232 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+// maxTaskAffinityScore is what a node for which every preferred task
+// affinity term of a task holds scores. Preferred anti-affinity terms that
+// do not hold count against it, down to -maxTaskAffinityScore.
+const maxTaskAffinityScore = 100
+
+// maxSpreadPenalty is the score lost by a node in the most crowded domain of
+// a ScheduleAnyway topology spread constraint.
+const maxSpreadPenalty = 100
+
+// placedTask is an unfinished task bound to a known node.
+type placedTask struct {
+	task models.Task
+	node models.Node
+}
+
+// taskTopology knows where the unfinished tasks bound to nodes run, for the
+// inter-task affinity and topology spread checks. It covers every node, not
+// only the candidates, so that a domain counts all the tasks it runs.
+type taskTopology struct {
+	placed []placedTask
+}
+
+// newTaskTopology indexes the unfinished tasks bound to nodes, skipping the
+// task being scheduled. A node listed twice is known by its last entry.
+func newTaskTopology(nodes []models.Node, tasks []models.Task, skipTaskID string) taskTopology {
+	byID := make(map[string]models.Node, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+	var tp taskTopology
+	for _, t := range tasks {
+		n, ok := byID[t.NodeID]
+		if !ok || t.ID == skipTaskID || t.Status.IsTerminal() {
+			continue
+		}
+		tp.placed = append(tp.placed, placedTask{task: t, node: n})
+	}
+	return tp
+}
+
+// matchesInDomain counts the tasks matching selector whose node is in the
+// given domain for key.
+func (tp taskTopology) matchesInDomain(selector models.LabelSelector, key, domain string) int {
+	count := 0
+	for _, p := range tp.placed {
+		if v, ok := p.node.TopologyValue(key); ok && v == domain && selector.Matches(p.task.Labels) {
+			count++
+		}
+	}
+	return count
+}
+
+// anyMatches reports whether any task matches selector.
+func (tp taskTopology) anyMatches(selector models.LabelSelector) bool {
+	for _, p := range tp.placed {
+		if selector.Matches(p.task.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// holds reports whether the domain of n for the term's topology key runs a
+// task matching the term's selector.
+func (tp taskTopology) holds(term models.TaskAffinityTerm, n models.Node) bool {
+	domain, ok := n.TopologyValue(term.TopologyKey)
+	return ok && tp.matchesInDomain(term.LabelSelector, term.TopologyKey, domain) > 0
+}
+
+// affinityMismatch returns why a node breaks the required task affinity or
+// anti-affinity of the task, or the required anti-affinity of a task
+// already placed, or "" when it breaks none.
+func (tp taskTopology) affinityMismatch(task models.Task, n models.Node) string {
+	if a := task.Affinity; a != nil && a.TaskAffinity != nil {
+		for _, term := range a.TaskAffinity.Required {
+			// The first of a group of tasks that attract each other has no
+			// peer to join, and may go anywhere.
+			if tp.holds(term, n) || term.LabelSelector.Matches(task.Labels) && !tp.anyMatches(term.LabelSelector) {
+				continue
+			}
+			return fmt.Sprintf("node's %s domain runs no task the task's required task affinity selects", term.TopologyKey)
+		}
+	}
+	if a := task.Affinity; a != nil && a.TaskAntiAffinity != nil {
+		for _, term := range a.TaskAntiAffinity.Required {
+			if tp.holds(term, n) {
+				return fmt.Sprintf("node's %s domain runs a task the task's required task anti-affinity excludes", term.TopologyKey)
+			}
+		}
+	}
+	// Anti-affinity is symmetric: the task may not join the domain of a task
+	// that excludes it.
+	for _, p := range tp.placed {
+		if p.task.Affinity == nil || p.task.Affinity.TaskAntiAffinity == nil {
+			continue
+		}
+		for _, term := range p.task.Affinity.TaskAntiAffinity.Required {
+			if !term.LabelSelector.Matches(task.Labels) {
+				continue
+			}
+			domain, ok := n.TopologyValue(term.TopologyKey)
+			if theirs, ok2 := p.node.TopologyValue(term.TopologyKey); ok && ok2 && domain == theirs {
+				return fmt.Sprintf("node's %s domain runs task %s, whose required task anti-affinity excludes the task", term.TopologyKey, p.task.ID)
+			}
+		}
+	}
+	return ""
+}
+
+// affinityScore rates a node between -maxTaskAffinityScore and
+// maxTaskAffinityScore by the share of the weight of the task's preferred
+// affinity terms that hold for it, minus that of its preferred
+// anti-affinity terms.
+func (tp taskTopology) affinityScore(task models.Task, n models.Node) float64 {
+	a := task.Affinity
+	if a == nil {
+		return 0
+	}
+	var score, total int
+	if a.TaskAffinity != nil {
+		for _, term := range a.TaskAffinity.Preferred {
+			total += term.Weight
+			if tp.holds(term.Term, n) {
+				score += term.Weight
+			}
+		}
+	}
+	if a.TaskAntiAffinity != nil {
+		for _, term := range a.TaskAntiAffinity.Preferred {
+			total += term.Weight
+			if tp.holds(term.Term, n) {
+				score -= term.Weight
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(score) / float64(total) * maxTaskAffinityScore
+}
+
+// spreadCounts returns how many tasks matching the constraint's selector
+// run in each domain for its topology key. The domains are those of the
+// candidate nodes the task may run on, so domains without any such task
+// count as zero.
+func (tp taskTopology) spreadCounts(task models.Task, c models.TopologySpreadConstraint, nodes []models.Node) map[string]int {
+	counts := make(map[string]int)
+	for _, n := range nodes {
+		if domain, ok := n.TopologyValue(c.TopologyKey); ok && task.MatchesNode(n) {
+			counts[domain] = 0
+		}
+	}
+	for _, p := range tp.placed {
+		domain, ok := p.node.TopologyValue(c.TopologyKey)
+		if _, counted := counts[domain]; ok && counted && task.MatchesNode(p.node) && c.LabelSelector.Matches(p.task.Labels) {
+			counts[domain]++
+		}
+	}
+	return counts
+}
+
+// spreadMismatch returns why placing the task on a node would break one of
+// its DoNotSchedule topology spread constraints, or "" when it would not.
+func (tp taskTopology) spreadMismatch(task models.Task, n models.Node, nodes []models.Node) string {
+	for _, c := range task.TopologySpreadConstraints {
+		if c.WhenUnsatisfiable == models.ScheduleAnyway {
+			continue
+		}
+		domain, ok := n.TopologyValue(c.TopologyKey)
+		if !ok {
+			return fmt.Sprintf("node has no %s label, which the task's topology spread constraint requires", c.TopologyKey)
+		}
+		counts := tp.spreadCounts(task, c, nodes)
+		if skew := counts[domain] + selfMatch(task, c) - minCount(counts); skew > c.MaxSkew {
+			return fmt.Sprintf("placing the task in %s %s would make the skew %d, above the maxSkew of %d", c.TopologyKey, domain, skew, c.MaxSkew)
+		}
+	}
+	return ""
+}
+
+// spreadPenalty returns the score a node loses for the task's ScheduleAnyway
+// topology spread constraints: nothing in the least crowded domain of each,
+// up to maxSpreadPenalty in the most crowded one or outside every domain.
+func (tp taskTopology) spreadPenalty(task models.Task, n models.Node, nodes []models.Node) float64 {
+	var penalty float64
+	for _, c := range task.TopologySpreadConstraints {
+		if c.WhenUnsatisfiable != models.ScheduleAnyway {
+			continue
+		}
+		domain, ok := n.TopologyValue(c.TopologyKey)
+		if !ok {
+			penalty += maxSpreadPenalty
+			continue
+		}
+		counts := tp.spreadCounts(task, c, nodes)
+		lowest, highest := minCount(counts), 0
+		for _, count := range counts {
+			highest = max(highest, count)
+		}
+		if highest > lowest {
+			penalty += float64(counts[domain]-lowest) / float64(highest-lowest) * maxSpreadPenalty
+		}
+	}
+	return penalty
+}
+
+// selfMatch is 1 when the task counts towards its own spread constraint.
+func selfMatch(task models.Task, c models.TopologySpreadConstraint) int {
+	if c.LabelSelector.Matches(task.Labels) {
+		return 1
+	}
+	return 0
+}
+
+// minCount returns the fewest tasks in any domain, or 0 without domains.
+func minCount(counts map[string]int) int {
+	lowest := -1
+	for _, count := range counts {
+		if lowest < 0 || count < lowest {
+			lowest = count
+		}
+	}
+	return max(lowest, 0)
+}
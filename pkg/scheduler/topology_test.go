@@ -0,0 +1,323 @@
+package scheduler_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/scheduler"
+)
+
+// zonedNodes returns four identical nodes, two in each of zones a and b.
+func zonedNodes() []models.Node {
+	return []models.Node{
+		{ID: "node-1", Healthy: true, Capacity: cpuMem("4", "8Gi"), Labels: map[string]string{"zone": "a"}},
+		{ID: "node-2", Healthy: true, Capacity: cpuMem("4", "8Gi"), Labels: map[string]string{"zone": "a"}},
+		{ID: "node-3", Healthy: true, Capacity: cpuMem("4", "8Gi"), Labels: map[string]string{"zone": "b"}},
+		{ID: "node-4", Healthy: true, Capacity: cpuMem("4", "8Gi"), Labels: map[string]string{"zone": "b"}},
+	}
+}
+
+// bind saves a small task with the given labels bound to a node.
+func bind(t *testing.T, ds datastore.Datastore, id, nodeID string, labels map[string]string) models.Task {
+	t.Helper()
+	task := newTask(id, "100m", "128Mi")
+	task.Status = "running"
+	task.NodeID = nodeID
+	task.Labels = labels
+	if err := ds.SaveTask(task); err != nil {
+		t.Fatalf("failed to save task: %v", err)
+	}
+	return task
+}
+
+func appSelector(app string) models.LabelSelector {
+	return models.LabelSelector{MatchLabels: map[string]string{"app": app}}
+}
+
+func TestResourceFitScheduler_RequiredTaskAffinity(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	task := newTask("web-1", "500m", "512Mi")
+	task.Labels = map[string]string{"app": "web"}
+	task.Affinity = &models.Affinity{TaskAffinity: &models.TaskAffinity{
+		Required: []models.TaskAffinityTerm{{LabelSelector: appSelector("cache"), TopologyKey: "zone"}},
+	}}
+
+	// Without any cache the task has nowhere to go.
+	_, err := sched.Schedule(task, zonedNodes())
+	var fitErr *scheduler.FitError
+	if !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if fitErr.Reasons["node-1"] != "node's zone domain runs no task the task's required task affinity selects" {
+		t.Errorf("unexpected reasons: %v", fitErr.Reasons)
+	}
+
+	// A cache on node-4 draws the task to zone b, where node-3 is idle.
+	bind(t, ds, "cache-1", "node-4", map[string]string{"app": "cache"})
+	assigned, err := sched.Schedule(task, zonedNodes())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-3" {
+		t.Errorf("expected node-3, got %s", assigned.ID)
+	}
+}
+
+func TestResourceFitScheduler_RequiredTaskAffinityFirstOfGroup(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	task := newTask("web-1", "500m", "512Mi")
+	task.Labels = map[string]string{"app": "web"}
+	task.Affinity = &models.Affinity{TaskAffinity: &models.TaskAffinity{
+		Required: []models.TaskAffinityTerm{{LabelSelector: appSelector("web"), TopologyKey: "zone"}},
+	}}
+
+	// The first task of a group selecting itself goes anywhere.
+	if _, err := sched.Schedule(task, zonedNodes()); err != nil {
+		t.Fatalf("expected the first of the group to be scheduled, got %v", err)
+	}
+
+	// Later ones join it.
+	bind(t, ds, "web-1", "node-3", task.Labels)
+	task.ID = "web-2"
+	assigned, err := sched.Schedule(task, zonedNodes())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-4" {
+		t.Errorf("expected the idle node in zone b, got %s", assigned.ID)
+	}
+}
+
+func TestResourceFitScheduler_RequiredTaskAntiAffinity(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	bind(t, ds, "web-1", "node-1", map[string]string{"app": "web"})
+	bind(t, ds, "web-2", "node-3", map[string]string{"app": "web"})
+	task := newTask("web-3", "500m", "512Mi")
+	task.Labels = map[string]string{"app": "web"}
+	task.Affinity = &models.Affinity{TaskAntiAffinity: &models.TaskAffinity{
+		Required: []models.TaskAffinityTerm{{LabelSelector: appSelector("web"), TopologyKey: models.TopologyKeyNode}},
+	}}
+
+	assigned, err := sched.Schedule(task, zonedNodes())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-2" {
+		t.Errorf("expected node-2, got %s", assigned.ID)
+	}
+
+	// By zone, both zones already run a web task.
+	task.Affinity.TaskAntiAffinity.Required[0].TopologyKey = "zone"
+	_, err = sched.Schedule(task, zonedNodes())
+	var fitErr *scheduler.FitError
+	if !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if fitErr.Reasons["node-2"] != "node's zone domain runs a task the task's required task anti-affinity excludes" {
+		t.Errorf("unexpected reasons: %v", fitErr.Reasons)
+	}
+}
+
+func TestResourceFitScheduler_TaskAntiAffinityIsSymmetric(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	loner := bind(t, ds, "loner", "node-1", map[string]string{"app": "loner"})
+	loner.Affinity = &models.Affinity{TaskAntiAffinity: &models.TaskAffinity{
+		Required: []models.TaskAffinityTerm{{LabelSelector: appSelector("web"), TopologyKey: "zone"}},
+	}}
+	if err := ds.SaveTask(loner); err != nil {
+		t.Fatalf("failed to save task: %v", err)
+	}
+
+	// The web task has no constraint of its own, but may not join the loner's
+	// zone.
+	task := newTask("web-1", "500m", "512Mi")
+	task.Labels = map[string]string{"app": "web"}
+	_, err := sched.Schedule(task, zonedNodes()[:2])
+	var fitErr *scheduler.FitError
+	if !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if fitErr.Reasons["node-2"] != "node's zone domain runs task loner, whose required task anti-affinity excludes the task" {
+		t.Errorf("unexpected reasons: %v", fitErr.Reasons)
+	}
+
+	// Tasks the loner does not select are unaffected.
+	task.Labels = map[string]string{"app": "batch"}
+	if _, err := sched.Schedule(task, zonedNodes()[:2]); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestResourceFitScheduler_PreferredTaskAffinity(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	bind(t, ds, "cache-1", "node-1", map[string]string{"app": "cache"})
+	bind(t, ds, "web-1", "node-3", map[string]string{"app": "web"})
+
+	task := newTask("web-2", "500m", "512Mi")
+	task.Labels = map[string]string{"app": "web"}
+	task.Affinity = &models.Affinity{
+		TaskAffinity: &models.TaskAffinity{Preferred: []models.WeightedTaskAffinityTerm{
+			{Weight: 50, Term: models.TaskAffinityTerm{LabelSelector: appSelector("cache"), TopologyKey: "zone"}},
+		}},
+		TaskAntiAffinity: &models.TaskAffinity{Preferred: []models.WeightedTaskAffinityTerm{
+			{Weight: 50, Term: models.TaskAffinityTerm{LabelSelector: appSelector("web"), TopologyKey: models.TopologyKeyNode}},
+		}},
+	}
+
+	// Zone a has the cache; node-2 is idle.
+	assigned, err := sched.Schedule(task, zonedNodes())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-2" {
+		t.Errorf("expected node-2, got %s", assigned.ID)
+	}
+
+	// Preferred terms never rule a node out.
+	task.Affinity.TaskAffinity.Preferred[0].Term.LabelSelector = appSelector("missing")
+	if _, err := sched.Schedule(task, zonedNodes()[2:]); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestResourceFitScheduler_TopologySpreadDoNotSchedule(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	// Zone a already runs two web tasks, zone b one, on its busier node.
+	bind(t, ds, "web-1", "node-1", map[string]string{"app": "web"})
+	bind(t, ds, "web-2", "node-2", map[string]string{"app": "web"})
+	bind(t, ds, "web-3", "node-4", map[string]string{"app": "web"})
+	bind(t, ds, "other", "node-4", map[string]string{"app": "other"})
+
+	task := newTask("web-4", "500m", "512Mi")
+	task.Labels = map[string]string{"app": "web"}
+	task.TopologySpreadConstraints = []models.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: "zone", LabelSelector: appSelector("web")},
+	}
+
+	// When zone b is full, the task waits rather than crowd zone a.
+	full := zonedNodes()
+	full[2].Capacity = cpuMem("100m", "8Gi")
+	full[3].Capacity = cpuMem("200m", "8Gi")
+	_, err := sched.Schedule(task, full)
+	var fitErr *scheduler.FitError
+	if !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if fitErr.Reasons["node-1"] != "placing the task in zone a would make the skew 2, above the maxSkew of 1" {
+		t.Errorf("unexpected reasons: %v", fitErr.Reasons)
+	}
+
+	// Spreading by node as well leaves node-3 as the only choice.
+	task.TopologySpreadConstraints = append(task.TopologySpreadConstraints,
+		models.TopologySpreadConstraint{MaxSkew: 1, TopologyKey: models.TopologyKeyNode, LabelSelector: appSelector("web")})
+	assigned, err := sched.Schedule(task, zonedNodes())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-3" {
+		t.Errorf("expected node-3, got %s", assigned.ID)
+	}
+
+	// Nodes without the topology key cannot take the task.
+	unlabeled := []models.Node{{ID: "node-5", Healthy: true, Capacity: cpuMem("4", "8Gi")}}
+	_, err = sched.Schedule(task, unlabeled)
+	if !errors.As(err, &fitErr) {
+		t.Fatalf("expected FitError, got %v", err)
+	}
+	if fitErr.Reasons["node-5"] != "node has no zone label, which the task's topology spread constraint requires" {
+		t.Errorf("unexpected reasons: %v", fitErr.Reasons)
+	}
+}
+
+// TestResourceFitScheduler_TopologyCountsTasksOnOtherNodes checks that tasks
+// on nodes that are not candidates, such as unhealthy ones, still count
+// towards their domain.
+func TestResourceFitScheduler_TopologyCountsTasksOnOtherNodes(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	nodes := zonedNodes()
+	nodes[1].Healthy = false
+	for _, n := range nodes {
+		if err := ds.SaveNode(n); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+	bind(t, ds, "web-1", "node-2", map[string]string{"app": "web"})
+	// Only node-1 in zone a and node-3 in zone b are offered; node-1 wins
+	// ties on its ID.
+	candidates := []models.Node{nodes[0], nodes[2]}
+
+	antiAffine := newTask("web-2", "500m", "512Mi")
+	antiAffine.Labels = map[string]string{"app": "web"}
+	antiAffine.Affinity = &models.Affinity{TaskAntiAffinity: &models.TaskAffinity{
+		Required: []models.TaskAffinityTerm{{LabelSelector: appSelector("web"), TopologyKey: "zone"}},
+	}}
+	spread := newTask("web-3", "500m", "512Mi")
+	spread.Labels = map[string]string{"app": "web"}
+	spread.TopologySpreadConstraints = []models.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: "zone", LabelSelector: appSelector("web")},
+	}
+	for _, task := range []models.Task{antiAffine, spread} {
+		assigned, err := sched.Schedule(task, candidates)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", task.ID, err)
+		}
+		if assigned.ID != "node-3" {
+			t.Errorf("%s: expected node-3, away from web-1 in zone a, got %s", task.ID, assigned.ID)
+		}
+	}
+}
+
+func TestResourceFitScheduler_TopologySpreadIgnoresIneligibleDomains(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	bind(t, ds, "web-1", "node-1", map[string]string{"app": "web"})
+
+	// Zone b is empty, but the task may not run there, so it does not count
+	// as the least crowded domain.
+	task := newTask("web-2", "500m", "512Mi")
+	task.Labels = map[string]string{"app": "web"}
+	task.NodeSelector = map[string]string{"zone": "a"}
+	task.TopologySpreadConstraints = []models.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: "zone", LabelSelector: appSelector("web")},
+	}
+	if _, err := sched.Schedule(task, zonedNodes()); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestResourceFitScheduler_TopologySpreadScheduleAnyway(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	sched := scheduler.NewResourceFitScheduler(ds, scheduler.LeastAllocated)
+	bind(t, ds, "web-1", "node-1", map[string]string{"app": "web"})
+	bind(t, ds, "web-2", "node-2", map[string]string{"app": "web"})
+	// The other zone is busier, but has fewer web tasks.
+	bind(t, ds, "big", "node-3", nil)
+
+	task := newTask("web-3", "500m", "512Mi")
+	task.Labels = map[string]string{"app": "web"}
+	task.TopologySpreadConstraints = []models.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: "zone", WhenUnsatisfiable: models.ScheduleAnyway, LabelSelector: appSelector("web")},
+	}
+	nodes := zonedNodes()[1:3]
+	assigned, err := sched.Schedule(task, nodes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assigned.ID != "node-3" {
+		t.Errorf("expected node-3 in the emptier zone, got %s", assigned.ID)
+	}
+
+	// With only the crowded zone left, the task is still scheduled.
+	if _, err := sched.Schedule(task, nodes[:1]); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
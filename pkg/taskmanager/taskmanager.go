@@ -3,33 +3,101 @@ package taskmanager
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/fntkg/container-orchestrator/pkg/datastore"
 	"github.com/fntkg/container-orchestrator/pkg/models"
 )
 
+// ErrTaskNotFound is returned when a task ID does not match any stored task.
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrTaskExists is returned when creating a task whose ID is already taken.
+var ErrTaskExists = errors.New("task already exists")
+
+// ErrInvalidPhase is returned when a task carries a status that is not a known phase.
+var ErrInvalidPhase = errors.New("invalid task phase")
+
+// TransitionError is returned when an update would move a task between two
+// phases that the lifecycle does not connect.
+type TransitionError struct {
+	TaskID string
+	From   models.TaskPhase
+	To     models.TaskPhase
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("task %s cannot transition from %q to %q", e.TaskID, e.From, e.To)
+}
+
+// transitions lists, for every phase, the phases a task may move to next.
+// Terminal phases have no outgoing transitions.
+var transitions = map[models.TaskPhase][]models.TaskPhase{
+	models.TaskPending:   {models.TaskScheduled, models.TaskFailed, models.TaskCancelled},
+	models.TaskScheduled: {models.TaskPending, models.TaskRunning, models.TaskFailed, models.TaskCancelled, models.TaskUnknown},
+	models.TaskRunning:   {models.TaskSucceeded, models.TaskFailed, models.TaskCancelled, models.TaskUnknown},
+	models.TaskUnknown:   {models.TaskRunning, models.TaskSucceeded, models.TaskFailed, models.TaskCancelled},
+}
+
+// CanTransition reports whether a task may move from one phase to another.
+// Staying in the same phase is always allowed.
+func CanTransition(from, to models.TaskPhase) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
 type TaskManager interface {
 	CreateTask(task models.Task) error
 	GetTask(taskID string) (*models.Task, error)
 	GetTasks() ([]models.Task, error)
+	ListTasks() ([]models.Task, uint64, error)
 	UpdateTask(task models.Task) error
+	Reschedule(taskID string) error
+	DeleteTask(taskID string) error
+	Watch(fromVersion uint64) (datastore.Watcher, error)
 }
 
 // TaskManager handles the lifecycle of tasks.
 type DefaultTaskManager struct {
-	ds datastore.Datastore
+	ds  datastore.Datastore
+	now func() time.Time
 }
 
 // NewTaskManager returns a new instance of TaskManager.
 func NewTaskManager(ds datastore.Datastore) *DefaultTaskManager {
 	return &DefaultTaskManager{
-		ds: ds,
+		ds:  ds,
+		now: time.Now,
 	}
 }
 
-// CreateTask stores a new task in the datastore.
+// CreateTask stores a new task in the datastore. Tasks created without a
+// status start out as pending; any other initial phase is rejected, and so
+// is an ID already in use.
 func (tm *DefaultTaskManager) CreateTask(task models.Task) error {
-	return tm.ds.SaveTask(task)
+	if task.Status == "" {
+		task.Status = models.TaskPending
+	}
+	if task.Status != models.TaskPending {
+		return fmt.Errorf("%w: new tasks must start as %q, got %q", ErrInvalidPhase, models.TaskPending, task.Status)
+	}
+	task.ResourceVersion = 0
+	task.Transitions = []models.PhaseTransition{{To: models.TaskPending, Time: tm.now()}}
+	if err := tm.ds.CreateTask(task); err != nil {
+		if errors.Is(err, datastore.ErrExists) {
+			return fmt.Errorf("task %s: %w", task.ID, ErrTaskExists)
+		}
+		return err
+	}
+	return nil
 }
 
 // GetTask retrieves a task by ID.
@@ -43,7 +111,7 @@ func (tm *DefaultTaskManager) GetTask(taskID string) (*models.Task, error) {
 			return &t, nil
 		}
 	}
-	return nil, errors.New("task not found")
+	return nil, ErrTaskNotFound
 }
 
 // GetTasks retrieves all tasks.
@@ -51,8 +119,116 @@ func (tm *DefaultTaskManager) GetTasks() ([]models.Task, error) {
 	return tm.ds.GetTasks()
 }
 
-// UpdateTask updates an existing task.
+// ListTasks retrieves all tasks together with the revision to resume a
+// watch from; see datastore.Datastore.ListTasks.
+func (tm *DefaultTaskManager) ListTasks() ([]models.Task, uint64, error) {
+	return tm.ds.ListTasks()
+}
+
+// UpdateTask updates an existing task. A change of status must follow the
+// lifecycle transition table; each accepted change is appended to the task's
+// transition history, which callers cannot overwrite.
+//
+// If task.ResourceVersion is set, the update only succeeds if the stored task
+// still has that version, and a datastore.ConflictError is returned
+// otherwise. Without a version the update is checked against the latest
+// stored task and retried if a concurrent writer gets in between.
 func (tm *DefaultTaskManager) UpdateTask(task models.Task) error {
-	// In our simple datastore, SaveTask will overwrite any existing task with the same ID.
+	if !task.Status.IsValid() {
+		return fmt.Errorf("%w: %q", ErrInvalidPhase, task.Status)
+	}
+	conditional := task.ResourceVersion != 0
+	for attempt := 0; ; attempt++ {
+		err := tm.updateOnce(task, conditional)
+		if conditional || !errors.Is(err, datastore.ErrConflict) || attempt+1 >= maxUpdateAttempts {
+			return err
+		}
+	}
+}
+
+// maxUpdateAttempts bounds how often an unconditional update is retried
+// after losing a race with another writer.
+const maxUpdateAttempts = 5
+
+func (tm *DefaultTaskManager) updateOnce(task models.Task, conditional bool) error {
+	current, err := tm.GetTask(task.ID)
+	if err != nil {
+		return err
+	}
+	if conditional && current.ResourceVersion != task.ResourceVersion {
+		return &datastore.ConflictError{Kind: "task", ID: task.ID, Expected: task.ResourceVersion, Actual: current.ResourceVersion}
+	}
+	if !CanTransition(current.Status, task.Status) {
+		return &TransitionError{TaskID: task.ID, From: current.Status, To: task.Status}
+	}
+
+	// Writing against the version the transition was checked on makes the
+	// check and the write atomic.
+	task.ResourceVersion = current.ResourceVersion
+	task.Transitions = current.Transitions
+	tm.recordTransition(&task, current.Status)
 	return tm.ds.SaveTask(task)
 }
+
+// Reschedule returns a failed task to the pending phase, unbound from its
+// node, so that it can be placed again. This is the only way out of a
+// terminal phase. It is open to tasks that were evicted, and to tasks whose
+// restart policy asks for them to run again, which counts as a restart; any
+// other task yields a TransitionError.
+func (tm *DefaultTaskManager) Reschedule(taskID string) error {
+	for attempt := 0; ; attempt++ {
+		task, err := tm.GetTask(taskID)
+		if err != nil {
+			return err
+		}
+		evicted := task.Reason == models.ReasonEvicted
+		if task.Status != models.TaskFailed || !evicted && !task.RestartAllowed(true) {
+			return &TransitionError{TaskID: taskID, From: task.Status, To: models.TaskPending}
+		}
+
+		from := task.Status
+		if !evicted {
+			task.RestartCount++
+		}
+		if task.Reason != "" {
+			task.LastTerminationReason = task.Reason
+		}
+		task.Status = models.TaskPending
+		task.NodeID = ""
+		task.Reason = ""
+		task.Message = ""
+		tm.recordTransition(task, from)
+		err = tm.ds.SaveTask(*task)
+		if !errors.Is(err, datastore.ErrConflict) || attempt+1 >= maxUpdateAttempts {
+			return err
+		}
+	}
+}
+
+// DeleteTask removes a task. A task that is still bound to a node should be
+// cancelled first, so that its node agent stops it.
+func (tm *DefaultTaskManager) DeleteTask(taskID string) error {
+	err := tm.ds.DeleteTask(taskID)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return ErrTaskNotFound
+	}
+	return err
+}
+
+// recordTransition appends a timestamped entry to the task's history if its
+// status differs from the previous one.
+func (tm *DefaultTaskManager) recordTransition(task *models.Task, from models.TaskPhase) {
+	if from == task.Status {
+		return
+	}
+	task.Transitions = append(task.Transitions, models.PhaseTransition{
+		From: from,
+		To:   task.Status,
+		Time: tm.now(),
+	})
+}
+
+// Watch streams changes to tasks after fromVersion; see datastore.Datastore.
+func (tm *DefaultTaskManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return tm.ds.Watch(datastore.KindTask, fromVersion)
+}
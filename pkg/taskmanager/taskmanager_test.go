@@ -2,6 +2,8 @@
 package taskmanager_test
 
 import (
+	"errors"
+	"sync"
 	"testing"
 
 	"github.com/fntkg/container-orchestrator/pkg/datastore"
@@ -44,10 +46,12 @@ func TestTaskManager_UpdateTask(t *testing.T) {
 		t.Fatalf("failed to create task: %v", err)
 	}
 
-	// Update the task's status.
-	updatedTask := models.Task{ID: "task-2", Status: "running"}
-	if err := tm.UpdateTask(updatedTask); err != nil {
-		t.Fatalf("failed to update task: %v", err)
+	// Update the task's status, going through the scheduled phase.
+	for _, phase := range []models.TaskPhase{models.TaskScheduled, models.TaskRunning} {
+		updatedTask := models.Task{ID: "task-2", Status: phase, NodeID: "node-1"}
+		if err := tm.UpdateTask(updatedTask); err != nil {
+			t.Fatalf("failed to update task to %s: %v", phase, err)
+		}
 	}
 
 	// Retrieve the task and verify the updated status.
@@ -59,6 +63,17 @@ func TestTaskManager_UpdateTask(t *testing.T) {
 	if retrievedTask.Status != "running" {
 		t.Errorf("expected task status 'running', got '%s'", retrievedTask.Status)
 	}
+
+	// Every transition, including creation, is recorded with a timestamp.
+	want := []models.TaskPhase{models.TaskPending, models.TaskScheduled, models.TaskRunning}
+	if len(retrievedTask.Transitions) != len(want) {
+		t.Fatalf("expected %d transitions, got %+v", len(want), retrievedTask.Transitions)
+	}
+	for i, tr := range retrievedTask.Transitions {
+		if tr.To != want[i] || tr.Time.IsZero() {
+			t.Errorf("transition %d: expected timestamped move to %s, got %+v", i, want[i], tr)
+		}
+	}
 }
 
 func TestTaskManager_GetTasks(t *testing.T) {
@@ -87,3 +102,219 @@ func TestTaskManager_GetTasks(t *testing.T) {
 		t.Errorf("expected %d tasks, got %d", len(tasksToCreate), len(tasks))
 	}
 }
+
+func TestTaskManager_CreateTaskDefaultsToPending(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+
+	if err := tm.CreateTask(models.Task{ID: "task-5"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	task, err := tm.GetTask("task-5")
+	if err != nil {
+		t.Fatalf("failed to retrieve task: %v", err)
+	}
+	if task.Status != "pending" {
+		t.Errorf("expected task status 'pending', got '%s'", task.Status)
+	}
+}
+
+func TestTaskManager_CreateTaskRejectsExistingID(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+
+	if err := tm.CreateTask(models.Task{ID: "task-6"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	if err := tm.UpdateTask(models.Task{ID: "task-6", Status: models.TaskFailed}); err != nil {
+		t.Fatalf("failed to fail task: %v", err)
+	}
+	if err := tm.CreateTask(models.Task{ID: "task-6"}); !errors.Is(err, taskmanager.ErrTaskExists) {
+		t.Fatalf("expected ErrTaskExists, got %v", err)
+	}
+	task, err := tm.GetTask("task-6")
+	if err != nil {
+		t.Fatalf("failed to retrieve task: %v", err)
+	}
+	if task.Status != models.TaskFailed || len(task.Transitions) != 2 {
+		t.Errorf("expected the failed task to be kept, got %q with %d transitions", task.Status, len(task.Transitions))
+	}
+}
+
+// TestTaskManager_ConcurrentCreatesWithOneID checks that of several creates
+// racing for one ID, exactly one succeeds.
+func TestTaskManager_ConcurrentCreatesWithOneID(t *testing.T) {
+	tm := taskmanager.NewTaskManager(datastore.NewInMemoryDatastore())
+	const creators = 8
+	errs := make(chan error, creators)
+	var wg sync.WaitGroup
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- tm.CreateTask(models.Task{ID: "task-7"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, taskmanager.ErrTaskExists):
+			t.Errorf("expected ErrTaskExists, got %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly one create to succeed, got %d", created)
+	}
+}
+
+func TestTaskManager_IllegalTransitions(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	tm := taskmanager.NewTaskManager(ds)
+
+	if err := tm.CreateTask(models.Task{ID: "task-6"}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	for _, phase := range []models.TaskPhase{models.TaskScheduled, models.TaskRunning, models.TaskSucceeded} {
+		if err := tm.UpdateTask(models.Task{ID: "task-6", Status: phase}); err != nil {
+			t.Fatalf("failed to update task to %s: %v", phase, err)
+		}
+	}
+
+	// A finished task cannot be moved back to pending.
+	err := tm.UpdateTask(models.Task{ID: "task-6", Status: models.TaskPending})
+	var transitionErr *taskmanager.TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("expected TransitionError, got %v", err)
+	}
+	if transitionErr.From != models.TaskSucceeded || transitionErr.To != models.TaskPending {
+		t.Errorf("unexpected transition error: %+v", transitionErr)
+	}
+
+	if err := tm.UpdateTask(models.Task{ID: "task-6", Status: "completed"}); !errors.Is(err, taskmanager.ErrInvalidPhase) {
+		t.Errorf("expected ErrInvalidPhase, got %v", err)
+	}
+	if err := tm.UpdateTask(models.Task{ID: "missing", Status: models.TaskRunning}); !errors.Is(err, taskmanager.ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound, got %v", err)
+	}
+	if err := tm.CreateTask(models.Task{ID: "task-7", Status: models.TaskRunning}); !errors.Is(err, taskmanager.ErrInvalidPhase) {
+		t.Errorf("expected ErrInvalidPhase when creating a running task, got %v", err)
+	}
+
+	task, _ := tm.GetTask("task-6")
+	if task.Status != models.TaskSucceeded {
+		t.Errorf("expected task to remain succeeded, got %s", task.Status)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to models.TaskPhase
+		allowed  bool
+	}{
+		{models.TaskPending, models.TaskScheduled, true},
+		{models.TaskPending, models.TaskRunning, false},
+		{models.TaskScheduled, models.TaskPending, true},
+		{models.TaskRunning, models.TaskUnknown, true},
+		{models.TaskUnknown, models.TaskRunning, true},
+		{models.TaskFailed, models.TaskRunning, false},
+		{models.TaskCancelled, models.TaskCancelled, true},
+	}
+	for _, tc := range cases {
+		if got := taskmanager.CanTransition(tc.from, tc.to); got != tc.allowed {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tc.from, tc.to, got, tc.allowed)
+		}
+	}
+}
+
+func TestTaskManager_Reschedule(t *testing.T) {
+	tm := taskmanager.NewTaskManager(datastore.NewInMemoryDatastore())
+	retries := 1
+	for _, task := range []models.Task{
+		{ID: "evicted"},
+		{ID: "crashed"},
+		{ID: "lost", RestartPolicy: models.RestartOnFailure, MaxRetries: &retries},
+	} {
+		id := task.ID
+		if err := tm.CreateTask(task); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		task, _ := tm.GetTask(id)
+		task.Status = models.TaskScheduled
+		task.NodeID = "node-1"
+		if err := tm.UpdateTask(*task); err != nil {
+			t.Fatalf("Failed to schedule task: %v", err)
+		}
+		task, _ = tm.GetTask(id)
+		task.Status = models.TaskFailed
+		switch id {
+		case "evicted":
+			task.Reason = models.ReasonEvicted
+			task.Message = "node lost"
+		case "lost":
+			task.Reason = "Lost"
+		}
+		if err := tm.UpdateTask(*task); err != nil {
+			t.Fatalf("Failed to fail task: %v", err)
+		}
+	}
+
+	if err := tm.Reschedule("evicted"); err != nil {
+		t.Fatalf("Failed to reschedule evicted task: %v", err)
+	}
+	task, _ := tm.GetTask("evicted")
+	if task.Status != models.TaskPending || task.NodeID != "" || task.Reason != "" || task.Message != "" {
+		t.Errorf("Expected a clean pending task, got %+v", task)
+	}
+	// Evictions are not the task's fault and do not count as restarts.
+	if task.RestartCount != 0 || task.LastTerminationReason != models.ReasonEvicted {
+		t.Errorf("Expected an uncounted eviction, got %+v", task)
+	}
+	if last := task.Transitions[len(task.Transitions)-1]; last.From != models.TaskFailed || last.To != models.TaskPending {
+		t.Errorf("Expected failed -> pending transition, got %+v", last)
+	}
+
+	// A task whose restart policy allows it is rescheduled once per retry.
+	if err := tm.Reschedule("lost"); err != nil {
+		t.Fatalf("Failed to reschedule lost task: %v", err)
+	}
+	task, _ = tm.GetTask("lost")
+	if task.Status != models.TaskPending || task.RestartCount != 1 || task.LastTerminationReason != "Lost" {
+		t.Errorf("Expected a pending task restarted once, got %+v", task)
+	}
+	task.Status = models.TaskFailed
+	if err := tm.UpdateTask(*task); err != nil {
+		t.Fatalf("Failed to fail task: %v", err)
+	}
+
+	// Tasks that failed on their own, or have no retries left, stay failed.
+	var transitionErr *taskmanager.TransitionError
+	if err := tm.Reschedule("lost"); !errors.As(err, &transitionErr) {
+		t.Errorf("Expected TransitionError once retries run out, got %v", err)
+	}
+	if err := tm.Reschedule("crashed"); !errors.As(err, &transitionErr) {
+		t.Errorf("Expected TransitionError for a task that was not evicted, got %v", err)
+	}
+	if err := tm.Reschedule("missing"); !errors.Is(err, taskmanager.ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestTaskManager_DeleteTask(t *testing.T) {
+	tm := taskmanager.NewTaskManager(datastore.NewInMemoryDatastore())
+	if err := tm.CreateTask(models.Task{ID: "task-1"}); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if err := tm.DeleteTask("task-1"); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	if _, err := tm.GetTask("task-1"); !errors.Is(err, taskmanager.ErrTaskNotFound) {
+		t.Errorf("Expected the task to be gone, got %v", err)
+	}
+	if err := tm.DeleteTask("task-1"); !errors.Is(err, taskmanager.ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+}
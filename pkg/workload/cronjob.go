@@ -0,0 +1,114 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+// ErrCronJobNotFound is returned when an ID does not match any cron job.
+var ErrCronJobNotFound = errors.New("cron job not found")
+
+// CronJobManager stores cron jobs. Their jobs are created by the cron job
+// controller.
+type CronJobManager interface {
+	CreateCronJob(cj models.CronJob) error
+	GetCronJob(id string) (*models.CronJob, error)
+	GetCronJobs() ([]models.CronJob, error)
+	UpdateCronJob(cj models.CronJob) error
+	UpdateCronJobStatus(cj models.CronJob) error
+	DeleteCronJob(id string) error
+	Watch(fromVersion uint64) (datastore.Watcher, error)
+}
+
+// DefaultCronJobManager keeps cron jobs in a datastore.
+type DefaultCronJobManager struct {
+	ds  datastore.Datastore
+	now func() time.Time
+}
+
+// NewCronJobManager creates a DefaultCronJobManager backed by ds.
+func NewCronJobManager(ds datastore.Datastore) *DefaultCronJobManager {
+	return &DefaultCronJobManager{ds: ds, now: time.Now}
+}
+
+// CreateCronJob stores a new cron job, created now, with an empty status.
+// A job template without a restart policy gets RestartNever.
+func (m *DefaultCronJobManager) CreateCronJob(cj models.CronJob) error {
+	if _, err := m.GetCronJob(cj.ID); err == nil {
+		return fmt.Errorf("cron job %s: %w", cj.ID, ErrAlreadyExists)
+	}
+	setCronJobDefaults(&cj)
+	cj.CreationTime = m.now()
+	cj.ResourceVersion = 0
+	cj.Status = models.CronJobStatus{}
+	return m.ds.SaveCronJob(cj)
+}
+
+// GetCronJob retrieves a cron job by ID.
+func (m *DefaultCronJobManager) GetCronJob(id string) (*models.CronJob, error) {
+	cronJobs, err := m.ds.GetCronJobs()
+	if err != nil {
+		return nil, err
+	}
+	if cj := find(cronJobs, id); cj != nil {
+		return cj, nil
+	}
+	return nil, ErrCronJobNotFound
+}
+
+// GetCronJobs retrieves all cron jobs.
+func (m *DefaultCronJobManager) GetCronJobs() ([]models.CronJob, error) {
+	return m.ds.GetCronJobs()
+}
+
+// UpdateCronJob replaces everything but the creation time and status of a
+// cron job. Jobs it already started keep running. The update is conditional
+// when cj.ResourceVersion is set and retried on conflicts otherwise.
+func (m *DefaultCronJobManager) UpdateCronJob(cj models.CronJob) error {
+	setCronJobDefaults(&cj)
+	return update(datastore.KindCronJob, cj.ID, cj.ResourceVersion, m.GetCronJob, m.ds.SaveCronJob, func(current *models.CronJob) error {
+		cj.ResourceVersion = current.ResourceVersion
+		cj.CreationTime = current.CreationTime
+		cj.Status = current.Status
+		*current = cj
+		return nil
+	})
+}
+
+// UpdateCronJobStatus records the status of a cron job, conditional on
+// cj.ResourceVersion.
+func (m *DefaultCronJobManager) UpdateCronJobStatus(cj models.CronJob) error {
+	return update(datastore.KindCronJob, cj.ID, cj.ResourceVersion, m.GetCronJob, m.ds.SaveCronJob, func(current *models.CronJob) error {
+		current.Status = cj.Status
+		return nil
+	})
+}
+
+// DeleteCronJob removes a cron job. The cron job controller then removes its
+// jobs, and the job controller their tasks.
+func (m *DefaultCronJobManager) DeleteCronJob(id string) error {
+	err := m.ds.DeleteCronJob(id)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return ErrCronJobNotFound
+	}
+	return err
+}
+
+// Watch streams changes to cron jobs after fromVersion; see
+// datastore.Datastore.
+func (m *DefaultCronJobManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return m.ds.Watch(datastore.KindCronJob, fromVersion)
+}
+
+func setCronJobDefaults(cj *models.CronJob) {
+	if cj.ConcurrencyPolicy == "" {
+		cj.ConcurrencyPolicy = models.AllowConcurrent
+	}
+	if cj.JobTemplate.Template.RestartPolicy == "" {
+		cj.JobTemplate.Template.RestartPolicy = models.RestartNever
+	}
+}
@@ -0,0 +1,67 @@
+package workload_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+func TestCronJobManager_CreateUpdateAndStatus(t *testing.T) {
+	m := workload.NewCronJobManager(datastore.NewInMemoryDatastore())
+	cj := models.CronJob{ID: "backup", Schedule: "@daily", JobTemplate: models.Job{Template: models.Task{Command: "backup"}}}
+	cj.Status.Active = []string{"stale"}
+	if err := m.CreateCronJob(cj); err != nil {
+		t.Fatalf("Failed to create cron job: %v", err)
+	}
+	if err := m.CreateCronJob(cj); !errors.Is(err, workload.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	stored, err := m.GetCronJob("backup")
+	if err != nil {
+		t.Fatalf("Failed to get cron job: %v", err)
+	}
+	if stored.CreationTime.IsZero() || len(stored.Status.Active) != 0 {
+		t.Errorf("Expected a creation time and an empty status, got %+v", stored)
+	}
+	if stored.ConcurrencyPolicy != models.AllowConcurrent || stored.JobTemplate.Template.RestartPolicy != models.RestartNever {
+		t.Errorf("Expected the Allow policy and the Never restart policy, got %+v", stored)
+	}
+	if stored.SuccessfulHistoryLimit() != models.DefaultSuccessfulJobsHistoryLimit || stored.FailedHistoryLimit() != models.DefaultFailedJobsHistoryLimit {
+		t.Errorf("Expected the default history limits, got %+v", stored)
+	}
+
+	stored.Status.Active = []string{"backup-1"}
+	if err := m.UpdateCronJobStatus(*stored); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if err := m.UpdateCronJobStatus(*stored); !errors.Is(err, datastore.ErrConflict) {
+		t.Errorf("Expected a conflict writing the status of a stale version, got %v", err)
+	}
+
+	// Updates keep the creation time and the status.
+	changed := models.CronJob{ID: "backup", Schedule: "@hourly", Suspend: true, JobTemplate: cj.JobTemplate}
+	if err := m.UpdateCronJob(changed); err != nil {
+		t.Fatalf("Failed to update cron job: %v", err)
+	}
+	updated, _ := m.GetCronJob("backup")
+	if updated.Schedule != "@hourly" || !updated.Suspend || !updated.CreationTime.Equal(stored.CreationTime) || len(updated.Status.Active) != 1 {
+		t.Errorf("Expected the new spec with the old creation time and status, got %+v", updated)
+	}
+	changed.ResourceVersion = stored.ResourceVersion
+	if err := m.UpdateCronJob(changed); !errors.Is(err, datastore.ErrConflict) {
+		t.Errorf("Expected a conflict updating a stale version, got %v", err)
+	}
+
+	if err := m.DeleteCronJob("backup"); err != nil {
+		t.Fatalf("Failed to delete cron job: %v", err)
+	}
+	if err := m.DeleteCronJob("backup"); !errors.Is(err, workload.ErrCronJobNotFound) {
+		t.Errorf("Expected ErrCronJobNotFound, got %v", err)
+	}
+	if err := m.UpdateCronJob(changed); !errors.Is(err, workload.ErrCronJobNotFound) {
+		t.Errorf("Expected ErrCronJobNotFound updating a deleted cron job, got %v", err)
+	}
+}
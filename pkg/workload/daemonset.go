@@ -0,0 +1,116 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+// ErrDaemonSetNotFound is returned when an ID does not match any daemon set.
+var ErrDaemonSetNotFound = errors.New("daemon set not found")
+
+// DaemonSetManager stores daemon sets. Their tasks are created and replaced
+// by the daemon set controller.
+type DaemonSetManager interface {
+	CreateDaemonSet(ds models.DaemonSet) error
+	GetDaemonSet(id string) (*models.DaemonSet, error)
+	GetDaemonSets() ([]models.DaemonSet, error)
+	UpdateDaemonSet(ds models.DaemonSet) error
+	UpdateDaemonSetStatus(ds models.DaemonSet) error
+	DeleteDaemonSet(id string) error
+	Watch(fromVersion uint64) (datastore.Watcher, error)
+}
+
+// DefaultDaemonSetManager keeps daemon sets in a datastore.
+type DefaultDaemonSetManager struct {
+	ds datastore.Datastore
+}
+
+// NewDaemonSetManager creates a DefaultDaemonSetManager backed by ds.
+func NewDaemonSetManager(ds datastore.Datastore) *DefaultDaemonSetManager {
+	return &DefaultDaemonSetManager{ds: ds}
+}
+
+// CreateDaemonSet stores a new daemon set with an empty status. A template
+// without a restart policy gets RestartAlways, and a daemon set without an
+// update strategy the RollingUpdate one.
+func (m *DefaultDaemonSetManager) CreateDaemonSet(ds models.DaemonSet) error {
+	if _, err := m.GetDaemonSet(ds.ID); err == nil {
+		return fmt.Errorf("daemon set %s: %w", ds.ID, ErrAlreadyExists)
+	}
+	setDaemonSetDefaults(&ds)
+	ds.ResourceVersion = 0
+	ds.Status = models.DaemonSetStatus{}
+	return m.ds.SaveDaemonSet(ds)
+}
+
+// GetDaemonSet retrieves a daemon set by ID.
+func (m *DefaultDaemonSetManager) GetDaemonSet(id string) (*models.DaemonSet, error) {
+	sets, err := m.ds.GetDaemonSets()
+	if err != nil {
+		return nil, err
+	}
+	if ds := find(sets, id); ds != nil {
+		return ds, nil
+	}
+	return nil, ErrDaemonSetNotFound
+}
+
+// GetDaemonSets retrieves all daemon sets.
+func (m *DefaultDaemonSetManager) GetDaemonSets() ([]models.DaemonSet, error) {
+	return m.ds.GetDaemonSets()
+}
+
+// UpdateDaemonSet replaces everything but the status of a daemon set. A new
+// template is rolled out as its update strategy says. The update is
+// conditional when ds.ResourceVersion is set and retried on conflicts
+// otherwise.
+func (m *DefaultDaemonSetManager) UpdateDaemonSet(ds models.DaemonSet) error {
+	setDaemonSetDefaults(&ds)
+	return m.update(ds.ID, ds.ResourceVersion, func(current *models.DaemonSet) error {
+		ds.ResourceVersion = current.ResourceVersion
+		ds.Status = current.Status
+		*current = ds
+		return nil
+	})
+}
+
+// UpdateDaemonSetStatus records the status of a daemon set, conditional on
+// ds.ResourceVersion.
+func (m *DefaultDaemonSetManager) UpdateDaemonSetStatus(ds models.DaemonSet) error {
+	return m.update(ds.ID, ds.ResourceVersion, func(current *models.DaemonSet) error {
+		current.Status = ds.Status
+		return nil
+	})
+}
+
+// DeleteDaemonSet removes a daemon set. The daemon set controller then
+// removes its tasks.
+func (m *DefaultDaemonSetManager) DeleteDaemonSet(id string) error {
+	err := m.ds.DeleteDaemonSet(id)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return ErrDaemonSetNotFound
+	}
+	return err
+}
+
+// Watch streams changes to daemon sets after fromVersion; see
+// datastore.Datastore.
+func (m *DefaultDaemonSetManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return m.ds.Watch(datastore.KindDaemonSet, fromVersion)
+}
+
+func (m *DefaultDaemonSetManager) update(id string, version uint64, change func(*models.DaemonSet) error) error {
+	return update(datastore.KindDaemonSet, id, version, m.GetDaemonSet, m.ds.SaveDaemonSet, change)
+}
+
+func setDaemonSetDefaults(ds *models.DaemonSet) {
+	if ds.Template.RestartPolicy == "" {
+		ds.Template.RestartPolicy = models.RestartAlways
+	}
+	if ds.UpdateStrategy.Type == "" {
+		ds.UpdateStrategy.Type = models.DaemonSetRollingUpdate
+	}
+}
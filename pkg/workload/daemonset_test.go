@@ -0,0 +1,62 @@
+package workload_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+func TestDaemonSetManager_CreateUpdateAndStatus(t *testing.T) {
+	m := workload.NewDaemonSetManager(datastore.NewInMemoryDatastore())
+	ds := models.DaemonSet{ID: "logs", Template: models.Task{Command: "ship-logs"}}
+	ds.Status.NumberReady = 3
+	if err := m.CreateDaemonSet(ds); err != nil {
+		t.Fatalf("Failed to create daemon set: %v", err)
+	}
+	if err := m.CreateDaemonSet(ds); !errors.Is(err, workload.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	stored, err := m.GetDaemonSet("logs")
+	if err != nil {
+		t.Fatalf("Failed to get daemon set: %v", err)
+	}
+	if stored.Status.NumberReady != 0 || stored.Template.RestartPolicy != models.RestartAlways || stored.UpdateStrategy.Type != models.DaemonSetRollingUpdate {
+		t.Errorf("Expected an empty status, the Always restart policy and the RollingUpdate strategy, got %+v", stored)
+	}
+	if got := stored.MaxUnavailable(10); got != 1 {
+		t.Errorf("Expected 1 unavailable node by default, got %d", got)
+	}
+
+	stored.Status.DesiredNumberScheduled = 2
+	if err := m.UpdateDaemonSetStatus(*stored); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if err := m.UpdateDaemonSetStatus(*stored); !errors.Is(err, datastore.ErrConflict) {
+		t.Errorf("Expected a conflict writing the status of a stale version, got %v", err)
+	}
+
+	// Updates keep the status.
+	changed := models.DaemonSet{
+		ID:             "logs",
+		NodeSelector:   models.LabelSelector{MatchLabels: map[string]string{"zone": "a"}},
+		Template:       models.Task{Command: "ship-logs-v2"},
+		UpdateStrategy: models.DaemonSetUpdateStrategy{RollingUpdate: &models.RollingUpdateDaemonSet{MaxUnavailable: models.FromPercent(30)}},
+	}
+	if err := m.UpdateDaemonSet(changed); err != nil {
+		t.Fatalf("Failed to update daemon set: %v", err)
+	}
+	updated, _ := m.GetDaemonSet("logs")
+	if updated.Template.Command != "ship-logs-v2" || updated.Status.DesiredNumberScheduled != 2 || updated.MaxUnavailable(10) != 3 || updated.MaxUnavailable(2) != 1 {
+		t.Errorf("Expected the new spec with the old status, got %+v", updated)
+	}
+
+	if err := m.DeleteDaemonSet("logs"); err != nil {
+		t.Fatalf("Failed to delete daemon set: %v", err)
+	}
+	if err := m.DeleteDaemonSet("logs"); !errors.Is(err, workload.ErrDaemonSetNotFound) {
+		t.Errorf("Expected ErrDaemonSetNotFound, got %v", err)
+	}
+}
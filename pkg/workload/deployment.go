@@ -0,0 +1,197 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+// ErrDeploymentNotFound is returned when an ID does not match any deployment.
+var ErrDeploymentNotFound = errors.New("deployment not found")
+
+// ErrRevisionNotFound is returned when rolling back to a revision whose
+// replica set no longer exists.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// DeploymentManager stores deployments. Their replica sets are created and
+// scaled by the deployment controller.
+type DeploymentManager interface {
+	CreateDeployment(d models.Deployment) error
+	GetDeployment(id string) (*models.Deployment, error)
+	GetDeployments() ([]models.Deployment, error)
+	UpdateDeployment(d models.Deployment) error
+	Scale(id string, replicas int) error
+	SetPaused(id string, paused bool) error
+	Rollback(id string, revision int) error
+	Revisions(id string) ([]models.ReplicaSet, error)
+	UpdateDeploymentStatus(d models.Deployment) error
+	DeleteDeployment(id string) error
+	Watch(fromVersion uint64) (datastore.Watcher, error)
+}
+
+// DefaultDeploymentManager keeps deployments in a datastore.
+type DefaultDeploymentManager struct {
+	ds datastore.Datastore
+}
+
+// NewDeploymentManager creates a DefaultDeploymentManager backed by ds.
+func NewDeploymentManager(ds datastore.Datastore) *DefaultDeploymentManager {
+	return &DefaultDeploymentManager{ds: ds}
+}
+
+// CreateDeployment stores a new deployment with an empty status. A template
+// without a restart policy gets RestartAlways, and a deployment without a
+// strategy the RollingUpdate one.
+func (m *DefaultDeploymentManager) CreateDeployment(d models.Deployment) error {
+	if _, err := m.GetDeployment(d.ID); err == nil {
+		return fmt.Errorf("deployment %s: %w", d.ID, ErrAlreadyExists)
+	}
+	setDeploymentDefaults(&d)
+	d.ResourceVersion = 0
+	d.Status = models.DeploymentStatus{}
+	return m.ds.SaveDeployment(d)
+}
+
+// GetDeployment retrieves a deployment by ID.
+func (m *DefaultDeploymentManager) GetDeployment(id string) (*models.Deployment, error) {
+	deployments, err := m.ds.GetDeployments()
+	if err != nil {
+		return nil, err
+	}
+	if d := find(deployments, id); d != nil {
+		return d, nil
+	}
+	return nil, ErrDeploymentNotFound
+}
+
+// GetDeployments retrieves all deployments.
+func (m *DefaultDeploymentManager) GetDeployments() ([]models.Deployment, error) {
+	return m.ds.GetDeployments()
+}
+
+// UpdateDeployment replaces everything but the selector and status of a
+// deployment. The selector cannot change, and the status is kept. The update
+// is conditional when d.ResourceVersion is set and retried on conflicts
+// otherwise.
+func (m *DefaultDeploymentManager) UpdateDeployment(d models.Deployment) error {
+	setDeploymentDefaults(&d)
+	return m.update(d.ID, d.ResourceVersion, func(current *models.Deployment) error {
+		if !reflect.DeepEqual(current.Selector, d.Selector) {
+			return models.ValidationError{{Field: "selector", Detail: "cannot be changed"}}
+		}
+		d.ResourceVersion = current.ResourceVersion
+		d.Status = current.Status
+		*current = d
+		return nil
+	})
+}
+
+// Scale sets the number of replicas a deployment wants.
+func (m *DefaultDeploymentManager) Scale(id string, replicas int) error {
+	if replicas < 0 {
+		return models.ValidationError{{Field: "replicas", Detail: "must not be negative"}}
+	}
+	return m.update(id, 0, func(current *models.Deployment) error {
+		current.Replicas = replicas
+		return nil
+	})
+}
+
+// SetPaused pauses or resumes the rollouts of a deployment.
+func (m *DefaultDeploymentManager) SetPaused(id string, paused bool) error {
+	return m.update(id, 0, func(current *models.Deployment) error {
+		current.Paused = paused
+		return nil
+	})
+}
+
+// Rollback sets the template of a deployment back to the one of an earlier
+// revision, which the deployment controller then rolls out as a new
+// revision. Revision zero means the one before the current revision.
+func (m *DefaultDeploymentManager) Rollback(id string, revision int) error {
+	history, err := m.Revisions(id)
+	if err != nil {
+		return err
+	}
+	return m.update(id, 0, func(current *models.Deployment) error {
+		var target *models.ReplicaSet
+		for i := range history {
+			rs := &history[i]
+			if (revision != 0 && rs.Revision == revision) || (revision == 0 && rs.Revision < current.Status.Revision) {
+				target = rs
+			}
+		}
+		if target == nil {
+			if revision == 0 {
+				return fmt.Errorf("deployment %s has no revision before %d: %w", id, current.Status.Revision, ErrRevisionNotFound)
+			}
+			return fmt.Errorf("deployment %s revision %d: %w", id, revision, ErrRevisionNotFound)
+		}
+		current.Template = target.Template
+		current.Template.Labels = maps.Clone(target.Template.Labels)
+		delete(current.Template.Labels, models.TemplateHashLabel)
+		return nil
+	})
+}
+
+// Revisions returns the replica sets of a deployment, oldest revision first.
+func (m *DefaultDeploymentManager) Revisions(id string) ([]models.ReplicaSet, error) {
+	if _, err := m.GetDeployment(id); err != nil {
+		return nil, err
+	}
+	sets, err := m.ds.GetReplicaSets()
+	if err != nil {
+		return nil, err
+	}
+	history := []models.ReplicaSet{}
+	for _, rs := range sets {
+		if rs.Owner != nil && rs.Owner.Kind == models.KindDeployment && rs.Owner.ID == id {
+			history = append(history, rs)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Revision < history[j].Revision })
+	return history, nil
+}
+
+// UpdateDeploymentStatus records the status of a deployment, conditional on
+// d.ResourceVersion.
+func (m *DefaultDeploymentManager) UpdateDeploymentStatus(d models.Deployment) error {
+	return m.update(d.ID, d.ResourceVersion, func(current *models.Deployment) error {
+		current.Status = d.Status
+		return nil
+	})
+}
+
+// DeleteDeployment removes a deployment. The deployment controller then
+// removes its replica sets, and with them their tasks.
+func (m *DefaultDeploymentManager) DeleteDeployment(id string) error {
+	err := m.ds.DeleteDeployment(id)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return ErrDeploymentNotFound
+	}
+	return err
+}
+
+// Watch streams changes to deployments after fromVersion; see
+// datastore.Datastore.
+func (m *DefaultDeploymentManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return m.ds.Watch(datastore.KindDeployment, fromVersion)
+}
+
+func (m *DefaultDeploymentManager) update(id string, version uint64, change func(*models.Deployment) error) error {
+	return update(datastore.KindDeployment, id, version, m.GetDeployment, m.ds.SaveDeployment, change)
+}
+
+func setDeploymentDefaults(d *models.Deployment) {
+	if d.Template.RestartPolicy == "" {
+		d.Template.RestartPolicy = models.RestartAlways
+	}
+	if d.Strategy.Type == "" {
+		d.Strategy.Type = models.RollingUpdate
+	}
+}
@@ -0,0 +1,125 @@
+package workload_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+func newDeployment(id string, replicas int) models.Deployment {
+	labels := map[string]string{"app": id}
+	return models.Deployment{
+		ID:       id,
+		Replicas: replicas,
+		Selector: models.LabelSelector{MatchLabels: labels},
+		Template: models.Task{Labels: labels, Command: "sleep", Args: []string{"60"}},
+	}
+}
+
+func TestDeploymentManager_CreateAndUpdate(t *testing.T) {
+	m := workload.NewDeploymentManager(datastore.NewInMemoryDatastore())
+	if err := m.CreateDeployment(newDeployment("web", 2)); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	if err := m.CreateDeployment(newDeployment("web", 2)); !errors.Is(err, workload.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	stored, err := m.GetDeployment("web")
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if stored.Strategy.Type != models.RollingUpdate || stored.Template.RestartPolicy != models.RestartAlways {
+		t.Errorf("Expected the RollingUpdate strategy and the Always restart policy, got %+v", stored)
+	}
+
+	stored.Status = models.DeploymentStatus{Revision: 1, Replicas: 2}
+	if err := m.UpdateDeploymentStatus(*stored); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	update := newDeployment("web", 3)
+	update.Paused = true
+	if err := m.UpdateDeployment(update); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+	updated, _ := m.GetDeployment("web")
+	if updated.Replicas != 3 || !updated.Paused || updated.Status.Revision != 1 {
+		t.Errorf("Expected the new spec with the old status, got %+v", updated)
+	}
+
+	update.Selector = models.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+	update.Template.Labels = update.Selector.MatchLabels
+	var validationErr models.ValidationError
+	if err := m.UpdateDeployment(update); !errors.As(err, &validationErr) {
+		t.Errorf("Expected a ValidationError changing the selector, got %v", err)
+	}
+	if err := m.SetPaused("missing", true); !errors.Is(err, workload.ErrDeploymentNotFound) {
+		t.Errorf("Expected ErrDeploymentNotFound, got %v", err)
+	}
+}
+
+func TestDeploymentManager_Rollback(t *testing.T) {
+	ds := datastore.NewInMemoryDatastore()
+	m := workload.NewDeploymentManager(ds)
+	rsm := workload.NewReplicaSetManager(ds)
+	d := newDeployment("web", 2)
+	d.Template.Command = "httpd"
+	if err := m.CreateDeployment(d); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	if err := m.Rollback("web", 0); !errors.Is(err, workload.ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound without history, got %v", err)
+	}
+
+	// Two revisions as the deployment controller would leave them, the
+	// second one current.
+	owner := &models.OwnerReference{Kind: models.KindDeployment, ID: "web"}
+	for revision, command := range map[int]string{1: "nginx", 2: "httpd"} {
+		rs := newReplicaSet("web-"+command, 0)
+		rs.Template.Labels = map[string]string{"app": "web", models.TemplateHashLabel: command}
+		rs.Selector.MatchLabels = rs.Template.Labels
+		rs.Template.Command = command
+		rs.Owner = owner
+		rs.Revision = revision
+		if err := rsm.CreateReplicaSet(rs); err != nil {
+			t.Fatalf("Failed to create replica set: %v", err)
+		}
+	}
+	if err := rsm.CreateReplicaSet(newReplicaSet("other", 1)); err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	history, err := m.Revisions("web")
+	if err != nil {
+		t.Fatalf("Failed to get revisions: %v", err)
+	}
+	if len(history) != 2 || history[0].Revision != 1 || history[1].Revision != 2 {
+		t.Fatalf("Expected revisions 1 and 2, got %+v", history)
+	}
+	stored, _ := m.GetDeployment("web")
+	stored.Status.Revision = 2
+	if err := m.UpdateDeploymentStatus(*stored); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+
+	if err := m.Rollback("web", 0); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	rolledBack, _ := m.GetDeployment("web")
+	if rolledBack.Template.Command != "nginx" || rolledBack.Template.Labels[models.TemplateHashLabel] != "" || rolledBack.Template.Labels["app"] != "web" {
+		t.Errorf("Expected the template of revision 1 without its hash, got %+v", rolledBack.Template)
+	}
+	if err := m.Rollback("web", 2); err != nil {
+		t.Fatalf("Failed to roll back to revision 2: %v", err)
+	}
+	if rolledBack, _ = m.GetDeployment("web"); rolledBack.Template.Command != "httpd" {
+		t.Errorf("Expected the template of revision 2, got %+v", rolledBack.Template)
+	}
+	if err := m.Rollback("web", 5); !errors.Is(err, workload.ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+	if _, err := m.Revisions("missing"); !errors.Is(err, workload.ErrDeploymentNotFound) {
+		t.Errorf("Expected ErrDeploymentNotFound, got %v", err)
+	}
+}
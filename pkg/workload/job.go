@@ -0,0 +1,87 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+// ErrJobNotFound is returned when an ID does not match any job.
+var ErrJobNotFound = errors.New("job not found")
+
+// JobManager stores jobs. Their tasks are created and tracked by the job
+// controller.
+type JobManager interface {
+	CreateJob(j models.Job) error
+	GetJob(id string) (*models.Job, error)
+	GetJobs() ([]models.Job, error)
+	UpdateJobStatus(j models.Job) error
+	DeleteJob(id string) error
+	Watch(fromVersion uint64) (datastore.Watcher, error)
+}
+
+// DefaultJobManager keeps jobs in a datastore.
+type DefaultJobManager struct {
+	ds datastore.Datastore
+}
+
+// NewJobManager creates a DefaultJobManager backed by ds.
+func NewJobManager(ds datastore.Datastore) *DefaultJobManager {
+	return &DefaultJobManager{ds: ds}
+}
+
+// CreateJob stores a new job with an empty status. A template without a
+// restart policy gets RestartNever.
+func (m *DefaultJobManager) CreateJob(j models.Job) error {
+	if _, err := m.GetJob(j.ID); err == nil {
+		return fmt.Errorf("job %s: %w", j.ID, ErrAlreadyExists)
+	}
+	if j.Template.RestartPolicy == "" {
+		j.Template.RestartPolicy = models.RestartNever
+	}
+	j.ResourceVersion = 0
+	j.Status = models.JobStatus{}
+	return m.ds.SaveJob(j)
+}
+
+// GetJob retrieves a job by ID.
+func (m *DefaultJobManager) GetJob(id string) (*models.Job, error) {
+	jobs, err := m.ds.GetJobs()
+	if err != nil {
+		return nil, err
+	}
+	if j := find(jobs, id); j != nil {
+		return j, nil
+	}
+	return nil, ErrJobNotFound
+}
+
+// GetJobs retrieves all jobs.
+func (m *DefaultJobManager) GetJobs() ([]models.Job, error) {
+	return m.ds.GetJobs()
+}
+
+// UpdateJobStatus records the status of a job, conditional on
+// j.ResourceVersion.
+func (m *DefaultJobManager) UpdateJobStatus(j models.Job) error {
+	return update(datastore.KindJob, j.ID, j.ResourceVersion, m.GetJob, m.ds.SaveJob, func(current *models.Job) error {
+		current.Status = j.Status
+		return nil
+	})
+}
+
+// DeleteJob removes a job. The job controller then removes its tasks.
+func (m *DefaultJobManager) DeleteJob(id string) error {
+	err := m.ds.DeleteJob(id)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return ErrJobNotFound
+	}
+	return err
+}
+
+// Watch streams changes to jobs after fromVersion; see datastore.Datastore.
+func (m *DefaultJobManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return m.ds.Watch(datastore.KindJob, fromVersion)
+}
@@ -0,0 +1,50 @@
+package workload_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+func TestJobManager_CreateAndStatus(t *testing.T) {
+	m := workload.NewJobManager(datastore.NewInMemoryDatastore())
+	j := models.Job{ID: "batch", Template: models.Task{Command: "true"}}
+	j.Status.Succeeded = 4
+	if err := m.CreateJob(j); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if err := m.CreateJob(j); !errors.Is(err, workload.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	stored, err := m.GetJob("batch")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if stored.Status.Succeeded != 0 || stored.Template.RestartPolicy != models.RestartNever {
+		t.Errorf("Expected an empty status and the Never restart policy, got %+v", stored)
+	}
+	if stored.WantedCompletions() != models.DefaultCompletions || stored.MaxParallel() != models.DefaultParallelism || stored.FailureLimit() != models.DefaultBackoffLimit {
+		t.Errorf("Expected the default completions, parallelism and backoff limit, got %+v", stored)
+	}
+
+	stored.Status.Active = 1
+	if err := m.UpdateJobStatus(*stored); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if err := m.UpdateJobStatus(*stored); !errors.Is(err, datastore.ErrConflict) {
+		t.Errorf("Expected a conflict writing the status of a stale version, got %v", err)
+	}
+	if updated, _ := m.GetJob("batch"); updated.Status.Active != 1 {
+		t.Errorf("Expected 1 active task, got %+v", updated.Status)
+	}
+
+	if err := m.DeleteJob("batch"); err != nil {
+		t.Fatalf("Failed to delete job: %v", err)
+	}
+	if err := m.DeleteJob("batch"); !errors.Is(err, workload.ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
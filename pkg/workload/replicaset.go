@@ -0,0 +1,131 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+// ErrReplicaSetNotFound is returned when an ID does not match any replica set.
+var ErrReplicaSetNotFound = errors.New("replica set not found")
+
+// ReplicaSetManager stores replica sets. Their tasks are created and removed
+// by the replica set controller.
+type ReplicaSetManager interface {
+	CreateReplicaSet(rs models.ReplicaSet) error
+	GetReplicaSet(id string) (*models.ReplicaSet, error)
+	GetReplicaSets() ([]models.ReplicaSet, error)
+	UpdateReplicaSet(rs models.ReplicaSet) error
+	Scale(id string, replicas int) error
+	UpdateReplicaSetStatus(rs models.ReplicaSet) error
+	DeleteReplicaSet(id string) error
+	Watch(fromVersion uint64) (datastore.Watcher, error)
+}
+
+// DefaultReplicaSetManager keeps replica sets in a datastore.
+type DefaultReplicaSetManager struct {
+	ds datastore.Datastore
+}
+
+// NewReplicaSetManager creates a DefaultReplicaSetManager backed by ds.
+func NewReplicaSetManager(ds datastore.Datastore) *DefaultReplicaSetManager {
+	return &DefaultReplicaSetManager{ds: ds}
+}
+
+// CreateReplicaSet stores a new replica set with an empty status. A
+// template without a restart policy gets RestartAlways.
+func (m *DefaultReplicaSetManager) CreateReplicaSet(rs models.ReplicaSet) error {
+	if _, err := m.GetReplicaSet(rs.ID); err == nil {
+		return fmt.Errorf("replica set %s: %w", rs.ID, ErrAlreadyExists)
+	}
+	setReplicaSetDefaults(&rs)
+	rs.ResourceVersion = 0
+	rs.Status = models.ReplicaSetStatus{}
+	return m.ds.SaveReplicaSet(rs)
+}
+
+// GetReplicaSet retrieves a replica set by ID.
+func (m *DefaultReplicaSetManager) GetReplicaSet(id string) (*models.ReplicaSet, error) {
+	sets, err := m.ds.GetReplicaSets()
+	if err != nil {
+		return nil, err
+	}
+	if rs := find(sets, id); rs != nil {
+		return rs, nil
+	}
+	return nil, ErrReplicaSetNotFound
+}
+
+// GetReplicaSets retrieves all replica sets.
+func (m *DefaultReplicaSetManager) GetReplicaSets() ([]models.ReplicaSet, error) {
+	return m.ds.GetReplicaSets()
+}
+
+// UpdateReplicaSet replaces the replica count, template and revision of a
+// replica set. Its selector cannot change, since the tasks it already has
+// would no longer be its own, and its owner and status are kept.
+//
+// Like TaskManager.UpdateTask, the update is conditional when
+// rs.ResourceVersion is set and retried on conflicts otherwise.
+func (m *DefaultReplicaSetManager) UpdateReplicaSet(rs models.ReplicaSet) error {
+	setReplicaSetDefaults(&rs)
+	return m.update(rs.ID, rs.ResourceVersion, func(current *models.ReplicaSet) error {
+		if !reflect.DeepEqual(current.Selector, rs.Selector) {
+			return models.ValidationError{{Field: "selector", Detail: "cannot be changed"}}
+		}
+		current.Replicas = rs.Replicas
+		current.Template = rs.Template
+		current.Revision = rs.Revision
+		return nil
+	})
+}
+
+// Scale sets the number of replicas a replica set wants.
+func (m *DefaultReplicaSetManager) Scale(id string, replicas int) error {
+	if replicas < 0 {
+		return models.ValidationError{{Field: "replicas", Detail: "must not be negative"}}
+	}
+	return m.update(id, 0, func(current *models.ReplicaSet) error {
+		current.Replicas = replicas
+		return nil
+	})
+}
+
+// UpdateReplicaSetStatus records the status of a replica set. It is
+// conditional on rs.ResourceVersion, so that the status always describes
+// the version of the replica set it was computed for.
+func (m *DefaultReplicaSetManager) UpdateReplicaSetStatus(rs models.ReplicaSet) error {
+	return m.update(rs.ID, rs.ResourceVersion, func(current *models.ReplicaSet) error {
+		current.Status = rs.Status
+		return nil
+	})
+}
+
+// DeleteReplicaSet removes a replica set. The replica set controller then
+// removes its tasks.
+func (m *DefaultReplicaSetManager) DeleteReplicaSet(id string) error {
+	err := m.ds.DeleteReplicaSet(id)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return ErrReplicaSetNotFound
+	}
+	return err
+}
+
+// Watch streams changes to replica sets after fromVersion; see
+// datastore.Datastore.
+func (m *DefaultReplicaSetManager) Watch(fromVersion uint64) (datastore.Watcher, error) {
+	return m.ds.Watch(datastore.KindReplicaSet, fromVersion)
+}
+
+func (m *DefaultReplicaSetManager) update(id string, version uint64, change func(*models.ReplicaSet) error) error {
+	return update(datastore.KindReplicaSet, id, version, m.GetReplicaSet, m.ds.SaveReplicaSet, change)
+}
+
+func setReplicaSetDefaults(rs *models.ReplicaSet) {
+	if rs.Template.RestartPolicy == "" {
+		rs.Template.RestartPolicy = models.RestartAlways
+	}
+}
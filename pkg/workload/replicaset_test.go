@@ -0,0 +1,92 @@
+package workload_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+	"github.com/fntkg/container-orchestrator/pkg/workload"
+)
+
+func newReplicaSet(id string, replicas int) models.ReplicaSet {
+	labels := map[string]string{"app": id}
+	return models.ReplicaSet{
+		ID:       id,
+		Replicas: replicas,
+		Selector: models.LabelSelector{MatchLabels: labels},
+		Template: models.Task{Labels: labels, Command: "sleep", Args: []string{"60"}},
+	}
+}
+
+func TestReplicaSetManager_CreateAndScale(t *testing.T) {
+	m := workload.NewReplicaSetManager(datastore.NewInMemoryDatastore())
+	rs := newReplicaSet("web", 2)
+	rs.Status.Replicas = 7
+	if err := m.CreateReplicaSet(rs); err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	if err := m.CreateReplicaSet(rs); !errors.Is(err, workload.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+
+	stored, err := m.GetReplicaSet("web")
+	if err != nil {
+		t.Fatalf("Failed to get replica set: %v", err)
+	}
+	if stored.Status.Replicas != 0 || stored.Template.RestartPolicy != models.RestartAlways {
+		t.Errorf("Expected an empty status and the Always restart policy, got %+v", stored)
+	}
+
+	if err := m.Scale("web", 4); err != nil {
+		t.Fatalf("Failed to scale: %v", err)
+	}
+	if err := m.Scale("web", -1); err == nil {
+		t.Error("Expected scaling to a negative count to fail")
+	}
+	if err := m.Scale("missing", 1); !errors.Is(err, workload.ErrReplicaSetNotFound) {
+		t.Errorf("Expected ErrReplicaSetNotFound, got %v", err)
+	}
+	if scaled, _ := m.GetReplicaSet("web"); scaled.Replicas != 4 {
+		t.Errorf("Expected 4 replicas, got %d", scaled.Replicas)
+	}
+}
+
+func TestReplicaSetManager_UpdateKeepsStatusAndSelector(t *testing.T) {
+	m := workload.NewReplicaSetManager(datastore.NewInMemoryDatastore())
+	if err := m.CreateReplicaSet(newReplicaSet("web", 2)); err != nil {
+		t.Fatalf("Failed to create replica set: %v", err)
+	}
+	stored, _ := m.GetReplicaSet("web")
+	stored.Status = models.ReplicaSetStatus{Replicas: 2, ReadyReplicas: 1}
+	if err := m.UpdateReplicaSetStatus(*stored); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	// The status was computed for a version that is now stale.
+	if err := m.UpdateReplicaSetStatus(*stored); !errors.Is(err, datastore.ErrConflict) {
+		t.Errorf("Expected a conflict writing the status of a stale version, got %v", err)
+	}
+
+	update := newReplicaSet("web", 3)
+	update.Template.Command = "true"
+	if err := m.UpdateReplicaSet(update); err != nil {
+		t.Fatalf("Failed to update replica set: %v", err)
+	}
+	updated, _ := m.GetReplicaSet("web")
+	if updated.Replicas != 3 || updated.Template.Command != "true" || updated.Status.ReadyReplicas != 1 {
+		t.Errorf("Expected the new spec with the old status, got %+v", updated)
+	}
+
+	update.Selector = models.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+	var validationErr models.ValidationError
+	if err := m.UpdateReplicaSet(update); !errors.As(err, &validationErr) {
+		t.Errorf("Expected a ValidationError changing the selector, got %v", err)
+	}
+
+	if err := m.DeleteReplicaSet("web"); err != nil {
+		t.Fatalf("Failed to delete replica set: %v", err)
+	}
+	if err := m.DeleteReplicaSet("web"); !errors.Is(err, workload.ErrReplicaSetNotFound) {
+		t.Errorf("Expected ErrReplicaSetNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Package workload manages the objects that run tasks on the user's behalf,
+// such as replica sets, deployments, daemon sets, jobs and cron jobs.
+package workload
+
+import (
+	"errors"
+
+	"github.com/fntkg/container-orchestrator/pkg/datastore"
+	"github.com/fntkg/container-orchestrator/pkg/models"
+)
+
+// ErrAlreadyExists is returned when creating an object whose ID is taken.
+var ErrAlreadyExists = errors.New("already exists")
+
+// maxUpdateAttempts bounds how often an unconditional update is retried
+// after losing a race with another writer.
+const maxUpdateAttempts = 5
+
+// object is a pointer to a stored object type T.
+type object[T any] interface {
+	*T
+	models.Object
+}
+
+// find returns the object with the given ID, or nil.
+func find[T any, PT object[T]](objs []T, id string) *T {
+	for i := range objs {
+		if PT(&objs[i]).GetID() == id {
+			return &objs[i]
+		}
+	}
+	return nil
+}
+
+// update applies change to the stored object and saves it. A non-zero
+// version makes the write conditional on it; otherwise conflicts are
+// retried.
+func update[T any, PT object[T]](kind, id string, version uint64, get func(string) (*T, error), save func(T) error, change func(PT) error) error {
+	for attempt := 0; ; attempt++ {
+		current, err := get(id)
+		if err != nil {
+			return err
+		}
+		if actual := PT(current).GetResourceVersion(); version != 0 && actual != version {
+			return &datastore.ConflictError{Kind: kind, ID: id, Expected: version, Actual: actual}
+		}
+		if err := change(current); err != nil {
+			return err
+		}
+		err = save(*current)
+		if version != 0 || !errors.Is(err, datastore.ErrConflict) || attempt+1 >= maxUpdateAttempts {
+			return err
+		}
+	}
+}